@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"sync"
+	"time"
+)
+
+// keyCache remembers recently verified API keys by the digest of the full
+// key string, so repeated requests skip the database lookup. Entries expire
+// after ttl, which bounds how long a key revoked on another instance keeps
+// working here; keys changed on this instance are dropped immediately.
+type keyCache struct {
+	ttl  time.Duration
+	size int
+
+	mu      sync.Mutex
+	entries map[string]cachedKey
+}
+
+type cachedKey struct {
+	apiKey   APIKey
+	cachedAt time.Time
+}
+
+// newKeyCache creates a cache holding up to size keys, or nil (no caching)
+// when ttl or size is not positive
+func newKeyCache(ttl time.Duration, size int) *keyCache {
+	if ttl <= 0 || size <= 0 {
+		return nil
+	}
+	return &keyCache{ttl: ttl, size: size, entries: make(map[string]cachedKey)}
+}
+
+// get returns a copy of the cached key for digest if it is still fresh
+func (c *keyCache) get(digest string, now time.Time) (*APIKey, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[digest]
+	if !ok || now.Sub(entry.cachedAt) >= c.ttl {
+		return nil, false
+	}
+	apiKey := entry.apiKey
+	return &apiKey, true
+}
+
+// put caches a verified key
+func (c *keyCache) put(digest string, apiKey *APIKey, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.size {
+		for d, entry := range c.entries {
+			if now.Sub(entry.cachedAt) >= c.ttl {
+				delete(c.entries, d)
+			}
+		}
+	}
+	if len(c.entries) >= c.size {
+		// Still full of fresh entries: evict an arbitrary one
+		for d := range c.entries {
+			delete(c.entries, d)
+			break
+		}
+	}
+	c.entries[digest] = cachedKey{apiKey: *apiKey, cachedAt: now}
+}
+
+// drop removes the cached keys matching fn
+func (c *keyCache) drop(fn func(apiKey *APIKey) bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for d, entry := range c.entries {
+		if fn(&entry.apiKey) {
+			delete(c.entries, d)
+		}
+	}
+}
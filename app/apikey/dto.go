@@ -10,6 +10,7 @@ type CreateRequest struct {
 	Permissions []string  `json:"permissions" binding:"omitempty"`
 	ExpiresAt   time.Time `json:"expires_at" binding:"omitempty"`
 	NeverExpire bool      `json:"never_expire" binding:"omitempty"`
+	Environment string    `json:"environment" binding:"omitempty,oneof=live test"` // Defaults to live
 }
 
 // UpdateRequest represents the request to update an API key
@@ -20,17 +21,118 @@ type UpdateRequest struct {
 	NeverExpire bool      `json:"never_expire" binding:"omitempty"`
 }
 
+// CreateOrganizationKeyRequest represents the request to create an API key
+// owned by an organization. Exactly one of ServiceAccountID and RoleID is required.
+type CreateOrganizationKeyRequest struct {
+	Name             string    `json:"name" binding:"required,max=100"`
+	Permissions      []string  `json:"permissions" binding:"omitempty"`
+	ExpiresAt        time.Time `json:"expires_at" binding:"omitempty"`
+	NeverExpire      bool      `json:"never_expire" binding:"omitempty"`
+	Environment      string    `json:"environment" binding:"omitempty,oneof=live test"` // Defaults to live
+	ServiceAccountID *uint     `json:"service_account_id" binding:"omitempty"`
+	RoleID           *uint     `json:"role_id" binding:"omitempty"`
+}
+
+// CreateServiceAccountRequest represents the request to create a service account
+type CreateServiceAccountRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"omitempty,max=500"`
+	RoleID      uint   `json:"role_id" binding:"required"`
+}
+
+// RateLimitRequest represents the request to override the rate limits of an
+// API key. Omitted or null fields use the configured defaults.
+type RateLimitRequest struct {
+	RateLimit      *int   `json:"rate_limit" binding:"omitempty,min=1"`
+	RateLimitBurst *int   `json:"rate_limit_burst" binding:"omitempty,min=0"`
+	MonthlyQuota   *int64 `json:"monthly_quota" binding:"omitempty,min=0"`
+}
+
+// RestrictionsRequest represents the request to restrict where an API key
+// may be used from. Empty lists allow any client IP or origin.
+type RestrictionsRequest struct {
+	AllowedIPs        []string `json:"allowed_ips" binding:"omitempty,max=50,dive,max=64"`
+	AllowedReferrers  []string `json:"allowed_referrers" binding:"omitempty,max=50,dive,max=255"`
+	DisableQueryParam bool     `json:"disable_query_param"`
+}
+
+// RotateRequest represents the request to rotate an API key. The body may be
+// empty; GraceHours defaults to the configured grace window.
+type RotateRequest struct {
+	GraceHours *int `json:"grace_hours" binding:"omitempty,min=0,max=720"`
+}
+
+// LeakReportRequest represents a report of API keys found in public, for
+// example by a secret scanner
+type LeakReportRequest struct {
+	Keys   []string `json:"keys" binding:"required,min=1,max=100,dive,max=255"`
+	Source string   `json:"source" binding:"omitempty,max=100"`
+	URL    string   `json:"url" binding:"omitempty,url,max=2048"`
+}
+
+// ServiceAccountResponse represents a service account in responses
+type ServiceAccountResponse struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	Name           string    `json:"name"`
+	Description    string    `json:"description,omitempty"`
+	RoleID         uint      `json:"role_id"`
+	CreatedBy      uint      `json:"created_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
 // Response represents the response format for API key operations
 type Response struct {
 	ID          uint       `json:"id"`
 	Name        string     `json:"name"`
 	Prefix      string     `json:"prefix"`
 	Key         string     `json:"key,omitempty"` // Only included when creating a new key
-	UserID      uint       `json:"user_id"`
+	UserID      uint       `json:"user_id,omitempty"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	DormantAt   *time.Time `json:"dormant_at,omitempty"` // Set while the key was never used
 	Permissions []string   `json:"permissions,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
+
+	// Overrides of the configured rate limits, if any
+	RateLimit      *int   `json:"rate_limit,omitempty"`
+	RateLimitBurst *int   `json:"rate_limit_burst,omitempty"`
+	MonthlyQuota   *int64 `json:"monthly_quota,omitempty"`
+
+	// LegacyFormat marks keys issued before the llk_ format, which should
+	// be replaced
+	LegacyFormat bool `json:"legacy_format,omitempty"`
+
+	// Environment and where the key may be used from
+	Environment       string   `json:"environment"`
+	AllowedIPs        []string `json:"allowed_ips,omitempty"`
+	AllowedReferrers  []string `json:"allowed_referrers,omitempty"`
+	DisableQueryParam bool     `json:"disable_query_param"`
+
+	// Lineage of rotated keys
+	PredecessorID *uint      `json:"predecessor_id,omitempty"`
+	SuccessorID   *uint      `json:"successor_id,omitempty"`
+	RotatedAt     *time.Time `json:"rotated_at,omitempty"`
+
+	// Set for organization keys
+	OrganizationID   *uint `json:"organization_id,omitempty"`
+	ServiceAccountID *uint `json:"service_account_id,omitempty"`
+	RoleID           *uint `json:"role_id,omitempty"`
+	CreatedBy        uint  `json:"created_by"`
+}
+
+// RotateResponse represents the new key issued by a rotation and the key it
+// replaces, which keeps working until its expires_at
+type RotateResponse struct {
+	Response
+	Predecessor Response `json:"predecessor"`
+}
+
+// LeakReportResponse represents the outcome of a leak report. Keys that are
+// not revoked are not identified.
+type LeakReportResponse struct {
+	Reported int `json:"reported"`
+	Revoked  int `json:"revoked"`
 }
 
 // ListResponse represents the paginated response for listing API keys
@@ -43,11 +145,6 @@ type ListResponse struct {
 
 // ToResponse converts an APIKey model to a Response DTO
 func ToResponse(apiKey *APIKey, includeKey string) Response {
-	var permissions []string
-	if apiKey.Permissions != "" {
-		permissions = splitPermissions(apiKey.Permissions)
-	}
-
 	return Response{
 		ID:          apiKey.ID,
 		Name:        apiKey.Name,
@@ -56,8 +153,29 @@ func ToResponse(apiKey *APIKey, includeKey string) Response {
 		UserID:      apiKey.UserID,
 		ExpiresAt:   apiKey.ExpiresAt,
 		LastUsedAt:  apiKey.LastUsedAt,
-		Permissions: permissions,
+		DormantAt:   apiKey.DormantAt,
+		Permissions: apiKey.Permissions,
 		CreatedAt:   apiKey.CreatedAt,
+
+		RateLimit:      apiKey.RateLimit,
+		RateLimitBurst: apiKey.RateLimitBurst,
+		MonthlyQuota:   apiKey.MonthlyQuota,
+
+		LegacyFormat: apiKey.IsLegacy(),
+
+		Environment:       apiKey.Environment,
+		AllowedIPs:        apiKey.AllowedIPs,
+		AllowedReferrers:  apiKey.AllowedReferrers,
+		DisableQueryParam: apiKey.DisableQueryParam,
+
+		PredecessorID: apiKey.PredecessorID,
+		SuccessorID:   apiKey.SuccessorID,
+		RotatedAt:     apiKey.RotatedAt,
+
+		OrganizationID:   apiKey.OrganizationID,
+		ServiceAccountID: apiKey.ServiceAccountID,
+		RoleID:           apiKey.RoleID,
+		CreatedBy:        apiKey.CreatedBy,
 	}
 }
 
@@ -70,126 +188,15 @@ func ToResponseList(apiKeys []*APIKey) []Response {
 	return responses
 }
 
-// Helper function to split permission string
-func splitPermissions(permissions string) []string {
-	if permissions == "" {
-		return []string{}
-	}
-	return splitCSV(permissions)
-}
-
-// Helper function to split CSV values
-func splitCSV(s string) []string {
-	if s == "" {
-		return []string{}
-	}
-	split := make([]string, 0)
-	for _, item := range splitString(s, ",") {
-		if item != "" {
-			split = append(split, item)
-		}
-	}
-	return split
-}
-
-// Helper function to split string by separator
-func splitString(s string, separator string) []string {
-	if s == "" {
-		return []string{}
-	}
-	return splitWithoutEmpty(s, separator)
-}
-
-// Helper function to split string and filter out empty values
-func splitWithoutEmpty(s string, separator string) []string {
-	result := make([]string, 0)
-	for _, item := range split(s, separator) {
-		if item != "" {
-			result = append(result, item)
-		}
-	}
-	return result
-}
-
-// Helper function to split string by separator
-func split(s string, separator string) []string {
-	if s == "" {
-		return []string{}
-	}
-	return splitTrim(s, separator)
-}
-
-// Helper function to split string by separator and trim spaces
-func splitTrim(s string, separator string) []string {
-	result := make([]string, 0)
-	for _, item := range splitString(s, separator) {
-		result = append(result, trim(item))
-	}
-	return result
-}
-
-// Helper function to trim spaces
-func trim(s string) string {
-	return trimSpace(s)
-}
-
-// Helper function to trim spaces
-func trimSpace(s string) string {
-	return trimAll(s, " ")
-}
-
-// Helper function to trim all occurrences of a character
-func trimAll(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trimAllSpace(s, cutset)
-}
-
-// Helper function to trim all spaces
-func trimAllSpace(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trimLeftRightSpace(s, cutset)
-}
-
-// Helper function to trim left and right spaces
-func trimLeftRightSpace(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trimLeftSpace(trimRightSpace(s, cutset), cutset)
-}
-
-// Helper function to trim left spaces
-func trimLeftSpace(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trimLeft(s, cutset)
-}
-
-// Helper function to trim right spaces
-func trimRightSpace(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trimRight(s, cutset)
-}
-
-// Helper function to trim left occurrences of a character
-func trimLeft(s string, cutset string) string {
-	if s == "" {
-		return ""
-	}
-	return trim(s)
-}
-
-// Helper function to trim right occurrences of a character
-func trimRight(s string, cutset string) string {
-	if s == "" {
-		return ""
+// ToServiceAccountResponse converts a ServiceAccount model to its response DTO
+func ToServiceAccountResponse(account *ServiceAccount) ServiceAccountResponse {
+	return ServiceAccountResponse{
+		ID:             account.ID,
+		OrganizationID: account.OrganizationID,
+		Name:           account.Name,
+		Description:    account.Description,
+		RoleID:         account.RoleID,
+		CreatedBy:      account.CreatedBy,
+		CreatedAt:      account.CreatedAt,
 	}
-	return s
 }
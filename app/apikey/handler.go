@@ -1,12 +1,17 @@
 package apikey
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
 	"github.com/llamacto/llama-gin-kit/pkg/response"
+	"gorm.io/gorm"
 )
 
 // Handler interface for API key operations
@@ -25,6 +30,36 @@ type Handler interface {
 	
 	// Delete revokes (deletes) an API key
 	Delete(c *gin.Context)
+
+	// CreateOrganizationKey creates an API key owned by an organization
+	CreateOrganizationKey(c *gin.Context)
+
+	// ListOrganizationKeys lists the API keys owned by an organization
+	ListOrganizationKeys(c *gin.Context)
+
+	// DeleteOrganizationKey revokes (deletes) an organization's API key
+	DeleteOrganizationKey(c *gin.Context)
+
+	// CreateServiceAccount creates a service account in an organization
+	CreateServiceAccount(c *gin.Context)
+
+	// ListServiceAccounts lists the service accounts of an organization
+	ListServiceAccounts(c *gin.Context)
+
+	// DeleteServiceAccount deletes a service account and revokes its keys
+	DeleteServiceAccount(c *gin.Context)
+
+	// SetRateLimits overrides the rate limits of an API key (administrators)
+	SetRateLimits(c *gin.Context)
+
+	// Rotate issues a successor for an API key
+	Rotate(c *gin.Context)
+
+	// SetRestrictions restricts where an API key may be used from
+	SetRestrictions(c *gin.Context)
+
+	// ReportLeaked revokes API keys reported as leaked
+	ReportLeaked(c *gin.Context)
 }
 
 // handler implements the Handler interface
@@ -58,26 +93,22 @@ func (h *handler) Create(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
 
-	// Set expiry time
-	var expiry *time.Time
-	if !req.NeverExpire {
-		if !req.ExpiresAt.IsZero() {
-			expiry = &req.ExpiresAt
-		} else {
-			// Default expiry: 1 year
-			defaultExpiry := time.Now().AddDate(1, 0, 0)
-			expiry = &defaultExpiry
-		}
-	}
-
 	// Generate API key
-	key, apiKey, err := h.service.GenerateAPIKey(userID.(uint), req.Name, expiry, req.Permissions)
+	key, apiKey, err := h.service.GenerateAPIKey(userID, req.Name, newKeyExpiry(req.ExpiresAt, req.NeverExpire), req.Permissions, req.Environment)
+	if errors.Is(err, ErrInvalidScope) {
+		response.BadRequest(c, "Invalid permissions", err)
+		return
+	}
+	if errors.Is(err, ErrInvalidEnvironment) {
+		response.BadRequest(c, "Invalid environment", err)
+		return
+	}
 	if err != nil {
 		response.InternalServerError(c, "Failed to create API key", err)
 		return
@@ -114,8 +145,8 @@ func (h *handler) Get(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
@@ -128,7 +159,7 @@ func (h *handler) Get(c *gin.Context) {
 	}
 
 	// Security check: ensure the key belongs to the user
-	if apiKey.UserID != userID.(uint) {
+	if apiKey.UserID != userID {
 		response.Unauthorized(c, "You do not have permission to access this API key")
 		return
 	}
@@ -159,14 +190,14 @@ func (h *handler) List(c *gin.Context) {
 	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
 
 	// Get API keys
-	apiKeys, total, err := h.service.ListAPIKeys(userID.(uint), page, perPage)
+	apiKeys, total, err := h.service.ListAPIKeys(userID, page, perPage)
 	if err != nil {
 		response.InternalServerError(c, "Failed to retrieve API keys", err)
 		return
@@ -196,6 +227,7 @@ func (h *handler) List(c *gin.Context) {
 // @Failure 400 {object} response.ErrorResponse "Bad request"
 // @Failure 401 {object} response.ErrorResponse "Unauthorized"
 // @Failure 404 {object} response.ErrorResponse "Not found"
+// @Failure 409 {object} response.ErrorResponse "Expiry of a rotated key"
 // @Failure 500 {object} response.ErrorResponse "Internal server error"
 // @Router /api/v1/apikeys/{id} [put]
 // @Security BearerAuth
@@ -215,8 +247,8 @@ func (h *handler) Update(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
@@ -230,7 +262,19 @@ func (h *handler) Update(c *gin.Context) {
 	}
 
 	// Update API key
-	apiKey, err := h.service.UpdateAPIKey(uint(id), userID.(uint), req.Name, expiry, req.Permissions)
+	apiKey, err := h.service.UpdateAPIKey(uint(id), userID, req.Name, expiry, req.NeverExpire, req.Permissions)
+	if errors.Is(err, ErrInvalidScope) {
+		response.BadRequest(c, "Invalid permissions", err)
+		return
+	}
+	if errors.Is(err, ErrAlreadyRotated) {
+		c.JSON(http.StatusConflict, response.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "The expiry of a rotated API key cannot be changed",
+			Error:   err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		response.HandleError(c, "Failed to update API key", err)
 		return
@@ -267,14 +311,14 @@ func (h *handler) Delete(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		response.Unauthorized(c, "User not authenticated")
 		return
 	}
 
 	// Delete API key
-	if err := h.service.RevokeAPIKey(uint(id), userID.(uint)); err != nil {
+	if err := h.service.RevokeAPIKey(uint(id), userID); err != nil {
 		response.HandleError(c, "Failed to delete API key", err)
 		return
 	}
@@ -282,3 +326,434 @@ func (h *handler) Delete(c *gin.Context) {
 	// Return response
 	c.Status(http.StatusNoContent)
 }
+
+// newKeyExpiry returns the expiry of a new API key, one year by default
+func newKeyExpiry(expiresAt time.Time, neverExpire bool) *time.Time {
+	if neverExpire {
+		return nil
+	}
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().AddDate(1, 0, 0)
+	}
+	return &expiresAt
+}
+
+// organizationRequest reads the organization ID path parameter and the
+// authenticated user managing it
+func organizationRequest(c *gin.Context) (uint, uint, bool) {
+	organizationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid organization ID", err)
+		return 0, 0, false
+	}
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return 0, 0, false
+	}
+	return uint(organizationID), userID, true
+}
+
+// handleOrganizationError maps organization key and service account errors
+// to responses
+func handleOrganizationError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrNotOrganizationAdmin):
+		response.Forbidden(c, err.Error())
+	case errors.Is(err, ErrOrganizationInactive), errors.Is(err, ErrServiceAccountNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, message, err)
+	case errors.Is(err, ErrServiceAccountExists):
+		c.JSON(http.StatusConflict, response.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: message,
+			Error:   err.Error(),
+		})
+	case errors.Is(err, ErrInvalidScope), errors.Is(err, ErrInvalidRole), errors.Is(err, ErrKeyOwner), errors.Is(err, ErrInvalidEnvironment):
+		response.BadRequest(c, message, err)
+	default:
+		response.InternalServerError(c, message, err)
+	}
+}
+
+// CreateOrganizationKey creates an API key owned by an organization
+// @Summary Create an organization API key
+// @Description Creates an API key owned by the organization, authenticating as a service account or bounded by an organization role. Requires the organization owner or admin role.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param request body CreateOrganizationKeyRequest true "API Key Details"
+// @Success 201 {object} Response "API Key created"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Router /api/v1/organizations/{id}/apikeys [post]
+// @Security BearerAuth
+func (h *handler) CreateOrganizationKey(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+
+	var req CreateOrganizationKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	key, apiKey, err := h.service.GenerateOrganizationAPIKey(c.Request.Context(), userID, organizationID, OrganizationKey{
+		Name:             req.Name,
+		ExpiresAt:        newKeyExpiry(req.ExpiresAt, req.NeverExpire),
+		Permissions:      req.Permissions,
+		Environment:      req.Environment,
+		ServiceAccountID: req.ServiceAccountID,
+		RoleID:           req.RoleID,
+	})
+	if err != nil {
+		handleOrganizationError(c, "Failed to create API key", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ToResponse(apiKey, key))
+}
+
+// ListOrganizationKeys lists the API keys owned by an organization
+// @Summary List organization API keys
+// @Description Lists the API keys owned by the organization and its service accounts
+// @Tags API Keys
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param per_page query int false "Items per page (default: 10)"
+// @Success 200 {object} ListResponse "List of API keys"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Router /api/v1/organizations/{id}/apikeys [get]
+// @Security BearerAuth
+func (h *handler) ListOrganizationKeys(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "10"))
+
+	apiKeys, total, err := h.service.ListOrganizationAPIKeys(c.Request.Context(), userID, organizationID, page, perPage)
+	if err != nil {
+		handleOrganizationError(c, "Failed to retrieve API keys", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ListResponse{
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+		Data:    ToResponseList(apiKeys),
+	})
+}
+
+// DeleteOrganizationKey revokes (deletes) an organization's API key
+// @Summary Delete an organization API key
+// @Tags API Keys
+// @Param id path int true "Organization ID"
+// @Param key_id path int true "API Key ID"
+// @Success 204 "No content"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Router /api/v1/organizations/{id}/apikeys/{key_id} [delete]
+// @Security BearerAuth
+func (h *handler) DeleteOrganizationKey(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("key_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid API key ID", err)
+		return
+	}
+
+	if err := h.service.RevokeOrganizationAPIKey(c.Request.Context(), userID, organizationID, uint(id)); err != nil {
+		handleOrganizationError(c, "Failed to delete API key", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateServiceAccount creates a service account in an organization
+// @Summary Create a service account
+// @Description Creates a named non-human principal whose API keys are bounded by the given organization role
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Param request body CreateServiceAccountRequest true "Service account details"
+// @Success 201 {object} ServiceAccountResponse "Service account created"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Failure 409 {object} response.ErrorResponse "Name already in use"
+// @Router /api/v1/organizations/{id}/service-accounts [post]
+// @Security BearerAuth
+func (h *handler) CreateServiceAccount(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+
+	var req CreateServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	account, err := h.service.CreateServiceAccount(c.Request.Context(), userID, organizationID, req.Name, req.Description, req.RoleID)
+	if err != nil {
+		handleOrganizationError(c, "Failed to create service account", err)
+		return
+	}
+	c.JSON(http.StatusCreated, ToServiceAccountResponse(account))
+}
+
+// ListServiceAccounts lists the service accounts of an organization
+// @Summary List service accounts
+// @Tags API Keys
+// @Produce json
+// @Param id path int true "Organization ID"
+// @Success 200 {array} ServiceAccountResponse "Service accounts"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Router /api/v1/organizations/{id}/service-accounts [get]
+// @Security BearerAuth
+func (h *handler) ListServiceAccounts(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+
+	accounts, err := h.service.ListServiceAccounts(c.Request.Context(), userID, organizationID)
+	if err != nil {
+		handleOrganizationError(c, "Failed to retrieve service accounts", err)
+		return
+	}
+	resp := make([]ServiceAccountResponse, len(accounts))
+	for i, account := range accounts {
+		resp[i] = ToServiceAccountResponse(account)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteServiceAccount deletes a service account and revokes its keys
+// @Summary Delete a service account
+// @Tags API Keys
+// @Param id path int true "Organization ID"
+// @Param account_id path int true "Service account ID"
+// @Success 204 "No content"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Router /api/v1/organizations/{id}/service-accounts/{account_id} [delete]
+// @Security BearerAuth
+func (h *handler) DeleteServiceAccount(c *gin.Context) {
+	organizationID, userID, ok := organizationRequest(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("account_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid service account ID", err)
+		return
+	}
+
+	if err := h.service.DeleteServiceAccount(c.Request.Context(), userID, organizationID, uint(id)); err != nil {
+		handleOrganizationError(c, "Failed to delete service account", err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetRateLimits overrides the rate limits of an API key
+// @Summary Set API key rate limits
+// @Description Overrides the configured rate limit, burst and monthly quota for one API key. Omitted fields use the defaults. Requires the admin role.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API Key ID"
+// @Param request body RateLimitRequest true "Rate limits"
+// @Success 200 {object} Response "API Key details"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 403 {object} response.ErrorResponse "Forbidden"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Router /api/v1/admin/apikeys/{id}/rate-limits [put]
+// @Security BearerAuth
+func (h *handler) SetRateLimits(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid API key ID", err)
+		return
+	}
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req RateLimitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	apiKey, err := h.service.SetRateLimits(userID, uint(id), ratelimit.Override{
+		Requests:     req.RateLimit,
+		Burst:        req.RateLimitBurst,
+		MonthlyQuota: req.MonthlyQuota,
+	})
+	switch {
+	case errors.Is(err, ErrInvalidRateLimit):
+		response.BadRequest(c, "Invalid rate limits", err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "API key not found", err)
+	case err != nil:
+		response.InternalServerError(c, "Failed to update API key", err)
+	default:
+		c.JSON(http.StatusOK, ToResponse(apiKey, ""))
+	}
+}
+
+// Rotate issues a successor for an API key
+// @Summary Rotate an API key
+// @Description Issues a new key with the same name, owner, permissions and limits. The old key keeps working for the grace window (default from API_KEY_ROTATION_GRACE_HOURS, never beyond its own expiry) and its owner is emailed before it stops. Keys can be rotated once; rotate the successor to rotate again.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API Key ID"
+// @Param request body RotateRequest false "Grace window"
+// @Success 201 {object} RotateResponse "New API key and the rotated key"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Failure 409 {object} response.ErrorResponse "Already rotated"
+// @Router /api/v1/apikeys/{id}/rotate [post]
+// @Security BearerAuth
+func (h *handler) Rotate(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid API key ID", err)
+		return
+	}
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req RotateRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+	var grace *time.Duration
+	if req.GraceHours != nil {
+		d := time.Duration(*req.GraceHours) * time.Hour
+		grace = &d
+	}
+
+	key, successor, predecessor, err := h.service.RotateAPIKey(c.Request.Context(), userID, uint(id), grace)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "API key not found", err)
+	case errors.Is(err, ErrAPIKeyExpired):
+		response.BadRequest(c, "Expired API keys cannot be rotated", err)
+	case errors.Is(err, ErrAlreadyRotated):
+		c.JSON(http.StatusConflict, response.ErrorResponse{
+			Code:    http.StatusConflict,
+			Message: "API key has already been rotated",
+			Error:   err.Error(),
+		})
+	case err != nil:
+		response.InternalServerError(c, "Failed to rotate API key", err)
+	default:
+		c.JSON(http.StatusCreated, RotateResponse{
+			Response:    ToResponse(successor, key),
+			Predecessor: ToResponse(predecessor, ""),
+		})
+	}
+}
+
+// SetRestrictions restricts where an API key may be used from
+// @Summary Restrict an API key
+// @Description Replaces the client IP addresses or CIDR ranges and the origins (e.g. https://app.example.com or https://*.example.com, matched against the Origin or Referer header) the key may be used from, and whether it may be sent in the api_key query parameter. Empty lists allow any client.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API Key ID"
+// @Param request body RestrictionsRequest true "Restrictions"
+// @Success 200 {object} Response "API Key details"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Failure 401 {object} response.ErrorResponse "Unauthorized"
+// @Failure 404 {object} response.ErrorResponse "Not found"
+// @Router /api/v1/apikeys/{id}/restrictions [put]
+// @Security BearerAuth
+func (h *handler) SetRestrictions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid API key ID", err)
+		return
+	}
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var req RestrictionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	apiKey, err := h.service.SetRestrictions(c.Request.Context(), userID, uint(id), Restrictions{
+		AllowedIPs:        req.AllowedIPs,
+		AllowedReferrers:  req.AllowedReferrers,
+		DisableQueryParam: req.DisableQueryParam,
+	})
+	switch {
+	case errors.Is(err, ErrInvalidRestriction):
+		response.BadRequest(c, "Invalid restrictions", err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, "API key not found", err)
+	case err != nil:
+		response.InternalServerError(c, "Failed to update API key", err)
+	default:
+		c.JSON(http.StatusOK, ToResponse(apiKey, ""))
+	}
+}
+
+// ReportLeaked revokes API keys reported as leaked
+// @Summary Report leaked API keys
+// @Description Revokes the reported keys and emails their owners. Intended for secret scanners and anyone who finds a key in public, so no authentication is required: holding a key is enough to abuse it, and so to revoke it. Only keys in the llk_ format are considered; see pkg/keyformat for the pattern and checksum.
+// @Tags API Keys
+// @Accept json
+// @Produce json
+// @Param request body LeakReportRequest true "Leaked keys"
+// @Success 200 {object} LeakReportResponse "Number of keys revoked"
+// @Failure 400 {object} response.ErrorResponse "Bad request"
+// @Router /api/v1/apikeys/leaked [post]
+func (h *handler) ReportLeaked(c *gin.Context) {
+	var req LeakReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	revoked, err := h.service.ReportLeaked(c.Request.Context(), LeakReport{
+		Keys:   req.Keys,
+		Source: req.Source,
+		URL:    req.URL,
+	})
+	switch {
+	case errors.Is(err, ErrTooManyLeakedKeys):
+		response.BadRequest(c, "Too many keys", err)
+	case err != nil:
+		response.InternalServerError(c, "Failed to revoke leaked API keys", err)
+	default:
+		c.JSON(http.StatusOK, LeakReportResponse{Reported: len(req.Keys), Revoked: revoked})
+	}
+}
@@ -0,0 +1,84 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/llamacto/llama-gin-kit/pkg/keyformat"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// maxLeakReportKeys bounds how many keys one report may contain
+const maxLeakReportKeys = 100
+
+// ErrTooManyLeakedKeys is returned for reports with more than
+// maxLeakReportKeys keys
+var ErrTooManyLeakedKeys = errors.New("too many keys in leak report")
+
+// LeakReport lists API keys found in public, for example by a secret
+// scanner, and where they were found
+type LeakReport struct {
+	Keys   []string
+	Source string // Who found the keys, such as github
+	URL    string // Where the keys were found
+}
+
+// ReportLeaked revokes the reported keys that are valid and emails their
+// owners. Only keys in the llk_ format are considered, so a report costs at
+// most one indexed lookup per key; anything else is ignored, as are keys
+// that are unknown or already revoked, so that reporters learn nothing
+// beyond the number revoked.
+func (s *service) ReportLeaked(ctx context.Context, report LeakReport) (int, error) {
+	if len(report.Keys) > maxLeakReportKeys {
+		return 0, ErrTooManyLeakedKeys
+	}
+
+	revoked := 0
+	seen := make(map[string]bool, len(report.Keys))
+	for _, raw := range report.Keys {
+		if ctx.Err() != nil {
+			return revoked, ctx.Err()
+		}
+		keyString := strings.TrimSpace(raw)
+		if seen[keyString] {
+			continue
+		}
+		seen[keyString] = true
+		if _, err := keyformat.Parse(keyString); err != nil {
+			continue
+		}
+
+		apiKey, err := s.lookup(keyString, s.digest(keyString))
+		if errors.Is(err, ErrInvalidAPIKey) {
+			continue
+		}
+		if err != nil {
+			return revoked, err
+		}
+		if err := s.repository.Delete(apiKey.ID); err != nil {
+			return revoked, err
+		}
+		s.forget(apiKey.ID)
+		revoked++
+		logger.Warn("Revoked leaked API key %d (%s...) reported by %q at %s", apiKey.ID, apiKey.Prefix, report.Source, report.URL)
+		s.notifyLeak(apiKey, report)
+	}
+	return revoked, nil
+}
+
+// notifyLeak emails the owner of a revoked key. The key is revoked either
+// way, so failures are only logged.
+func (s *service) notifyLeak(apiKey *APIKey, report LeakReport) {
+	to, err := s.repository.FindUserEmail(s.recipient(apiKey))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err == nil {
+		err = s.notifyLeaked(to, apiKey, report)
+	}
+	if err != nil {
+		logger.Warn("Failed to send leak notice for API key %d: %v", apiKey.ID, err)
+	}
+}
@@ -0,0 +1,145 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize is how many keys are deleted per transaction
+const purgeBatchSize = 500
+
+// RunMaintenance sends expiry reminders, marks dormant keys and purges old
+// keys. Each step logs its own errors so one failing does not stop the rest;
+// steps interrupted by shutdown are not errors.
+func (s *service) RunMaintenance(ctx context.Context) {
+	if err := s.NotifyExpiring(ctx); err != nil && ctx.Err() == nil {
+		logger.Error("Failed to notify owners of expiring API keys", err)
+	}
+	if err := s.markDormant(); err != nil {
+		logger.Error("Failed to mark dormant API keys", err)
+	}
+	if err := s.purge(ctx); err != nil && ctx.Err() == nil {
+		logger.Error("Failed to purge expired API keys", err)
+	}
+}
+
+// NotifyExpiring emails the owners of keys about to expire. Each reminder
+// is claimed before it is sent so that instances do not send it twice;
+// failed emails are released and retried on the next run, while keys whose
+// owner cannot be reached stay claimed.
+func (s *service) NotifyExpiring(ctx context.Context) error {
+	now := s.now()
+	windows := []struct {
+		rotated bool
+		notice  time.Duration
+	}{
+		{rotated: true, notice: s.cfg.RotationNoticeDuration},
+		{rotated: false, notice: s.cfg.ExpiryNoticeDuration},
+	}
+	for _, w := range windows {
+		if w.notice <= 0 {
+			continue
+		}
+		apiKeys, err := s.repository.FindExpiring(now, now.Add(w.notice), w.rotated)
+		if err != nil {
+			return err
+		}
+		for _, apiKey := range apiKeys {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := s.notifyExpiring(apiKey, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// notifyExpiring sends the reminder for one key
+func (s *service) notifyExpiring(apiKey *APIKey, now time.Time) error {
+	claimed, err := s.repository.ClaimExpiryNotice(apiKey.ID, now)
+	if err != nil || !claimed {
+		return err
+	}
+	to, err := s.repository.FindUserEmail(s.recipient(apiKey))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Nobody to remind
+		return nil
+	}
+	if err == nil {
+		err = s.notify(to, apiKey)
+	}
+	if err != nil {
+		logger.Warn("Failed to send expiry notice for API key %d: %v", apiKey.ID, err)
+		return s.repository.ReleaseExpiryNotice(apiKey.ID)
+	}
+	return nil
+}
+
+// recipient returns the user to email about a key: the owner of a personal
+// key, or for organization keys whoever rotated or created it
+func (s *service) recipient(apiKey *APIKey) uint {
+	if !apiKey.IsOrganizationKey() {
+		return apiKey.UserID
+	}
+	if apiKey.IsRotated() {
+		if successor, err := s.repository.FindByID(*apiKey.SuccessorID); err == nil {
+			return successor.CreatedBy
+		}
+	}
+	return apiKey.CreatedBy
+}
+
+// markDormant marks keys that were never used within DormantDuration of
+// their creation
+func (s *service) markDormant() error {
+	if s.cfg.DormantDuration <= 0 {
+		return nil
+	}
+	now := s.now()
+	marked, err := s.repository.MarkDormant(now.Add(-s.cfg.DormantDuration), now)
+	if err != nil {
+		return err
+	}
+	if marked > 0 {
+		logger.Info("Marked %d never-used API keys as dormant", marked)
+	}
+	return nil
+}
+
+// purge deletes keys that expired or were revoked more than
+// RetentionDuration ago, in batches
+func (s *service) purge(ctx context.Context) error {
+	if s.cfg.RetentionDuration <= 0 {
+		return nil
+	}
+	before := s.now().Add(-s.cfg.RetentionDuration)
+	var total int64
+	for ctx.Err() == nil {
+		purged, err := s.repository.Purge(before, purgeBatchSize)
+		if err != nil {
+			return err
+		}
+		total += purged
+		if purged < purgeBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logger.Info("Purged %d expired or revoked API keys", total)
+	}
+	return ctx.Err()
+}
+
+// sameTime reports whether two optional times are equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -3,25 +3,113 @@ package apikey
 import (
 	"time"
 
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
 	"gorm.io/gorm"
 )
 
 // APIKey represents an API key for authenticating API requests
 type APIKey struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	Name        string         `json:"name" gorm:"type:varchar(100);not null"`
-	Key         string         `json:"key" gorm:"type:varchar(64);uniqueIndex;not null"` // Hashed key
-	Prefix      string         `json:"prefix" gorm:"type:varchar(8);not null"`           // First 8 characters for identification
-	UserID      uint           `json:"user_id" gorm:"not null"`                          // Owner of the API key
-	LastUsedAt  *time.Time     `json:"last_used_at"`                                     // Track when the key was last used
-	ExpiresAt   *time.Time     `json:"expires_at"`                                       // Optional expiration date
-	Permissions string         `json:"permissions" gorm:"type:text"`                      // JSON string of permissions
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+	ID                uint           `json:"id" gorm:"primaryKey"`
+	Name              string         `json:"name" gorm:"type:varchar(100);not null"`
+	Key               string         `json:"key" gorm:"type:varchar(255);uniqueIndex;not null"`        // Hashed secret
+	LookupID          *string        `json:"-" gorm:"type:varchar(32);uniqueIndex"`                    // Public part of the key used to find it, nil for legacy keys
+	Prefix            string         `json:"prefix" gorm:"type:varchar(8);not null"`                   // First 8 characters for identification
+	UserID            uint           `json:"user_id" gorm:"not null"`                                  // Owner of a personal API key, 0 for organization keys
+	OrganizationID    *uint          `json:"organization_id,omitempty" gorm:"index"`                   // Owning organization of an organization key
+	ServiceAccountID  *uint          `json:"service_account_id,omitempty" gorm:"index"`                // Service account the key authenticates as, if any
+	RoleID            *uint          `json:"role_id,omitempty"`                                        // Organization role bounding a key without service account
+	CreatedBy         uint           `json:"created_by"`                                               // User who created the key
+	LastUsedAt        *time.Time     `json:"last_used_at"`                                             // Track when the key was last used
+	DormantAt         *time.Time     `json:"dormant_at,omitempty"`                                     // When the key was marked as never used; cleared on first use
+	ExpiresAt         *time.Time     `json:"expires_at" gorm:"index"`                                  // Optional expiration date
+	Permissions       []string       `json:"permissions" gorm:"type:jsonb;serializer:json"`            // Granted scopes, see pkg/scope
+	Environment       string         `json:"environment" gorm:"type:varchar(8);not null;default:live"` // live or test, also marked in the key string
+	AllowedIPs        []string       `json:"allowed_ips" gorm:"type:jsonb;serializer:json"`            // CIDR ranges the key may be used from, empty for any
+	AllowedReferrers  []string       `json:"allowed_referrers" gorm:"type:jsonb;serializer:json"`      // Origins the key may be used from, empty for any
+	DisableQueryParam bool           `json:"disable_query_param" gorm:"not null;default:false"`        // Refuse the key in the api_key query parameter
+	RateLimit         *int           `json:"rate_limit,omitempty"`                                     // Requests per rate limit window, nil for the default
+	RateLimitBurst    *int           `json:"rate_limit_burst,omitempty"`                               // Token bucket capacity, nil for the default
+	MonthlyQuota      *int64         `json:"monthly_quota,omitempty"`                                  // Requests per calendar month (0 is unlimited), nil for the default
+	PredecessorID     *uint          `json:"predecessor_id,omitempty" gorm:"index"`                    // Key this key was rotated from
+	SuccessorID       *uint          `json:"successor_id,omitempty"`                                   // Key this key was rotated to
+	RotatedAt         *time.Time     `json:"rotated_at,omitempty"`                                     // When the key was rotated; it keeps working until ExpiresAt
+	ExpiryNotifiedAt  *time.Time     `json:"-"`                                                        // When the owner was reminded that the key expires
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 // TableName specifies the table name for the APIKey model
 func (APIKey) TableName() string {
 	return "api_keys"
 }
+
+// IsLegacy reports whether the key predates the llk_ key format. Legacy
+// keys are found by their non-unique prefix and should be rotated.
+func (k *APIKey) IsLegacy() bool {
+	return k.LookupID == nil
+}
+
+// IsOrganizationKey reports whether the key is owned by an organization,
+// directly or through one of its service accounts, rather than by a user
+func (k *APIKey) IsOrganizationKey() bool {
+	return k.OrganizationID != nil
+}
+
+// Restrictions returns where the key may be used from
+func (k *APIKey) Restrictions() Restrictions {
+	return Restrictions{AllowedIPs: k.AllowedIPs, AllowedReferrers: k.AllowedReferrers, DisableQueryParam: k.DisableQueryParam}
+}
+
+// IsRotated reports whether the key was replaced by a successor and only
+// works until the end of its grace window
+func (k *APIKey) IsRotated() bool {
+	return k.SuccessorID != nil
+}
+
+// RateLimitOverride returns the key's limits that replace the configured
+// defaults, or nil if it has none
+func (k *APIKey) RateLimitOverride() *ratelimit.Override {
+	if k.RateLimit == nil && k.RateLimitBurst == nil && k.MonthlyQuota == nil {
+		return nil
+	}
+	return &ratelimit.Override{Requests: k.RateLimit, Burst: k.RateLimitBurst, MonthlyQuota: k.MonthlyQuota}
+}
+
+// ServiceAccount is a named non-human principal of an organization. Its API
+// keys keep working when the people who created them leave, and what they
+// may do is bounded by the account's organization role.
+type ServiceAccount struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	OrganizationID uint           `json:"organization_id" gorm:"not null;index"`
+	Name           string         `json:"name" gorm:"type:varchar(100);not null"`
+	Description    string         `json:"description" gorm:"type:varchar(500)"`
+	RoleID         uint           `json:"role_id" gorm:"not null"`
+	CreatedBy      uint           `json:"created_by"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName specifies the table name for the ServiceAccount model
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// RateLimitCounter is the database-backed rate limit counter of one key
+// ("key:<id>", "user:<id>", "org:<id>" or "ip:<address>", prefixed with
+// "quota:" for monthly quotas), shared by all instances
+type RateLimitCounter struct {
+	Key         string  `gorm:"primaryKey;size:100"`
+	Tokens      float64 `gorm:"not null;default:0"`
+	Count       int64   `gorm:"not null;default:0"`
+	Previous    int64   `gorm:"not null;default:0"`
+	WindowStart time.Time
+	UpdatedAt   time.Time `gorm:"autoUpdateTime:false"` // Last counted request, drives token refills
+	ExpiresAt   time.Time `gorm:"index"`
+}
+
+// TableName specifies the table name for the RateLimitCounter model
+func (RateLimitCounter) TableName() string {
+	return "rate_limit_counters"
+}
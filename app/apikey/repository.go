@@ -1,9 +1,13 @@
 package apikey
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository interface for API key operations
@@ -11,11 +15,28 @@ type Repository interface {
 	Create(apiKey *APIKey) error
 	FindByID(id uint) (*APIKey, error)
 	FindByKey(key string) (*APIKey, error)
-	FindByPrefix(prefix string) (*APIKey, error)
+	FindByLookupID(lookupID string) (*APIKey, error)
+	FindLegacyByPrefix(prefix string) ([]*APIKey, error)
+	UpdateKeyHash(id uint, hash string) error
 	FindByUserID(userID uint, page, pageSize int) ([]*APIKey, int64, error)
 	Update(apiKey *APIKey) error
 	Delete(id uint) error
-	UpdateLastUsed(id uint) error
+	UpdateLastUsed(id uint, at time.Time) error
+	FindByOrganizationID(organizationID uint, page, pageSize int) ([]*APIKey, int64, error)
+	OrganizationActive(organizationID uint) (bool, error)
+	UserActive(userID uint) (bool, error)
+	CreateServiceAccount(account *ServiceAccount) error
+	FindServiceAccount(id uint) (*ServiceAccount, error)
+	FindServiceAccounts(organizationID uint) ([]*ServiceAccount, error)
+	ServiceAccountNameExists(organizationID uint, name string) (bool, error)
+	DeleteServiceAccount(id uint) error
+	Rotate(predecessor, successor *APIKey) error
+	FindExpiring(now, before time.Time, rotated bool) ([]*APIKey, error)
+	ClaimExpiryNotice(id uint, at time.Time) (bool, error)
+	ReleaseExpiryNotice(id uint) error
+	MarkDormant(createdBefore, at time.Time) (int64, error)
+	Purge(before time.Time, limit int) (int64, error)
+	FindUserEmail(userID uint) (string, error)
 }
 
 // repository is the implementation of Repository interface
@@ -51,15 +72,28 @@ func (r *repository) FindByKey(key string) (*APIKey, error) {
 	return &apiKey, nil
 }
 
-// FindByPrefix finds an API key by its prefix
-func (r *repository) FindByPrefix(prefix string) (*APIKey, error) {
+// FindByLookupID finds an API key by the lookup ID embedded in it
+func (r *repository) FindByLookupID(lookupID string) (*APIKey, error) {
 	var apiKey APIKey
-	if err := r.db.Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+	if err := r.db.Where("lookup_id = ?", lookupID).First(&apiKey).Error; err != nil {
 		return nil, err
 	}
 	return &apiKey, nil
 }
 
+// FindLegacyByPrefix finds the legacy API keys sharing a prefix. Prefixes
+// are not unique, so every candidate has to be checked.
+func (r *repository) FindLegacyByPrefix(prefix string) ([]*APIKey, error) {
+	var apiKeys []*APIKey
+	err := r.db.Where("prefix = ? AND lookup_id IS NULL", prefix).Order("id").Find(&apiKeys).Error
+	return apiKeys, err
+}
+
+// UpdateKeyHash replaces the stored hash of an API key
+func (r *repository) UpdateKeyHash(id uint, hash string) error {
+	return r.db.Model(&APIKey{}).Where("id = ?", id).Update("key", hash).Error
+}
+
 // FindByUserID finds all API keys for a user with pagination
 func (r *repository) FindByUserID(userID uint, page, pageSize int) ([]*APIKey, int64, error) {
 	var apiKeys []*APIKey
@@ -96,8 +130,253 @@ func (r *repository) Delete(id uint) error {
 	return r.db.Delete(&APIKey{}, id).Error
 }
 
-// UpdateLastUsed updates the last used timestamp for an API key
-func (r *repository) UpdateLastUsed(id uint) error {
+// UpdateLastUsed records when an API key was last used, unless a later use
+// is already recorded
+func (r *repository) UpdateLastUsed(id uint, at time.Time) error {
+	return r.db.Model(&APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, at).
+		Updates(map[string]interface{}{"last_used_at": at, "dormant_at": nil}).Error
+}
+
+// FindByOrganizationID finds the API keys owned by an organization or its
+// service accounts with pagination
+func (r *repository) FindByOrganizationID(organizationID uint, page, pageSize int) ([]*APIKey, int64, error) {
+	var apiKeys []*APIKey
+	var total int64
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+
+	offset := (page - 1) * pageSize
+
+	query := r.db.Model(&APIKey{}).Where("organization_id = ?", organizationID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("id").Offset(offset).Limit(pageSize).Find(&apiKeys).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return apiKeys, total, nil
+}
+
+// OrganizationActive reports whether the organization exists and is enabled
+func (r *repository) OrganizationActive(organizationID uint) (bool, error) {
+	var count int64
+	err := r.db.Table("organizations").
+		Where("id = ? AND status = 1 AND deleted_at IS NULL", organizationID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// UserActive reports whether a user exists and is neither deleted nor
+// disabled
+func (r *repository) UserActive(userID uint) (bool, error) {
+	var count int64
+	err := r.db.Table("users").
+		Where("id = ? AND status = 1 AND deleted_at IS NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CreateServiceAccount creates a service account
+func (r *repository) CreateServiceAccount(account *ServiceAccount) error {
+	return r.db.Create(account).Error
+}
+
+// FindServiceAccount finds a service account by its ID
+func (r *repository) FindServiceAccount(id uint) (*ServiceAccount, error) {
+	var account ServiceAccount
+	if err := r.db.First(&account, id).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindServiceAccounts lists the service accounts of an organization
+func (r *repository) FindServiceAccounts(organizationID uint) ([]*ServiceAccount, error) {
+	var accounts []*ServiceAccount
+	err := r.db.Where("organization_id = ?", organizationID).Order("name").Find(&accounts).Error
+	return accounts, err
+}
+
+// ServiceAccountNameExists checks whether the organization already has a
+// service account with the name
+func (r *repository) ServiceAccountNameExists(organizationID uint, name string) (bool, error) {
+	var count int64
+	err := r.db.Model(&ServiceAccount{}).
+		Where("organization_id = ? AND LOWER(name) = LOWER(?)", organizationID, name).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// DeleteServiceAccount soft deletes a service account and revokes its keys
+func (r *repository) DeleteServiceAccount(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("service_account_id = ?", id).Delete(&APIKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&ServiceAccount{}, id).Error
+	})
+}
+
+// Rotate creates the successor and links the predecessor to it, shortening
+// the predecessor's expiry to the one it was given. It fails with
+// ErrAlreadyRotated if the predecessor was rotated concurrently.
+func (r *repository) Rotate(predecessor, successor *APIKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(successor).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&APIKey{}).
+			Where("id = ? AND successor_id IS NULL", predecessor.ID).
+			Updates(map[string]interface{}{
+				"successor_id": successor.ID,
+				"rotated_at":   predecessor.RotatedAt,
+				"expires_at":   predecessor.ExpiresAt,
+				// The shortened expiry gets its own reminder
+				"expiry_notified_at": nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyRotated
+		}
+		predecessor.SuccessorID = &successor.ID
+		return nil
+	})
+}
+
+// FindExpiring finds keys that are still valid at now, expire before the
+// given time and whose owner was not yet reminded. rotated selects keys
+// replaced by a successor rather than keys reaching their own expiry.
+func (r *repository) FindExpiring(now, before time.Time, rotated bool) ([]*APIKey, error) {
+	query := r.db.Where("expiry_notified_at IS NULL AND expires_at > ? AND expires_at <= ?", now, before)
+	if rotated {
+		query = query.Where("successor_id IS NOT NULL")
+	} else {
+		query = query.Where("successor_id IS NULL")
+	}
+	var apiKeys []*APIKey
+	err := query.Order("expires_at").Find(&apiKeys).Error
+	return apiKeys, err
+}
+
+// ClaimExpiryNotice records that the owner is being reminded of the expiry.
+// It reports false if another instance already claimed the reminder.
+func (r *repository) ClaimExpiryNotice(id uint, at time.Time) (bool, error) {
+	result := r.db.Model(&APIKey{}).
+		Where("id = ? AND expiry_notified_at IS NULL", id).
+		UpdateColumn("expiry_notified_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseExpiryNotice clears a claimed reminder so it is sent again
+func (r *repository) ReleaseExpiryNotice(id uint) error {
+	return r.db.Model(&APIKey{}).Where("id = ?", id).UpdateColumn("expiry_notified_at", nil).Error
+}
+
+// MarkDormant marks keys created before the given time that were never
+// used as dormant and returns how many were marked
+func (r *repository) MarkDormant(createdBefore, at time.Time) (int64, error) {
+	result := r.db.Model(&APIKey{}).
+		Where("last_used_at IS NULL AND dormant_at IS NULL AND created_at < ?", createdBefore).
+		UpdateColumn("dormant_at", at)
+	return result.RowsAffected, result.Error
+}
+
+// Purge permanently deletes up to limit keys that expired or were revoked
+// before the given time and returns how many were deleted
+func (r *repository) Purge(before time.Time, limit int) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		err := tx.Unscoped().Model(&APIKey{}).
+			Where("(deleted_at IS NOT NULL AND deleted_at < ?) OR expires_at < ?", before, before).
+			Order("id").
+			Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		result := tx.Unscoped().Where("id IN ?", ids).Delete(&APIKey{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// FindUserEmail returns the email address of an active user
+func (r *repository) FindUserEmail(userID uint) (string, error) {
+	var emails []string
+	err := r.db.Table("users").
+		Where("id = ? AND deleted_at IS NULL", userID).
+		Pluck("email", &emails).Error
+	if err != nil {
+		return "", err
+	}
+	if len(emails) == 0 {
+		return "", gorm.ErrRecordNotFound
+	}
+	return emails[0], nil
+}
+
+// rateLimitStore is the database-backed ratelimit.Store
+type rateLimitStore struct {
+	db      *gorm.DB
+	updates atomic.Int64
+}
+
+// NewRateLimitStore creates a rate limit store persisted in the database
+func NewRateLimitStore(db *gorm.DB) ratelimit.Store {
+	return &rateLimitStore{db: db}
+}
+
+// Update applies fn to the key's counter while holding its row lock
+func (s *rateLimitStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *ratelimit.State)) (*ratelimit.State, error) {
 	now := time.Now()
-	return r.db.Model(&APIKey{}).Where("id = ?", id).Update("last_used_at", now).Error
+	// Expired counters are only reset on use; sweep them now and then
+	if s.updates.Add(1)%1000 == 0 {
+		if err := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&RateLimitCounter{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var state *ratelimit.State
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&RateLimitCounter{Key: key, ExpiresAt: now.Add(ttl)}).Error; err != nil {
+			return err
+		}
+		var counter RateLimitCounter
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		state = &ratelimit.State{}
+		if counter.ExpiresAt.After(now) {
+			state.Tokens = counter.Tokens
+			state.Count = counter.Count
+			state.Previous = counter.Previous
+			state.WindowStart = counter.WindowStart
+			state.UpdatedAt = counter.UpdatedAt
+		}
+		fn(state)
+		counter.Tokens = state.Tokens
+		counter.Count = state.Count
+		counter.Previous = state.Previous
+		counter.WindowStart = state.WindowStart
+		counter.UpdatedAt = state.UpdatedAt
+		counter.ExpiresAt = now.Add(ttl)
+		return tx.Save(&counter).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
 }
@@ -0,0 +1,181 @@
+package apikey
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/llamacto/llama-gin-kit/pkg/keyformat"
+)
+
+// Environments a key is issued for. The environment is part of the key
+// string (llk_live_... or llk_test_...) so that test keys are recognizable
+// wherever they show up.
+const (
+	EnvironmentLive = keyformat.EnvironmentLive
+	EnvironmentTest = keyformat.EnvironmentTest
+)
+
+const (
+	// maxAllowedIPs and maxAllowedReferrers bound the restriction lists
+	maxAllowedIPs       = 50
+	maxAllowedReferrers = 50
+)
+
+var (
+	// ErrInvalidEnvironment is returned for environments other than live and test
+	ErrInvalidEnvironment = errors.New("environment must be live or test")
+	// ErrInvalidRestriction is returned for malformed IP ranges or referrers
+	ErrInvalidRestriction = errors.New("invalid API key restriction")
+	// ErrAPIKeyRestricted is returned when a valid key is used from an IP
+	// address, origin or transport the key does not allow
+	ErrAPIKeyRestricted = errors.New("API key is not allowed for this request")
+)
+
+// Restrictions limits where an API key may be used from. Empty lists allow
+// any client IP or origin.
+type Restrictions struct {
+	AllowedIPs        []string // IP addresses or CIDR ranges
+	AllowedReferrers  []string // Origins such as https://app.example.com or https://*.example.com
+	DisableQueryParam bool     // Refuse the key in the api_key query parameter
+}
+
+// RequestInfo describes how a request presented an API key
+type RequestInfo struct {
+	ClientIP  string
+	Origin    string // Origin header
+	Referer   string // Referer header, used when there is no Origin
+	FromQuery bool   // The key was sent in the api_key query parameter
+}
+
+// validEnvironment returns the environment to issue a key for, live when
+// none is given
+func validEnvironment(environment string) (string, error) {
+	switch environment {
+	case "":
+		return EnvironmentLive, nil
+	case EnvironmentLive, EnvironmentTest:
+		return environment, nil
+	default:
+		return "", ErrInvalidEnvironment
+	}
+}
+
+// normalizeRestrictions validates the restrictions and returns them in
+// canonical form: IP addresses as CIDR ranges and referrers as lower-case
+// origins
+func normalizeRestrictions(r Restrictions) (Restrictions, error) {
+	if len(r.AllowedIPs) > maxAllowedIPs || len(r.AllowedReferrers) > maxAllowedReferrers {
+		return Restrictions{}, fmt.Errorf("%w: at most %d IP ranges and %d referrers", ErrInvalidRestriction, maxAllowedIPs, maxAllowedReferrers)
+	}
+
+	out := Restrictions{AllowedIPs: []string{}, AllowedReferrers: []string{}, DisableQueryParam: r.DisableQueryParam}
+	seen := make(map[string]bool)
+	for _, raw := range r.AllowedIPs {
+		cidr, err := normalizeCIDR(strings.TrimSpace(raw))
+		if err != nil {
+			return Restrictions{}, err
+		}
+		if !seen[cidr] {
+			seen[cidr] = true
+			out.AllowedIPs = append(out.AllowedIPs, cidr)
+		}
+	}
+	for _, raw := range r.AllowedReferrers {
+		origin, err := normalizeOrigin(strings.TrimSpace(raw))
+		if err != nil {
+			return Restrictions{}, err
+		}
+		if !seen[origin] {
+			seen[origin] = true
+			out.AllowedReferrers = append(out.AllowedReferrers, origin)
+		}
+	}
+	return out, nil
+}
+
+// normalizeCIDR accepts an IP address or CIDR range
+func normalizeCIDR(raw string) (string, error) {
+	if ip := net.ParseIP(raw); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(raw)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q is not an IP address or CIDR range", ErrInvalidRestriction, raw)
+	}
+	return network.String(), nil
+}
+
+// normalizeOrigin accepts an http(s) origin whose host may start with "*."
+// to allow every subdomain. Paths are dropped.
+func normalizeOrigin(raw string) (string, error) {
+	u, err := url.Parse(strings.ToLower(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		strings.Contains(strings.TrimPrefix(u.Host, "*."), "*") {
+		return "", fmt.Errorf("%w: %q is not an http(s) origin", ErrInvalidRestriction, raw)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// Permits reports whether the key may be used for the request, returning an
+// error wrapping ErrAPIKeyRestricted if not
+func (k *APIKey) Permits(req RequestInfo) error {
+	if req.FromQuery && k.DisableQueryParam {
+		return fmt.Errorf("%w: send the key in the X-API-Key header", ErrAPIKeyRestricted)
+	}
+	if len(k.AllowedIPs) > 0 && !ipAllowed(k.AllowedIPs, req.ClientIP) {
+		return fmt.Errorf("%w: client IP %s is not allowed", ErrAPIKeyRestricted, req.ClientIP)
+	}
+	if len(k.AllowedReferrers) > 0 {
+		origin := req.Origin
+		if origin == "" || origin == "null" {
+			origin = req.Referer
+		}
+		if !originAllowed(k.AllowedReferrers, origin) {
+			return fmt.Errorf("%w: origin is not allowed", ErrAPIKeyRestricted)
+		}
+	}
+	return nil
+}
+
+func ipAllowed(allowed []string, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range allowed {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed matches the origin of an Origin or Referer header value
+// against the allowed origins
+func originAllowed(allowed []string, value string) bool {
+	u, err := url.Parse(strings.ToLower(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return false
+	}
+	for _, a := range allowed {
+		scheme, host, _ := strings.Cut(a, "://")
+		if scheme != u.Scheme {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(host, "*"); ok {
+			// *.example.com matches subdomains, not example.com itself
+			if strings.HasSuffix(u.Host, suffix) && len(u.Host) > len(suffix) {
+				return true
+			}
+		} else if host == u.Host {
+			return true
+		}
+	}
+	return false
+}
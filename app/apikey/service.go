@@ -1,19 +1,106 @@
 package apikey
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/llamacto/llama-gin-kit/app/authorization"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/email"
+	"github.com/llamacto/llama-gin-kit/pkg/hasher"
+	"github.com/llamacto/llama-gin-kit/pkg/keyformat"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
+	"gorm.io/gorm"
 )
 
+const (
+	// KeyPrefix starts every API key. Keys have the form
+	// llk_<environment>_<lookup ID>_<secret><checksum>, see pkg/keyformat:
+	// the lookup ID finds the key, and the key is only stored as an
+	// HMAC-SHA256 digest keyed with the server pepper. Keys issued before
+	// environments and checksums were introduced omit them.
+	KeyPrefix = keyformat.Prefix
+
+	// hmacScheme marks digests in the key column, as opposed to the bcrypt
+	// hashes of legacy keys
+	hmacScheme = "$hmac-sha256$"
+
+	lookupIDBytes = keyformat.LookupIDLength / 2
+	secretBytes   = keyformat.SecretLength / 2
+
+	// maintenanceInterval is how often expiry reminders are sent, dormant
+	// keys marked and old keys purged
+	maintenanceInterval = 10 * time.Minute
+)
+
+var (
+	// ErrInvalidAPIKey is returned when an API key does not match any key
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyExpired is returned when an API key is past its expiry
+	ErrAPIKeyExpired = errors.New("API key expired")
+	// ErrAlreadyRotated is returned when a key already has a successor
+	ErrAlreadyRotated = errors.New("API key has already been rotated")
+	// ErrInvalidRateLimit is returned for negative limits or a zero rate
+	ErrInvalidRateLimit = errors.New("invalid rate limit")
+	// ErrInvalidScope is returned when a requested permission is not a valid,
+	// registered scope
+	ErrInvalidScope = errors.New("invalid permission scope")
+	// ErrNotOrganizationAdmin is returned when the caller may not manage the
+	// organization's API keys and service accounts
+	ErrNotOrganizationAdmin = errors.New("organization owner or admin role required")
+	// ErrOrganizationInactive is returned for organizations that are deleted or disabled
+	ErrOrganizationInactive = errors.New("organization is not active")
+	// ErrUserInactive is returned for personal keys whose owner is deleted or disabled
+	ErrUserInactive = errors.New("API key owner is not active")
+	// ErrServiceAccountNotFound is returned when the service account does not
+	// exist in the organization
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	// ErrServiceAccountExists is returned when the organization already has a
+	// service account with the name
+	ErrServiceAccountExists = errors.New("service account name already in use")
+	// ErrInvalidRole is returned when the role cannot be granted to an
+	// organization key or service account
+	ErrInvalidRole = errors.New("invalid organization role")
+	// ErrKeyOwner is returned unless exactly one of a service account and a
+	// role is given for an organization key
+	ErrKeyOwner = errors.New("exactly one of service_account_id and role_id is required")
+)
+
+// OrganizationRoles looks up the roles that manage organization keys and
+// bound what they may do
+type OrganizationRoles interface {
+	HasSystemRole(ctx context.Context, userID uint, roles ...string) (bool, error)
+	HasOrganizationRole(ctx context.Context, userID, organizationID uint, roles ...string) (bool, error)
+	FindOrganizationRole(ctx context.Context, roleID uint) (*authorization.Role, error)
+}
+
+// OrganizationKey describes an organization-owned API key to create. The
+// key authenticates as the service account, or directly as the organization
+// bounded by the role; exactly one of them must be set.
+type OrganizationKey struct {
+	Name             string
+	ExpiresAt        *time.Time
+	Permissions      []string
+	Environment      string // live (the default) or test
+	ServiceAccountID *uint
+	RoleID           *uint
+}
+
 // Service interface for API key operations
 type Service interface {
-	// GenerateAPIKey creates a new API key for a user
-	GenerateAPIKey(userID uint, name string, expiry *time.Time, permissions []string) (string, *APIKey, error)
+	// GenerateAPIKey creates a new API key for a user in the live (the
+	// default) or test environment
+	GenerateAPIKey(userID uint, name string, expiry *time.Time, permissions []string, environment string) (string, *APIKey, error)
 	
 	// ValidateAPIKey checks if an API key is valid
 	ValidateAPIKey(apiKey string) (*APIKey, error)
@@ -27,53 +114,155 @@ type Service interface {
 	// RevokeAPIKey revokes (deletes) an API key
 	RevokeAPIKey(id uint, userID uint) error
 	
-	// UpdateAPIKey updates an API key's name, permissions or expiry
-	UpdateAPIKey(id uint, userID uint, name string, expiry *time.Time, permissions []string) (*APIKey, error)
+	// UpdateAPIKey updates an API key's name, permissions or expiry. A nil
+	// expiry removes it unless the key was rotated and neverExpire is false.
+	UpdateAPIKey(id uint, userID uint, name string, expiry *time.Time, neverExpire bool, permissions []string) (*APIKey, error)
+
+	// ResolvePermissions returns the scopes requests made with the key may
+	// use. Personal keys stop working while their owner is disabled.
+	// Organization keys are confined to their organization and bounded by
+	// the permissions of their organization role.
+	ResolvePermissions(ctx context.Context, apiKey *APIKey) ([]string, error)
+
+	// GenerateOrganizationAPIKey creates an API key owned by an organization
+	GenerateOrganizationAPIKey(ctx context.Context, actorID, organizationID uint, req OrganizationKey) (string, *APIKey, error)
+
+	// ListOrganizationAPIKeys lists the API keys owned by an organization
+	ListOrganizationAPIKeys(ctx context.Context, actorID, organizationID uint, page, pageSize int) ([]*APIKey, int64, error)
+
+	// RevokeOrganizationAPIKey revokes (deletes) an organization's API key
+	RevokeOrganizationAPIKey(ctx context.Context, actorID, organizationID, id uint) error
+
+	// CreateServiceAccount creates a service account in an organization
+	CreateServiceAccount(ctx context.Context, actorID, organizationID uint, name, description string, roleID uint) (*ServiceAccount, error)
+
+	// ListServiceAccounts lists the service accounts of an organization
+	ListServiceAccounts(ctx context.Context, actorID, organizationID uint) ([]*ServiceAccount, error)
+
+	// DeleteServiceAccount deletes a service account and revokes its keys
+	DeleteServiceAccount(ctx context.Context, actorID, organizationID, id uint) error
+
+	// Start runs maintenance periodically until ctx is done. The returned
+	// channel is closed once it has stopped.
+	Start(ctx context.Context) <-chan struct{}
+
+	// SetRateLimits replaces the key's rate limit and quota overrides on
+	// behalf of an administrator; nil fields use the configured defaults
+	SetRateLimits(actorID, id uint, limits ratelimit.Override) (*APIKey, error)
+
+	// RotateAPIKey issues a successor with the key's name, owner,
+	// permissions and limits. The key keeps working for the grace window
+	// (the configured one when nil) and is then replaced. It returns the
+	// successor's key string, the successor and the rotated key.
+	RotateAPIKey(ctx context.Context, actorID, id uint, grace *time.Duration) (string, *APIKey, *APIKey, error)
+
+	// NotifyExpiring emails the owners of keys about to expire, once per
+	// key: rotated keys within the rotation notice period and other keys
+	// within the expiry notice period
+	NotifyExpiring(ctx context.Context) error
+
+	// RunMaintenance sends expiry reminders, marks keys that were never
+	// used as dormant and purges keys expired or revoked longer than the
+	// retention window
+	RunMaintenance(ctx context.Context)
+
+	// SetRestrictions replaces the client IP ranges and origins the key may
+	// be used from and whether it may be sent in the query string
+	SetRestrictions(ctx context.Context, actorID, id uint, restrictions Restrictions) (*APIKey, error)
+
+	// ReportLeaked revokes the reported keys that are valid and emails
+	// their owners. It returns how many keys were revoked.
+	ReportLeaked(ctx context.Context, report LeakReport) (int, error)
 }
 
 // service is the implementation of Service interface
 type service struct {
 	repository Repository
+	hasher     hasher.Hasher
+	scopes     *scope.Registry
+	roles      OrganizationRoles
+	cfg        config.APIKeyConfig
+	cache      *keyCache
+	now        func() time.Time
+	// notify reminds the owner at the address that the key expires
+	notify func(to string, apiKey *APIKey) error
+	// notifyLeaked tells the owner at the address that the key was revoked
+	// because it leaked
+	notifyLeaked func(to string, apiKey *APIKey, report LeakReport) error
 }
 
-// NewAPIKeyService creates a new API key service
-func NewAPIKeyService(repository Repository) Service {
-	return &service{repository: repository}
+// NewAPIKeyService creates a new API key service. Requested permissions are
+// validated against the scope registry; roles manage and bound organization
+// keys, which are refused when it is nil. The hasher only verifies legacy
+// keys issued before the llk_ format.
+func NewAPIKeyService(repository Repository, h hasher.Hasher, scopes *scope.Registry, roles OrganizationRoles, cfg config.APIKeyConfig) Service {
+	return &service{
+		repository: repository,
+		hasher:     h,
+		scopes:     scopes,
+		roles:      roles,
+		cfg:        cfg,
+		cache:      newKeyCache(cfg.CacheDuration, cfg.CacheSize),
+		now:        time.Now,
+		notify: func(to string, apiKey *APIKey) error {
+			if apiKey.IsRotated() {
+				return email.SendAPIKeyRotationEmail(to, apiKey.Name, apiKey.Prefix, *apiKey.ExpiresAt)
+			}
+			return email.SendAPIKeyExpiryEmail(to, apiKey.Name, apiKey.Prefix, *apiKey.ExpiresAt)
+		},
+		notifyLeaked: func(to string, apiKey *APIKey, report LeakReport) error {
+			return email.SendAPIKeyLeakedEmail(to, apiKey.Name, apiKey.Prefix, report.Source, report.URL)
+		},
+	}
 }
 
-// GenerateAPIKey creates a new API key for a user
-func (s *service) GenerateAPIKey(userID uint, name string, expiry *time.Time, permissions []string) (string, *APIKey, error) {
-	// Generate a random API key (32 bytes, 64 hex chars)
-	b := make([]byte, 32)
+// digest returns the stored form of an API key string
+func (s *service) digest(apiKeyString string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Pepper))
+	mac.Write([]byte(apiKeyString))
+	return hmacScheme + hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
 	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validateScopes checks requested permissions against the registry and
+// returns them in canonical form
+func (s *service) validateScopes(permissions []string) ([]string, error) {
+	scopes, err := s.scopes.Validate(permissions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidScope, err)
+	}
+	return scopes, nil
+}
+
+// GenerateAPIKey creates a new API key for a user
+func (s *service) GenerateAPIKey(userID uint, name string, expiry *time.Time, permissions []string, environment string) (string, *APIKey, error) {
+	scopes, err := s.validateScopes(permissions)
+	if err != nil {
 		return "", nil, err
 	}
-	
-	keyString := hex.EncodeToString(b)
-	
-	// Get prefix for easy identification
-	prefix := keyString[:8]
-	
-	// Hash the key for storage
-	hashedKey, err := bcrypt.GenerateFromPassword([]byte(keyString), bcrypt.DefaultCost)
+	environment, err = validEnvironment(environment)
 	if err != nil {
 		return "", nil, err
 	}
-	
-	// Convert permissions array to string
-	permissionsStr := strings.Join(permissions, ",")
-	
+
 	apiKey := &APIKey{
 		Name:        name,
-		Key:         string(hashedKey),
-		Prefix:      prefix,
 		UserID:      userID,
+		CreatedBy:   userID,
 		ExpiresAt:   expiry,
-		Permissions: permissionsStr,
+		Permissions: scopes,
+		Environment: environment,
 	}
-	
-	// Save to database
-	if err := s.repository.Create(apiKey); err != nil {
+	keyString, err := s.issue(apiKey)
+	if err != nil {
 		return "", nil, err
 	}
 	
@@ -81,40 +270,131 @@ func (s *service) GenerateAPIKey(userID uint, name string, expiry *time.Time, pe
 	return keyString, apiKey, nil
 }
 
-// ValidateAPIKey checks if an API key is valid and returns the API key entity
-func (s *service) ValidateAPIKey(apiKeyString string) (*APIKey, error) {
-	if len(apiKeyString) < 8 {
-		return nil, errors.New("invalid API key format")
+// issue generates the key string for apiKey, stores its digest and returns
+// the key string
+func (s *service) issue(apiKey *APIKey) (string, error) {
+	keyString, err := s.newSecret(apiKey)
+	if err != nil {
+		return "", err
 	}
-	
-	// Extract prefix (first 8 chars)
-	prefix := apiKeyString[:8]
-	
-	// Find the API key by prefix
-	apiKey, err := s.repository.FindByPrefix(prefix)
+	if err := s.repository.Create(apiKey); err != nil {
+		return "", err
+	}
+	return keyString, nil
+}
+
+// newSecret generates the key string for apiKey and sets its lookup ID,
+// prefix and digest without storing it
+func (s *service) newSecret(apiKey *APIKey) (string, error) {
+	lookupID, err := randomHex(lookupIDBytes)
 	if err != nil {
-		return nil, errors.New("invalid API key")
+		return "", err
 	}
-	
-	// Check if key is expired
-	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("API key expired")
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return "", err
 	}
-	
-	// Verify the key
-	if err := bcrypt.CompareHashAndPassword([]byte(apiKey.Key), []byte(apiKeyString)); err != nil {
-		return nil, errors.New("invalid API key")
+	if apiKey.Environment == "" {
+		apiKey.Environment = EnvironmentLive
 	}
-	
+	keyString := keyformat.New(apiKey.Environment, lookupID, secret)
+
+	apiKey.LookupID = &lookupID
+	// Get prefix for easy identification
+	apiKey.Prefix = lookupID[:8]
+	apiKey.Key = s.digest(keyString)
+	return keyString, nil
+}
+
+// ValidateAPIKey checks if an API key is valid and returns the API key
+// entity. Recently verified keys are served from the cache.
+func (s *service) ValidateAPIKey(apiKeyString string) (*APIKey, error) {
+	now := s.now()
+	digest := s.digest(apiKeyString)
+	apiKey, ok := s.cache.get(digest, now)
+	if !ok {
+		var err error
+		if strings.HasPrefix(apiKeyString, KeyPrefix) {
+			apiKey, err = s.lookup(apiKeyString, digest)
+		} else {
+			apiKey, err = s.lookupLegacy(apiKeyString, digest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.cache.put(digest, apiKey, now)
+	}
+
+	// Check if key is expired
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
+		return nil, ErrAPIKeyExpired
+	}
+
 	// Update last used timestamp
-	if err := s.repository.UpdateLastUsed(apiKey.ID); err != nil {
+	if err := s.repository.UpdateLastUsed(apiKey.ID, now); err != nil {
 		// Non-critical error, just log it
-		// logger.Warn("Failed to update API key last used timestamp", err)
+		logger.Warn("Failed to update last use of API key %d: %v", apiKey.ID, err)
+	}
+	return apiKey, nil
+}
+
+// lookup finds an llk_ key by its lookup ID and compares the digests in
+// constant time. Malformed keys and typos caught by the checksum are
+// rejected without a database lookup.
+func (s *service) lookup(apiKeyString, digest string) (*APIKey, error) {
+	parsed, err := keyformat.Parse(apiKeyString)
+	if err != nil {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := s.repository.FindByLookupID(parsed.LookupID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(apiKey.Key), []byte(digest)) || apiKey.Environment != parsed.Environment {
+		return nil, ErrInvalidAPIKey
 	}
-	
 	return apiKey, nil
 }
 
+// lookupLegacy verifies a key issued before the llk_ format against every
+// legacy key sharing its prefix. A matching bcrypt hash is replaced by the
+// digest, so only the first use of a legacy key pays for bcrypt.
+func (s *service) lookupLegacy(apiKeyString, digest string) (*APIKey, error) {
+	if len(apiKeyString) < 8 {
+		return nil, ErrInvalidAPIKey
+	}
+	candidates, err := s.repository.FindLegacyByPrefix(apiKeyString[:8])
+	if err != nil {
+		return nil, err
+	}
+	for _, apiKey := range candidates {
+		if strings.HasPrefix(apiKey.Key, hmacScheme) {
+			if hmac.Equal([]byte(apiKey.Key), []byte(digest)) {
+				return apiKey, nil
+			}
+			continue
+		}
+		if ok, err := s.hasher.Verify(apiKey.Key, apiKeyString); err != nil || !ok {
+			continue
+		}
+		if err := s.repository.UpdateKeyHash(apiKey.ID, digest); err != nil {
+			logger.Warn("Failed to upgrade hash of API key %d: %v", apiKey.ID, err)
+		} else {
+			apiKey.Key = digest
+		}
+		return apiKey, nil
+	}
+	return nil, ErrInvalidAPIKey
+}
+
+// forget drops the key from the verification cache
+func (s *service) forget(id uint) {
+	s.cache.drop(func(apiKey *APIKey) bool { return apiKey.ID == id })
+}
+
 // GetAPIKey gets an API key by ID
 func (s *service) GetAPIKey(id uint) (*APIKey, error) {
 	return s.repository.FindByID(id)
@@ -137,11 +417,21 @@ func (s *service) RevokeAPIKey(id uint, userID uint) error {
 		return errors.New("unauthorized to revoke this API key")
 	}
 	
-	return s.repository.Delete(id)
+	if err := s.repository.Delete(id); err != nil {
+		return err
+	}
+	s.forget(id)
+	return nil
 }
 
-// UpdateAPIKey updates an API key's name, permissions or expiry
-func (s *service) UpdateAPIKey(id uint, userID uint, name string, expiry *time.Time, permissions []string) (*APIKey, error) {
+// UpdateAPIKey updates an API key's name, permissions or expiry. The expiry
+// of a rotated key cannot be changed; it is kept when none is given.
+func (s *service) UpdateAPIKey(id uint, userID uint, name string, expiry *time.Time, neverExpire bool, permissions []string) (*APIKey, error) {
+	scopes, err := s.validateScopes(permissions)
+	if err != nil {
+		return nil, err
+	}
+
 	apiKey, err := s.repository.FindByID(id)
 	if err != nil {
 		return nil, err
@@ -152,14 +442,440 @@ func (s *service) UpdateAPIKey(id uint, userID uint, name string, expiry *time.T
 		return nil, errors.New("unauthorized to update this API key")
 	}
 	
+	// A rotated key only lives out its grace window
+	if apiKey.IsRotated() {
+		if expiry == nil && !neverExpire {
+			expiry = apiKey.ExpiresAt
+		}
+		if !sameTime(apiKey.ExpiresAt, expiry) {
+			return nil, ErrAlreadyRotated
+		}
+	}
+
+	// A new expiry gets its own reminder
+	if !sameTime(apiKey.ExpiresAt, expiry) {
+		apiKey.ExpiryNotifiedAt = nil
+	}
+
 	// Update fields
 	apiKey.Name = name
 	apiKey.ExpiresAt = expiry
-	apiKey.Permissions = strings.Join(permissions, ",")
+	apiKey.Permissions = scopes
 	
 	if err := s.repository.Update(apiKey); err != nil {
 		return nil, err
 	}
+	s.forget(id)
 	
 	return apiKey, nil
 }
+
+// ResolvePermissions returns the scopes requests made with the key may use
+func (s *service) ResolvePermissions(ctx context.Context, apiKey *APIKey) ([]string, error) {
+	if !apiKey.IsOrganizationKey() {
+		active, err := s.repository.UserActive(apiKey.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, ErrUserInactive
+		}
+		return apiKey.Permissions, nil
+	}
+
+	organizationID := *apiKey.OrganizationID
+	active, err := s.repository.OrganizationActive(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrOrganizationInactive
+	}
+
+	roleID, err := s.keyRole(apiKey)
+	if err != nil {
+		return nil, err
+	}
+	if s.roles == nil || roleID == 0 {
+		return []string{}, nil
+	}
+	role, err := s.roles.FindOrganizationRole(ctx, roleID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// The role was removed or disabled: the key keeps authenticating but
+		// may no longer do anything
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return boundScopes(apiKey.Permissions, organizationID, role.Permissions), nil
+}
+
+// keyRole returns the organization role bounding an organization key
+func (s *service) keyRole(apiKey *APIKey) (uint, error) {
+	if apiKey.ServiceAccountID == nil {
+		if apiKey.RoleID == nil {
+			return 0, nil
+		}
+		return *apiKey.RoleID, nil
+	}
+
+	account, err := s.repository.FindServiceAccount(*apiKey.ServiceAccountID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && account.OrganizationID != *apiKey.OrganizationID) {
+		return 0, ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return account.RoleID, nil
+}
+
+// boundScopes narrows the granted scopes to the organization and to the
+// resource:action pairs of the role's permissions
+func boundScopes(granted []string, organizationID uint, permissions []*authorization.Permission) []string {
+	org := scope.Scope{Org: strconv.FormatUint(uint64(organizationID), 10), Resource: scope.Wildcard, Action: scope.Wildcard}
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, g := range granted {
+		sc, err := scope.Parse(g)
+		if err != nil {
+			continue
+		}
+		if sc, ok := scope.Intersect(sc, org); ok {
+			for _, p := range permissions {
+				bounded, ok := scope.Intersect(sc, scope.Scope{Resource: p.Resource, Action: p.Action})
+				if !ok {
+					continue
+				}
+				if key := bounded.String(); !seen[key] {
+					seen[key] = true
+					out = append(out, key)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// requireOrganizationAdmin checks that the actor is an owner or admin of the
+// organization, or a system administrator
+func (s *service) requireOrganizationAdmin(ctx context.Context, actorID, organizationID uint) error {
+	if s.roles == nil {
+		return ErrNotOrganizationAdmin
+	}
+	ok, err := s.roles.HasOrganizationRole(ctx, actorID, organizationID, authorization.OrgRoleOwner, authorization.OrgRoleAdmin)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		ok, err = s.roles.HasSystemRole(ctx, actorID, authorization.RoleAdmin)
+		if err != nil {
+			return err
+		}
+	}
+	if !ok {
+		return ErrNotOrganizationAdmin
+	}
+
+	active, err := s.repository.OrganizationActive(organizationID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrOrganizationInactive
+	}
+	return nil
+}
+
+// checkRole verifies that the role can be granted to organization principals
+func (s *service) checkRole(ctx context.Context, roleID uint) error {
+	if _, err := s.roles.FindOrganizationRole(ctx, roleID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRole
+		}
+		return err
+	}
+	return nil
+}
+
+// GenerateOrganizationAPIKey creates an API key owned by an organization
+func (s *service) GenerateOrganizationAPIKey(ctx context.Context, actorID, organizationID uint, req OrganizationKey) (string, *APIKey, error) {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return "", nil, err
+	}
+	if (req.ServiceAccountID == nil) == (req.RoleID == nil) {
+		return "", nil, ErrKeyOwner
+	}
+
+	scopes, err := s.validateScopes(req.Permissions)
+	if err != nil {
+		return "", nil, err
+	}
+	environment, err := validEnvironment(req.Environment)
+	if err != nil {
+		return "", nil, err
+	}
+	// Scopes naming another organization could never be used
+	org := strconv.FormatUint(uint64(organizationID), 10)
+	for _, raw := range scopes {
+		if sc, _ := scope.Parse(raw); sc.Org != "" && sc.Org != scope.Wildcard && sc.Org != org {
+			return "", nil, fmt.Errorf("%w: %q is limited to another organization", ErrInvalidScope, raw)
+		}
+	}
+
+	if req.ServiceAccountID != nil {
+		account, err := s.repository.FindServiceAccount(*req.ServiceAccountID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && account.OrganizationID != organizationID) {
+			return "", nil, ErrServiceAccountNotFound
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	} else if err := s.checkRole(ctx, *req.RoleID); err != nil {
+		return "", nil, err
+	}
+
+	apiKey := &APIKey{
+		Name:             req.Name,
+		OrganizationID:   &organizationID,
+		ServiceAccountID: req.ServiceAccountID,
+		RoleID:           req.RoleID,
+		CreatedBy:        actorID,
+		ExpiresAt:        req.ExpiresAt,
+		Permissions:      scopes,
+		Environment:      environment,
+	}
+	keyString, err := s.issue(apiKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyString, apiKey, nil
+}
+
+// ListOrganizationAPIKeys lists the API keys owned by an organization
+func (s *service) ListOrganizationAPIKeys(ctx context.Context, actorID, organizationID uint, page, pageSize int) ([]*APIKey, int64, error) {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return nil, 0, err
+	}
+	return s.repository.FindByOrganizationID(organizationID, page, pageSize)
+}
+
+// RevokeOrganizationAPIKey revokes (deletes) an organization's API key
+func (s *service) RevokeOrganizationAPIKey(ctx context.Context, actorID, organizationID, id uint) error {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return err
+	}
+	apiKey, err := s.repository.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if apiKey.OrganizationID == nil || *apiKey.OrganizationID != organizationID {
+		return gorm.ErrRecordNotFound
+	}
+	if err := s.repository.Delete(id); err != nil {
+		return err
+	}
+	s.forget(id)
+	return nil
+}
+
+// CreateServiceAccount creates a service account in an organization
+func (s *service) CreateServiceAccount(ctx context.Context, actorID, organizationID uint, name, description string, roleID uint) (*ServiceAccount, error) {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	exists, err := s.repository.ServiceAccountNameExists(organizationID, name)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrServiceAccountExists
+	}
+	if err := s.checkRole(ctx, roleID); err != nil {
+		return nil, err
+	}
+
+	account := &ServiceAccount{
+		OrganizationID: organizationID,
+		Name:           name,
+		Description:    description,
+		RoleID:         roleID,
+		CreatedBy:      actorID,
+	}
+	if err := s.repository.CreateServiceAccount(account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// ListServiceAccounts lists the service accounts of an organization
+func (s *service) ListServiceAccounts(ctx context.Context, actorID, organizationID uint) ([]*ServiceAccount, error) {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return nil, err
+	}
+	return s.repository.FindServiceAccounts(organizationID)
+}
+
+// DeleteServiceAccount deletes a service account and revokes its keys
+func (s *service) DeleteServiceAccount(ctx context.Context, actorID, organizationID, id uint) error {
+	if err := s.requireOrganizationAdmin(ctx, actorID, organizationID); err != nil {
+		return err
+	}
+	account, err := s.repository.FindServiceAccount(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && account.OrganizationID != organizationID) {
+		return ErrServiceAccountNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.repository.DeleteServiceAccount(id); err != nil {
+		return err
+	}
+	s.cache.drop(func(apiKey *APIKey) bool {
+		return apiKey.ServiceAccountID != nil && *apiKey.ServiceAccountID == id
+	})
+	return nil
+}
+
+// Start runs maintenance every maintenanceInterval until ctx is done. The
+// returned channel is closed once it has stopped.
+func (s *service) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(maintenanceInterval)
+		defer ticker.Stop()
+		for {
+			s.RunMaintenance(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// findManagedKey finds a key the actor manages: their own personal keys,
+// or the keys of organizations they administer. Other keys are reported as
+// not found.
+func (s *service) findManagedKey(ctx context.Context, actorID, id uint) (*APIKey, error) {
+	apiKey, err := s.repository.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey.IsOrganizationKey() {
+		if err := s.requireOrganizationAdmin(ctx, actorID, *apiKey.OrganizationID); err != nil {
+			return nil, gorm.ErrRecordNotFound
+		}
+	} else if apiKey.UserID != actorID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return apiKey, nil
+}
+
+// SetRateLimits replaces the key's rate limit and quota overrides
+func (s *service) SetRateLimits(actorID, id uint, limits ratelimit.Override) (*APIKey, error) {
+	if (limits.Requests != nil && *limits.Requests < 1) ||
+		(limits.Burst != nil && *limits.Burst < 0) ||
+		(limits.MonthlyQuota != nil && *limits.MonthlyQuota < 0) {
+		return nil, ErrInvalidRateLimit
+	}
+	apiKey, err := s.repository.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.RateLimit = limits.Requests
+	apiKey.RateLimitBurst = limits.Burst
+	apiKey.MonthlyQuota = limits.MonthlyQuota
+	if err := s.repository.Update(apiKey); err != nil {
+		return nil, err
+	}
+	s.forget(id)
+	logger.Info("Administrator %d changed the rate limits of API key %d", actorID, id)
+	return apiKey, nil
+}
+
+// RotateAPIKey issues a successor for the key. Users rotate their own keys,
+// organization admins the keys of their organization.
+func (s *service) RotateAPIKey(ctx context.Context, actorID, id uint, grace *time.Duration) (string, *APIKey, *APIKey, error) {
+	apiKey, err := s.findManagedKey(ctx, actorID, id)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	now := s.now()
+	if apiKey.ExpiresAt != nil && !apiKey.ExpiresAt.After(now) {
+		return "", nil, nil, ErrAPIKeyExpired
+	}
+	if apiKey.IsRotated() {
+		return "", nil, nil, ErrAlreadyRotated
+	}
+
+	successor := &APIKey{
+		Name:              apiKey.Name,
+		UserID:            apiKey.UserID,
+		OrganizationID:    apiKey.OrganizationID,
+		ServiceAccountID:  apiKey.ServiceAccountID,
+		RoleID:            apiKey.RoleID,
+		CreatedBy:         actorID,
+		ExpiresAt:         apiKey.ExpiresAt,
+		Permissions:       apiKey.Permissions,
+		Environment:       apiKey.Environment,
+		AllowedIPs:        apiKey.AllowedIPs,
+		AllowedReferrers:  apiKey.AllowedReferrers,
+		DisableQueryParam: apiKey.DisableQueryParam,
+		RateLimit:         apiKey.RateLimit,
+		RateLimitBurst:    apiKey.RateLimitBurst,
+		MonthlyQuota:      apiKey.MonthlyQuota,
+		PredecessorID:     &apiKey.ID,
+	}
+	keyString, err := s.newSecret(successor)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	window := s.cfg.RotationGraceDuration
+	if grace != nil {
+		window = *grace
+	}
+	// The grace window never extends the key's lifetime
+	expiresAt := now.Add(window)
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(expiresAt) {
+		expiresAt = *apiKey.ExpiresAt
+	}
+	apiKey.ExpiresAt = &expiresAt
+	apiKey.RotatedAt = &now
+
+	if err := s.repository.Rotate(apiKey, successor); err != nil {
+		return "", nil, nil, err
+	}
+	s.forget(apiKey.ID)
+	logger.Info("User %d rotated API key %d to %d", actorID, apiKey.ID, successor.ID)
+	return keyString, successor, apiKey, nil
+}
+
+// SetRestrictions replaces where the key may be used from. Users restrict
+// their own keys, organization admins the keys of their organization.
+func (s *service) SetRestrictions(ctx context.Context, actorID, id uint, restrictions Restrictions) (*APIKey, error) {
+	restrictions, err := normalizeRestrictions(restrictions)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := s.findManagedKey(ctx, actorID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.AllowedIPs = restrictions.AllowedIPs
+	apiKey.AllowedReferrers = restrictions.AllowedReferrers
+	apiKey.DisableQueryParam = restrictions.DisableQueryParam
+	if err := s.repository.Update(apiKey); err != nil {
+		return nil, err
+	}
+	s.forget(id)
+	return apiKey, nil
+}
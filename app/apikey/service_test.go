@@ -0,0 +1,1136 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/authorization"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/hasher"
+	"github.com/llamacto/llama-gin-kit/pkg/keyformat"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository used by the tests
+type memoryRepository struct {
+	mu            sync.Mutex
+	nextID        uint
+	keys          map[uint]*APIKey
+	accounts      map[uint]*ServiceAccount
+	organizations map[uint]bool
+	disabledUsers map[uint]bool
+	lookups       int
+	emails        map[uint]string
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		keys:          make(map[uint]*APIKey),
+		accounts:      make(map[uint]*ServiceAccount),
+		organizations: make(map[uint]bool),
+		disabledUsers: make(map[uint]bool),
+		emails:        make(map[uint]string),
+	}
+}
+
+func (r *memoryRepository) Create(apiKey *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	apiKey.ID = r.nextID
+	copied := *apiKey
+	r.keys[apiKey.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) FindByID(id uint) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *k
+	return &copied, nil
+}
+
+func (r *memoryRepository) FindByKey(key string) (*APIKey, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) FindByLookupID(lookupID string) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	for _, k := range r.keys {
+		if k.LookupID != nil && *k.LookupID == lookupID {
+			copied := *k
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) FindLegacyByPrefix(prefix string) ([]*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups++
+	var out []*APIKey
+	for _, k := range r.keys {
+		if k.LookupID == nil && k.Prefix == prefix {
+			copied := *k
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) UpdateKeyHash(id uint, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.Key = hash
+	}
+	return nil
+}
+
+func (r *memoryRepository) FindByUserID(userID uint, page, pageSize int) ([]*APIKey, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*APIKey
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			copied := *k
+			out = append(out, &copied)
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+func (r *memoryRepository) Update(apiKey *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *apiKey
+	r.keys[apiKey.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.keys, id)
+	return nil
+}
+
+func (r *memoryRepository) UpdateLastUsed(id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.LastUsedAt = &at
+		k.DormantAt = nil
+	}
+	return nil
+}
+
+func (r *memoryRepository) FindByOrganizationID(organizationID uint, page, pageSize int) ([]*APIKey, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*APIKey
+	for _, k := range r.keys {
+		if k.OrganizationID != nil && *k.OrganizationID == organizationID {
+			copied := *k
+			out = append(out, &copied)
+		}
+	}
+	return out, int64(len(out)), nil
+}
+
+func (r *memoryRepository) OrganizationActive(organizationID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.organizations[organizationID], nil
+}
+
+func (r *memoryRepository) UserActive(userID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.disabledUsers[userID], nil
+}
+
+func (r *memoryRepository) CreateServiceAccount(account *ServiceAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	account.ID = r.nextID
+	copied := *account
+	r.accounts[account.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) FindServiceAccount(id uint) (*ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.accounts[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *a
+	return &copied, nil
+}
+
+func (r *memoryRepository) FindServiceAccounts(organizationID uint) ([]*ServiceAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*ServiceAccount
+	for _, a := range r.accounts {
+		if a.OrganizationID == organizationID {
+			copied := *a
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) ServiceAccountNameExists(organizationID uint, name string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, a := range r.accounts {
+		if a.OrganizationID == organizationID && strings.EqualFold(a.Name, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRepository) DeleteServiceAccount(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for keyID, k := range r.keys {
+		if k.ServiceAccountID != nil && *k.ServiceAccountID == id {
+			delete(r.keys, keyID)
+		}
+	}
+	delete(r.accounts, id)
+	return nil
+}
+
+func (r *memoryRepository) Rotate(predecessor, successor *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[predecessor.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if k.SuccessorID != nil {
+		return ErrAlreadyRotated
+	}
+	r.nextID++
+	successor.ID = r.nextID
+	copied := *successor
+	r.keys[successor.ID] = &copied
+	k.SuccessorID = &copied.ID
+	k.RotatedAt = predecessor.RotatedAt
+	k.ExpiresAt = predecessor.ExpiresAt
+	k.ExpiryNotifiedAt = nil
+	predecessor.SuccessorID = &copied.ID
+	return nil
+}
+
+func (r *memoryRepository) FindExpiring(now, before time.Time, rotated bool) ([]*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*APIKey
+	for _, k := range r.keys {
+		if k.IsRotated() == rotated && k.ExpiryNotifiedAt == nil && k.ExpiresAt != nil &&
+			k.ExpiresAt.After(now) && !k.ExpiresAt.After(before) {
+			copied := *k
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) ClaimExpiryNotice(id uint, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k, ok := r.keys[id]
+	if !ok || k.ExpiryNotifiedAt != nil {
+		return false, nil
+	}
+	k.ExpiryNotifiedAt = &at
+	return true, nil
+}
+
+func (r *memoryRepository) ReleaseExpiryNotice(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if k, ok := r.keys[id]; ok {
+		k.ExpiryNotifiedAt = nil
+	}
+	return nil
+}
+
+func (r *memoryRepository) MarkDormant(createdBefore, at time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var marked int64
+	for _, k := range r.keys {
+		if k.LastUsedAt == nil && k.DormantAt == nil && k.CreatedAt.Before(createdBefore) {
+			k.DormantAt = &at
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (r *memoryRepository) Purge(before time.Time, limit int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var purged int64
+	for id, k := range r.keys {
+		if purged < int64(limit) && k.ExpiresAt != nil && k.ExpiresAt.Before(before) {
+			delete(r.keys, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryRepository) FindUserEmail(userID uint) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	email, ok := r.emails[userID]
+	if !ok {
+		return "", gorm.ErrRecordNotFound
+	}
+	return email, nil
+}
+
+// fakeRoles grants organization admin to the listed users and knows the
+// permissions of each organization role
+type fakeRoles struct {
+	admins map[uint]bool
+	roles  map[uint][]*authorization.Permission
+}
+
+func (f *fakeRoles) HasSystemRole(ctx context.Context, userID uint, roles ...string) (bool, error) {
+	return false, nil
+}
+
+func (f *fakeRoles) HasOrganizationRole(ctx context.Context, userID, organizationID uint, roles ...string) (bool, error) {
+	return f.admins[userID], nil
+}
+
+func (f *fakeRoles) FindOrganizationRole(ctx context.Context, roleID uint) (*authorization.Role, error) {
+	permissions, ok := f.roles[roleID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &authorization.Role{ID: roleID, Permissions: permissions}, nil
+}
+
+const (
+	testOrg   = uint(42)
+	testAdmin = uint(1)
+	testRole  = uint(7)
+)
+
+func newTestService(t *testing.T) (*service, *memoryRepository, *fakeRoles) {
+	t.Helper()
+	registry := scope.NewRegistry()
+	registry.Register("organizations", "read", "write", "delete")
+	registry.Register("teams", "read", "write")
+
+	repo := newMemoryRepository()
+	repo.organizations[testOrg] = true
+	roles := &fakeRoles{
+		admins: map[uint]bool{testAdmin: true},
+		roles: map[uint][]*authorization.Permission{
+			testRole: {
+				{Resource: "organizations", Action: "read"},
+				{Resource: "teams", Action: "read"},
+			},
+		},
+	}
+	cfg := config.APIKeyConfig{
+		Pepper:                 "test-pepper",
+		CacheDuration:          time.Minute,
+		CacheSize:              100,
+		RotationGraceDuration:  24 * time.Hour,
+		RotationNoticeDuration: 2 * time.Hour,
+		ExpiryNoticeDuration:   7 * 24 * time.Hour,
+		DormantDuration:        90 * 24 * time.Hour,
+		RetentionDuration:      30 * 24 * time.Hour,
+	}
+	svc := NewAPIKeyService(repo, hasher.NewBcrypt(4), registry, roles, cfg).(*service)
+	return svc, repo, roles
+}
+
+func TestResolvePermissions_OrganizationKeyIsBoundedByRole(t *testing.T) {
+	svc, _, roles := newTestService(t)
+	ctx := context.Background()
+
+	role := testRole
+	_, key, err := svc.GenerateOrganizationAPIKey(ctx, testAdmin, testOrg, OrganizationKey{
+		Name:        "ci",
+		Permissions: []string{"*"},
+		RoleID:      &role,
+	})
+	if err != nil {
+		t.Fatalf("GenerateOrganizationAPIKey failed: %v", err)
+	}
+	if key.UserID != 0 || key.CreatedBy != testAdmin {
+		t.Errorf("Expected an organization key created by the admin, got user %d created by %d", key.UserID, key.CreatedBy)
+	}
+
+	perms, err := svc.ResolvePermissions(ctx, key)
+	if err != nil {
+		t.Fatalf("ResolvePermissions failed: %v", err)
+	}
+	sort.Strings(perms)
+	want := []string{"org:42/organizations:read", "org:42/teams:read"}
+	if strings.Join(perms, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, perms)
+	}
+	if !scope.Allows(perms, scope.Scope{Org: "42", Resource: "organizations", ID: "42", Action: "read"}) {
+		t.Error("Expected the key to read its organization")
+	}
+	if scope.Allows(perms, scope.Scope{Org: "43", Resource: "organizations", ID: "43", Action: "read"}) {
+		t.Error("Expected the key to be confined to its organization")
+	}
+	if scope.Allows(perms, scope.Scope{Org: "42", Resource: "teams", Action: "write"}) {
+		t.Error("Expected the role to bound the key's wildcard scope")
+	}
+
+	// Narrowing the role narrows existing keys
+	roles.roles[testRole] = []*authorization.Permission{{Resource: "teams", Action: "read"}}
+	perms, err = svc.ResolvePermissions(ctx, key)
+	if err != nil {
+		t.Fatalf("ResolvePermissions failed: %v", err)
+	}
+	if len(perms) != 1 || perms[0] != "org:42/teams:read" {
+		t.Errorf("Expected only org:42/teams:read, got %v", perms)
+	}
+}
+
+func TestResolvePermissions_ServiceAccountKeys(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	ctx := context.Background()
+
+	account, err := svc.CreateServiceAccount(ctx, testAdmin, testOrg, "deploy-bot", "", testRole)
+	if err != nil {
+		t.Fatalf("CreateServiceAccount failed: %v", err)
+	}
+	if _, err := svc.CreateServiceAccount(ctx, testAdmin, testOrg, "Deploy-Bot", "", testRole); !errors.Is(err, ErrServiceAccountExists) {
+		t.Errorf("Expected ErrServiceAccountExists, got %v", err)
+	}
+
+	_, key, err := svc.GenerateOrganizationAPIKey(ctx, testAdmin, testOrg, OrganizationKey{
+		Name:             "deploy",
+		Permissions:      []string{"teams:*"},
+		ServiceAccountID: &account.ID,
+	})
+	if err != nil {
+		t.Fatalf("GenerateOrganizationAPIKey failed: %v", err)
+	}
+	perms, err := svc.ResolvePermissions(ctx, key)
+	if err != nil || len(perms) != 1 || perms[0] != "org:42/teams:read" {
+		t.Errorf("Expected org:42/teams:read, got %v %v", perms, err)
+	}
+
+	// Disabling the organization stops its keys
+	repo.organizations[testOrg] = false
+	if _, err := svc.ResolvePermissions(ctx, key); !errors.Is(err, ErrOrganizationInactive) {
+		t.Errorf("Expected ErrOrganizationInactive, got %v", err)
+	}
+	repo.organizations[testOrg] = true
+
+	// Deleting the service account revokes its keys
+	if err := svc.DeleteServiceAccount(ctx, testAdmin, testOrg, account.ID); err != nil {
+		t.Fatalf("DeleteServiceAccount failed: %v", err)
+	}
+	if _, err := repo.FindByID(key.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected the service account key to be revoked, got %v", err)
+	}
+}
+
+func TestGenerateOrganizationAPIKey_Validation(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+	role := testRole
+	missing := uint(99)
+
+	cases := []struct {
+		name    string
+		actor   uint
+		req     OrganizationKey
+		wantErr error
+	}{
+		{"not an admin", 2, OrganizationKey{Name: "k", RoleID: &role}, ErrNotOrganizationAdmin},
+		{"no owner", testAdmin, OrganizationKey{Name: "k"}, ErrKeyOwner},
+		{"unknown role", testAdmin, OrganizationKey{Name: "k", RoleID: &missing}, ErrInvalidRole},
+		{"unknown service account", testAdmin, OrganizationKey{Name: "k", ServiceAccountID: &missing}, ErrServiceAccountNotFound},
+		{"other organization", testAdmin, OrganizationKey{Name: "k", RoleID: &role, Permissions: []string{"org:43/*"}}, ErrInvalidScope},
+		{"unregistered scope", testAdmin, OrganizationKey{Name: "k", RoleID: &role, Permissions: []string{"billing:read"}}, ErrInvalidScope},
+	}
+	for _, tc := range cases {
+		if _, _, err := svc.GenerateOrganizationAPIKey(ctx, tc.actor, testOrg, tc.req); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestPersonalKeys_KeepTheirScopes(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	expiry := time.Now().Add(time.Hour)
+
+	secret, key, err := svc.GenerateAPIKey(5, "cli", &expiry, []string{"teams:write", " teams:write"}, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	validated, err := svc.ValidateAPIKey(secret)
+	if err != nil || validated.ID != key.ID {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+	perms, err := svc.ResolvePermissions(context.Background(), validated)
+	if err != nil || len(perms) != 1 || perms[0] != "teams:write" {
+		t.Errorf("Expected [teams:write], got %v %v", perms, err)
+	}
+}
+
+func TestPersonalKeys_StopWorkingForDisabledOwners(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	ctx := context.Background()
+
+	secret, _, err := svc.GenerateAPIKey(5, "cli", nil, []string{"teams:read"}, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	validated, err := svc.ValidateAPIKey(secret)
+	if err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	// The cached key is rejected as soon as its owner is disabled
+	repo.disabledUsers[5] = true
+	validated, err = svc.ValidateAPIKey(secret)
+	if err != nil {
+		t.Fatalf("Expected the cached key to validate, got %v", err)
+	}
+	if _, err := svc.ResolvePermissions(ctx, validated); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("Expected ErrUserInactive, got %v", err)
+	}
+
+	delete(repo.disabledUsers, 5)
+	if perms, err := svc.ResolvePermissions(ctx, validated); err != nil || len(perms) != 1 {
+		t.Errorf("Expected the key to work again once the owner is enabled, got %v %v", perms, err)
+	}
+}
+
+func TestValidateAPIKey_LookupFormat(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	expiry := time.Now().Add(time.Hour)
+
+	secret, key, err := svc.GenerateAPIKey(5, "cli", &expiry, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	parts := strings.Split(secret, "_")
+	if len(parts) != 4 || parts[0]+"_" != KeyPrefix || parts[1] != EnvironmentLive || len(parts[2]) != 16 ||
+		len(parts[3]) != keyformat.SecretLength+keyformat.ChecksumLength {
+		t.Fatalf("Unexpected key format %q", secret)
+	}
+	if err := keyformat.Verify(secret); err != nil {
+		t.Errorf("Expected a valid checksum, got %v", err)
+	}
+	rawSecret := parts[3][:keyformat.SecretLength]
+	if key.Prefix != parts[2][:8] || !strings.HasPrefix(key.Key, hmacScheme) || strings.Contains(key.Key, rawSecret) {
+		t.Errorf("Unexpected stored key %+v", key)
+	}
+
+	if _, err := svc.ValidateAPIKey(secret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	// A typo fails the checksum before the database is consulted
+	lookups := repo.lookups
+	tampered := secret[:len(secret)-1] + "0"
+	if tampered == secret {
+		tampered = secret[:len(secret)-1] + "1"
+	}
+	if _, err := svc.ValidateAPIKey(tampered); !errors.Is(err, ErrInvalidAPIKey) || repo.lookups != lookups {
+		t.Errorf("Expected ErrInvalidAPIKey without a lookup for a bad checksum, got %v", err)
+	}
+	wrongSecret := keyformat.New(EnvironmentLive, parts[2], strings.Repeat("0", keyformat.SecretLength))
+	if _, err := svc.ValidateAPIKey(wrongSecret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for a wrong secret, got %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(keyformat.New(EnvironmentLive, "0000000000000000", rawSecret)); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for an unknown lookup ID, got %v", err)
+	}
+
+	// Served from the cache
+	lookups = repo.lookups
+	if _, err := svc.ValidateAPIKey(secret); err != nil || repo.lookups != lookups {
+		t.Errorf("Expected a cached validation, got %v with %d lookups", err, repo.lookups-lookups)
+	}
+
+	// Expiry is checked on cached keys too
+	svc.now = func() time.Time { return expiry.Add(time.Second) }
+	svc.cache.ttl = time.Hour
+	if _, err := svc.ValidateAPIKey(secret); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("Expected ErrAPIKeyExpired, got %v", err)
+	}
+}
+
+func TestValidateAPIKey_UpgradesLegacyKeys(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	legacy := strings.Repeat("ab", 32)
+	hash, err := svc.hasher.Hash(legacy)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	key := &APIKey{Name: "old", UserID: 5, Prefix: legacy[:8], Key: hash, Permissions: []string{"*"}}
+	if err := repo.Create(key); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	validated, err := svc.ValidateAPIKey(legacy)
+	if err != nil || validated.ID != key.ID {
+		t.Fatalf("Expected the legacy key to validate, got %v", err)
+	}
+	stored, _ := repo.FindByID(key.ID)
+	if stored.Key != svc.digest(legacy) {
+		t.Errorf("Expected the bcrypt hash to be replaced by the digest, got %q", stored.Key)
+	}
+	if !ToResponse(stored, "").LegacyFormat {
+		t.Error("Expected legacy keys to be flagged")
+	}
+
+	// Verified with the digest once the cache is cold
+	svc.cache = newKeyCache(time.Minute, 100)
+	if _, err := svc.ValidateAPIKey(legacy); err != nil {
+		t.Errorf("Expected the upgraded key to validate, got %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(legacy[:8] + strings.Repeat("cd", 28)); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for a wrong legacy key, got %v", err)
+	}
+}
+
+func TestValidateAPIKey_RevocationDropsCachedKey(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+
+	secret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(secret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+	if err := svc.RevokeAPIKey(key.ID, 5); err != nil {
+		t.Fatalf("RevokeAPIKey failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the revoked key to be rejected, got %v", err)
+	}
+
+	account, err := svc.CreateServiceAccount(ctx, testAdmin, testOrg, "ci", "", testRole)
+	if err != nil {
+		t.Fatalf("CreateServiceAccount failed: %v", err)
+	}
+	secret, _, err = svc.GenerateOrganizationAPIKey(ctx, testAdmin, testOrg, OrganizationKey{Name: "deploy", ServiceAccountID: &account.ID})
+	if err != nil {
+		t.Fatalf("GenerateOrganizationAPIKey failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(secret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+	if err := svc.DeleteServiceAccount(ctx, testAdmin, testOrg, account.ID); err != nil {
+		t.Fatalf("DeleteServiceAccount failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(secret); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the service account's key to be rejected, got %v", err)
+	}
+}
+
+func TestValidateAPIKey_RecordsLastUse(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	secret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	// The second validation is served from the cache and still counts
+	for i := 0; i < 2; i++ {
+		now = now.Add(time.Minute / 2)
+		if _, err := svc.ValidateAPIKey(secret); err != nil {
+			t.Fatalf("Expected the key to validate, got %v", err)
+		}
+		if k, _ := repo.FindByID(key.ID); k.LastUsedAt == nil || !k.LastUsedAt.Equal(now) {
+			t.Errorf("Expected the last use at %v, got %v", now, k.LastUsedAt)
+		}
+	}
+}
+
+func TestStart_StopsOnCancel(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	svc.notify = func(to string, apiKey *APIKey) error { return nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := svc.Start(ctx)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Start to stop after cancellation")
+	}
+}
+
+func TestSetRateLimits(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	secret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if key.RateLimitOverride() != nil {
+		t.Fatal("Expected new keys to use the default limits")
+	}
+	if _, err := svc.ValidateAPIKey(secret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	zero := 0
+	if _, err := svc.SetRateLimits(testAdmin, key.ID, ratelimit.Override{Requests: &zero}); !errors.Is(err, ErrInvalidRateLimit) {
+		t.Errorf("Expected ErrInvalidRateLimit for a zero rate, got %v", err)
+	}
+	requests, quota := 5, int64(1000)
+	if _, err := svc.SetRateLimits(testAdmin, key.ID, ratelimit.Override{Requests: &requests, MonthlyQuota: &quota}); err != nil {
+		t.Fatalf("SetRateLimits failed: %v", err)
+	}
+
+	// The cached key is dropped so the new limits apply at once
+	validated, err := svc.ValidateAPIKey(secret)
+	if err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+	p := ratelimit.Policy{Requests: 100, Burst: 50}.With(validated.RateLimitOverride())
+	if p.Requests != 5 || p.Burst != 50 || p.MonthlyQuota != 1000 {
+		t.Errorf("Unexpected policy %+v", p)
+	}
+}
+
+func TestRotateAPIKey_OverlapsForTheGraceWindow(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	requests := 5
+	oldSecret, key, err := svc.GenerateAPIKey(5, "cli", nil, []string{"teams:read"}, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, err := svc.SetRateLimits(testAdmin, key.ID, ratelimit.Override{Requests: &requests}); err != nil {
+		t.Fatalf("SetRateLimits failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(oldSecret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	if _, _, _, err := svc.RotateAPIKey(ctx, 6, key.ID, nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected other users' keys to be hidden, got %v", err)
+	}
+	newSecret, successor, predecessor, err := svc.RotateAPIKey(ctx, 5, key.ID, nil)
+	if err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+	if newSecret == oldSecret || successor.Name != "cli" || successor.UserID != 5 || *successor.RateLimit != 5 ||
+		strings.Join(successor.Permissions, ",") != "teams:read" {
+		t.Errorf("Expected a new secret for the same key, got %+v", successor)
+	}
+	if *successor.PredecessorID != key.ID || *predecessor.SuccessorID != successor.ID || !predecessor.ExpiresAt.Equal(now.Add(24*time.Hour)) {
+		t.Errorf("Unexpected lineage %+v -> %+v", predecessor, successor)
+	}
+
+	// Both keys work during the grace window, the cached old key included
+	for _, secret := range []string{oldSecret, newSecret} {
+		if _, err := svc.ValidateAPIKey(secret); err != nil {
+			t.Errorf("Expected both keys to validate, got %v", err)
+		}
+	}
+	if _, _, _, err := svc.RotateAPIKey(ctx, 5, key.ID, nil); !errors.Is(err, ErrAlreadyRotated) {
+		t.Errorf("Expected ErrAlreadyRotated, got %v", err)
+	}
+
+	now = now.Add(24*time.Hour + time.Second)
+	if _, err := svc.ValidateAPIKey(oldSecret); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("Expected the old key to expire after the grace window, got %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(newSecret); err != nil {
+		t.Errorf("Expected the successor to keep working, got %v", err)
+	}
+}
+
+func TestRotateAPIKey_GraceNeverExtendsExpiry(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	expiry := now.Add(time.Hour)
+	_, key, err := svc.GenerateAPIKey(5, "cli", &expiry, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	grace := 48 * time.Hour
+	_, successor, predecessor, err := svc.RotateAPIKey(context.Background(), 5, key.ID, &grace)
+	if err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+	if !predecessor.ExpiresAt.Equal(expiry) || !successor.ExpiresAt.Equal(expiry) {
+		t.Errorf("Expected both keys to keep the original expiry, got %v and %v", predecessor.ExpiresAt, successor.ExpiresAt)
+	}
+}
+
+func TestUpdateAPIKey_KeepsExpiryOfRotatedKey(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	oldSecret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	_, _, predecessor, err := svc.RotateAPIKey(context.Background(), 5, key.ID, nil)
+	if err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+
+	later := now.Add(365 * 24 * time.Hour)
+	if _, err := svc.UpdateAPIKey(key.ID, 5, "cli", &later, false, nil); !errors.Is(err, ErrAlreadyRotated) {
+		t.Errorf("Expected ErrAlreadyRotated for a later expiry, got %v", err)
+	}
+	if _, err := svc.UpdateAPIKey(key.ID, 5, "cli", nil, true, nil); !errors.Is(err, ErrAlreadyRotated) {
+		t.Errorf("Expected ErrAlreadyRotated for never_expire, got %v", err)
+	}
+
+	// Renaming without an expiry keeps the grace window, as does resending it
+	updated, err := svc.UpdateAPIKey(key.ID, 5, "old cli", nil, false, []string{"teams:read"})
+	if err != nil {
+		t.Fatalf("Expected a rename of the rotated key to succeed, got %v", err)
+	}
+	if updated.Name != "old cli" || updated.ExpiresAt == nil || !updated.ExpiresAt.Equal(*predecessor.ExpiresAt) ||
+		strings.Join(updated.Permissions, ",") != "teams:read" {
+		t.Errorf("Unexpected update %+v", updated)
+	}
+	if _, err := svc.UpdateAPIKey(key.ID, 5, "old cli", predecessor.ExpiresAt, false, nil); err != nil {
+		t.Errorf("Expected resending the expiry to succeed, got %v", err)
+	}
+
+	now = now.Add(24*time.Hour + time.Second)
+	if _, err := svc.ValidateAPIKey(oldSecret); !errors.Is(err, ErrAPIKeyExpired) {
+		t.Errorf("Expected the old key to expire after the grace window, got %v", err)
+	}
+}
+
+func TestNotifyExpiring_RemindsOwnerOfRotatedKeyOnce(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	repo.emails[5] = "owner@example.com"
+	var sent []string
+	svc.notify = func(to string, apiKey *APIKey) error {
+		sent = append(sent, to+" "+apiKey.Name)
+		return nil
+	}
+
+	_, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, _, _, err := svc.RotateAPIKey(ctx, 5, key.ID, nil); err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+
+	if err := svc.NotifyExpiring(ctx); err != nil || len(sent) != 0 {
+		t.Fatalf("Expected no notice a day before expiry, got %v %v", sent, err)
+	}
+	now = now.Add(23 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := svc.NotifyExpiring(ctx); err != nil {
+			t.Fatalf("NotifyExpiring failed: %v", err)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "owner@example.com cli" {
+		t.Errorf("Expected one notice to the owner, got %v", sent)
+	}
+}
+
+func TestValidateAPIKey_Environments(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+
+	if _, _, err := svc.GenerateAPIKey(5, "cli", nil, nil, "staging"); !errors.Is(err, ErrInvalidEnvironment) {
+		t.Errorf("Expected ErrInvalidEnvironment, got %v", err)
+	}
+	secret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, EnvironmentTest)
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if !strings.HasPrefix(secret, "llk_test_") || key.Environment != EnvironmentTest {
+		t.Fatalf("Expected a test key, got %q in %q", secret, key.Environment)
+	}
+	if validated, err := svc.ValidateAPIKey(secret); err != nil || validated.Environment != EnvironmentTest {
+		t.Errorf("Expected the test key to validate, got %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(strings.Replace(secret, "_test_", "_live_", 1)); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected a changed marker to be rejected, got %v", err)
+	}
+
+	// Keys issued before the environment marker are live keys
+	lookupID := "0123456789abcdef"
+	unmarked := KeyPrefix + lookupID + "_" + strings.Repeat("ab", 32)
+	if err := repo.Create(&APIKey{Name: "old", UserID: 5, LookupID: &lookupID, Prefix: lookupID[:8], Key: svc.digest(unmarked), Environment: EnvironmentLive}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(unmarked); err != nil {
+		t.Errorf("Expected the unmarked key to validate, got %v", err)
+	}
+}
+
+func TestSetRestrictions(t *testing.T) {
+	svc, _, _ := newTestService(t)
+	ctx := context.Background()
+	secret, key, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(secret); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	for _, bad := range []Restrictions{
+		{AllowedIPs: []string{"10.0.0.0/33"}},
+		{AllowedReferrers: []string{"example.com"}},
+		{AllowedReferrers: []string{"https://app.*.example.com"}},
+	} {
+		if _, err := svc.SetRestrictions(ctx, 5, key.ID, bad); !errors.Is(err, ErrInvalidRestriction) {
+			t.Errorf("Expected ErrInvalidRestriction for %+v, got %v", bad, err)
+		}
+	}
+	restrictions := Restrictions{
+		AllowedIPs:        []string{"10.1.2.3", "192.168.0.0/16", "2001:db8::/32"},
+		AllowedReferrers:  []string{"https://App.example.com/path", "https://*.example.org"},
+		DisableQueryParam: true,
+	}
+	if _, err := svc.SetRestrictions(ctx, 6, key.ID, restrictions); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected other users' keys to be hidden, got %v", err)
+	}
+	updated, err := svc.SetRestrictions(ctx, 5, key.ID, restrictions)
+	if err != nil {
+		t.Fatalf("SetRestrictions failed: %v", err)
+	}
+	if strings.Join(updated.AllowedIPs, ",") != "10.1.2.3/32,192.168.0.0/16,2001:db8::/32" ||
+		strings.Join(updated.AllowedReferrers, ",") != "https://app.example.com,https://*.example.org" {
+		t.Errorf("Unexpected normalized restrictions %v %v", updated.AllowedIPs, updated.AllowedReferrers)
+	}
+
+	// The cached key is dropped so the restrictions apply at once
+	validated, err := svc.ValidateAPIKey(secret)
+	if err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+	tests := []struct {
+		req     RequestInfo
+		allowed bool
+	}{
+		{RequestInfo{ClientIP: "10.1.2.3", Origin: "https://app.example.com"}, true},
+		{RequestInfo{ClientIP: "192.168.4.5", Referer: "https://a.b.example.org/page?q=1"}, true},
+		{RequestInfo{ClientIP: "2001:db8::1", Origin: "https://x.example.org"}, true},
+		{RequestInfo{ClientIP: "10.1.2.4", Origin: "https://app.example.com"}, false},
+		{RequestInfo{ClientIP: "10.1.2.3", Origin: "https://example.org"}, false},
+		{RequestInfo{ClientIP: "10.1.2.3", Origin: "http://app.example.com"}, false},
+		{RequestInfo{ClientIP: "10.1.2.3"}, false},
+		{RequestInfo{ClientIP: "10.1.2.3", Origin: "https://app.example.com", FromQuery: true}, false},
+	}
+	for _, tt := range tests {
+		err := validated.Permits(tt.req)
+		if (err == nil) != tt.allowed || (err != nil && !errors.Is(err, ErrAPIKeyRestricted)) {
+			t.Errorf("Permits(%+v) = %v, expected allowed %v", tt.req, err, tt.allowed)
+		}
+	}
+}
+
+func TestNotifyExpiring_RemindsBeforeExpiryAndRetriesFailures(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	repo.emails[5] = "owner@example.com"
+	fail := true
+	var sent []string
+	svc.notify = func(to string, apiKey *APIKey) error {
+		if fail {
+			return errors.New("mail server down")
+		}
+		sent = append(sent, to)
+		return nil
+	}
+
+	expiry := now.Add(10 * 24 * time.Hour)
+	_, key, err := svc.GenerateAPIKey(5, "cli", &expiry, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, _, err := svc.GenerateAPIKey(6, "unreachable", &expiry, nil, ""); err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+
+	now = now.Add(4 * 24 * time.Hour)
+	if err := svc.NotifyExpiring(ctx); err != nil {
+		t.Fatalf("NotifyExpiring failed: %v", err)
+	}
+	if k, _ := repo.FindByID(key.ID); k.ExpiryNotifiedAt != nil {
+		t.Fatal("Expected a failed reminder to be released")
+	}
+	fail = false
+	for i := 0; i < 2; i++ {
+		if err := svc.NotifyExpiring(ctx); err != nil {
+			t.Fatalf("NotifyExpiring failed: %v", err)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "owner@example.com" {
+		t.Errorf("Expected one reminder to the owner, got %v", sent)
+	}
+
+	// Extending the key earns a new reminder
+	extended := expiry.Add(30 * 24 * time.Hour)
+	if _, err := svc.UpdateAPIKey(key.ID, 5, "cli", &extended, false, nil); err != nil {
+		t.Fatalf("UpdateAPIKey failed: %v", err)
+	}
+	now = extended.Add(-24 * time.Hour)
+	if err := svc.NotifyExpiring(ctx); err != nil || len(sent) != 2 {
+		t.Errorf("Expected a reminder for the new expiry, got %v %v", sent, err)
+	}
+}
+
+func TestRunMaintenance_MarksDormantAndPurgesExpiredKeys(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	svc.notify = func(to string, apiKey *APIKey) error { return nil }
+	ctx := context.Background()
+
+	expiry := now.Add(24 * time.Hour)
+	expiringSecret, expiring, err := svc.GenerateAPIKey(5, "expiring", &expiry, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	unusedSecret, unused, err := svc.GenerateAPIKey(5, "unused", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	usedSecret, used, err := svc.GenerateAPIKey(5, "used", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	for _, id := range []uint{expiring.ID, unused.ID, used.ID} {
+		repo.keys[id].CreatedAt = now
+	}
+	for _, secret := range []string{expiringSecret, usedSecret} {
+		if _, err := svc.ValidateAPIKey(secret); err != nil {
+			t.Fatalf("Expected the key to validate, got %v", err)
+		}
+	}
+
+	now = now.Add(91 * 24 * time.Hour)
+	svc.RunMaintenance(ctx)
+	if k, _ := repo.FindByID(unused.ID); k.DormantAt == nil {
+		t.Error("Expected the never-used key to be dormant")
+	}
+	if k, _ := repo.FindByID(used.ID); k.DormantAt != nil {
+		t.Error("Expected the used key not to be dormant")
+	}
+	if _, err := repo.FindByID(expiring.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Expected the key expired past the retention window to be purged, got %v", err)
+	}
+
+	// Using a dormant key wakes it up
+	if _, err := svc.ValidateAPIKey(unusedSecret); err != nil {
+		t.Fatalf("Expected the dormant key to validate, got %v", err)
+	}
+	if k, _ := repo.FindByID(unused.ID); k.DormantAt != nil {
+		t.Error("Expected the key to no longer be dormant after use")
+	}
+}
+
+func TestReportLeaked_RevokesKeysAndEmailsOwners(t *testing.T) {
+	svc, repo, _ := newTestService(t)
+	ctx := context.Background()
+	repo.emails[5] = "owner@example.com"
+	var sent []string
+	svc.notifyLeaked = func(to string, apiKey *APIKey, report LeakReport) error {
+		sent = append(sent, to+" "+apiKey.Name+" "+report.URL)
+		return nil
+	}
+
+	leaked, key, err := svc.GenerateAPIKey(5, "ci", nil, nil, EnvironmentTest)
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	kept, _, err := svc.GenerateAPIKey(5, "prod", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(leaked); err != nil {
+		t.Fatalf("Expected the key to validate, got %v", err)
+	}
+
+	parsed, err := keyformat.Parse(kept)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	forged := keyformat.New(EnvironmentLive, parsed.LookupID, strings.Repeat("0", keyformat.SecretLength))
+	lookups := repo.lookups
+	revoked, err := svc.ReportLeaked(ctx, LeakReport{
+		Keys:   []string{leaked, " " + leaked + "\n", "not-a-key", kept[:len(kept)-1], forged},
+		Source: "github",
+		URL:    "https://github.com/example/repo/blob/main/.env",
+	})
+	if err != nil || revoked != 1 {
+		t.Fatalf("Expected one key revoked, got %d %v", revoked, err)
+	}
+	// Duplicates, garbage and bad checksums do not reach the database
+	if repo.lookups-lookups != 2 {
+		t.Errorf("Expected lookups for the leaked and the forged key only, got %d", repo.lookups-lookups)
+	}
+	if _, err := repo.FindByID(key.ID); err == nil {
+		t.Error("Expected the leaked key to be revoked")
+	}
+	if _, err := svc.ValidateAPIKey(leaked); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Errorf("Expected the cached key to be dropped, got %v", err)
+	}
+	if _, err := svc.ValidateAPIKey(kept); err != nil {
+		t.Errorf("Expected the other key to keep working, got %v", err)
+	}
+	if len(sent) != 1 || sent[0] != "owner@example.com ci https://github.com/example/repo/blob/main/.env" {
+		t.Errorf("Expected one email to the owner, got %v", sent)
+	}
+
+	// Reporting the key again is harmless
+	if revoked, err := svc.ReportLeaked(ctx, LeakReport{Keys: []string{leaked}}); err != nil || revoked != 0 {
+		t.Errorf("Expected nothing revoked, got %d %v", revoked, err)
+	}
+	if _, err := svc.ReportLeaked(ctx, LeakReport{Keys: make([]string, maxLeakReportKeys+1)}); !errors.Is(err, ErrTooManyLeakedKeys) {
+		t.Errorf("Expected ErrTooManyLeakedKeys, got %v", err)
+	}
+}
@@ -0,0 +1,8 @@
+package identity
+
+// ProviderInfo 可用于登录的外部身份提供方
+type ProviderInfo struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
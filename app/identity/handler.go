@@ -0,0 +1,85 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/response"
+)
+
+// Handler handles external identity listing and unlinking requests
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new identity handler
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Providers 查询可用的外部登录方式
+// @Summary 查询可用的外部登录方式
+// @Tags 外部登录
+// @Produce json
+// @Success 200 {array} ProviderInfo
+// @Router /auth/oidc/providers [get]
+func (h *Handler) Providers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Providers())
+}
+
+// List 查询已关联的外部账户
+// @Summary 查询已关联的外部账户
+// @Tags 外部登录
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} Identity
+// @Router /users/identities [get]
+func (h *Handler) List(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	identities, err := h.service.List(c.Request.Context(), principal.UserID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to list linked accounts", err)
+		return
+	}
+	c.JSON(http.StatusOK, identities)
+}
+
+// Unlink 解除关联的外部账户
+// @Summary 解除关联的外部账户
+// @Tags 外部登录
+// @Produce json
+// @Security Bearer
+// @Param id path int true "关联ID"
+// @Success 200 {string} string "已解除关联"
+// @Failure 404 {object} response.ErrorResponse
+// @Router /users/identities/{id} [delete]
+func (h *Handler) Unlink(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid identity ID", err)
+		return
+	}
+
+	if err := h.service.Unlink(c.Request.Context(), principal.UserID, uint(id)); err != nil {
+		if errors.Is(err, ErrIdentityNotFound) {
+			response.NotFound(c, err.Error(), err)
+			return
+		}
+		response.InternalServerError(c, "Failed to unlink account", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已解除关联"})
+}
@@ -0,0 +1,23 @@
+package identity
+
+import (
+	"time"
+)
+
+// Identity links a user to their account at an external identity provider.
+// A provider account can only be linked to one user.
+type Identity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Provider    string     `gorm:"size:50;not null;uniqueIndex:idx_user_identities_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_user_identities_provider_subject" json:"-"` // Stable user ID at the provider (sub claim)
+	Email       string     `gorm:"size:100" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// TableName specifies the database table name
+func (Identity) TableName() string {
+	return "user_identities"
+}
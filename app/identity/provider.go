@@ -0,0 +1,80 @@
+package identity
+
+import (
+	"context"
+
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/oidc"
+)
+
+// External is a user as asserted by an identity provider
+type External struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string // Preferred username, if the provider sends one
+	Picture       string
+}
+
+// Provider is an external identity provider users can sign in with.
+// Implementations must verify everything they return from Exchange.
+type Provider interface {
+	Name() string
+	DisplayName() string
+	// AuthCodeURL returns where to send the user to sign in
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the authorization code returned to the callback
+	Exchange(ctx context.Context, code, verifier, nonce string) (*External, error)
+}
+
+// oidcProvider adapts a generic OpenID Connect provider
+type oidcProvider struct {
+	name        string
+	displayName string
+	client      *oidc.Provider
+}
+
+// NewOIDCProvider creates a provider backed by OpenID Connect discovery
+func NewOIDCProvider(name, displayName string, cfg oidc.Config) Provider {
+	return &oidcProvider{name: name, displayName: displayName, client: oidc.NewProvider(cfg)}
+}
+
+func (p *oidcProvider) Name() string        { return p.name }
+func (p *oidcProvider) DisplayName() string { return p.displayName }
+
+func (p *oidcProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	return p.client.AuthCodeURL(ctx, state, nonce, verifier)
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*External, error) {
+	claims, err := p.client.Exchange(ctx, code, verifier, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &External{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Username:      claims.PreferredUsername,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// ProvidersFromConfig creates the OpenID Connect providers listed in the configuration
+func ProvidersFromConfig(cfg config.OIDCConfig) []Provider {
+	providers := make([]Provider, 0, len(cfg.Providers))
+	for _, p := range cfg.Providers {
+		providers = append(providers, NewOIDCProvider(p.Name, p.DisplayName, oidc.Config{
+			IssuerURL:    p.IssuerURL,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  cfg.RedirectBaseURL + "/" + p.Name + "/callback",
+			Scopes:       p.Scopes,
+		}))
+	}
+	return providers
+}
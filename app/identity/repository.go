@@ -0,0 +1,65 @@
+package identity
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository interface for external identity data access
+type Repository interface {
+	Get(ctx context.Context, provider, subject string) (*Identity, error)
+	Create(ctx context.Context, identity *Identity) error
+	ListByUser(ctx context.Context, userID uint) ([]*Identity, error)
+	Delete(ctx context.Context, userID, id uint) (bool, error)
+	TouchLogin(ctx context.Context, id uint, at time.Time) error
+}
+
+// repository implementation of Repository
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new identity repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Get retrieves the identity of a provider account
+func (r *repository) Get(ctx context.Context, provider, subject string) (*Identity, error) {
+	var identity Identity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Create links a provider account to a user
+func (r *repository) Create(ctx context.Context, identity *Identity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+// ListByUser retrieves every identity linked to a user
+func (r *repository) ListByUser(ctx context.Context, userID uint) ([]*Identity, error) {
+	var identities []*Identity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// Delete unlinks one of the user's identities. It reports false when the
+// identity does not exist or belongs to another user.
+func (r *repository) Delete(ctx context.Context, userID, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&Identity{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// TouchLogin records a sign-in through the identity
+func (r *repository) TouchLogin(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&Identity{}).Where("id = ?", id).Update("last_login_at", at).Error
+}
@@ -0,0 +1,234 @@
+package identity
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/oidc"
+	"github.com/llamacto/llama-gin-kit/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// FlowCookie is the cookie that carries the flow token between the
+	// redirect to the provider and the callback
+	FlowCookie = "oidc_flow"
+	// flowPurpose separates sign-in flow tokens from other signed tokens
+	flowPurpose = "oidc_flow"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider that is not configured
+	ErrUnknownProvider = errors.New("unknown identity provider")
+	// ErrInvalidState is returned when the callback does not belong to a
+	// sign-in started by this browser, or the sign-in took too long
+	ErrInvalidState = errors.New("sign-in request is invalid or expired")
+	// ErrAlreadyLinked is returned when the provider account belongs to another user
+	ErrAlreadyLinked = errors.New("this external account is already linked to another user")
+	// ErrIdentityNotFound is returned when unlinking an unknown identity
+	ErrIdentityNotFound = errors.New("linked account not found")
+)
+
+// Options configures the identity service
+type Options struct {
+	SigningSecret string        // Signs the sign-in flow token
+	StateTTL      time.Duration // How long a user may take at the provider
+	BaseURL       string        // Public URL of the OIDC routes, used for login links
+}
+
+// Flow is a started sign-in. Token must be kept by the browser, normally in
+// an HttpOnly cookie, and handed back with the callback.
+type Flow struct {
+	AuthURL   string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// Service interface for external identities
+type Service interface {
+	// Providers lists the configured providers
+	Providers() []ProviderInfo
+	// Begin starts a sign-in with state, nonce and PKCE verifier bound to the flow token
+	Begin(ctx context.Context, provider string) (*Flow, error)
+	// Finish checks the callback against the flow token and returns the verified identity
+	Finish(ctx context.Context, provider, flowToken, state, code string) (*External, error)
+
+	// Find returns the identity linked to a provider account
+	Find(ctx context.Context, provider, subject string) (*Identity, error)
+	// Link attaches a provider account to a user
+	Link(ctx context.Context, userID uint, external *External) (*Identity, error)
+	// RecordLogin stamps a sign-in through the identity
+	RecordLogin(ctx context.Context, identity *Identity)
+
+	List(ctx context.Context, userID uint) ([]*Identity, error)
+	Unlink(ctx context.Context, userID, id uint) error
+}
+
+// service implementation of Service
+type service struct {
+	repo      Repository
+	providers map[string]Provider
+	order     []string
+	opts      Options
+	now       func() time.Time
+}
+
+// NewService creates a new identity service
+func NewService(repo Repository, providers []Provider, opts Options) Service {
+	if opts.StateTTL <= 0 {
+		opts.StateTTL = 10 * time.Minute
+	}
+	s := &service{repo: repo, providers: make(map[string]Provider, len(providers)), opts: opts, now: time.Now}
+	for _, p := range providers {
+		s.providers[p.Name()] = p
+		s.order = append(s.order, p.Name())
+	}
+	return s
+}
+
+// Providers lists the configured providers
+func (s *service) Providers() []ProviderInfo {
+	infos := make([]ProviderInfo, 0, len(s.order))
+	for _, name := range s.order {
+		infos = append(infos, ProviderInfo{
+			Name:        name,
+			DisplayName: s.providers[name].DisplayName(),
+			LoginURL:    s.opts.BaseURL + "/" + name,
+		})
+	}
+	return infos
+}
+
+// flowClaims is the signed content of a flow token
+type flowClaims struct {
+	Provider  string `json:"p"`
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	Purpose   string `json:"purpose"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Begin starts a sign-in with state, nonce and PKCE verifier bound to the flow token
+func (s *service) Begin(ctx context.Context, provider string) (*Flow, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	claims := flowClaims{Provider: provider, Purpose: flowPurpose}
+	for _, v := range []*string{&claims.State, &claims.Nonce, &claims.Verifier} {
+		random, err := oidc.NewState()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate sign-in state: %w", err)
+		}
+		*v = random
+	}
+	expiresAt := s.now().Add(s.opts.StateTTL)
+	claims.ExpiresAt = expiresAt.Unix()
+
+	authURL, err := p.AuthCodeURL(ctx, claims.State, claims.Nonce, claims.Verifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sign-in URL: %w", err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Flow{
+		AuthURL:   authURL,
+		Token:     utils.SignToken([]byte(s.opts.SigningSecret), payload),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// Finish checks the callback against the flow token and returns the verified identity
+func (s *service) Finish(ctx context.Context, provider, flowToken, state, code string) (*External, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	payload, err := utils.VerifySignedToken([]byte(s.opts.SigningSecret), flowToken)
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	var claims flowClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidState
+	}
+	if claims.Purpose != flowPurpose || claims.Provider != provider || s.now().Unix() >= claims.ExpiresAt ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return nil, ErrInvalidState
+	}
+
+	external, err := p.Exchange(ctx, code, claims.Verifier, claims.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	external.Provider = provider
+	return external, nil
+}
+
+// Find returns the identity linked to a provider account
+func (s *service) Find(ctx context.Context, provider, subject string) (*Identity, error) {
+	return s.repo.Get(ctx, provider, subject)
+}
+
+// Link attaches a provider account to a user
+func (s *service) Link(ctx context.Context, userID uint, external *External) (*Identity, error) {
+	existing, err := s.repo.Get(ctx, external.Provider, external.Subject)
+	if err == nil {
+		if existing.UserID != userID {
+			return nil, ErrAlreadyLinked
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load identity: %w", err)
+	}
+
+	identity := &Identity{
+		UserID:   userID,
+		Provider: external.Provider,
+		Subject:  external.Subject,
+		Email:    external.Email,
+	}
+	if err := s.repo.Create(ctx, identity); err != nil {
+		return nil, fmt.Errorf("failed to link identity: %w", err)
+	}
+	logger.Info("Linked %s identity to user %d", external.Provider, userID)
+	return identity, nil
+}
+
+// RecordLogin stamps a sign-in through the identity
+func (s *service) RecordLogin(ctx context.Context, identity *Identity) {
+	now := s.now()
+	if err := s.repo.TouchLogin(ctx, identity.ID, now); err != nil {
+		logger.Error("Failed to record identity login:", err)
+		return
+	}
+	identity.LastLoginAt = &now
+}
+
+// List returns the identities linked to a user
+func (s *service) List(ctx context.Context, userID uint) ([]*Identity, error) {
+	return s.repo.ListByUser(ctx, userID)
+}
+
+// Unlink removes one of the user's identities
+func (s *service) Unlink(ctx context.Context, userID, id uint) error {
+	deleted, err := s.repo.Delete(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+	if !deleted {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
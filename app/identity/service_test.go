@@ -0,0 +1,260 @@
+package identity
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/llamacto/llama-gin-kit/pkg/oidc"
+	"gorm.io/gorm"
+)
+
+// stubIdP is a stand-in OpenID provider. Its authorization endpoint signs
+// the user in immediately and redirects back with a code; the token
+// endpoint checks the PKCE verifier and returns an ES256 ID token.
+type stubIdP struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]url.Values
+}
+
+func newStubIdP(t *testing.T) *stubIdP {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp := &stubIdP{key: key, codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "EC", "kid": "k1", "crv": "P-256",
+			"x": base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y": base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		code := "code-" + q.Get("state")
+		idp.mu.Lock()
+		idp.codes[code] = q
+		idp.mu.Unlock()
+		http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		idp.mu.Lock()
+		authz, ok := idp.codes[r.PostForm.Get("code")]
+		idp.mu.Unlock()
+		if !ok || oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != authz.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":            idp.server.URL,
+			"sub":            "employee-7",
+			"aud":            authz.Get("client_id"),
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          authz.Get("nonce"),
+			"email":          "jane@example.com",
+			"email_verified": true,
+		})
+		token.Header["kid"] = "k1"
+		signed, _ := token.SignedString(key)
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": signed})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+// signIn follows the authorization URL and returns the callback parameters
+func (idp *stubIdP) signIn(t *testing.T, authURL string) (state, code string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorization request failed: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("expected a redirect to the callback: %v", err)
+	}
+	return callback.Query().Get("state"), callback.Query().Get("code")
+}
+
+// memoryRepository is an in-memory Repository used by the tests
+type memoryRepository struct {
+	mu         sync.Mutex
+	nextID     uint
+	identities []*Identity
+}
+
+func (r *memoryRepository) Get(ctx context.Context, provider, subject string) (*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			copied := *i
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) Create(ctx context.Context, identity *Identity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	identity.ID = r.nextID
+	copied := *identity
+	r.identities = append(r.identities, &copied)
+	return nil
+}
+
+func (r *memoryRepository) ListByUser(ctx context.Context, userID uint) ([]*Identity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*Identity
+	for _, i := range r.identities {
+		if i.UserID == userID {
+			out = append(out, i)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) Delete(ctx context.Context, userID, id uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for n, i := range r.identities {
+		if i.ID == id && i.UserID == userID {
+			r.identities = append(r.identities[:n], r.identities[n+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRepository) TouchLogin(ctx context.Context, id uint, at time.Time) error {
+	return nil
+}
+
+func newTestService(idp *stubIdP) Service {
+	provider := NewOIDCProvider("company", "Company SSO", oidc.Config{
+		IssuerURL:   idp.server.URL,
+		ClientID:    "llama",
+		RedirectURL: "http://app.test/v1/auth/oidc/company/callback",
+	})
+	return NewService(&memoryRepository{}, []Provider{provider}, Options{SigningSecret: "test-secret"})
+}
+
+func TestService_BeginFinish(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t)
+	svc := newTestService(idp)
+
+	flow, err := svc.Begin(ctx, "company")
+	if err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	state, code := idp.signIn(t, flow.AuthURL)
+
+	external, err := svc.Finish(ctx, "company", flow.Token, state, code)
+	if err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	if external.Provider != "company" || external.Subject != "employee-7" || !external.EmailVerified {
+		t.Errorf("unexpected identity %+v", external)
+	}
+}
+
+func TestService_FinishRejectsForeignState(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t)
+	svc := newTestService(idp)
+
+	// A callback from a sign-in started in another browser
+	mine, _ := svc.Begin(ctx, "company")
+	theirs, _ := svc.Begin(ctx, "company")
+	state, code := idp.signIn(t, theirs.AuthURL)
+
+	if _, err := svc.Finish(ctx, "company", mine.Token, state, code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected ErrInvalidState, got %v", err)
+	}
+	if _, err := svc.Finish(ctx, "company", "", state, code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected ErrInvalidState without a flow token, got %v", err)
+	}
+}
+
+func TestService_FinishRejectsExpiredFlow(t *testing.T) {
+	ctx := context.Background()
+	idp := newStubIdP(t)
+	svc := newTestService(idp).(*service)
+
+	flow, _ := svc.Begin(ctx, "company")
+	state, code := idp.signIn(t, flow.AuthURL)
+	svc.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	if _, err := svc.Finish(ctx, "company", flow.Token, state, code); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("expected ErrInvalidState, got %v", err)
+	}
+}
+
+func TestService_UnknownProvider(t *testing.T) {
+	svc := newTestService(newStubIdP(t))
+	if _, err := svc.Begin(context.Background(), "other"); !errors.Is(err, ErrUnknownProvider) {
+		t.Fatalf("expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestService_LinkAndUnlink(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(newStubIdP(t))
+	external := &External{Provider: "company", Subject: "employee-7", Email: "jane@example.com"}
+
+	linked, err := svc.Link(ctx, 1, external)
+	if err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if again, err := svc.Link(ctx, 1, external); err != nil || again.ID != linked.ID {
+		t.Errorf("expected linking twice to be idempotent, got %v %v", again, err)
+	}
+	if _, err := svc.Link(ctx, 2, external); !errors.Is(err, ErrAlreadyLinked) {
+		t.Errorf("expected ErrAlreadyLinked for another user, got %v", err)
+	}
+
+	if err := svc.Unlink(ctx, 2, linked.ID); !errors.Is(err, ErrIdentityNotFound) {
+		t.Errorf("expected another user's unlink to fail, got %v", err)
+	}
+	if err := svc.Unlink(ctx, 1, linked.ID); err != nil {
+		t.Fatalf("Unlink failed: %v", err)
+	}
+	if _, err := svc.Find(ctx, "company", "employee-7"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("expected the identity to be gone, got %v", err)
+	}
+}
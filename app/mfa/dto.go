@@ -0,0 +1,28 @@
+package mfa
+
+import (
+	"time"
+)
+
+// StatusResponse 两步验证状态
+type StatusResponse struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// EnrollResponse 开始绑定身份验证器的返回
+type EnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"` // 可渲染为二维码
+}
+
+// CodeRequest 提交验证码请求；Code 可以是动态验证码或恢复码
+type CodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RecoveryCodesResponse 恢复码仅在生成时返回一次
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,187 @@
+package mfa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/response"
+)
+
+// Handler handles two-factor enrolment and management requests
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new two-factor handler
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Status 查询两步验证状态
+// @Summary 查询两步验证状态
+// @Tags 两步验证
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} StatusResponse
+// @Router /users/mfa [get]
+func (h *Handler) Status(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	status, err := h.service.Status(c.Request.Context(), principal.UserID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to load two-factor status", err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// Enroll 开始绑定身份验证器
+// @Summary 开始绑定身份验证器
+// @Description 生成新的 TOTP 密钥和 otpauth URI，需调用确认接口后才会生效
+// @Tags 两步验证
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} EnrollResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /users/mfa/totp [post]
+func (h *Handler) Enroll(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	account := principal.Username
+	if account == "" {
+		account = fmt.Sprintf("user-%d", principal.UserID)
+	}
+	enrollment, err := h.service.Enroll(c.Request.Context(), principal.UserID, account)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// Confirm 确认绑定身份验证器
+// @Summary 确认绑定身份验证器
+// @Description 提交身份验证器中的第一个验证码以启用两步验证，返回一次性恢复码
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body CodeRequest true "验证码"
+// @Success 200 {object} RecoveryCodesResponse
+// @Router /users/mfa/totp/confirm [post]
+func (h *Handler) Confirm(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) {
+		codes, err := h.service.Confirm(c.Request.Context(), userID, code)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// Disable 关闭两步验证
+// @Summary 关闭两步验证
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body CodeRequest true "验证码或恢复码"
+// @Success 200 {string} string "两步验证已关闭"
+// @Router /users/mfa/totp/disable [post]
+func (h *Handler) Disable(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) {
+		if err := h.service.Disable(c.Request.Context(), userID, code); err != nil {
+			handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+// @Summary 重新生成恢复码
+// @Description 旧的恢复码全部失效
+// @Tags 两步验证
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Param body body CodeRequest true "身份验证器中的验证码"
+// @Success 200 {object} RecoveryCodesResponse
+// @Router /users/mfa/recovery-codes [post]
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	h.withCode(c, func(userID uint, code string) {
+		codes, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), userID, code)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	})
+}
+
+// ForceDisable 管理员关闭用户的两步验证
+// @Summary 管理员关闭用户的两步验证
+// @Tags 管理
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "两步验证已关闭"
+// @Router /admin/users/{id}/mfa [delete]
+func (h *Handler) ForceDisable(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID", err)
+		return
+	}
+
+	if err := h.service.ForceDisable(c.Request.Context(), principal.UserID, uint(userID)); err != nil {
+		handleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+func (h *Handler) withCode(c *gin.Context, fn func(userID uint, code string)) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	var req CodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+	fn(principal.UserID, req.Code)
+}
+
+func handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidCode):
+		response.BadRequest(c, err.Error(), err)
+	case errors.Is(err, ErrAlreadyEnabled):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrNotEnabled), errors.Is(err, ErrEnrollmentNotStarted):
+		response.NotFound(c, err.Error(), err)
+	default:
+		response.InternalServerError(c, "Failed to process two-factor request", err)
+	}
+}
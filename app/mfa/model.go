@@ -0,0 +1,41 @@
+package mfa
+
+import (
+	"time"
+)
+
+// TOTPFactor is a user's authenticator app enrolment. It is created when
+// enrolment starts and only protects logins once EnabledAt is set.
+type TOTPFactor struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	Secret       string     `gorm:"size:64;not null" json:"-"`   // Base32 shared secret
+	EnabledAt    *time.Time `json:"enabled_at"`                  // Set once the first code is confirmed
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // Highest accepted time step, blocks code replay
+}
+
+// TableName specifies the database table name
+func (TOTPFactor) TableName() string {
+	return "mfa_totp_factors"
+}
+
+// IsEnabled reports whether the factor is required at login.
+func (f *TOTPFactor) IsEnabled() bool {
+	return f.EnabledAt != nil
+}
+
+// RecoveryCode is a single-use backup code for when the authenticator is lost
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null;index" json:"-"` // SHA-256 of the normalized code
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// TableName specifies the database table name
+func (RecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
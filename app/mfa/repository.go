@@ -0,0 +1,98 @@
+package mfa
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository interface for two-factor data access
+type Repository interface {
+	GetFactor(ctx context.Context, userID uint) (*TOTPFactor, error)
+	SaveFactor(ctx context.Context, factor *TOTPFactor) error
+	AdvanceStep(ctx context.Context, factorID uint, step int64) (bool, error)
+	DeleteFactor(ctx context.Context, userID uint) error
+	ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []*RecoveryCode) error
+	UseRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error)
+	CountRecoveryCodes(ctx context.Context, userID uint) (int64, error)
+}
+
+// repository implementation of Repository
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new two-factor repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// GetFactor retrieves the user's TOTP factor
+func (r *repository) GetFactor(ctx context.Context, userID uint) (*TOTPFactor, error) {
+	var factor TOTPFactor
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&factor).Error; err != nil {
+		return nil, err
+	}
+	return &factor, nil
+}
+
+// SaveFactor creates or updates a TOTP factor
+func (r *repository) SaveFactor(ctx context.Context, factor *TOTPFactor) error {
+	return r.db.WithContext(ctx).Save(factor).Error
+}
+
+// AdvanceStep records an accepted time step. It reports false when the step
+// (or a later one) was already used, i.e. the code is being replayed.
+func (r *repository) AdvanceStep(ctx context.Context, factorID uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&TOTPFactor{}).
+		Where("id = ? AND last_used_step < ?", factorID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteFactor removes the user's TOTP factor and recovery codes
+func (r *repository) DeleteFactor(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&TOTPFactor{}).Error
+	})
+}
+
+// ReplaceRecoveryCodes swaps all of the user's recovery codes for new ones
+func (r *repository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []*RecoveryCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks a matching unused recovery code as used
+func (r *repository) UseRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (r *repository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,361 @@
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/totp"
+	"github.com/llamacto/llama-gin-kit/pkg/utils"
+	"gorm.io/gorm"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes are issued at a time
+	recoveryCodeCount = 10
+	// codeSkew accepts codes from one period either side of now for clock drift
+	codeSkew = 1
+	// challengePurpose separates login challenges from other signed tokens
+	challengePurpose = "mfa_login"
+)
+
+var (
+	// ErrNotEnabled is returned when the user has no active second factor
+	ErrNotEnabled = errors.New("two-factor authentication is not enabled")
+	// ErrAlreadyEnabled is returned when enrolling a user who already has a factor
+	ErrAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrEnrollmentNotStarted is returned when confirming without enrolling first
+	ErrEnrollmentNotStarted = errors.New("two-factor enrolment has not been started")
+	// ErrInvalidCode is returned for a wrong, expired or replayed code
+	ErrInvalidCode = errors.New("invalid verification code")
+	// ErrInvalidChallenge is returned for a tampered or expired login challenge
+	ErrInvalidChallenge = errors.New("login challenge is invalid or expired")
+)
+
+// Options configures the two-factor service
+type Options struct {
+	Issuer        string        // Shown in authenticator apps
+	SigningSecret string        // Signs login challenges
+	ChallengeTTL  time.Duration // How long a password-verified login waits for its code
+}
+
+// Service interface for two-factor authentication
+type Service interface {
+	Status(ctx context.Context, userID uint) (*StatusResponse, error)
+	IsEnabled(ctx context.Context, userID uint) (bool, error)
+
+	// Enroll starts (or restarts) enrolment and returns the new secret
+	Enroll(ctx context.Context, userID uint, account string) (*EnrollResponse, error)
+	// Confirm activates the factor with a first code and returns recovery codes
+	Confirm(ctx context.Context, userID uint, code string) ([]string, error)
+	// Disable removes the factor after checking a code
+	Disable(ctx context.Context, userID uint, code string) error
+	// RegenerateRecoveryCodes invalidates old recovery codes after checking a code
+	RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error)
+	// ForceDisable removes a user's factor on behalf of an administrator
+	ForceDisable(ctx context.Context, adminID, userID uint) error
+	// Purge deletes all of a user's two-factor data, enrolled or not
+	Purge(ctx context.Context, userID uint) error
+
+	// Verify checks a TOTP or recovery code for an enabled factor
+	Verify(ctx context.Context, userID uint, code string) error
+	// NewChallenge returns the short-lived token handed out after the password check
+	NewChallenge(userID uint) (string, time.Time, error)
+	// ParseChallenge returns the user a login challenge was issued to
+	ParseChallenge(challenge string) (uint, error)
+}
+
+// service implementation of Service
+type service struct {
+	repo Repository
+	opts Options
+	now  func() time.Time
+}
+
+// NewService creates a new two-factor service
+func NewService(repo Repository, opts Options) Service {
+	if opts.ChallengeTTL <= 0 {
+		opts.ChallengeTTL = 5 * time.Minute
+	}
+	return &service{repo: repo, opts: opts, now: time.Now}
+}
+
+// Status returns whether two-factor authentication is enabled for the user
+func (s *service) Status(ctx context.Context, userID uint) (*StatusResponse, error) {
+	factor, err := s.enabledFactor(ctx, userID)
+	if errors.Is(err, ErrNotEnabled) {
+		return &StatusResponse{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	remaining, err := s.repo.CountRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return &StatusResponse{
+		Enabled:                true,
+		EnabledAt:              factor.EnabledAt,
+		RecoveryCodesRemaining: int(remaining),
+	}, nil
+}
+
+// IsEnabled reports whether the user must pass a second factor at login
+func (s *service) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	_, err := s.enabledFactor(ctx, userID)
+	if errors.Is(err, ErrNotEnabled) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Enroll starts (or restarts) enrolment and returns the new secret
+func (s *service) Enroll(ctx context.Context, userID uint, account string) (*EnrollResponse, error) {
+	factor, err := s.repo.GetFactor(ctx, userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load factor: %w", err)
+	}
+	if factor != nil && factor.IsEnabled() {
+		return nil, ErrAlreadyEnabled
+	}
+	if factor == nil {
+		factor = &TOTPFactor{UserID: userID}
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate secret: %w", err)
+	}
+	factor.Secret = secret
+	factor.LastUsedStep = 0
+	if err := s.repo.SaveFactor(ctx, factor); err != nil {
+		return nil, fmt.Errorf("failed to save factor: %w", err)
+	}
+
+	return &EnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: totp.URI(s.opts.Issuer, account, secret),
+	}, nil
+}
+
+// Confirm activates the factor with a first code and returns recovery codes
+func (s *service) Confirm(ctx context.Context, userID uint, code string) ([]string, error) {
+	factor, err := s.repo.GetFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEnrollmentNotStarted
+		}
+		return nil, fmt.Errorf("failed to load factor: %w", err)
+	}
+	if factor.IsEnabled() {
+		return nil, ErrAlreadyEnabled
+	}
+	if err := s.verifyTOTP(ctx, factor, code); err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	factor.EnabledAt = &now
+	if err := s.repo.SaveFactor(ctx, factor); err != nil {
+		return nil, fmt.Errorf("failed to enable factor: %w", err)
+	}
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// Disable removes the factor after checking a code
+func (s *service) Disable(ctx context.Context, userID uint, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteFactor(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates old recovery codes after checking a code
+func (s *service) RegenerateRecoveryCodes(ctx context.Context, userID uint, code string) ([]string, error) {
+	factor, err := s.enabledFactor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// Only an authenticator code is accepted so a leaked recovery code cannot mint new ones
+	if err := s.verifyTOTP(ctx, factor, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(ctx, userID)
+}
+
+// ForceDisable removes a user's factor on behalf of an administrator
+func (s *service) ForceDisable(ctx context.Context, adminID, userID uint) error {
+	if _, err := s.enabledFactor(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteFactor(ctx, userID); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	logger.Warn("Two-factor authentication for user %d disabled by administrator %d", userID, adminID)
+	return nil
+}
+
+// Purge deletes all of a user's two-factor data, enrolled or not
+func (s *service) Purge(ctx context.Context, userID uint) error {
+	if err := s.repo.DeleteFactor(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete two-factor data: %w", err)
+	}
+	return nil
+}
+
+// Verify checks a TOTP or recovery code for an enabled factor
+func (s *service) Verify(ctx context.Context, userID uint, code string) error {
+	factor, err := s.enabledFactor(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if isTOTPCode(code) {
+		return s.verifyTOTP(ctx, factor, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, utils.HashToken(normalizeRecoveryCode(code)), s.now())
+	if err != nil {
+		return fmt.Errorf("failed to check recovery code: %w", err)
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	logger.Info("Recovery code used by user %d", userID)
+	return nil
+}
+
+// challengeClaims is the signed content of a login challenge
+type challengeClaims struct {
+	UserID    uint   `json:"uid"`
+	Purpose   string `json:"purpose"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// NewChallenge returns the short-lived token handed out after the password check
+func (s *service) NewChallenge(userID uint) (string, time.Time, error) {
+	expiresAt := s.now().Add(s.opts.ChallengeTTL)
+	payload, err := json.Marshal(challengeClaims{
+		UserID:    userID,
+		Purpose:   challengePurpose,
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return utils.SignToken([]byte(s.opts.SigningSecret), payload), expiresAt, nil
+}
+
+// ParseChallenge returns the user a login challenge was issued to
+func (s *service) ParseChallenge(challenge string) (uint, error) {
+	payload, err := utils.VerifySignedToken([]byte(s.opts.SigningSecret), challenge)
+	if err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	var claims challengeClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return 0, ErrInvalidChallenge
+	}
+	if claims.Purpose != challengePurpose || s.now().Unix() >= claims.ExpiresAt {
+		return 0, ErrInvalidChallenge
+	}
+	return claims.UserID, nil
+}
+
+func (s *service) enabledFactor(ctx context.Context, userID uint) (*TOTPFactor, error) {
+	factor, err := s.repo.GetFactor(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnabled
+		}
+		return nil, fmt.Errorf("failed to load factor: %w", err)
+	}
+	if !factor.IsEnabled() {
+		return nil, ErrNotEnabled
+	}
+	return factor, nil
+}
+
+func (s *service) verifyTOTP(ctx context.Context, factor *TOTPFactor, code string) error {
+	step, ok := totp.Validate(factor.Secret, code, s.now(), codeSkew)
+	if !ok {
+		return ErrInvalidCode
+	}
+	advanced, err := s.repo.AdvanceStep(ctx, factor.ID, step)
+	if err != nil {
+		return fmt.Errorf("failed to record code use: %w", err)
+	}
+	if !advanced {
+		return ErrInvalidCode
+	}
+	factor.LastUsedStep = step
+	return nil
+}
+
+func (s *service) issueRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	records := make([]*RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		plain = append(plain, code)
+		records = append(records, &RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, records); err != nil {
+		return nil, fmt.Errorf("failed to save recovery codes: %w", err)
+	}
+	return plain, nil
+}
+
+// recoveryAlphabet omits characters that are easily confused when read aloud
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// newRecoveryCode returns a code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	max := big.NewInt(int64(len(recoveryAlphabet)))
+	out := make([]byte, 0, 11)
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			out = append(out, '-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, recoveryAlphabet[n.Int64()])
+	}
+	return string(out), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totp.Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,212 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/totp"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository used by the tests
+type memoryRepository struct {
+	mu      sync.Mutex
+	nextID  uint
+	factors map[uint]*TOTPFactor
+	codes   map[uint][]*RecoveryCode
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		factors: make(map[uint]*TOTPFactor),
+		codes:   make(map[uint][]*RecoveryCode),
+	}
+}
+
+func (r *memoryRepository) GetFactor(ctx context.Context, userID uint) (*TOTPFactor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.factors[userID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *f
+	return &copied, nil
+}
+
+func (r *memoryRepository) SaveFactor(ctx context.Context, factor *TOTPFactor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if factor.ID == 0 {
+		r.nextID++
+		factor.ID = r.nextID
+	}
+	copied := *factor
+	r.factors[factor.UserID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) AdvanceStep(ctx context.Context, factorID uint, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, f := range r.factors {
+		if f.ID == factorID && f.LastUsedStep < step {
+			f.LastUsedStep = step
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRepository) DeleteFactor(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.factors, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *memoryRepository) ReplaceRecoveryCodes(ctx context.Context, userID uint, codes []*RecoveryCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[userID] = codes
+	return nil
+}
+
+func (r *memoryRepository) UseRecoveryCode(ctx context.Context, userID uint, hash string, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.codes[userID] {
+		if c.CodeHash == hash && c.UsedAt == nil {
+			c.UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryRepository) CountRecoveryCodes(ctx context.Context, userID uint) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var count int64
+	for _, c := range r.codes[userID] {
+		if c.UsedAt == nil {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func newTestService(now *time.Time) *service {
+	svc := NewService(newMemoryRepository(), Options{
+		Issuer:        "Test",
+		SigningSecret: "test-secret",
+		ChallengeTTL:  time.Minute,
+	}).(*service)
+	svc.now = func() time.Time { return *now }
+	return svc
+}
+
+// enable enrolls and confirms a factor, returning its secret and recovery codes
+func enable(t *testing.T, svc *service, now time.Time, userID uint) (string, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := svc.Enroll(ctx, userID, "alice")
+	if err != nil {
+		t.Fatalf("Enroll failed: %v", err)
+	}
+	code, _ := totp.Code(enrollment.Secret, now)
+	recovery, err := svc.Confirm(ctx, userID, code)
+	if err != nil {
+		t.Fatalf("Confirm failed: %v", err)
+	}
+	return enrollment.Secret, recovery
+}
+
+func TestService_EnrollAndConfirm(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc := newTestService(&now)
+	ctx := context.Background()
+
+	if err := svc.Verify(ctx, 1, "123456"); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("Expected ErrNotEnabled before enrolment, got %v", err)
+	}
+
+	_, recovery := enable(t, svc, now, 1)
+	if len(recovery) != recoveryCodeCount {
+		t.Errorf("Expected %d recovery codes, got %d", recoveryCodeCount, len(recovery))
+	}
+
+	enabled, err := svc.IsEnabled(ctx, 1)
+	if err != nil || !enabled {
+		t.Fatalf("Expected factor to be enabled, got %v %v", enabled, err)
+	}
+	if _, err := svc.Enroll(ctx, 1, "alice"); !errors.Is(err, ErrAlreadyEnabled) {
+		t.Errorf("Expected ErrAlreadyEnabled, got %v", err)
+	}
+}
+
+func TestService_RejectsReplayedCode(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc := newTestService(&now)
+	ctx := context.Background()
+	secret, _ := enable(t, svc, now, 1)
+
+	// The confirmation code has been spent; the next period's code works once
+	now = now.Add(totp.Period * time.Second)
+	code, _ := totp.Code(secret, now)
+	if err := svc.Verify(ctx, 1, code); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if err := svc.Verify(ctx, 1, code); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected replayed code to be rejected, got %v", err)
+	}
+}
+
+func TestService_RecoveryCodeSingleUse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc := newTestService(&now)
+	ctx := context.Background()
+	_, recovery := enable(t, svc, now, 1)
+
+	if err := svc.Verify(ctx, 1, recovery[0]); err != nil {
+		t.Fatalf("Recovery code rejected: %v", err)
+	}
+	if err := svc.Verify(ctx, 1, recovery[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Expected used recovery code to be rejected, got %v", err)
+	}
+
+	status, err := svc.Status(ctx, 1)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Errorf("Expected %d remaining codes, got %d", recoveryCodeCount-1, status.RecoveryCodesRemaining)
+	}
+}
+
+func TestService_ChallengeExpires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	svc := newTestService(&now)
+
+	challenge, _, err := svc.NewChallenge(7)
+	if err != nil {
+		t.Fatalf("NewChallenge failed: %v", err)
+	}
+	userID, err := svc.ParseChallenge(challenge)
+	if err != nil || userID != 7 {
+		t.Fatalf("Expected user 7, got %d %v", userID, err)
+	}
+
+	if _, err := svc.ParseChallenge(challenge + "x"); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected tampered challenge to be rejected, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := svc.ParseChallenge(challenge); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("Expected expired challenge to be rejected, got %v", err)
+	}
+}
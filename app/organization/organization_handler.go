@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
 )
 
 // Handler struct for organization operations
@@ -26,8 +27,8 @@ func (h *Handler) CreateOrganization(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -41,7 +42,7 @@ func (h *Handler) CreateOrganization(c *gin.Context) {
 		Status:      1, // Active
 	}
 
-	if err := h.service.CreateOrganization(c.Request.Context(), org, userID.(uint)); err != nil {
+	if err := h.service.CreateOrganization(c.Request.Context(), org, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -215,13 +216,13 @@ func (h *Handler) DeleteOrganization(c *gin.Context) {
 
 // GetMyOrganizations gets organizations for the current user
 func (h *Handler) GetMyOrganizations(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	orgs, err := h.service.GetUserOrganizations(c.Request.Context(), userID.(uint))
+	orgs, err := h.service.GetUserOrganizations(c.Request.Context(), userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -0,0 +1,11 @@
+package privacy
+
+import "time"
+
+// AccountDeletionResponse is returned when a user deletes their account
+type AccountDeletionResponse struct {
+	Message string `json:"message"`
+	// ErasureScheduledAt is when the account's personal data will be erased;
+	// until then an administrator can restore the account.
+	ErasureScheduledAt time.Time `json:"erasure_scheduled_at"`
+}
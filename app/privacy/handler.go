@@ -0,0 +1,220 @@
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/app/user"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/response"
+	"gorm.io/gorm"
+)
+
+// Handler handles data export and account deletion requests
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new privacy handler
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RequestExport 导出个人数据
+// @Summary 导出个人数据
+// @Description 在后台生成包含个人资料、组织成员关系、API 密钥信息和审计记录的 ZIP 压缩包；已有进行中的导出时直接返回该导出
+// @Tags 隐私
+// @Produce json
+// @Security Bearer
+// @Success 202 {object} DataExport
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/data-exports [post]
+func (h *Handler) RequestExport(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	export, err := h.service.RequestExport(c.Request.Context(), userID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to request data export", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, export)
+}
+
+// ListExports 查询数据导出
+// @Summary 查询数据导出
+// @Description 列出当前用户的数据导出及其状态
+// @Tags 隐私
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} DataExport
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/data-exports [get]
+func (h *Handler) ListExports(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	exports, err := h.service.ListExports(c.Request.Context(), userID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to list data exports", err)
+		return
+	}
+	c.JSON(http.StatusOK, exports)
+}
+
+// GetExport 查询数据导出状态
+// @Summary 查询数据导出状态
+// @Tags 隐私
+// @Produce json
+// @Security Bearer
+// @Param id path int true "导出ID"
+// @Success 200 {object} DataExport
+// @Failure 404 {object} response.ErrorResponse
+// @Router /users/data-exports/{id} [get]
+func (h *Handler) GetExport(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid export ID", err)
+		return
+	}
+
+	export, err := h.service.GetExport(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		handlePrivacyError(c, "Failed to get data export", err)
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// DownloadExport 下载数据导出
+// @Summary 下载数据导出
+// @Description 下载已完成的数据导出 ZIP 压缩包，过期后不可下载
+// @Tags 隐私
+// @Produce application/zip
+// @Security Bearer
+// @Param id path int true "导出ID"
+// @Success 200 {file} file
+// @Failure 404 {object} response.ErrorResponse
+// @Failure 409 {object} response.ErrorResponse
+// @Router /users/data-exports/{id}/download [get]
+func (h *Handler) DownloadExport(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid export ID", err)
+		return
+	}
+
+	export, err := h.service.DownloadExport(c.Request.Context(), userID, uint(id))
+	if err != nil {
+		handlePrivacyError(c, "Failed to download data export", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="data-export-%d.zip"`, export.ID))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", export.Archive)
+}
+
+// DeleteAccount 删除账户
+// @Summary 删除账户
+// @Description 立即删除当前用户的账户并注销所有会话；宽限期结束后擦除个人数据，期间管理员可恢复账户
+// @Tags 用户
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} AccountDeletionResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/account [delete]
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	request, err := h.service.DeleteAccount(c.Request.Context(), userID, c.ClientIP())
+	if err != nil {
+		response.InternalServerError(c, "Failed to delete account", err)
+		return
+	}
+	c.JSON(http.StatusOK, AccountDeletionResponse{
+		Message:            "账户已删除",
+		ErasureScheduledAt: request.ScheduledAt,
+	})
+}
+
+// ListErasures 查询待擦除账户
+// @Summary 查询待擦除账户
+// @Description 管理员列出已删除、尚未擦除个人数据的账户
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} ErasureRequest
+// @Router /admin/erasures [get]
+func (h *Handler) ListErasures(c *gin.Context) {
+	requests, err := h.service.ListPendingErasures(c.Request.Context())
+	if err != nil {
+		response.InternalServerError(c, "Failed to list erasures", err)
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// RestoreAccount 恢复已删除账户
+// @Summary 恢复已删除账户
+// @Description 管理员在宽限期内取消数据擦除并恢复账户，操作写入审计记录
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "账户已恢复"
+// @Failure 404 {object} response.ErrorResponse
+// @Router /admin/users/{id}/restore [post]
+func (h *Handler) RestoreAccount(c *gin.Context) {
+	adminID, ok := auth.UserID(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID", err)
+		return
+	}
+
+	actor := user.AdminActor{ID: adminID, IP: c.ClientIP()}
+	if err := h.service.RestoreAccount(c.Request.Context(), actor, uint(id)); err != nil {
+		handlePrivacyError(c, "Failed to restore account", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "账户已恢复"})
+}
+
+func handlePrivacyError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, ErrExportNotFound), errors.Is(err, ErrErasureNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		response.NotFound(c, err.Error(), err)
+	case errors.Is(err, ErrExportNotReady), errors.Is(err, ErrExportExpired):
+		response.Error(c, http.StatusConflict, err.Error())
+	default:
+		response.InternalServerError(c, message, err)
+	}
+}
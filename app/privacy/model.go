@@ -0,0 +1,104 @@
+package privacy
+
+import (
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/token"
+	"github.com/llamacto/llama-gin-kit/app/user"
+)
+
+// Data export states
+const (
+	ExportPending   = "pending"
+	ExportRunning   = "running"
+	ExportCompleted = "completed"
+	ExportFailed    = "failed"
+)
+
+// DataExport is a user's request for a copy of their personal data. The
+// archive is built in the background and kept until ExpiresAt.
+type DataExport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	Error       string     `gorm:"size:500" json:"error,omitempty"`
+	Size        int64      `json:"size"`
+	Archive     []byte     `json:"-"` // ZIP file, cleared once expired
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// TableName specifies the database table name
+func (DataExport) TableName() string {
+	return "data_exports"
+}
+
+// ErasureRequest schedules the erasure of a deleted account's personal data
+// once its grace period ends. Until then an administrator can restore the account.
+type ErasureRequest struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      uint       `gorm:"not null;uniqueIndex" json:"user_id"`
+	ScheduledAt time.Time  `gorm:"not null;index" json:"scheduled_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	IP          string     `gorm:"size:45" json:"ip"`
+}
+
+// TableName specifies the database table name
+func (ErasureRequest) TableName() string {
+	return "erasure_requests"
+}
+
+// Pending reports whether the erasure is still to be carried out
+func (e *ErasureRequest) Pending() bool {
+	return e.CompletedAt == nil && e.CancelledAt == nil
+}
+
+// UserData is the personal data of one user, written to the export archive
+// one section per file.
+type UserData struct {
+	Profile     *user.User            `json:"profile"`
+	Memberships []MembershipRecord    `json:"memberships"`
+	Invitations []InvitationRecord    `json:"invitations"`
+	APIKeys     []APIKeyRecord        `json:"api_keys"`
+	Sessions    []*token.Session      `json:"sessions"`
+	AuditLogs   []*user.AdminAuditLog `json:"audit_logs"`
+}
+
+// MembershipRecord is an organization membership in a data export
+type MembershipRecord struct {
+	OrganizationID   uint      `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	TeamID           *uint     `json:"team_id"`
+	Status           int       `json:"status"`
+	JoinedAt         time.Time `json:"joined_at"`
+	InvitedBy        uint      `json:"invited_by"`
+}
+
+// InvitationRecord is an invitation sent to the user's email address
+type InvitationRecord struct {
+	ID             uint      `json:"id"`
+	OrganizationID uint      `json:"organization_id"`
+	TeamID         *uint     `json:"team_id"`
+	RoleID         uint      `json:"role_id"`
+	InvitedBy      uint      `json:"invited_by"`
+	Status         int       `json:"status"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// APIKeyRecord is the metadata of an API key; the key hash is never exported
+type APIKeyRecord struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
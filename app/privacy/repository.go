@@ -0,0 +1,288 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/app/invitation"
+	"github.com/llamacto/llama-gin-kit/app/member"
+	"github.com/llamacto/llama-gin-kit/app/token"
+	"github.com/llamacto/llama-gin-kit/app/user"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface for data export and erasure
+type Repository interface {
+	CreateExport(ctx context.Context, export *DataExport) error
+	GetExport(ctx context.Context, userID, id uint, withArchive bool) (*DataExport, error)
+	ListExports(ctx context.Context, userID uint) ([]*DataExport, error)
+	FindActiveExport(ctx context.Context, userID uint) (*DataExport, error)
+	ClaimExport(ctx context.Context, now, staleBefore time.Time) (*DataExport, error)
+	SaveExport(ctx context.Context, export *DataExport) error
+	PurgeExpiredExports(ctx context.Context, now time.Time) (int64, error)
+	CollectUserData(ctx context.Context, userID uint) (*UserData, error)
+
+	ScheduleErasure(ctx context.Context, request *ErasureRequest) error
+	CancelErasure(ctx context.Context, userID uint, at time.Time) (bool, error)
+	ListPendingErasures(ctx context.Context) ([]*ErasureRequest, error)
+	DueErasures(ctx context.Context, now time.Time, limit int) ([]*ErasureRequest, error)
+	EraseUserData(ctx context.Context, userID uint) error
+	CompleteErasure(ctx context.Context, id uint, at time.Time) error
+}
+
+// repository implementation of Repository
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new privacy repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// CreateExport stores a new export request
+func (r *repository) CreateExport(ctx context.Context, export *DataExport) error {
+	return r.db.WithContext(ctx).Create(export).Error
+}
+
+// GetExport retrieves one of the user's exports, loading the archive only when asked to
+func (r *repository) GetExport(ctx context.Context, userID, id uint, withArchive bool) (*DataExport, error) {
+	query := r.db.WithContext(ctx)
+	if !withArchive {
+		query = query.Omit("archive")
+	}
+	var export DataExport
+	if err := query.Where("id = ? AND user_id = ?", id, userID).First(&export).Error; err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ListExports retrieves the user's exports, newest first, without their archives
+func (r *repository) ListExports(ctx context.Context, userID uint) ([]*DataExport, error) {
+	var exports []*DataExport
+	err := r.db.WithContext(ctx).Omit("archive").
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Find(&exports).Error
+	return exports, err
+}
+
+// FindActiveExport returns the user's pending or running export, if any
+func (r *repository) FindActiveExport(ctx context.Context, userID uint) (*DataExport, error) {
+	var export DataExport
+	err := r.db.WithContext(ctx).Omit("archive").
+		Where("user_id = ? AND status IN ?", userID, []string{ExportPending, ExportRunning}).
+		First(&export).Error
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ClaimExport marks the oldest pending export as running and returns it. A
+// running export last touched before staleBefore is claimed again, so jobs
+// interrupted by a restart are picked up. It returns nil when there is no work.
+func (r *repository) ClaimExport(ctx context.Context, now, staleBefore time.Time) (*DataExport, error) {
+	claimable := r.db.WithContext(ctx).
+		Where("status = ? OR (status = ? AND updated_at < ?)", ExportPending, ExportRunning, staleBefore)
+
+	// Another instance may claim the same row first; try the next one then
+	for attempt := 0; attempt < 3; attempt++ {
+		var export DataExport
+		err := claimable.Session(&gorm.Session{}).Omit("archive").Order("id").First(&export).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result := claimable.Session(&gorm.Session{}).Model(&DataExport{}).
+			Where("id = ?", export.ID).
+			Updates(map[string]interface{}{"status": ExportRunning, "updated_at": now})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			export.Status = ExportRunning
+			export.UpdatedAt = now
+			return &export, nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveExport stores the outcome of an export
+func (r *repository) SaveExport(ctx context.Context, export *DataExport) error {
+	return r.db.WithContext(ctx).Save(export).Error
+}
+
+// PurgeExpiredExports deletes expired exports together with their archives
+func (r *repository) PurgeExpiredExports(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&DataExport{})
+	return result.RowsAffected, result.Error
+}
+
+// CollectUserData gathers everything stored about the user across modules
+func (r *repository) CollectUserData(ctx context.Context, userID uint) (*UserData, error) {
+	db := r.db.WithContext(ctx)
+	data := &UserData{}
+
+	var profile user.User
+	if err := db.Unscoped().First(&profile, userID).Error; err != nil {
+		return nil, err
+	}
+	data.Profile = &profile
+
+	if err := db.Table("organization_members AS om").
+		Select("om.organization_id, o.name AS organization_name, om.team_id, om.status, om.joined_at, om.invited_by").
+		Joins("LEFT JOIN organizations o ON o.id = om.organization_id").
+		Where("om.user_id = ? AND om.deleted_at IS NULL", userID).
+		Order("om.id").
+		Scan(&data.Memberships).Error; err != nil {
+		return nil, fmt.Errorf("failed to load memberships: %w", err)
+	}
+
+	if db.Migrator().HasTable(&invitation.Invitation{}) {
+		if err := db.Model(&invitation.Invitation{}).
+			Select("id, organization_id, team_id, role_id, invited_by, status, expires_at, created_at").
+			Where("LOWER(email) = LOWER(?) AND deleted_at IS NULL", profile.Email).
+			Order("id").
+			Scan(&data.Invitations).Error; err != nil {
+			return nil, fmt.Errorf("failed to load invitations: %w", err)
+		}
+	}
+
+	var keys []apikey.APIKey
+	if err := db.Unscoped().Where("user_id = ?", userID).Order("id").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to load api keys: %w", err)
+	}
+	for _, k := range keys {
+		record := APIKeyRecord{
+			ID:          k.ID,
+			Name:        k.Name,
+			Prefix:      k.Prefix,
+			Permissions: k.Permissions,
+			CreatedAt:   k.CreatedAt,
+			LastUsedAt:  k.LastUsedAt,
+			ExpiresAt:   k.ExpiresAt,
+		}
+		if k.DeletedAt.Valid {
+			record.RevokedAt = &k.DeletedAt.Time
+		}
+		data.APIKeys = append(data.APIKeys, record)
+	}
+
+	if err := db.Where("user_id = ?", userID).Order("created_at").Find(&data.Sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load sessions: %w", err)
+	}
+
+	if err := db.Where("user_id = ?", userID).Order("id").Find(&data.AuditLogs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load audit logs: %w", err)
+	}
+
+	return data, nil
+}
+
+// ScheduleErasure stores the erasure of a user, replacing an earlier
+// cancelled or pending one
+func (r *repository) ScheduleErasure(ctx context.Context, request *ErasureRequest) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"scheduled_at": request.ScheduledAt,
+			"ip":           request.IP,
+			"completed_at": nil,
+			"cancelled_at": nil,
+			"updated_at":   request.CreatedAt,
+		}),
+	}).Create(request).Error
+}
+
+// CancelErasure cancels a pending erasure. It reports false if the user has
+// no pending erasure.
+func (r *repository) CancelErasure(ctx context.Context, userID uint, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&ErasureRequest{}).
+		Where("user_id = ? AND completed_at IS NULL AND cancelled_at IS NULL", userID).
+		Update("cancelled_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ListPendingErasures retrieves the erasures still to be carried out, soonest first
+func (r *repository) ListPendingErasures(ctx context.Context) ([]*ErasureRequest, error) {
+	var requests []*ErasureRequest
+	err := r.db.WithContext(ctx).
+		Where("completed_at IS NULL AND cancelled_at IS NULL").
+		Order("scheduled_at").
+		Find(&requests).Error
+	return requests, err
+}
+
+// DueErasures retrieves pending erasures whose grace period has ended
+func (r *repository) DueErasures(ctx context.Context, now time.Time, limit int) ([]*ErasureRequest, error) {
+	var requests []*ErasureRequest
+	err := r.db.WithContext(ctx).
+		Where("completed_at IS NULL AND cancelled_at IS NULL AND scheduled_at <= ?", now).
+		Order("scheduled_at").
+		Limit(limit).
+		Find(&requests).Error
+	return requests, err
+}
+
+// EraseUserData removes the user's memberships, API keys and exports, and
+// anonymizes invitations sent to the user's email address. It must run
+// before the user row itself is anonymized.
+func (r *repository) EraseUserData(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var profile user.User
+		if err := tx.Unscoped().Select("id", "email").First(&profile, userID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&member.Member{}).Error; err != nil {
+			return fmt.Errorf("failed to delete memberships: %w", err)
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&apikey.APIKey{}).Error; err != nil {
+			return fmt.Errorf("failed to delete api keys: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&DataExport{}).Error; err != nil {
+			return fmt.Errorf("failed to delete data exports: %w", err)
+		}
+		// Sign-in history keeps client IPs and user agents; every token is revoked by now
+		if err := tx.Where("user_id = ?", userID).Delete(&token.RefreshToken{}).Error; err != nil {
+			return fmt.Errorf("failed to delete refresh tokens: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&token.Session{}).Error; err != nil {
+			return fmt.Errorf("failed to delete sessions: %w", err)
+		}
+
+		if tx.Migrator().HasTable(&invitation.Invitation{}) {
+			// Invitations belong to the organization, so they are kept without the address
+			if err := tx.Model(&invitation.Invitation{}).
+				Where("LOWER(email) = LOWER(?)", profile.Email).
+				Updates(map[string]interface{}{
+					"email":  gorm.Expr("CONCAT('erased-', id, '@erased.invalid')"),
+					"token":  "",
+					"status": gorm.Expr("CASE WHEN status = 0 THEN 3 ELSE status END"),
+				}).Error; err != nil {
+				return fmt.Errorf("failed to anonymize invitations: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// CompleteErasure marks an erasure as carried out
+func (r *repository) CompleteErasure(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&ErasureRequest{}).
+		Where("id = ?", id).
+		Update("completed_at", at).Error
+}
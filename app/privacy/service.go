@@ -0,0 +1,334 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/user"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrExportNotFound is returned for an unknown export or one of another user
+	ErrExportNotFound = errors.New("data export not found")
+	// ErrExportNotReady is returned when downloading an export that has not finished
+	ErrExportNotReady = errors.New("data export is not ready yet")
+	// ErrExportExpired is returned when downloading an export past its expiry
+	ErrExportExpired = errors.New("data export has expired")
+	// ErrErasureNotFound is returned when the user has no pending erasure
+	ErrErasureNotFound = errors.New("no pending erasure for this user")
+)
+
+const (
+	// staleExportAfter is how long a running export may go without finishing
+	// before another worker takes it over
+	staleExportAfter = 15 * time.Minute
+	// erasureBatchSize limits the erasures carried out per run
+	erasureBatchSize = 20
+)
+
+// Accounts is the part of the user module the privacy service builds on
+type Accounts interface {
+	DeleteAccount(userID uint) error
+	EraseAccount(ctx context.Context, userID uint) error
+	RestoreAccount(ctx context.Context, actor user.AdminActor, userID uint) error
+}
+
+// Options configures the privacy service
+type Options struct {
+	ErasureGracePeriod time.Duration // How long a deleted account can be restored
+	ExportTTL          time.Duration // How long a finished export can be downloaded
+	PollInterval       time.Duration // How often the background worker looks for work
+}
+
+// Service interface for personal data export and erasure
+type Service interface {
+	// RequestExport queues an export of the user's data, or returns the one already queued
+	RequestExport(ctx context.Context, userID uint) (*DataExport, error)
+	ListExports(ctx context.Context, userID uint) ([]*DataExport, error)
+	GetExport(ctx context.Context, userID, id uint) (*DataExport, error)
+	// DownloadExport returns a finished export including its archive
+	DownloadExport(ctx context.Context, userID, id uint) (*DataExport, error)
+
+	// DeleteAccount deletes the account now and schedules the erasure of its
+	// personal data once the grace period ends
+	DeleteAccount(ctx context.Context, userID uint, ip string) (*ErasureRequest, error)
+	ListPendingErasures(ctx context.Context) ([]*ErasureRequest, error)
+	// RestoreAccount cancels a pending erasure and restores the account
+	RestoreAccount(ctx context.Context, actor user.AdminActor, userID uint) error
+
+	// RunOnce builds queued exports, removes expired ones and carries out due erasures
+	RunOnce(ctx context.Context)
+	// Start runs RunOnce in the background until ctx is cancelled. The
+	// returned channel is closed once the worker has stopped.
+	Start(ctx context.Context) <-chan struct{}
+}
+
+// service implementation of Service
+type service struct {
+	repo     Repository
+	accounts Accounts
+	opts     Options
+	now      func() time.Time
+	wake     chan struct{}
+}
+
+// NewService creates a new privacy service
+func NewService(repo Repository, accounts Accounts, opts Options) Service {
+	if opts.ExportTTL <= 0 {
+		opts.ExportTTL = 72 * time.Hour
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Minute
+	}
+	return &service{
+		repo:     repo,
+		accounts: accounts,
+		opts:     opts,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// RequestExport queues an export of the user's data
+func (s *service) RequestExport(ctx context.Context, userID uint) (*DataExport, error) {
+	active, err := s.repo.FindActiveExport(ctx, userID)
+	if err == nil {
+		return active, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check running exports: %w", err)
+	}
+
+	export := &DataExport{UserID: userID, Status: ExportPending}
+	if err := s.repo.CreateExport(ctx, export); err != nil {
+		return nil, fmt.Errorf("failed to queue data export: %w", err)
+	}
+
+	// Let the worker pick it up now rather than on its next tick
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return export, nil
+}
+
+// ListExports lists the user's exports
+func (s *service) ListExports(ctx context.Context, userID uint) ([]*DataExport, error) {
+	return s.repo.ListExports(ctx, userID)
+}
+
+// GetExport returns the status of one of the user's exports
+func (s *service) GetExport(ctx context.Context, userID, id uint) (*DataExport, error) {
+	export, err := s.repo.GetExport(ctx, userID, id, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExportNotFound
+	}
+	return export, err
+}
+
+// DownloadExport returns a finished export including its archive
+func (s *service) DownloadExport(ctx context.Context, userID, id uint) (*DataExport, error) {
+	export, err := s.repo.GetExport(ctx, userID, id, true)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExportNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if export.Status != ExportCompleted {
+		return nil, ErrExportNotReady
+	}
+	if export.ExpiresAt != nil && !s.now().Before(*export.ExpiresAt) {
+		return nil, ErrExportExpired
+	}
+	return export, nil
+}
+
+// DeleteAccount deletes the account and schedules its erasure
+func (s *service) DeleteAccount(ctx context.Context, userID uint, ip string) (*ErasureRequest, error) {
+	now := s.now()
+	request := &ErasureRequest{
+		CreatedAt:   now,
+		UserID:      userID,
+		ScheduledAt: now.Add(s.opts.ErasureGracePeriod),
+		IP:          ip,
+	}
+	// Schedule first so a deleted account is never left without an erasure
+	if err := s.repo.ScheduleErasure(ctx, request); err != nil {
+		return nil, fmt.Errorf("failed to schedule erasure: %w", err)
+	}
+	if err := s.accounts.DeleteAccount(userID); err != nil {
+		if _, cancelErr := s.repo.CancelErasure(ctx, userID, now); cancelErr != nil {
+			logger.Error("Failed to cancel erasure after failed account deletion", cancelErr)
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// ListPendingErasures lists the erasures still to be carried out
+func (s *service) ListPendingErasures(ctx context.Context) ([]*ErasureRequest, error) {
+	return s.repo.ListPendingErasures(ctx)
+}
+
+// RestoreAccount cancels a pending erasure and restores the account
+func (s *service) RestoreAccount(ctx context.Context, actor user.AdminActor, userID uint) error {
+	cancelled, err := s.repo.CancelErasure(ctx, userID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to cancel erasure: %w", err)
+	}
+	if !cancelled {
+		return ErrErasureNotFound
+	}
+	return s.accounts.RestoreAccount(ctx, actor, userID)
+}
+
+// Start runs the background worker until ctx is cancelled and returns a
+// channel closed once it has stopped
+func (s *service) Start(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(s.opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			s.RunOnce(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+	return done
+}
+
+// RunOnce builds queued exports, removes expired ones and carries out due erasures
+func (s *service) RunOnce(ctx context.Context) {
+	for {
+		now := s.now()
+		export, err := s.repo.ClaimExport(ctx, now, now.Add(-staleExportAfter))
+		if err != nil {
+			logger.Error("Failed to claim data export", err)
+			break
+		}
+		if export == nil {
+			break
+		}
+		s.buildExport(ctx, export)
+	}
+
+	if purged, err := s.repo.PurgeExpiredExports(ctx, s.now()); err != nil {
+		logger.Error("Failed to purge expired data exports", err)
+	} else if purged > 0 {
+		logger.Info("Purged %d expired data exports", purged)
+	}
+
+	due, err := s.repo.DueErasures(ctx, s.now(), erasureBatchSize)
+	if err != nil {
+		logger.Error("Failed to load due erasures", err)
+		return
+	}
+	for _, request := range due {
+		// Failures are retried on the next run
+		if err := s.erase(ctx, request); err != nil {
+			logger.Error(fmt.Sprintf("Failed to erase user %d", request.UserID), err)
+		}
+	}
+}
+
+// buildExport collects the user's data into a ZIP archive and stores it
+func (s *service) buildExport(ctx context.Context, export *DataExport) {
+	data, err := s.repo.CollectUserData(ctx, export.UserID)
+	var archive []byte
+	if err == nil {
+		archive, err = buildArchive(data, s.now())
+	}
+
+	now := s.now()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to build data export %d", export.ID), err)
+		export.Status = ExportFailed
+		export.Error = "failed to collect account data"
+	} else {
+		expiresAt := now.Add(s.opts.ExportTTL)
+		export.Status = ExportCompleted
+		export.Archive = archive
+		export.Size = int64(len(archive))
+		export.ExpiresAt = &expiresAt
+	}
+	export.CompletedAt = &now
+
+	if err := s.repo.SaveExport(ctx, export); err != nil {
+		logger.Error(fmt.Sprintf("Failed to save data export %d", export.ID), err)
+	}
+}
+
+// erase removes the user's data from other modules first, because it is
+// found through the email address that anonymizing the account clears
+func (s *service) erase(ctx context.Context, request *ErasureRequest) error {
+	if err := s.repo.EraseUserData(ctx, request.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The account was hard-deleted by an administrator meanwhile
+			return s.repo.CompleteErasure(ctx, request.ID, s.now())
+		}
+		return err
+	}
+	if err := s.accounts.EraseAccount(ctx, request.UserID); err != nil {
+		return err
+	}
+	if err := s.repo.CompleteErasure(ctx, request.ID, s.now()); err != nil {
+		return err
+	}
+	logger.Info("Erased personal data of user %d", request.UserID)
+	return nil
+}
+
+// buildArchive writes each section of the user's data to its own JSON file
+func buildArchive(data *UserData, createdAt time.Time) ([]byte, error) {
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"memberships.json", emptyIfNil(data.Memberships)},
+		{"invitations.json", emptyIfNil(data.Invitations)},
+		{"api_keys.json", emptyIfNil(data.APIKeys)},
+		{"sessions.json", emptyIfNil(data.Sessions)},
+		{"audit_history.json", emptyIfNil(data.AuditLogs)},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: createdAt})
+		if err != nil {
+			return nil, err
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.content); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// emptyIfNil makes empty sections encode as [] rather than null
+func emptyIfNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+	return items
+}
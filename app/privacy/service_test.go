@@ -0,0 +1,330 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/user"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository used by the tests
+type memoryRepository struct {
+	mu       sync.Mutex
+	nextID   uint
+	exports  map[uint]*DataExport
+	erasures map[uint]*ErasureRequest // keyed by user ID
+	erased   map[uint]bool
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		exports:  make(map[uint]*DataExport),
+		erasures: make(map[uint]*ErasureRequest),
+		erased:   make(map[uint]bool),
+	}
+}
+
+func (r *memoryRepository) CreateExport(ctx context.Context, export *DataExport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	export.ID = r.nextID
+	copied := *export
+	r.exports[export.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) GetExport(ctx context.Context, userID, id uint, withArchive bool) (*DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	export, ok := r.exports[id]
+	if !ok || export.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *export
+	if !withArchive {
+		copied.Archive = nil
+	}
+	return &copied, nil
+}
+
+func (r *memoryRepository) ListExports(ctx context.Context, userID uint) ([]*DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*DataExport
+	for _, export := range r.exports {
+		if export.UserID == userID {
+			copied := *export
+			copied.Archive = nil
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) FindActiveExport(ctx context.Context, userID uint) (*DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, export := range r.exports {
+		if export.UserID == userID && (export.Status == ExportPending || export.Status == ExportRunning) {
+			copied := *export
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) ClaimExport(ctx context.Context, now, staleBefore time.Time) (*DataExport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, export := range r.exports {
+		if export.Status == ExportPending || (export.Status == ExportRunning && export.UpdatedAt.Before(staleBefore)) {
+			export.Status = ExportRunning
+			export.UpdatedAt = now
+			copied := *export
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryRepository) SaveExport(ctx context.Context, export *DataExport) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *export
+	r.exports[export.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) PurgeExpiredExports(ctx context.Context, now time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var purged int64
+	for _, export := range r.exports {
+		if export.ExpiresAt != nil && !now.Before(*export.ExpiresAt) && export.Archive != nil {
+			export.Archive = nil
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *memoryRepository) CollectUserData(ctx context.Context, userID uint) (*UserData, error) {
+	return &UserData{
+		Profile: &user.User{ID: userID, Username: "alice", Email: "alice@example.com"},
+		APIKeys: []APIKeyRecord{{ID: 1, Name: "ci"}},
+	}, nil
+}
+
+func (r *memoryRepository) ScheduleErasure(ctx context.Context, request *ErasureRequest) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	request.ID = r.nextID
+	copied := *request
+	r.erasures[request.UserID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) CancelErasure(ctx context.Context, userID uint, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	request, ok := r.erasures[userID]
+	if !ok || !request.Pending() {
+		return false, nil
+	}
+	request.CancelledAt = &at
+	return true, nil
+}
+
+func (r *memoryRepository) ListPendingErasures(ctx context.Context) ([]*ErasureRequest, error) {
+	return r.DueErasures(ctx, time.Unix(1<<40, 0), 0)
+}
+
+func (r *memoryRepository) DueErasures(ctx context.Context, now time.Time, limit int) ([]*ErasureRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*ErasureRequest
+	for _, request := range r.erasures {
+		if request.Pending() && !request.ScheduledAt.After(now) {
+			copied := *request
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) EraseUserData(ctx context.Context, userID uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.erased[userID] = true
+	return nil
+}
+
+func (r *memoryRepository) CompleteErasure(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, request := range r.erasures {
+		if request.ID == id {
+			request.CompletedAt = &at
+		}
+	}
+	return nil
+}
+
+// fakeAccounts records the calls made to the user module
+type fakeAccounts struct {
+	deleted  map[uint]bool
+	erased   map[uint]bool
+	restored map[uint]bool
+}
+
+func newFakeAccounts() *fakeAccounts {
+	return &fakeAccounts{deleted: map[uint]bool{}, erased: map[uint]bool{}, restored: map[uint]bool{}}
+}
+
+func (a *fakeAccounts) DeleteAccount(userID uint) error {
+	a.deleted[userID] = true
+	return nil
+}
+
+func (a *fakeAccounts) EraseAccount(ctx context.Context, userID uint) error {
+	a.erased[userID] = true
+	return nil
+}
+
+func (a *fakeAccounts) RestoreAccount(ctx context.Context, actor user.AdminActor, userID uint) error {
+	a.restored[userID] = true
+	return nil
+}
+
+func newTestService(now *time.Time) (*service, *memoryRepository, *fakeAccounts) {
+	repo := newMemoryRepository()
+	accounts := newFakeAccounts()
+	svc := NewService(repo, accounts, Options{
+		ErasureGracePeriod: 30 * 24 * time.Hour,
+		ExportTTL:          72 * time.Hour,
+	}).(*service)
+	svc.now = func() time.Time { return *now }
+	return svc, repo, accounts
+}
+
+func TestService_ExportBuildsArchive(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc, _, _ := newTestService(&now)
+	ctx := context.Background()
+
+	export, err := svc.RequestExport(ctx, 7)
+	if err != nil {
+		t.Fatalf("RequestExport failed: %v", err)
+	}
+	again, err := svc.RequestExport(ctx, 7)
+	if err != nil || again.ID != export.ID {
+		t.Fatalf("Expected the queued export to be reused, got %v %v", again, err)
+	}
+	if _, err := svc.DownloadExport(ctx, 7, export.ID); !errors.Is(err, ErrExportNotReady) {
+		t.Fatalf("Expected ErrExportNotReady before the worker ran, got %v", err)
+	}
+
+	svc.RunOnce(ctx)
+
+	if _, err := svc.DownloadExport(ctx, 8, export.ID); !errors.Is(err, ErrExportNotFound) {
+		t.Errorf("Expected another user's export to be hidden, got %v", err)
+	}
+	done, err := svc.DownloadExport(ctx, 7, export.ID)
+	if err != nil {
+		t.Fatalf("DownloadExport failed: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(done.Archive), int64(len(done.Archive)))
+	if err != nil {
+		t.Fatalf("Archive is not a valid ZIP: %v", err)
+	}
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	for _, name := range []string{"profile.json", "memberships.json", "api_keys.json", "audit_history.json"} {
+		if !names[name] {
+			t.Errorf("Expected %s in the archive", name)
+		}
+	}
+
+	now = now.Add(73 * time.Hour)
+	if _, err := svc.DownloadExport(ctx, 7, export.ID); !errors.Is(err, ErrExportExpired) {
+		t.Errorf("Expected ErrExportExpired, got %v", err)
+	}
+}
+
+func TestService_ErasureWaitsForGracePeriod(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc, repo, accounts := newTestService(&now)
+	ctx := context.Background()
+
+	if _, err := svc.DeleteAccount(ctx, 7, "203.0.113.1"); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+	if !accounts.deleted[7] {
+		t.Fatal("Expected the account to be deleted immediately")
+	}
+
+	now = now.Add(29 * 24 * time.Hour)
+	svc.RunOnce(ctx)
+	if accounts.erased[7] || repo.erased[7] {
+		t.Fatal("Expected no erasure during the grace period")
+	}
+
+	now = now.Add(2 * 24 * time.Hour)
+	svc.RunOnce(ctx)
+	if !accounts.erased[7] || !repo.erased[7] {
+		t.Fatal("Expected the account to be erased after the grace period")
+	}
+	if pending, _ := svc.ListPendingErasures(ctx); len(pending) != 0 {
+		t.Errorf("Expected no pending erasures, got %d", len(pending))
+	}
+}
+
+func TestService_RestoreCancelsErasure(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc, _, accounts := newTestService(&now)
+	ctx := context.Background()
+	admin := user.AdminActor{ID: 1, IP: "203.0.113.2"}
+
+	if err := svc.RestoreAccount(ctx, admin, 7); !errors.Is(err, ErrErasureNotFound) {
+		t.Fatalf("Expected ErrErasureNotFound without a deletion, got %v", err)
+	}
+	if _, err := svc.DeleteAccount(ctx, 7, "203.0.113.1"); err != nil {
+		t.Fatalf("DeleteAccount failed: %v", err)
+	}
+	if err := svc.RestoreAccount(ctx, admin, 7); err != nil {
+		t.Fatalf("RestoreAccount failed: %v", err)
+	}
+	if !accounts.restored[7] {
+		t.Fatal("Expected the account to be restored")
+	}
+
+	now = now.Add(31 * 24 * time.Hour)
+	svc.RunOnce(ctx)
+	if accounts.erased[7] {
+		t.Error("Expected a restored account not to be erased")
+	}
+}
+
+func TestService_StartStopsOnCancel(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc, _, _ := newTestService(&now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := svc.Start(ctx)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the worker to stop after cancellation")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
 	"github.com/llamacto/llama-gin-kit/pkg/response"
 )
 
@@ -47,19 +48,13 @@ func (h *handler) CreateTeam(c *gin.Context) {
 	}
 
 	// Get user ID from context (set by auth middleware)
-	userID, exists := c.Get("userID")
-	if !exists {
-		response.Error(c, http.StatusUnauthorized, "User not authenticated")
-		return
-	}
-
-	userIDUint, ok := userID.(uint)
+	userID, ok := auth.UserID(c)
 	if !ok {
-		response.Error(c, http.StatusInternalServerError, "Invalid user ID format")
+		response.Error(c, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	team, err := h.service.CreateTeam(&req, userIDUint)
+	team, err := h.service.CreateTeam(&req, userID)
 	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to create team")
 		return
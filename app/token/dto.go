@@ -0,0 +1,36 @@
+package token
+
+import (
+	"time"
+)
+
+// ClientInfo describes the client a token is being issued to
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// TokenPair is the access/refresh token pair returned on login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int64     `json:"expires_in"`              // Access token lifetime in seconds
+	RefreshToken     string    `json:"refresh_token,omitempty"` // Not issued for impersonation
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+// RefreshRequest 刷新令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest 退出登录请求
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// SessionResponse is a login session as listed to its owner
+type SessionResponse struct {
+	*Session
+	Current bool `json:"current"` // Whether the request was made from this session
+}
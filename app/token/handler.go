@@ -0,0 +1,196 @@
+package token
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/response"
+)
+
+// Handler handles token refresh and logout requests
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new token handler
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// Refresh 刷新访问令牌
+// @Summary 刷新访问令牌
+// @Description 使用刷新令牌换取新的访问令牌和刷新令牌，旧的刷新令牌随即失效
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param body body RefreshRequest true "刷新令牌"
+// @Success 200 {object} TokenPair
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /token/refresh [post]
+func (h *Handler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	pair, err := h.service.Refresh(c.Request.Context(), req.RefreshToken, ClientFromContext(c))
+	if err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pair)
+}
+
+// Logout 退出登录
+// @Summary 退出登录
+// @Description 吊销刷新令牌及其整条轮换链；若携带 Authorization 头，同时吊销当前访问令牌
+// @Tags 认证
+// @Accept json
+// @Produce json
+// @Param body body LogoutRequest true "刷新令牌"
+// @Success 200 {string} string "已退出登录"
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /logout [post]
+func (h *Handler) Logout(c *gin.Context) {
+	var req LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BadRequest(c, "Invalid request parameters", err)
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), req.RefreshToken); err != nil {
+		handleTokenError(c, err)
+		return
+	}
+
+	// Also kill the access token used for this request, if any
+	if bearer, err := auth.BearerToken(c); err == nil {
+		if claims, err := jwt.ParseTokenWithContext(c.Request.Context(), bearer); err == nil {
+			if err := h.service.RevokeAccessToken(c.Request.Context(), claims); err != nil {
+				logger.Error("Failed to revoke access token on logout", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// ListSessions 查询登录会话
+// @Summary 查询登录会话
+// @Description 列出当前用户所有有效的登录会话（设备、IP、最后活跃时间），并标记当前会话
+// @Tags 认证
+// @Produce json
+// @Security Bearer
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/sessions [get]
+func (h *Handler) ListSessions(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(c.Request.Context(), principal.UserID)
+	if err != nil {
+		response.InternalServerError(c, "Failed to list sessions", err)
+		return
+	}
+
+	out := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		out = append(out, SessionResponse{
+			Session: session,
+			Current: principal.SessionID != "" && session.ID == principal.SessionID,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// RevokeSession 退出指定会话
+// @Summary 退出指定会话
+// @Description 吊销指定会话的刷新令牌及已签发的访问令牌
+// @Tags 认证
+// @Produce json
+// @Security Bearer
+// @Param id path string true "会话ID"
+// @Success 200 {string} string "已退出该会话"
+// @Failure 404 {object} response.ErrorResponse
+// @Router /users/sessions/{id} [delete]
+func (h *Handler) RevokeSession(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	if err := h.service.RevokeSession(c.Request.Context(), principal.UserID, c.Param("id")); err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			response.NotFound(c, err.Error(), err)
+			return
+		}
+		response.InternalServerError(c, "Failed to revoke session", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已退出该会话"})
+}
+
+// RevokeAllSessions 退出所有会话
+// @Summary 退出所有会话
+// @Description 在所有设备上退出登录；keep_current=true 时保留当前会话
+// @Tags 认证
+// @Produce json
+// @Security Bearer
+// @Param keep_current query bool false "是否保留当前会话"
+// @Success 200 {string} string "已退出所有会话"
+// @Failure 401 {object} response.ErrorResponse
+// @Router /users/sessions [delete]
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		response.Unauthorized(c, "User not authenticated")
+		return
+	}
+
+	var err error
+	if c.Query("keep_current") == "true" && principal.SessionID != "" {
+		err = h.service.RevokeOtherSessions(c.Request.Context(), principal.UserID, principal.SessionID)
+	} else {
+		err = h.service.RevokeAllForUser(c.Request.Context(), principal.UserID)
+	}
+	if err != nil {
+		response.InternalServerError(c, "Failed to revoke sessions", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已退出所有会话"})
+}
+
+// ClientFromContext extracts the client description from the request. The
+// IP is the connection address unless the request came through a trusted
+// proxy (see routes.NewEngine), so login lockouts can be keyed on it.
+func ClientFromContext(c *gin.Context) ClientInfo {
+	return ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
+func handleTokenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidRefreshToken),
+		errors.Is(err, ErrRefreshTokenExpired),
+		errors.Is(err, ErrRefreshTokenReused),
+		errors.Is(err, ErrSubjectDisabled):
+		response.Unauthorized(c, err.Error())
+	default:
+		response.InternalServerError(c, "Failed to process refresh token", err)
+	}
+}
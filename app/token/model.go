@@ -0,0 +1,85 @@
+package token
+
+import (
+	"time"
+)
+
+// RefreshToken is a persisted, single-use refresh token. Tokens issued by
+// rotating one another share a FamilyID so that the whole chain can be
+// revoked at once when reuse of an old token is detected.
+type RefreshToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	FamilyID  string     `gorm:"size:36;not null;index" json:"family_id"`
+	ParentID  *uint      `json:"parent_id"`                             // Token this one was rotated from
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // SHA-256 of the opaque token
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`      // Hard expiry of this token
+	RotatedAt *time.Time `json:"rotated_at"`                            // Set once the token has been exchanged
+	RevokedAt *time.Time `gorm:"index" json:"revoked_at"`               // Set on logout or reuse detection
+	IP        string     `gorm:"size:45" json:"ip"`                     // Client IP the token was issued to
+	UserAgent string     `gorm:"size:255" json:"user_agent"`            // Client user agent the token was issued to
+}
+
+// TableName specifies the database table name
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// IsActive reports whether the token can still be exchanged.
+func (t *RefreshToken) IsActive(now time.Time) bool {
+	return t.RevokedAt == nil && t.RotatedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// RevokedToken records an access token revoked before its expiry
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	JTI       string    `gorm:"column:jti;size:64;not null;uniqueIndex" json:"jti"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"` // Row can be purged after this
+}
+
+// TableName specifies the database table name
+func (RevokedToken) TableName() string {
+	return "revoked_tokens"
+}
+
+// UserTokenRevocation invalidates every access token issued to a user before RevokedAt
+type UserTokenRevocation struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	RevokedAt time.Time `gorm:"not null" json:"revoked_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName specifies the database table name
+func (UserTokenRevocation) TableName() string {
+	return "user_token_revocations"
+}
+
+// Session is one login of a user on a device. It spans every refresh token
+// rotated from that login, so its ID is the refresh token FamilyID, and it is
+// the sid claim of the access tokens issued for it.
+type Session struct {
+	ID         string     `gorm:"primaryKey;size:36" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"-"`
+	UserID     uint       `gorm:"not null;index" json:"-"`
+	Device     string     `gorm:"size:100" json:"device"`           // e.g. "Chrome on macOS"
+	UserAgent  string     `gorm:"size:255" json:"user_agent"`       // Latest client user agent
+	IP         string     `gorm:"size:45" json:"ip"`                // Latest client IP
+	LastSeenAt time.Time  `json:"last_seen_at"`                     // Last login or refresh
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"` // Expiry of the newest refresh token
+	RevokedAt  *time.Time `gorm:"index" json:"-"`                   // Set on logout or sign-out
+}
+
+// TableName specifies the database table name
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// IsActive reports whether the session can still be refreshed.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
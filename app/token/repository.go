@@ -0,0 +1,201 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository interface for refresh token data access
+type Repository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	MarkRotated(ctx context.Context, id uint, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	RevokeByUser(ctx context.Context, userID uint, at time.Time) error
+
+	CreateSession(ctx context.Context, session *Session) error
+	TouchSession(ctx context.Context, session *Session) (bool, error)
+	GetSession(ctx context.Context, id string) (*Session, error)
+	ListSessions(ctx context.Context, userID uint, now time.Time) ([]*Session, error)
+}
+
+// repository implementation of Repository
+type repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new refresh token repository
+func NewRepository(db *gorm.DB) Repository {
+	return &repository{db: db}
+}
+
+// Create adds a new refresh token
+func (r *repository) Create(ctx context.Context, token *RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+// FindByHash retrieves a refresh token by its hash
+func (r *repository) FindByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkRotated flags a token as exchanged. It reports false when another
+// request rotated or revoked the token first.
+func (r *repository) MarkRotated(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", id).
+		Update("rotated_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token in a rotation chain and its session
+func (r *repository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", at).Error
+	})
+}
+
+// RevokeByUser revokes every refresh token and session of a user
+func (r *repository) RevokeByUser(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", at).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", at).Error
+	})
+}
+
+// CreateSession records a new login session. An existing session with the
+// same ID is left untouched.
+func (r *repository) CreateSession(ctx context.Context, session *Session) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(session).Error
+}
+
+// TouchSession updates the client details and expiry of an active session.
+// It reports false when the session does not exist or was revoked.
+func (r *repository) TouchSession(ctx context.Context, session *Session) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", session.ID).
+		Updates(map[string]interface{}{
+			"device":       session.Device,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetSession retrieves a session by ID
+func (r *repository) GetSession(ctx context.Context, id string) (*Session, error) {
+	var session Session
+	if err := r.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListSessions returns the user's active sessions, most recently used first
+func (r *repository) ListSessions(ctx context.Context, userID uint, now time.Time) ([]*Session, error) {
+	var sessions []*Session
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// revocationStore is the database-backed jwt.RevocationStore
+type revocationStore struct {
+	db *gorm.DB
+}
+
+// NewRevocationStore creates a revocation store persisted in the database
+func NewRevocationStore(db *gorm.DB) jwt.RevocationStore {
+	return &revocationStore{db: db}
+}
+
+// RevokeToken records a revoked access token
+func (r *revocationStore) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&RevokedToken{JTI: jti, UserID: userID, ExpiresAt: expiresAt}).Error
+}
+
+// IsTokenRevoked checks whether an access token has been revoked
+func (r *revocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeSession marks a session revoked. The sessions row doubles as the
+// revocation record, so expiresAt is not needed.
+func (r *revocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	return r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsSessionRevoked checks whether a session has been revoked
+func (r *revocationStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND revoked_at IS NOT NULL", sessionID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeUser moves the user's revocation cutoff forward
+func (r *revocationStore) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at", "updated_at"}),
+		}).
+		Create(&UserTokenRevocation{UserID: userID, RevokedAt: at}).Error
+}
+
+// UserRevokedAt returns the user's revocation cutoff, if any
+func (r *revocationStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, bool, error) {
+	var revocation UserTokenRevocation
+	if err := r.db.WithContext(ctx).First(&revocation, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	return revocation.RevokedAt, true, nil
+}
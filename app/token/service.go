@@ -0,0 +1,360 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown or malformed refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenExpired is returned when the refresh token is past its expiry
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrRefreshTokenReused is returned when an already rotated or revoked token is presented
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrSubjectDisabled is returned when the token owner can no longer sign in
+	ErrSubjectDisabled = errors.New("account is disabled")
+	// ErrSessionNotFound is returned for an unknown session or one owned by another user
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Subject is the minimal user information needed to mint an access token
+type Subject struct {
+	ID       uint
+	Username string
+	Active   bool
+}
+
+// SubjectLoader resolves the user a refresh token belongs to
+type SubjectLoader interface {
+	LoadSubject(ctx context.Context, userID uint) (*Subject, error)
+}
+
+// SubjectLoaderFunc adapts a function to the SubjectLoader interface
+type SubjectLoaderFunc func(ctx context.Context, userID uint) (*Subject, error)
+
+// LoadSubject calls f(ctx, userID)
+func (f SubjectLoaderFunc) LoadSubject(ctx context.Context, userID uint) (*Subject, error) {
+	return f(ctx, userID)
+}
+
+// Service interface for token issuing and rotation
+type Service interface {
+	// Issue starts a new refresh token family for the subject
+	Issue(ctx context.Context, subject *Subject, client ClientInfo) (*TokenPair, error)
+
+	// Refresh exchanges a refresh token for a new token pair
+	Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error)
+
+	// Revoke invalidates the refresh token and every token rotated from the same login
+	Revoke(ctx context.Context, refreshToken string) error
+
+	// Impersonate issues an access token for the subject on behalf of an
+	// administrator. No refresh token is issued, so it cannot be extended.
+	Impersonate(ctx context.Context, subject *Subject, impersonatorID uint) (*TokenPair, error)
+
+	// RevokeAccessToken invalidates a single access token before it expires
+	RevokeAccessToken(ctx context.Context, claims *jwt.Claims) error
+
+	// RevokeAllForUser invalidates every access and refresh token issued to a
+	// user, signing them out everywhere
+	RevokeAllForUser(ctx context.Context, userID uint) error
+
+	// ListSessions returns the user's active login sessions
+	ListSessions(ctx context.Context, userID uint) ([]*Session, error)
+
+	// RevokeSession signs the user out of one session
+	RevokeSession(ctx context.Context, userID uint, sessionID string) error
+
+	// RevokeOtherSessions signs the user out of every session but keepID
+	RevokeOtherSessions(ctx context.Context, userID uint, keepID string) error
+}
+
+// service implementation of Service
+type service struct {
+	repo     Repository
+	jwt      *jwt.Service
+	subjects SubjectLoader
+	now      func() time.Time
+}
+
+// NewService creates a new token service
+func NewService(repo Repository, jwtService *jwt.Service, subjects SubjectLoader) Service {
+	return &service{
+		repo:     repo,
+		jwt:      jwtService,
+		subjects: subjects,
+		now:      time.Now,
+	}
+}
+
+// Issue starts a new refresh token family for the subject
+func (s *service) Issue(ctx context.Context, subject *Subject, client ClientInfo) (*TokenPair, error) {
+	now := s.now()
+	session := s.session(uuid.NewString(), subject.ID, client, now)
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+	return s.issue(ctx, subject, session.ID, nil, client)
+}
+
+// Refresh exchanges a refresh token for a new token pair
+func (s *service) Refresh(ctx context.Context, refreshToken string, client ClientInfo) (*TokenPair, error) {
+	current, err := s.find(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if current.RotatedAt != nil || current.RevokedAt != nil {
+		s.revokeFamilyOnReuse(ctx, current)
+		return nil, ErrRefreshTokenReused
+	}
+	if !now.Before(current.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	rotated, err := s.repo.MarkRotated(ctx, current.ID, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if !rotated {
+		// Another request exchanged the same token concurrently
+		s.revokeFamilyOnReuse(ctx, current)
+		return nil, ErrRefreshTokenReused
+	}
+
+	subject, err := s.subjects.LoadSubject(ctx, current.UserID)
+	if err != nil || subject == nil || !subject.Active {
+		if revokeErr := s.revokeFamily(ctx, current.FamilyID); revokeErr != nil {
+			logger.Error("Failed to revoke refresh token family", revokeErr)
+		}
+		return nil, ErrSubjectDisabled
+	}
+
+	session := s.session(current.FamilyID, current.UserID, client, now)
+	touched, err := s.repo.TouchSession(ctx, session)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+	if !touched {
+		// Families issued before sessions were recorded have no row yet
+		if err := s.repo.CreateSession(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to store session: %w", err)
+		}
+	}
+
+	parentID := current.ID
+	return s.issue(ctx, subject, current.FamilyID, &parentID, client)
+}
+
+// Revoke invalidates the refresh token and every token rotated from the same login
+func (s *service) Revoke(ctx context.Context, refreshToken string) error {
+	current, err := s.find(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return s.revokeFamily(ctx, current.FamilyID)
+}
+
+// Impersonate issues an access token for the subject on behalf of an administrator
+func (s *service) Impersonate(ctx context.Context, subject *Subject, impersonatorID uint) (*TokenPair, error) {
+	accessToken, err := s.jwt.GenerateImpersonationToken(subject.ID, subject.Username, impersonatorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	return &TokenPair{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.jwt.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// RevokeAccessToken invalidates a single access token before it expires
+func (s *service) RevokeAccessToken(ctx context.Context, claims *jwt.Claims) error {
+	return s.jwt.RevokeToken(ctx, claims)
+}
+
+// RevokeAllForUser invalidates every access and refresh token issued to a user
+func (s *service) RevokeAllForUser(ctx context.Context, userID uint) error {
+	if err := s.repo.RevokeByUser(ctx, userID, s.now()); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if err := s.jwt.RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// ListSessions returns the user's active login sessions
+func (s *service) ListSessions(ctx context.Context, userID uint) ([]*Session, error) {
+	return s.repo.ListSessions(ctx, userID, s.now())
+}
+
+// RevokeSession signs the user out of one session
+func (s *service) RevokeSession(ctx context.Context, userID uint, sessionID string) error {
+	session, err := s.repo.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return fmt.Errorf("failed to load session: %w", err)
+	}
+	if session.UserID != userID || !session.IsActive(s.now()) {
+		return ErrSessionNotFound
+	}
+	return s.revokeFamily(ctx, session.ID)
+}
+
+// RevokeOtherSessions signs the user out of every session but keepID
+func (s *service) RevokeOtherSessions(ctx context.Context, userID uint, keepID string) error {
+	sessions, err := s.repo.ListSessions(ctx, userID, s.now())
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	for _, session := range sessions {
+		if session.ID == keepID {
+			continue
+		}
+		if err := s.revokeFamily(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeFamily revokes a login's refresh tokens and the access tokens
+// already issued for it
+func (s *service) revokeFamily(ctx context.Context, familyID string) error {
+	if err := s.repo.RevokeFamily(ctx, familyID, s.now()); err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	if err := s.jwt.RevokeSession(ctx, familyID); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
+
+// session describes the login session of a token family as seen from client
+func (s *service) session(id string, userID uint, client ClientInfo, now time.Time) *Session {
+	return &Session{
+		ID:         id,
+		UserID:     userID,
+		Device:     describeDevice(client.UserAgent),
+		UserAgent:  truncate(client.UserAgent, 255),
+		IP:         client.IP,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(s.jwt.RefreshTokenTTL()),
+	}
+}
+
+func (s *service) issue(ctx context.Context, subject *Subject, familyID string, parentID *uint, client ClientInfo) (*TokenPair, error) {
+	accessToken, err := s.jwt.GenerateSessionToken(subject.ID, subject.Username, familyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	record := &RefreshToken{
+		UserID:    subject.ID,
+		FamilyID:  familyID,
+		ParentID:  parentID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: s.now().Add(s.jwt.RefreshTokenTTL()),
+		IP:        client.IP,
+		UserAgent: truncate(client.UserAgent, 255),
+	}
+	if err := s.repo.Create(ctx, record); err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(s.jwt.AccessTokenTTL().Seconds()),
+		RefreshToken:     raw,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
+}
+
+func (s *service) find(ctx context.Context, refreshToken string) (*RefreshToken, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	current, err := s.repo.FindByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+	return current, nil
+}
+
+func (s *service) revokeFamilyOnReuse(ctx context.Context, token *RefreshToken) {
+	logger.Warn("Refresh token reuse detected, revoking family %s for user %d", token.FamilyID, token.UserID)
+	if err := s.revokeFamily(ctx, token.FamilyID); err != nil {
+		logger.Error("Failed to revoke refresh token family", err)
+	}
+}
+
+// describeDevice summarises a user agent as "<browser> on <OS>"
+func describeDevice(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"), strings.Contains(userAgent, "CriOS/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(userAgent, "curl/"):
+		return "curl"
+	}
+
+	os := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		os = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		os = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		os = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"), strings.Contains(userAgent, "Macintosh"):
+		os = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		os = "Linux"
+	}
+	if os == "" {
+		return browser
+	}
+	return browser + " on " + os
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
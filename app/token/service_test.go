@@ -0,0 +1,344 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"gorm.io/gorm"
+)
+
+// memoryRepository is an in-memory Repository used by the tests
+type memoryRepository struct {
+	mu       sync.Mutex
+	nextID   uint
+	tokens   map[uint]*RefreshToken
+	sessions map[string]*Session
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{tokens: make(map[uint]*RefreshToken), sessions: make(map[string]*Session)}
+}
+
+func (r *memoryRepository) Create(ctx context.Context, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	token.ID = r.nextID
+	copied := *token
+	r.tokens[token.ID] = &copied
+	return nil
+}
+
+func (r *memoryRepository) FindByHash(ctx context.Context, hash string) (*RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == hash {
+			copied := *t
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *memoryRepository) MarkRotated(ctx context.Context, id uint, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[id]
+	if !ok || t.RotatedAt != nil || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.RotatedAt = &at
+	return true, nil
+}
+
+func (r *memoryRepository) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	if session, ok := r.sessions[familyID]; ok && session.RevokedAt == nil {
+		session.RevokedAt = &at
+	}
+	return nil
+}
+
+func (r *memoryRepository) RevokeByUser(ctx context.Context, userID uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) CreateSession(ctx context.Context, session *Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.sessions[session.ID]; !ok {
+		copied := *session
+		r.sessions[session.ID] = &copied
+	}
+	return nil
+}
+
+func (r *memoryRepository) TouchSession(ctx context.Context, session *Session) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	existing, ok := r.sessions[session.ID]
+	if !ok || existing.RevokedAt != nil {
+		return false, nil
+	}
+	existing.Device, existing.UserAgent, existing.IP = session.Device, session.UserAgent, session.IP
+	existing.LastSeenAt, existing.ExpiresAt = session.LastSeenAt, session.ExpiresAt
+	return true, nil
+}
+
+func (r *memoryRepository) GetSession(ctx context.Context, id string) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *memoryRepository) ListSessions(ctx context.Context, userID uint, now time.Time) ([]*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.IsActive(now) {
+			copied := *session
+			out = append(out, &copied)
+		}
+	}
+	return out, nil
+}
+
+func newTestService(active *bool) (*service, *memoryRepository) {
+	repo := newMemoryRepository()
+	cfg := &config.Config{JWT: config.JWTConfig{
+		Secret:                "test-secret",
+		AccessExpireDuration:  time.Minute,
+		RefreshExpireDuration: time.Hour,
+	}}
+	loader := SubjectLoaderFunc(func(ctx context.Context, userID uint) (*Subject, error) {
+		return &Subject{ID: userID, Username: "alice", Active: *active}, nil
+	})
+	svc := NewService(repo, jwt.NewService(cfg), loader).(*service)
+	return svc, repo
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+
+	first, err := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	second, err := svc.Refresh(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("Expected a new refresh token after rotation")
+	}
+	if second.ExpiresIn != 60 {
+		t.Errorf("Expected expires_in 60, got %d", second.ExpiresIn)
+	}
+
+	if _, err := svc.Refresh(ctx, second.RefreshToken, ClientInfo{}); err != nil {
+		t.Fatalf("Refreshing the rotated token failed: %v", err)
+	}
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+
+	first, _ := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, ClientInfo{})
+	second, err := svc.Refresh(ctx, first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	// Replaying the first token must be detected and kill the whole chain
+	if _, err := svc.Refresh(ctx, first.RefreshToken, ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, second.RefreshToken, ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("Expected the descendant token to be revoked, got %v", err)
+	}
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+
+	pair, _ := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, ClientInfo{})
+	svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	if _, err := svc.Refresh(ctx, pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Fatalf("Expected ErrRefreshTokenExpired, got %v", err)
+	}
+}
+
+func TestRefresh_DisabledSubject(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+
+	pair, _ := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, ClientInfo{})
+	active = false
+
+	if _, err := svc.Refresh(ctx, pair.RefreshToken, ClientInfo{}); !errors.Is(err, ErrSubjectDisabled) {
+		t.Fatalf("Expected ErrSubjectDisabled, got %v", err)
+	}
+}
+
+func TestRevoke_InvalidatesChain(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+
+	first, _ := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, ClientInfo{})
+	second, _ := svc.Refresh(ctx, first.RefreshToken, ClientInfo{})
+
+	if err := svc.Revoke(ctx, second.RefreshToken); err != nil {
+		t.Fatalf("Revoke failed: %v", err)
+	}
+	if _, err := svc.Refresh(ctx, second.RefreshToken, ClientInfo{}); err == nil {
+		t.Fatal("Expected refresh to fail after logout")
+	}
+	if err := svc.Revoke(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("Expected ErrInvalidRefreshToken, got %v", err)
+	}
+}
+
+func TestSessions_TrackLoginAndRefresh(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+	chrome := ClientInfo{IP: "10.0.0.1", UserAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"}
+
+	pair, err := svc.Issue(ctx, &Subject{ID: 1, Username: "alice", Active: true}, chrome)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	claims, err := svc.jwt.ParseTokenWithContext(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseTokenWithContext failed: %v", err)
+	}
+
+	sessions, _ := svc.ListSessions(ctx, 1)
+	if len(sessions) != 1 || sessions[0].ID != claims.SessionID {
+		t.Fatalf("Expected one session matching the sid claim, got %+v", sessions)
+	}
+	if sessions[0].Device != "Chrome on macOS" || sessions[0].IP != "10.0.0.1" {
+		t.Errorf("Unexpected session details %+v", sessions[0])
+	}
+
+	// Refreshing from another network keeps the session and updates it
+	if _, err := svc.Refresh(ctx, pair.RefreshToken, ClientInfo{IP: "10.0.0.2", UserAgent: chrome.UserAgent}); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	sessions, _ = svc.ListSessions(ctx, 1)
+	if len(sessions) != 1 || sessions[0].IP != "10.0.0.2" {
+		t.Errorf("Expected the session to follow the refresh, got %+v", sessions)
+	}
+}
+
+func TestRevokeSession_InvalidatesAccessTokens(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+	subject := &Subject{ID: 1, Username: "alice", Active: true}
+
+	laptop, _ := svc.Issue(ctx, subject, ClientInfo{})
+	phone, _ := svc.Issue(ctx, subject, ClientInfo{})
+	claims, _ := svc.jwt.ParseTokenWithContext(ctx, laptop.AccessToken)
+
+	if err := svc.RevokeSession(ctx, 2, claims.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("Expected ErrSessionNotFound for another user, got %v", err)
+	}
+	if err := svc.RevokeSession(ctx, 1, claims.SessionID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+
+	if _, err := svc.jwt.ParseTokenWithContext(ctx, laptop.AccessToken); !errors.Is(err, jwt.ErrTokenRevoked) {
+		t.Errorf("Expected the session's access token to be revoked, got %v", err)
+	}
+	if _, err := svc.Refresh(ctx, laptop.RefreshToken, ClientInfo{}); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("Expected the session's refresh token to be revoked, got %v", err)
+	}
+	if _, err := svc.jwt.ParseTokenWithContext(ctx, phone.AccessToken); err != nil {
+		t.Errorf("Expected the other session to stay valid, got %v", err)
+	}
+}
+
+func TestRevokeOtherSessions_KeepsCurrent(t *testing.T) {
+	active := true
+	svc, _ := newTestService(&active)
+	ctx := context.Background()
+	subject := &Subject{ID: 1, Username: "alice", Active: true}
+
+	current, _ := svc.Issue(ctx, subject, ClientInfo{})
+	other, _ := svc.Issue(ctx, subject, ClientInfo{})
+	claims, _ := svc.jwt.ParseTokenWithContext(ctx, current.AccessToken)
+
+	if err := svc.RevokeOtherSessions(ctx, 1, claims.SessionID); err != nil {
+		t.Fatalf("RevokeOtherSessions failed: %v", err)
+	}
+	if _, err := svc.jwt.ParseTokenWithContext(ctx, other.AccessToken); !errors.Is(err, jwt.ErrTokenRevoked) {
+		t.Errorf("Expected the other session to be revoked, got %v", err)
+	}
+	sessions, _ := svc.ListSessions(ctx, 1)
+	if len(sessions) != 1 || sessions[0].ID != claims.SessionID {
+		t.Errorf("Expected only the current session to remain, got %+v", sessions)
+	}
+}
+
+func TestImpersonate_IssuesAccessTokenOnly(t *testing.T) {
+	active := true
+	svc, repo := newTestService(&active)
+	ctx := context.Background()
+
+	pair, err := svc.Impersonate(ctx, &Subject{ID: 5, Username: "bob", Active: true}, 1)
+	if err != nil {
+		t.Fatalf("Impersonate failed: %v", err)
+	}
+	if pair.RefreshToken != "" || len(repo.tokens) != 0 {
+		t.Error("Expected no refresh token for impersonation")
+	}
+
+	claims, err := svc.jwt.ParseTokenWithContext(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseTokenWithContext failed: %v", err)
+	}
+	if claims.UserID != 5 || claims.ImpersonatorID != 1 {
+		t.Errorf("Expected user 5 impersonated by 1, got %d by %d", claims.UserID, claims.ImpersonatorID)
+	}
+}
@@ -1,9 +1,15 @@
 package user
 
+import (
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/app/token"
+)
+
 // UserRegisterRequest 用户注册请求
 type UserRegisterRequest struct {
-	Username string `json:"username" binding:"required,min=3,max=50"`
-	Password string `json:"password" binding:"required,min=6,max=50"`
+	Username string `json:"username" binding:"required,max=50"`  // 长度、字符和保留名由用户名规则校验
+	Password string `json:"password" binding:"required,max=128"` // 长度和复杂度由密码策略校验
 	Email    string `json:"email" binding:"required,email"`
 	Nickname string `json:"nickname" binding:"max=50"`
 	Phone    string `json:"phone" binding:"max=20"`
@@ -16,9 +22,35 @@ type UserLoginRequest struct {
 }
 
 // UserLoginResponse 用户登录响应
+// 开启两步验证时只返回 mfa_required 和 mfa_token，需调用 /login/mfa 换取令牌
 type UserLoginResponse struct {
+	Token string `json:"token,omitempty"` // 访问令牌，与 access_token 相同，保留以兼容旧客户端
+	*token.TokenPair
+	User *User `json:"user,omitempty"`
+
+	MFARequired  bool       `json:"mfa_required,omitempty"`
+	MFAToken     string     `json:"mfa_token,omitempty"`
+	MFAExpiresAt *time.Time `json:"mfa_expires_at,omitempty"`
+}
+
+// UserLoginMFARequest 两步验证登录请求
+type UserLoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // 动态验证码或恢复码
+}
+
+// UserPasswordlessRequest 请求免密登录邮件
+type UserPasswordlessRequest struct {
+	Email  string `json:"email" binding:"required,email"`
+	Method string `json:"method" binding:"omitempty,oneof=link code"` // link（默认）发送登录链接，code 发送 6 位验证码
+}
+
+// UserPasswordlessLoginRequest 免密登录请求
+// 使用登录链接时只需 token，使用验证码时需 email 和 code
+type UserPasswordlessLoginRequest struct {
 	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Email string `json:"email" binding:"omitempty,email"`
+	Code  string `json:"code" binding:"omitempty,len=6,numeric"`
 }
 
 // UserUpdateRequest 用户信息更新请求
@@ -29,13 +61,80 @@ type UserUpdateRequest struct {
 	Bio      string `json:"bio" binding:"max=500"`
 }
 
+// UserChangeUsernameRequest 修改用户名请求
+type UserChangeUsernameRequest struct {
+	Username string `json:"username" binding:"required,max=50"` // 长度、字符和保留名由用户名规则校验
+}
+
+// UserPublicProfile 按用户名查询到的公开资料
+type UserPublicProfile struct {
+	ID         uint              `json:"id"`
+	Username   string            `json:"username"`
+	Nickname   string            `json:"nickname"`
+	Avatar     string            `json:"avatar"`
+	AvatarURLs map[string]string `json:"avatar_urls,omitempty"`
+	Bio        string            `json:"bio"`
+}
+
 // UserChangePasswordRequest 修改密码请求
 type UserChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6,max=50"`
+	NewPassword string `json:"new_password" binding:"required,max=128"` // 长度和复杂度由密码策略校验
 }
 
 // UserPasswordResetRequest 重置密码请求
 type UserPasswordResetRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
+
+// UserVerifyEmailRequest 验证邮箱请求
+type UserVerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UserResendVerificationRequest 重新发送验证邮件请求
+type UserResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// UserPasswordResetConfirmRequest 确认重置密码请求
+type UserPasswordResetConfirmRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,max=128"` // 长度和复杂度由密码策略校验
+}
+
+// AdminActor 执行管理操作的管理员，用于审计记录
+type AdminActor struct {
+	ID uint
+	IP string
+}
+
+// AdminUserQuery 管理员查询用户条件
+type AdminUserQuery struct {
+	Page          int    `form:"page,default=1" binding:"min=1"`
+	PageSize      int    `form:"page_size,default=20" binding:"min=1,max=100"`
+	Query         string `form:"q" binding:"max=100"` // 匹配用户名、邮箱或昵称
+	Status        *int   `form:"status" binding:"omitempty,oneof=0 1"`
+	EmailVerified *bool  `form:"email_verified"`
+}
+
+// AdminAuditQuery 管理员操作审计查询条件
+type AdminAuditQuery struct {
+	Page     int    `form:"page,default=1" binding:"min=1"`
+	PageSize int    `form:"page_size,default=20" binding:"min=1,max=100"`
+	UserID   uint   `form:"user_id"`  // 被操作的用户
+	ActorID  uint   `form:"actor_id"` // 执行操作的管理员
+	Action   string `form:"action"`
+}
+
+// AdminImpersonateRequest 管理员模拟登录请求
+type AdminImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"` // 写入审计记录
+}
+
+// AdminImpersonateResponse 管理员模拟登录响应
+// 只签发访问令牌，过期后需重新发起模拟登录
+type AdminImpersonateResponse struct {
+	*token.TokenPair
+	User *User `json:"user"`
+}
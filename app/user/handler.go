@@ -1,11 +1,24 @@
 package user
 
 import (
+	"errors"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/app/identity"
+	"github.com/llamacto/llama-gin-kit/app/mfa"
+	"github.com/llamacto/llama-gin-kit/app/token"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/lockout"
 	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/oidc"
+	"gorm.io/gorm"
 )
 
 // UserHandler 用户处理器
@@ -38,7 +51,11 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 	user, err := h.service.Register(&req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		if errors.Is(err, ErrUsernameTaken) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -47,12 +64,12 @@ func (h *UserHandler) Register(c *gin.Context) {
 
 // Login 用户登录
 // @Summary 用户登录
-// @Description 用户登录并获取令牌
+// @Description 用户登录并获取访问令牌和刷新令牌
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Param body body UserLoginRequest true "登录信息"
-// @Success 200 {object} User
+// @Success 200 {object} UserLoginResponse
 // @Router /users/login [post]
 func (h *UserHandler) Login(c *gin.Context) {
 	var req UserLoginRequest
@@ -61,15 +78,277 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.service.Login(&req)
+	resp, err := h.service.Login(&req, token.ClientFromContext(c))
 	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+		if errors.Is(err, ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// LoginMFA 两步验证登录
+// @Summary 两步验证登录
+// @Description 使用登录返回的 mfa_token 和身份验证器验证码（或恢复码）换取访问令牌
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserLoginMFARequest true "两步验证信息"
+// @Success 200 {object} UserLoginResponse
+// @Failure 401 {object} map[string]string
+// @Router /login/mfa [post]
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	var req UserLoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.service.LoginMFA(&req, token.ClientFromContext(c))
+	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+		if errors.Is(err, mfa.ErrInvalidChallenge) || errors.Is(err, mfa.ErrInvalidCode) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RequestPasswordlessLogin 请求免密登录
+// @Summary 请求免密登录
+// @Description 向邮箱发送一次性登录链接或 6 位验证码；无论邮箱是否注册都返回相同结果
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserPasswordlessRequest true "邮箱和发送方式"
+// @Success 200 {string} string "如果该邮箱已注册，登录邮件已发送"
+// @Failure 404 {object} map[string]string
+// @Router /login/passwordless [post]
+func (h *UserHandler) RequestPasswordlessLogin(c *gin.Context) {
+	var req UserPasswordlessRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.RequestPasswordlessLogin(&req, token.ClientFromContext(c)); err != nil {
+		if errors.Is(err, ErrPasswordlessDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("处理免密登录请求失败:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，登录邮件已发送"})
+}
+
+// PasswordlessLogin 免密登录
+// @Summary 免密登录
+// @Description 使用邮件中的登录链接令牌，或邮箱和 6 位验证码登录；每个链接和验证码只能使用一次
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserPasswordlessLoginRequest true "登录令牌或邮箱和验证码"
+// @Success 200 {object} UserLoginResponse
+// @Failure 401 {object} map[string]string
+// @Router /login/passwordless/verify [post]
+func (h *UserHandler) PasswordlessLogin(c *gin.Context) {
+	var req UserPasswordlessLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	resp, err := h.service.PasswordlessLogin(&req, token.ClientFromContext(c))
+	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, ErrPasswordlessDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrInvalidLoginCode):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrEmailNotVerified), errors.Is(err, ErrAccountDisabled):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// OIDCLogin 跳转到外部身份提供方登录
+// @Summary 外部身份登录
+// @Description 生成 state、nonce 和 PKCE 参数后重定向到身份提供方，回调时校验
+// @Tags 外部登录
+// @Param provider path string true "身份提供方名称"
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Router /auth/oidc/{provider} [get]
+func (h *UserHandler) OIDCLogin(c *gin.Context) {
+	flow, err := h.service.BeginExternalLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		if errors.Is(err, identity.ErrUnknownProvider) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("开始外部身份登录失败:", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "身份提供方暂时不可用"})
+		return
+	}
+
+	// Lax 允许身份提供方重定向回来时携带该 Cookie
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(identity.FlowCookie, flow.Token, int(time.Until(flow.ExpiresAt).Seconds()), "/", "", isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, flow.AuthURL)
+}
+
+// OIDCCallback 外部身份提供方回调
+// @Summary 外部身份登录回调
+// @Description 校验 state 后用授权码换取 ID Token，按已验证邮箱关联或创建账户，返回与密码登录相同的结果
+// @Tags 外部登录
+// @Produce json
+// @Param provider path string true "身份提供方名称"
+// @Param code query string true "授权码"
+// @Param state query string true "state"
+// @Success 200 {object} UserLoginResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [get]
+func (h *UserHandler) OIDCCallback(c *gin.Context) {
+	flowToken, _ := c.Cookie(identity.FlowCookie)
+	// 每次登录流程只能使用一次
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(identity.FlowCookie, "", -1, "/", "", isSecureRequest(c), true)
+
+	if providerErr := c.Query("error"); providerErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": providerErr, "error_description": c.Query("error_description")})
+		return
+	}
+
+	resp, err := h.service.CompleteExternalLogin(c.Request.Context(), c.Param("provider"), flowToken,
+		c.Query("state"), c.Query("code"), token.ClientFromContext(c))
+	if err != nil {
+		switch {
+		case errors.Is(err, identity.ErrUnknownProvider):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, identity.ErrInvalidState), errors.Is(err, oidc.ErrInvalidIDToken),
+			errors.Is(err, oidc.ErrNonceMismatch), errors.Is(err, ErrExternalEmailRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrExternalEmailConflict), errors.Is(err, identity.ErrAlreadyLinked):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrExternalSignupDisabled), errors.Is(err, ErrEmailNotVerified):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			logger.Error("外部身份登录失败:", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "外部身份登录失败"})
+		}
+		return
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
 
+// isSecureRequest reports whether the request reached us over HTTPS
+func isSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
+
+// respondLocked 登录被锁定时返回 429 和 Retry-After
+func respondLocked(c *gin.Context, err error) bool {
+	var locked *lockout.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	seconds := int(locked.RetryAfter.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retry_after": seconds})
+	return true
+}
+
+// UnlockAccount 解除账户登录锁定
+// @Summary 解除账户登录锁定
+// @Description 管理员清除指定用户的登录失败记录和锁定
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "账户已解锁"
+// @Router /admin/users/{id}/lockout [delete]
+func (h *UserHandler) UnlockAccount(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.service.UnlockAccount(c.Request.Context(), principal.UserID, uint(userID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		logger.Error("解除账户锁定失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除账户锁定失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "账户已解锁"})
+}
+
+// UnlockIP 解除 IP 登录锁定
+// @Summary 解除 IP 登录锁定
+// @Description 管理员清除指定客户端 IP 的登录失败记录和锁定
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param ip path string true "客户端 IP"
+// @Success 200 {string} string "IP 已解锁"
+// @Router /admin/lockouts/ips/{ip} [delete]
+func (h *UserHandler) UnlockIP(c *gin.Context) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid IP address"})
+		return
+	}
+
+	if err := h.service.UnlockIP(c.Request.Context(), principal.UserID, ip.String()); err != nil {
+		logger.Error("解除 IP 锁定失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除 IP 锁定失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "IP 已解锁"})
+}
+
 // UpdateProfile 更新用户信息
 // @Summary 更新用户信息
 // @Description 更新当前用户的个人资料
@@ -80,12 +359,11 @@ func (h *UserHandler) Login(c *gin.Context) {
 // @Success 200 {object} User
 // @Router /users/profile [put]
 func (h *UserHandler) UpdateProfile(c *gin.Context) {
-	userIDVal, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
 		return
 	}
-	userID := userIDVal.(uint)
 
 	var req UserUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -102,6 +380,176 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// ChangeUsername 修改用户名
+// @Summary 修改用户名
+// @Description 修改当前用户的用户名，旧用户名在保留期内仍指向该用户；修改次数受限
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserChangeUsernameRequest true "新用户名"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /users/username [put]
+func (h *UserHandler) ChangeUsername(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	var req UserChangeUsernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	user, err := h.service.ChangeUsername(c.Request.Context(), userID, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, ErrUsernameTaken):
+			status = http.StatusConflict
+		case errors.Is(err, ErrUsernameChangeLimited):
+			status = http.StatusTooManyRequests
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// GetByUsername 按用户名查询用户
+// @Summary 按用户名查询用户
+// @Description 查询用户的公开资料；使用保留期内的旧用户名时重定向到当前用户名
+// @Tags 用户
+// @Produce json
+// @Param username path string true "用户名"
+// @Success 200 {object} UserPublicProfile
+// @Success 301 {string} string "重定向到当前用户名"
+// @Failure 404 {object} map[string]string
+// @Router /users/by-username/{username} [get]
+func (h *UserHandler) GetByUsername(c *gin.Context) {
+	username := c.Param("username")
+	user, renamed, err := h.service.ResolveUsername(c.Request.Context(), username)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if renamed {
+		c.Redirect(http.StatusMovedPermanently, strings.TrimSuffix(c.Request.URL.Path, username)+url.PathEscape(user.Username))
+		return
+	}
+
+	c.JSON(http.StatusOK, UserPublicProfile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Nickname:   user.Nickname,
+		Avatar:     user.Avatar,
+		AvatarURLs: user.AvatarURLs,
+		Bio:        user.Bio,
+	})
+}
+
+// UploadAvatar 上传头像
+// @Summary 上传头像
+// @Description 上传 JPEG、PNG 或 GIF 图片作为头像，服务端去除元数据并裁剪为多个尺寸的方形图片
+// @Tags 用户
+// @Accept multipart/form-data
+// @Produce json
+// @Param avatar formData file true "头像图片"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /users/avatar [post]
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	// 逐段读取表单，文件内容直接交给服务层按大小限制读取，不落盘
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请使用 multipart/form-data 上传头像"})
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的上传内容"})
+			return
+		}
+		if part.FormName() != "avatar" {
+			part.Close()
+			continue
+		}
+
+		user, err := h.service.UploadAvatar(c.Request.Context(), userID, part)
+		part.Close()
+		if err != nil {
+			c.JSON(avatarErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, user)
+		return
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{"error": "缺少头像文件"})
+}
+
+// DeleteAvatar 删除头像
+// @Summary 删除头像
+// @Description 删除当前用户的头像及已上传的图片
+// @Tags 用户
+// @Produce json
+// @Success 200 {object} User
+// @Router /users/avatar [delete]
+func (h *UserHandler) DeleteAvatar(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return
+	}
+
+	user, err := h.service.DeleteAvatar(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// avatarErrorStatus 头像上传错误对应的状态码
+func avatarErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrAvatarUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrAvatarTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrAvatarUnsupported):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrInvalidAvatar):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ChangePassword 修改密码
 // @Summary 修改密码
 // @Description 修改当前用户的密码
@@ -112,12 +560,11 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 // @Success 200 {string} string "密码修改成功"
 // @Router /users/password [put]
 func (h *UserHandler) ChangePassword(c *gin.Context) {
-	userIDVal, exists := c.Get("userID")
-	if !exists {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
 		return
 	}
-	userID := userIDVal.(uint)
 
 	var req UserChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -135,13 +582,13 @@ func (h *UserHandler) ChangePassword(c *gin.Context) {
 
 // ResetPassword 重置密码
 // @Summary 重置密码
-// @Description 通过邮箱重置用户密码
+// @Description 向邮箱发送一次性重置密码链接；无论邮箱是否注册都返回相同结果
 // @Tags 用户
 // @Accept json
 // @Produce json
 // @Param body body UserPasswordResetRequest true "邮箱信息"
-// @Success 200 {string} string "重置密码邮件已发送"
-// @Router /users/password/reset [post]
+// @Success 200 {string} string "如果该邮箱已注册，重置密码邮件已发送"
+// @Router /password/reset [post]
 func (h *UserHandler) ResetPassword(c *gin.Context) {
 	var req UserPasswordResetRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -149,75 +596,131 @@ func (h *UserHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.ResetPassword(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	if err := h.service.ResetPassword(&req, token.ClientFromContext(c)); err != nil {
+		logger.Error("处理重置密码请求失败:", err)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "密码重置邮件已发送"})
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码邮件已发送"})
 }
 
-// GetProfile 获取用户个人资料
-// @Summary 获取用户个人资料
-// @Description 获取当前登录用户的个人资料
+// ConfirmPasswordReset 确认重置密码
+// @Summary 确认重置密码
+// @Description 使用邮件中的重置令牌设置新密码，令牌仅可使用一次
 // @Tags 用户
 // @Accept json
 // @Produce json
-// @Security Bearer
-// @Success 200 {object} User
-// @Router /users/profile [get]
-func (h *UserHandler) GetProfile(c *gin.Context) {
-	userIDVal, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+// @Param body body UserPasswordResetConfirmRequest true "重置令牌和新密码"
+// @Success 200 {string} string "密码已重置"
+// @Failure 400 {object} map[string]string
+// @Router /password/reset/confirm [post]
+func (h *UserHandler) ConfirmPasswordReset(c *gin.Context) {
+	var req UserPasswordResetConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
-	userID, ok := userIDVal.(uint)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "用户ID类型错误"})
+	if err := h.service.ConfirmPasswordReset(&req); err != nil {
+		if errors.Is(err, ErrInvalidResetToken) || IsPasswordRejected(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("重置密码失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
 		return
 	}
 
-	user, err := h.service.GetProfile(userID)
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请使用新密码登录"})
+}
+
+// VerifyEmail 验证邮箱
+// @Summary 验证邮箱
+// @Description 使用验证邮件中的签名令牌确认邮箱地址
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserVerifyEmailRequest true "验证令牌"
+// @Success 200 {object} User
+// @Failure 400 {object} map[string]string
+// @Router /email/verify [post]
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req UserVerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	user, err := h.service.VerifyEmail(&req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, ErrInvalidVerificationToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Error("验证邮箱失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "验证邮箱失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
 
-// DeleteAccount 删除账户
-// @Summary 删除账户
-// @Description 删除当前用户的账户
+// ResendVerification 重新发送验证邮件
+// @Summary 重新发送验证邮件
+// @Description 向未验证的邮箱重新发送验证链接；无论邮箱是否注册都返回相同结果
+// @Tags 用户
+// @Accept json
+// @Produce json
+// @Param body body UserResendVerificationRequest true "邮箱信息"
+// @Success 200 {string} string "如果该邮箱待验证，验证邮件已发送"
+// @Router /email/verify/resend [post]
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	var req UserResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.ResendVerification(&req); err != nil {
+		logger.Error("处理重发验证邮件请求失败:", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱待验证，验证邮件已发送"})
+}
+
+// GetProfile 获取用户个人资料
+// @Summary 获取用户个人资料
+// @Description 获取当前登录用户的个人资料
 // @Tags 用户
-// @Success 200 {string} string "账户已删除"
-// @Router /users/account [delete]
-func (h *UserHandler) DeleteAccount(c *gin.Context) {
-	userIDVal, exists := c.Get("userID")
-	if !exists {
+// @Accept json
+// @Produce json
+// @Security Bearer
+// @Success 200 {object} User
+// @Router /users/profile [get]
+func (h *UserHandler) GetProfile(c *gin.Context) {
+	userID, ok := auth.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
 		return
 	}
-	userID := userIDVal.(uint)
 
-	if err := h.service.DeleteAccount(userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	user, err := h.service.GetProfile(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "账户已删除"})
+	c.JSON(http.StatusOK, user)
 }
 
 // Get 获取指定用户信息
 // @Summary 获取指定用户信息
-// @Description 根据用户ID获取用户信息
-// @Tags 用户
+// @Description 管理员根据用户ID获取用户信息
+// @Tags 管理员
 // @Produce json
+// @Security Bearer
 // @Param id path int true "用户ID"
 // @Success 200 {object} User
-// @Router /users/{id} [get]
+// @Router /admin/users/{id} [get]
 func (h *UserHandler) Get(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
@@ -237,18 +740,25 @@ func (h *UserHandler) Get(c *gin.Context) {
 
 // List 获取用户列表
 // @Summary 获取用户列表
-// @Description 分页获取用户列表
-// @Tags 用户
+// @Description 管理员分页查询用户，可按关键字、状态和邮箱验证状态筛选
+// @Tags 管理员
 // @Produce json
+// @Security Bearer
 // @Param page query int false "页码" default(1)
-// @Param page_size query int false "每页数量" default(10)
+// @Param page_size query int false "每页数量" default(20)
+// @Param q query string false "用户名、邮箱或昵称关键字"
+// @Param status query int false "状态：1 正常，0 禁用"
+// @Param email_verified query bool false "邮箱是否已验证"
 // @Success 200 {array} User
-// @Router /users [get]
+// @Router /admin/users [get]
 func (h *UserHandler) List(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	var query AdminUserQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	users, total, err := h.service.List(c.Request.Context(), page, pageSize)
+	users, total, err := h.service.SearchUsers(c.Request.Context(), &query)
 	if err != nil {
 		logger.Error("获取用户列表失败:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户列表失败"})
@@ -258,28 +768,176 @@ func (h *UserHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"total": total, "list": users})
 }
 
-// GetUserInfo 获取用户信息
-// @Summary 获取用户信息
-// @Description 根据用户ID获取用户详细信息
-// @Tags user
+// Disable 禁用账户
+// @Summary 禁用账户
+// @Description 管理员禁用账户，并注销该用户所有已登录会话
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "账户已禁用"
+// @Router /admin/users/{id}/disable [post]
+func (h *UserHandler) Disable(c *gin.Context) {
+	actor, userID, ok := adminRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.DisableUser(c.Request.Context(), actor, userID); err != nil {
+		respondAdminError(c, "禁用账户失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "账户已禁用"})
+}
+
+// Enable 启用账户
+// @Summary 启用账户
+// @Description 管理员启用被禁用的账户
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "账户已启用"
+// @Router /admin/users/{id}/enable [post]
+func (h *UserHandler) Enable(c *gin.Context) {
+	actor, userID, ok := adminRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.EnableUser(c.Request.Context(), actor, userID); err != nil {
+		respondAdminError(c, "启用账户失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "账户已启用"})
+}
+
+// ForcePasswordReset 强制重置密码
+// @Summary 强制重置密码
+// @Description 管理员使用户原密码失效并注销所有会话，同时向用户发送重置密码邮件
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "已发送重置密码邮件"
+// @Router /admin/users/{id}/password-reset [post]
+func (h *UserHandler) ForcePasswordReset(c *gin.Context) {
+	actor, userID, ok := adminRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.ForcePasswordReset(c.Request.Context(), actor, userID); err != nil {
+		respondAdminError(c, "强制重置密码失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已发送重置密码邮件"})
+}
+
+// Impersonate 模拟用户登录
+// @Summary 模拟用户登录
+// @Description 管理员以用户身份签发短期访问令牌，必须填写原因并记入审计日志；该令牌不能访问管理接口或修改凭证
+// @Tags 管理员
 // @Accept json
 // @Produce json
+// @Security Bearer
 // @Param id path int true "用户ID"
-// @Success 200 {object} UserInfo
-// @Router /users/info/{id} [get]
-func (h *UserHandler) GetUserInfo(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 64)
+// @Param body body AdminImpersonateRequest true "模拟登录原因"
+// @Success 200 {object} AdminImpersonateResponse
+// @Router /admin/users/{id}/impersonate [post]
+func (h *UserHandler) Impersonate(c *gin.Context) {
+	actor, userID, ok := adminRequest(c)
+	if !ok {
+		return
+	}
+	var req AdminImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.service.Impersonate(c.Request.Context(), actor, userID, req.Reason)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		respondAdminError(c, "模拟登录失败", err)
 		return
 	}
+	c.JSON(http.StatusOK, resp)
+}
 
-	userInfo, err := h.service.GetUserByID(uint(id))
+// HardDelete 永久删除账户
+// @Summary 永久删除账户
+// @Description 管理员永久删除账户及其外部身份和两步验证数据，操作不可恢复
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param id path int true "用户ID"
+// @Success 200 {string} string "账户已永久删除"
+// @Router /admin/users/{id} [delete]
+func (h *UserHandler) HardDelete(c *gin.Context) {
+	actor, userID, ok := adminRequest(c)
+	if !ok {
+		return
+	}
+	if err := h.service.HardDelete(c.Request.Context(), actor, userID); err != nil {
+		respondAdminError(c, "删除账户失败", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "账户已永久删除"})
+}
+
+// ListAuditLogs 查询管理员操作记录
+// @Summary 查询管理员操作记录
+// @Description 分页查询管理员对用户账户的操作记录，可按用户、管理员和操作类型筛选
+// @Tags 管理员
+// @Produce json
+// @Security Bearer
+// @Param page query int false "页码" default(1)
+// @Param page_size query int false "每页数量" default(20)
+// @Param user_id query int false "被操作的用户ID"
+// @Param actor_id query int false "管理员ID"
+// @Param action query string false "操作类型，如 user.impersonate"
+// @Success 200 {array} AdminAuditLog
+// @Router /admin/audit-logs [get]
+func (h *UserHandler) ListAuditLogs(c *gin.Context) {
+	var query AdminAuditQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	entries, total, err := h.service.ListAuditLogs(c.Request.Context(), &query)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		logger.Error("获取管理员操作记录失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取管理员操作记录失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, userInfo)
+	c.JSON(http.StatusOK, gin.H{"total": total, "list": entries})
+}
+
+// adminRequest 读取当前管理员和路径中的用户 ID，失败时已写入响应
+func adminRequest(c *gin.Context) (AdminActor, uint, bool) {
+	principal, ok := auth.FromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权访问"})
+		return AdminActor{}, 0, false
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return AdminActor{}, 0, false
+	}
+	return AdminActor{ID: principal.UserID, IP: c.ClientIP()}, uint(userID), true
+}
+
+// respondAdminError 将管理操作的错误映射为响应状态码
+func respondAdminError(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, ErrAdminSelfAction), errors.Is(err, ErrAdminTarget):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrAccountDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error(message+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
 }
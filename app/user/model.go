@@ -1,6 +1,7 @@
 package user
 
 import (
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -12,15 +13,22 @@ type User struct {
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
-	Username  string         `gorm:"size:50;not null" json:"username"`
-	Password  string         `gorm:"size:100;not null" json:"-"`
-	Email     string         `gorm:"size:100;not null;unique" json:"email"`
+	Username  string         `gorm:"size:50;not null;uniqueIndex" json:"username"` // lower case
+	Password  string         `gorm:"size:255;not null" json:"-"`
+	Email     string         `gorm:"size:100;not null;unique" json:"email"` // lower case
 	Nickname  string         `gorm:"size:50" json:"nickname"`
 	Avatar    string         `gorm:"size:255" json:"avatar"`
 	Phone     string         `gorm:"size:20" json:"phone"`
 	Bio       string         `gorm:"size:500" json:"bio"`
 	Status    int            `gorm:"default:1" json:"status"` // 1: active, 0: disabled
 	LastLogin *time.Time     `json:"last_login"`
+	// EmailVerifiedAt is nil until the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// AvatarURLs maps each square size in pixels ("64", "256", ...) to the
+	// uploaded avatar image; empty when Avatar is an external URL
+	AvatarURLs map[string]string `gorm:"serializer:json;type:text" json:"avatar_urls,omitempty"`
+	// AvatarKeys are the storage keys of the uploaded images, deleted when replaced
+	AvatarKeys []string `gorm:"serializer:json;type:text" json:"-"`
 }
 
 // TableName specifies the database table name
@@ -28,6 +36,22 @@ func (User) TableName() string {
 	return "users"
 }
 
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// NormalizeUsername returns the stored form of a username. Usernames are
+// case-insensitive, so they are compared and stored in lower case.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// NormalizeEmail returns the stored form of an email address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 // UserInfo represents user information data transfer object
 type UserInfo struct {
 	ID        uint       `json:"id"`
@@ -39,4 +63,119 @@ type UserInfo struct {
 	Bio       string     `json:"bio"`
 	Status    int        `json:"status"`
 	LastLogin *time.Time `json:"last_login"`
+
+	EmailVerifiedAt *time.Time        `json:"email_verified_at"`
+	AvatarURLs      map[string]string `json:"avatar_urls,omitempty"`
+}
+
+// PasswordResetToken is a single-use password reset token. Only the SHA-256
+// of the token is stored; the raw value is sent to the user by email.
+type PasswordResetToken struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	IP        string     `gorm:"size:45" json:"ip"`
+}
+
+// TableName specifies the database table name
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
+
+// LoginCode is a single-use passwordless sign-in sent by email. The magic
+// link and the 6-digit code of one request share a row; only their hashes
+// are stored.
+type LoginCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Email     string     `gorm:"size:255;not null;index" json:"email"` // lower-cased
+	LinkHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"` // wrong codes entered
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	IP        string     `gorm:"size:45" json:"ip"`
+}
+
+// TableName specifies the database table name
+func (LoginCode) TableName() string {
+	return "login_codes"
+}
+
+// PasswordHistory keeps the hashes of a user's recent passwords so
+// they cannot be reused. Only the newest Password.HistorySize rows are kept.
+type PasswordHistory struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UserID       uint      `gorm:"not null;index" json:"user_id"`
+	PasswordHash string    `gorm:"size:255;not null" json:"-"`
+}
+
+// TableName specifies the database table name
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+// UsernameHistory records a username change. Until ExpiresAt the old
+// username resolves to the user and cannot be claimed by anyone else.
+type UsernameHistory struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	OldUsername string    `gorm:"size:50;not null;index" json:"old_username"`
+	NewUsername string    `gorm:"size:50;not null" json:"new_username"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`
+}
+
+// TableName specifies the database table name
+func (UsernameHistory) TableName() string {
+	return "username_histories"
+}
+
+// LoginAttempt is the database-backed failed login counter of one lockout key
+// ("account:<id>" or "ip:<address>"), shared by all instances.
+type LoginAttempt struct {
+	Key         string    `gorm:"primaryKey;size:100" json:"key"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Failures    int       `gorm:"not null;default:0" json:"failures"`
+	WindowStart time.Time `json:"window_start"`
+	Lockouts    int       `gorm:"not null;default:0" json:"lockouts"`
+	LockedUntil time.Time `gorm:"index" json:"locked_until"`
+}
+
+// TableName specifies the database table name
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// Admin audit actions
+const (
+	AuditActionDisable       = "user.disable"
+	AuditActionEnable        = "user.enable"
+	AuditActionPasswordReset = "user.password_reset"
+	AuditActionImpersonate   = "user.impersonate"
+	AuditActionDelete        = "user.delete"
+	AuditActionUnlock        = "user.unlock"
+	AuditActionRestore       = "user.restore"
+)
+
+// AdminAuditLog records an administrator action on a user account. Rows are
+// kept after the target user is deleted.
+type AdminAuditLog struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+	ActorID      uint      `gorm:"not null;index" json:"actor_id"`       // Administrator who acted
+	TargetUserID uint      `gorm:"not null;index" json:"target_user_id"` // User acted on
+	Action       string    `gorm:"size:50;not null;index" json:"action"`
+	IP           string    `gorm:"size:45" json:"ip"`      // Administrator's client IP
+	Detail       string    `gorm:"size:500" json:"detail"` // Free text, e.g. the impersonation reason
+}
+
+// TableName specifies the database table name
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
 }
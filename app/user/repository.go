@@ -2,8 +2,14 @@ package user
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/llamacto/llama-gin-kit/pkg/lockout"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // UserRepository interface for user data access
@@ -13,10 +19,35 @@ type UserRepository interface {
 	Delete(ctx context.Context, id uint) error
 	Get(ctx context.Context, id uint) (*User, error)
 	List(ctx context.Context, page, pageSize int) ([]*User, int64, error)
+	Search(ctx context.Context, query *AdminUserQuery) ([]*User, int64, error)
+	HardDelete(ctx context.Context, id uint) error
+	Anonymize(ctx context.Context, id uint) ([]string, error)
+	Restore(ctx context.Context, id uint) (bool, error)
 	GetByUsername(ctx context.Context, username string) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	ExistsByUsername(ctx context.Context, username string) (bool, error)
+	UsernameHeld(ctx context.Context, username string, exceptUserID uint, now time.Time) (bool, error)
+	ResolveOldUsername(ctx context.Context, username string, now time.Time) (*User, error)
+	ChangeUsername(ctx context.Context, entry *UsernameHistory) (bool, error)
+	CountUsernameChangesSince(ctx context.Context, userID uint, since time.Time) (int64, error)
 	FindByID(id uint) (*UserInfo, error)
+	CreatePasswordReset(ctx context.Context, reset *PasswordResetToken) error
+	GetPasswordResetByHash(ctx context.Context, hash string) (*PasswordResetToken, error)
+	ConsumePasswordReset(ctx context.Context, reset *PasswordResetToken, passwordHash string, at time.Time) (bool, error)
+	InvalidatePasswordResets(ctx context.Context, userID uint, at time.Time) error
+	RehashPassword(ctx context.Context, userID uint, oldHash, newHash string) error
+	SetAvatar(ctx context.Context, userID uint, avatar string, urls map[string]string, keys []string) error
+	RecentPasswordHashes(ctx context.Context, userID uint, limit int) ([]string, error)
+	CountLoginCodesSince(ctx context.Context, email string, since time.Time) (int64, error)
+	CreateLoginCode(ctx context.Context, code *LoginCode) error
+	GetLoginCodeByLinkHash(ctx context.Context, hash string) (*LoginCode, error)
+	GetActiveLoginCode(ctx context.Context, email string, now time.Time) (*LoginCode, error)
+	IncrementLoginCodeAttempts(ctx context.Context, id uint) error
+	ConsumeLoginCode(ctx context.Context, id uint, at time.Time) (bool, error)
+	AddPasswordHistory(ctx context.Context, entry *PasswordHistory, keep int) error
+	CreateAuditLog(ctx context.Context, entry *AdminAuditLog) error
+	ListAuditLogs(ctx context.Context, query *AdminAuditQuery) ([]*AdminAuditLog, int64, error)
 }
 
 // UserRepositoryImpl implementation of UserRepository
@@ -31,14 +62,22 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 
 // Create adds a new user
 func (r *UserRepositoryImpl) Create(ctx context.Context, user *User) error {
+	normalizeUser(user)
 	return r.db.WithContext(ctx).Create(user).Error
 }
 
 // Update modifies an existing user
 func (r *UserRepositoryImpl) Update(ctx context.Context, user *User) error {
+	normalizeUser(user)
 	return r.db.WithContext(ctx).Save(user).Error
 }
 
+// normalizeUser lower-cases the username and email so uniqueness is case-insensitive
+func normalizeUser(user *User) {
+	user.Username = NormalizeUsername(user.Username)
+	user.Email = NormalizeEmail(user.Email)
+}
+
 // Delete removes a user by ID
 func (r *UserRepositoryImpl) Delete(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).Delete(&User{}, id).Error
@@ -70,10 +109,112 @@ func (r *UserRepositoryImpl) List(ctx context.Context, page, pageSize int) ([]*U
 	return users, total, nil
 }
 
+// Search retrieves users matching the admin filters, newest first
+func (r *UserRepositoryImpl) Search(ctx context.Context, query *AdminUserQuery) ([]*User, int64, error) {
+	db := r.db.WithContext(ctx).Model(&User{})
+	if query.Query != "" {
+		like := "%" + strings.ToLower(query.Query) + "%"
+		db = db.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ? OR LOWER(nickname) LIKE ?", like, like, like)
+	}
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+	if query.EmailVerified != nil {
+		if *query.EmailVerified {
+			db = db.Where("email_verified_at IS NOT NULL")
+		} else {
+			db = db.Where("email_verified_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []*User
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// HardDelete permanently removes a user and their password reset tokens
+func (r *UserRepositoryImpl) HardDelete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := deleteCredentials(tx, id); err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&User{}, id).Error
+	})
+}
+
+// Anonymize removes the personal data of a deleted user. The row itself is
+// kept, soft-deleted, so audit records still resolve to a user ID. The
+// storage keys of the user's uploaded avatar images are returned for the
+// caller to delete.
+func (r *UserRepositoryImpl) Anonymize(ctx context.Context, id uint) ([]string, error) {
+	var avatarKeys []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Unscoped().Select("id", "avatar_keys").First(&user, id).Error; err != nil {
+			return err
+		}
+		avatarKeys = user.AvatarKeys
+
+		if err := deleteCredentials(tx, id); err != nil {
+			return err
+		}
+		placeholder := fmt.Sprintf("deleted-%d", id)
+		return tx.Unscoped().Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"username":          placeholder,
+			"email":             placeholder + "@erased.invalid",
+			"password":          "",
+			"nickname":          "",
+			"avatar":            "",
+			"avatar_urls":       nil,
+			"avatar_keys":       nil,
+			"phone":             "",
+			"bio":               "",
+			"status":            0,
+			"last_login":        nil,
+			"email_verified_at": nil,
+			"deleted_at":        gorm.Expr("COALESCE(deleted_at, ?)", time.Now()),
+		}).Error
+	})
+	return avatarKeys, err
+}
+
+// Restore undoes the soft delete of a user. It reports false if the user
+// does not exist or is not deleted.
+func (r *UserRepositoryImpl) Restore(ctx context.Context, id uint) (bool, error) {
+	result := r.db.WithContext(ctx).Unscoped().Model(&User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// deleteCredentials removes the user's reset tokens, sign-in codes, password
+// history and username history
+func deleteCredentials(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&PasswordResetToken{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&LoginCode{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&UsernameHistory{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&PasswordHistory{}).Error
+}
+
 // GetByUsername retrieves a user by username
 func (r *UserRepositoryImpl) GetByUsername(ctx context.Context, username string) (*User, error) {
 	var user User
-	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("username = ?", NormalizeUsername(username)).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -82,21 +223,87 @@ func (r *UserRepositoryImpl) GetByUsername(ctx context.Context, username string)
 // GetByEmail retrieves a user by email
 func (r *UserRepositoryImpl) GetByEmail(ctx context.Context, email string) (*User, error) {
 	var user User
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("email = ?", NormalizeEmail(email)).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// ExistsByEmail checks if an email is already registered
+// ExistsByEmail checks if an email is already registered. Deleted accounts
+// keep their email until they are erased.
 func (r *UserRepositoryImpl) ExistsByEmail(ctx context.Context, email string) (bool, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Model(&User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+	if err := r.db.WithContext(ctx).Unscoped().Model(&User{}).Where("email = ?", NormalizeEmail(email)).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ExistsByUsername checks if a username is taken, including by deleted accounts
+func (r *UserRepositoryImpl) ExistsByUsername(ctx context.Context, username string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&User{}).Where("username = ?", NormalizeUsername(username)).Count(&count).Error; err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
 
+// UsernameHeld reports whether another user gave up the username recently
+// enough that it still redirects to them
+func (r *UserRepositoryImpl) UsernameHeld(ctx context.Context, username string, exceptUserID uint, now time.Time) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&UsernameHistory{}).
+		Where("old_username = ? AND user_id <> ? AND expires_at > ?", NormalizeUsername(username), exceptUserID, now).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ResolveOldUsername finds the user who most recently gave up the username,
+// while it still redirects to them
+func (r *UserRepositoryImpl) ResolveOldUsername(ctx context.Context, username string, now time.Time) (*User, error) {
+	var entry UsernameHistory
+	if err := r.db.WithContext(ctx).
+		Where("old_username = ? AND expires_at > ?", NormalizeUsername(username), now).
+		Order("created_at DESC").
+		First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, entry.UserID)
+}
+
+// ChangeUsername renames the user and records the old username. It reports
+// false if the username was changed meanwhile.
+func (r *UserRepositoryImpl) ChangeUsername(ctx context.Context, entry *UsernameHistory) (bool, error) {
+	changed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&User{}).
+			Where("id = ? AND username = ?", entry.UserID, entry.OldUsername).
+			Update("username", entry.NewUsername)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		// Taking back one of your own old usernames ends its redirect; the
+		// entry is kept because it counts towards the change limit
+		if err := tx.Model(&UsernameHistory{}).
+			Where("user_id = ? AND old_username = ? AND expires_at > ?", entry.UserID, entry.NewUsername, entry.CreatedAt).
+			Update("expires_at", entry.CreatedAt).Error; err != nil {
+			return err
+		}
+		changed = true
+		return tx.Create(entry).Error
+	})
+	return changed, err
+}
+
+// CountUsernameChangesSince counts the user's username changes since the given time
+func (r *UserRepositoryImpl) CountUsernameChangesSince(ctx context.Context, userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&UsernameHistory{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
 // FindByID retrieves user information by ID
 func (r *UserRepositoryImpl) FindByID(id uint) (*UserInfo, error) {
 	var user User
@@ -114,5 +321,258 @@ func (r *UserRepositoryImpl) FindByID(id uint) (*UserInfo, error) {
 		Bio:       user.Bio,
 		Status:    user.Status,
 		LastLogin: user.LastLogin,
+
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		AvatarURLs:      user.AvatarURLs,
 	}, nil
 }
+
+// CreatePasswordReset stores a new password reset token
+func (r *UserRepositoryImpl) CreatePasswordReset(ctx context.Context, reset *PasswordResetToken) error {
+	return r.db.WithContext(ctx).Create(reset).Error
+}
+
+// GetPasswordResetByHash retrieves a password reset token by its hash
+func (r *UserRepositoryImpl) GetPasswordResetByHash(ctx context.Context, hash string) (*PasswordResetToken, error) {
+	var reset PasswordResetToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&reset).Error; err != nil {
+		return nil, err
+	}
+	return &reset, nil
+}
+
+// ConsumePasswordReset marks the token used and sets the new password in one
+// transaction. It reports false if the token was already used or has expired.
+func (r *UserRepositoryImpl) ConsumePasswordReset(ctx context.Context, reset *PasswordResetToken, passwordHash string, at time.Time) (bool, error) {
+	consumed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", reset.ID, at).
+			Update("used_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", reset.UserID).Update("password", passwordHash).Error; err != nil {
+			return err
+		}
+		// Any other outstanding links for this user are no longer needed
+		if err := tx.Model(&PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", reset.UserID).
+			Update("used_at", at).Error; err != nil {
+			return err
+		}
+		consumed = true
+		return nil
+	})
+	return consumed, err
+}
+
+// InvalidatePasswordResets marks every unused reset token of a user as used
+func (r *UserRepositoryImpl) InvalidatePasswordResets(ctx context.Context, userID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", at).Error
+}
+
+// lockoutStore is the database-backed lockout.Store
+type lockoutStore struct {
+	db *gorm.DB
+}
+
+// RehashPassword replaces the stored password hash with an upgraded hash of
+// the same password. Nothing changes if the password was changed meanwhile.
+func (r *UserRepositoryImpl) RehashPassword(ctx context.Context, userID uint, oldHash, newHash string) error {
+	return r.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND password = ?", userID, oldHash).
+		UpdateColumn("password", newHash).Error
+}
+
+// SetAvatar replaces the user's avatar URL and uploaded avatar images
+func (r *UserRepositoryImpl) SetAvatar(ctx context.Context, userID uint, avatar string, urls map[string]string, keys []string) error {
+	return r.db.WithContext(ctx).Model(&User{ID: userID}).
+		Select("avatar", "avatar_urls", "avatar_keys").
+		Updates(&User{Avatar: avatar, AvatarURLs: urls, AvatarKeys: keys}).Error
+}
+
+// CountLoginCodesSince counts the passwordless sign-ins sent to an email address since the given time
+func (r *UserRepositoryImpl) CountLoginCodesSince(ctx context.Context, email string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&LoginCode{}).
+		Where("email = ? AND created_at >= ?", email, since).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateLoginCode stores a passwordless sign-in and invalidates the unused
+// ones previously sent to the same email address
+func (r *UserRepositoryImpl) CreateLoginCode(ctx context.Context, code *LoginCode) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&LoginCode{}).
+			Where("email = ? AND used_at IS NULL", code.Email).
+			Update("used_at", code.CreatedAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(code).Error
+	})
+}
+
+// GetLoginCodeByLinkHash finds a passwordless sign-in by the SHA-256 of its magic link token
+func (r *UserRepositoryImpl) GetLoginCodeByLinkHash(ctx context.Context, hash string) (*LoginCode, error) {
+	var code LoginCode
+	if err := r.db.WithContext(ctx).Where("link_hash = ?", hash).First(&code).Error; err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetActiveLoginCode returns the newest unused, unexpired passwordless sign-in of an email address
+func (r *UserRepositoryImpl) GetActiveLoginCode(ctx context.Context, email string, now time.Time) (*LoginCode, error) {
+	var code LoginCode
+	err := r.db.WithContext(ctx).
+		Where("email = ? AND used_at IS NULL AND expires_at > ?", email, now).
+		Order("id DESC").
+		First(&code).Error
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// IncrementLoginCodeAttempts counts a wrong code entered for a passwordless sign-in
+func (r *UserRepositoryImpl) IncrementLoginCodeAttempts(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Model(&LoginCode{}).
+		Where("id = ?", id).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// ConsumeLoginCode marks a passwordless sign-in as used. It reports false if
+// another request used it first.
+func (r *UserRepositoryImpl) ConsumeLoginCode(ctx context.Context, id uint, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&LoginCode{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RecentPasswordHashes returns the hashes of the user's newest passwords
+func (r *UserRepositoryImpl) RecentPasswordHashes(ctx context.Context, userID uint, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.WithContext(ctx).Model(&PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("password_hash", &hashes).Error
+	return hashes, err
+}
+
+// AddPasswordHistory records a new password and drops all but the newest keep entries
+func (r *UserRepositoryImpl) AddPasswordHistory(ctx context.Context, entry *PasswordHistory, keep int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		var keepIDs []uint
+		if err := tx.Model(&PasswordHistory{}).
+			Where("user_id = ?", entry.UserID).
+			Order("id DESC").
+			Limit(keep).
+			Pluck("id", &keepIDs).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND id NOT IN ?", entry.UserID, keepIDs).Delete(&PasswordHistory{}).Error
+	})
+}
+
+// CreateAuditLog records an administrator action
+func (r *UserRepositoryImpl) CreateAuditLog(ctx context.Context, entry *AdminAuditLog) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// ListAuditLogs retrieves administrator actions, newest first
+func (r *UserRepositoryImpl) ListAuditLogs(ctx context.Context, query *AdminAuditQuery) ([]*AdminAuditLog, int64, error) {
+	db := r.db.WithContext(ctx).Model(&AdminAuditLog{})
+	if query.UserID != 0 {
+		db = db.Where("target_user_id = ?", query.UserID)
+	}
+	if query.ActorID != 0 {
+		db = db.Where("actor_id = ?", query.ActorID)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var entries []*AdminAuditLog
+	if err := db.Order("id DESC").Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// NewLockoutStore creates a login lockout store persisted in the database
+func NewLockoutStore(db *gorm.DB) lockout.Store {
+	return &lockoutStore{db: db}
+}
+
+// Get returns the failure record of a key, or nil if there is none
+func (s *lockoutStore) Get(ctx context.Context, key string) (*lockout.Record, error) {
+	var attempt LoginAttempt
+	if err := s.db.WithContext(ctx).First(&attempt, "key = ?", key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return attempt.record(), nil
+}
+
+// Update applies fn to the key's record while holding its row lock
+func (s *lockoutStore) Update(ctx context.Context, key string, fn func(record *lockout.Record)) (*lockout.Record, error) {
+	var record *lockout.Record
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&LoginAttempt{Key: key}).Error; err != nil {
+			return err
+		}
+		var attempt LoginAttempt
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&attempt, "key = ?", key).Error; err != nil {
+			return err
+		}
+
+		record = attempt.record()
+		fn(record)
+		attempt.Failures = record.Failures
+		attempt.WindowStart = record.WindowStart
+		attempt.Lockouts = record.Lockouts
+		attempt.LockedUntil = record.LockedUntil
+		return tx.Save(&attempt).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Reset removes the failure record of a key
+func (s *lockoutStore) Reset(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
+
+func (a *LoginAttempt) record() *lockout.Record {
+	return &lockout.Record{
+		Key:         a.Key,
+		Failures:    a.Failures,
+		WindowStart: a.WindowStart,
+		Lockouts:    a.Lockouts,
+		LockedUntil: a.LockedUntil,
+	}
+}
@@ -1,16 +1,39 @@
 package user
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
+	"github.com/llamacto/llama-gin-kit/app/authorization"
+	"github.com/llamacto/llama-gin-kit/app/identity"
+	"github.com/llamacto/llama-gin-kit/app/mfa"
+	"github.com/llamacto/llama-gin-kit/app/token"
+	"github.com/llamacto/llama-gin-kit/config"
 	"github.com/llamacto/llama-gin-kit/pkg/email"
-	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"github.com/llamacto/llama-gin-kit/pkg/hasher"
+	"github.com/llamacto/llama-gin-kit/pkg/imaging"
+	"github.com/llamacto/llama-gin-kit/pkg/lockout"
 	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/password"
+	"github.com/llamacto/llama-gin-kit/pkg/storage"
 	"github.com/llamacto/llama-gin-kit/pkg/utils"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 // UserService User 服务接口
@@ -21,24 +44,245 @@ type UserService interface {
 	Get(ctx context.Context, id uint) (*User, error)
 	List(ctx context.Context, page, pageSize int) ([]*User, int64, error)
 	Register(req *UserRegisterRequest) (*User, error)
-	Login(req *UserLoginRequest) (*UserLoginResponse, error)
+	Login(req *UserLoginRequest, client token.ClientInfo) (*UserLoginResponse, error)
+	LoginMFA(req *UserLoginMFARequest, client token.ClientInfo) (*UserLoginResponse, error)
+	RequestPasswordlessLogin(req *UserPasswordlessRequest, client token.ClientInfo) error
+	PasswordlessLogin(req *UserPasswordlessLoginRequest, client token.ClientInfo) (*UserLoginResponse, error)
+	BeginExternalLogin(ctx context.Context, provider string) (*identity.Flow, error)
+	CompleteExternalLogin(ctx context.Context, provider, flowToken, state, code string, client token.ClientInfo) (*UserLoginResponse, error)
 	UpdateProfile(userID uint, req *UserUpdateRequest) (*User, error)
+	ChangeUsername(ctx context.Context, userID uint, req *UserChangeUsernameRequest) (*User, error)
+	ResolveUsername(ctx context.Context, username string) (*User, bool, error)
+	UploadAvatar(ctx context.Context, userID uint, file io.Reader) (*User, error)
+	DeleteAvatar(ctx context.Context, userID uint) (*User, error)
 	ChangePassword(userID uint, req *UserChangePasswordRequest) error
-	ResetPassword(req *UserPasswordResetRequest) error
+	ResetPassword(req *UserPasswordResetRequest, client token.ClientInfo) error
+	ConfirmPasswordReset(req *UserPasswordResetConfirmRequest) error
+	VerifyEmail(req *UserVerifyEmailRequest) (*User, error)
+	ResendVerification(req *UserResendVerificationRequest) error
+	IsEmailVerified(ctx context.Context, userID uint) (bool, error)
 	GetProfile(userID uint) (*User, error)
 	DeleteAccount(userID uint) error
 	GetUserByID(id uint) (*UserInfo, error)
 	GetByID(id uint) (*User, error)
+	UnlockAccount(ctx context.Context, adminID, userID uint) error
+	UnlockIP(ctx context.Context, adminID uint, ip string) error
+	SearchUsers(ctx context.Context, query *AdminUserQuery) ([]*User, int64, error)
+	DisableUser(ctx context.Context, actor AdminActor, userID uint) error
+	EnableUser(ctx context.Context, actor AdminActor, userID uint) error
+	ForcePasswordReset(ctx context.Context, actor AdminActor, userID uint) error
+	Impersonate(ctx context.Context, actor AdminActor, userID uint, reason string) (*AdminImpersonateResponse, error)
+	HardDelete(ctx context.Context, actor AdminActor, userID uint) error
+	EraseAccount(ctx context.Context, userID uint) error
+	RestoreAccount(ctx context.Context, actor AdminActor, userID uint) error
+	ListAuditLogs(ctx context.Context, query *AdminAuditQuery) ([]*AdminAuditLog, int64, error)
 }
 
+// RoleChecker 查询用户是否持有系统角色
+type RoleChecker interface {
+	HasSystemRole(ctx context.Context, userID uint, roles ...string) (bool, error)
+}
+
+var (
+	// ErrInvalidResetToken 重置链接无效、已使用或已过期
+	ErrInvalidResetToken = errors.New("重置链接无效或已过期")
+	// ErrInvalidVerificationToken 邮箱验证链接无效或已过期
+	ErrInvalidVerificationToken = errors.New("验证链接无效或已过期")
+	// ErrEmailNotVerified 邮箱尚未验证
+	ErrEmailNotVerified = errors.New("邮箱尚未验证，请先完成邮箱验证")
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrExternalEmailRequired 外部身份未提供邮箱，无法关联或创建账户
+	ErrExternalEmailRequired = errors.New("外部账户未提供邮箱地址")
+	// ErrExternalEmailConflict 邮箱已被未验证的本地账户使用，不能自动关联
+	ErrExternalEmailConflict = errors.New("该邮箱已注册，请使用密码登录并验证邮箱后再关联外部账户")
+	// ErrExternalSignupDisabled 不允许通过外部身份注册新账户
+	ErrExternalSignupDisabled = errors.New("该外部账户未关联任何用户")
+	// ErrAccountDisabled 账户已被禁用
+	ErrAccountDisabled = errors.New("账户已被禁用")
+	// ErrAdminSelfAction 管理员不能对自己的账户执行该操作
+	ErrAdminSelfAction = errors.New("不能对自己的账户执行该操作")
+	// ErrAdminTarget 不能对其他管理员执行该操作
+	ErrAdminTarget = errors.New("不能对管理员账户执行该操作")
+	// ErrPasswordlessDisabled 未开启免密登录
+	ErrPasswordlessDisabled = errors.New("未开启免密登录")
+	// ErrInvalidLoginCode 登录链接或验证码无效、已使用或已过期
+	ErrInvalidLoginCode = errors.New("登录链接或验证码无效或已过期")
+	// ErrAvatarUnavailable 未配置对象存储，无法上传头像
+	ErrAvatarUnavailable = errors.New("头像上传功能未启用")
+	// ErrAvatarTooLarge 头像文件超过大小限制
+	ErrAvatarTooLarge = errors.New("头像文件过大")
+	// ErrAvatarUnsupported 头像不是 JPEG、PNG 或 GIF 图片
+	ErrAvatarUnsupported = errors.New("头像仅支持 JPEG、PNG 或 GIF 格式")
+	// ErrInvalidAvatar 头像无法解码或分辨率过高
+	ErrInvalidAvatar = errors.New("头像图片无效或分辨率过高")
+	// ErrInvalidUsername 用户名长度或字符不符合要求
+	ErrInvalidUsername = errors.New("用户名格式无效")
+	// ErrUsernameReserved 用户名为系统保留
+	ErrUsernameReserved = errors.New("该用户名为系统保留")
+	// ErrUsernameTaken 用户名已被使用，或仍为其他用户保留
+	ErrUsernameTaken = errors.New("用户名已被占用")
+	// ErrUsernameChangeLimited 修改用户名次数超过限制
+	ErrUsernameChangeLimited = errors.New("修改用户名过于频繁，请稍后再试")
+)
+
+// usernamePattern 用户名只能包含小写字母、数字、下划线、连字符和点，且以字母或数字开头和结尾
+var usernamePattern = regexp.MustCompile(`^[a-z0-9](?:[a-z0-9._-]*[a-z0-9])?$`)
+
 // UserServiceImpl User 服务实现
 type UserServiceImpl struct {
-	repo UserRepository
+	repo       UserRepository
+	tokens     token.Service
+	mfa        mfa.Service
+	identities identity.Service
+	guard      *lockout.Guard
+	hasher     hasher.Hasher
+	passwords  *PasswordValidator
+	avatars    *AvatarUploader
+	roles      RoleChecker
+	auth       config.AuthConfig
+	usernames  config.UsernameConfig
+	oidc       config.OIDCConfig
 }
 
 // NewUserService 创建 User 服务
-func NewUserService(repo UserRepository) *UserServiceImpl {
-	return &UserServiceImpl{repo: repo}
+func NewUserService(repo UserRepository, tokens token.Service, mfaService mfa.Service, identities identity.Service, guard *lockout.Guard, h hasher.Hasher, passwords *PasswordValidator, avatars *AvatarUploader, roles RoleChecker, authCfg config.AuthConfig, usernameCfg config.UsernameConfig, oidcCfg config.OIDCConfig) *UserServiceImpl {
+	return &UserServiceImpl{repo: repo, tokens: tokens, mfa: mfaService, identities: identities, guard: guard, hasher: h, passwords: passwords, avatars: avatars, roles: roles, auth: authCfg, usernames: usernameCfg, oidc: oidcCfg}
+}
+
+// PasswordValidator 校验新密码是否符合密码策略、是否出现在泄露密码列表中，以及是否与近期密码重复
+type PasswordValidator struct {
+	policy      password.Policy
+	breached    *password.BreachChecker
+	historySize int
+}
+
+// NewPasswordValidator 根据配置创建新密码校验器，配置了泄露密码列表时在此加载
+func NewPasswordValidator(cfg config.PasswordConfig) (*PasswordValidator, error) {
+	v := &PasswordValidator{
+		policy: password.Policy{
+			MinLength:      cfg.MinLength,
+			MaxLength:      cfg.MaxLength,
+			RequireUpper:   cfg.RequireUpper,
+			RequireLower:   cfg.RequireLower,
+			RequireDigit:   cfg.RequireDigit,
+			RequireSymbol:  cfg.RequireSymbol,
+			MinCharClasses: cfg.MinCharClasses,
+			RejectUserInfo: cfg.RejectUserInfo,
+		},
+		historySize: cfg.HistorySize,
+	}
+	if cfg.BreachedListPath != "" {
+		breached, err := password.OpenBreachedList(cfg.BreachedListPath)
+		if err != nil {
+			return nil, err
+		}
+		v.breached = breached
+	}
+	return v, nil
+}
+
+// IsPasswordRejected 判断错误是否表示新密码被拒绝，应作为请求错误返回
+func IsPasswordRejected(err error) bool {
+	return errors.Is(err, password.ErrPolicy) || errors.Is(err, password.ErrBreached) || errors.Is(err, password.ErrReused)
+}
+
+// AvatarUploader 校验上传的头像，重新编码为多个尺寸的方形图片并写入对象存储
+type AvatarUploader struct {
+	store storage.Store
+	cfg   config.AvatarConfig
+}
+
+// NewAvatarUploader 创建头像上传器，store 为 nil 时头像上传不可用
+func NewAvatarUploader(store storage.Store, cfg config.AvatarConfig) *AvatarUploader {
+	return &AvatarUploader{store: store, cfg: cfg}
+}
+
+// upload 生成并上传各尺寸的头像，返回最大尺寸的地址、各尺寸地址和存储键
+// 重新编码会丢弃 EXIF 等元数据
+func (u *AvatarUploader) upload(userID uint, file io.Reader) (string, map[string]string, []string, error) {
+	if u == nil || u.store == nil {
+		return "", nil, nil, ErrAvatarUnavailable
+	}
+
+	maxBytes := int64(u.cfg.MaxSizeKB) * 1024
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("读取头像失败: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return "", nil, nil, ErrAvatarTooLarge
+	}
+
+	img, format, err := imaging.Decode(data, u.cfg.MaxDimension)
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return "", nil, nil, ErrAvatarUnsupported
+	case err != nil:
+		return "", nil, nil, ErrInvalidAvatar
+	}
+
+	// PNG 和 GIF 可能含透明像素，统一保存为 PNG
+	ext, contentType := ".jpg", "image/jpeg"
+	if format != imaging.FormatJPEG {
+		format, ext, contentType = imaging.FormatPNG, ".png", "image/png"
+	}
+
+	prefix := fmt.Sprintf("avatars/%d/%s", userID, uuid.NewString())
+	var (
+		avatar  string
+		largest int
+		urls    = make(map[string]string, len(u.cfg.Sizes))
+		keys    = make([]string, 0, len(u.cfg.Sizes))
+	)
+	for _, size := range u.cfg.Sizes {
+		var buf bytes.Buffer
+		if err := imaging.Encode(&buf, imaging.Square(img, size), format, u.cfg.JPEGQuality); err != nil {
+			u.remove(keys)
+			return "", nil, nil, fmt.Errorf("生成头像失败: %w", err)
+		}
+		key := fmt.Sprintf("%s_%d%s", prefix, size, ext)
+		location, err := u.store.UploadFile(buf.Bytes(), key, contentType)
+		if err != nil {
+			u.remove(keys)
+			return "", nil, nil, fmt.Errorf("上传头像失败: %w", err)
+		}
+		keys = append(keys, key)
+		urls[strconv.Itoa(size)] = location
+		if size > largest {
+			avatar, largest = location, size
+		}
+	}
+	return avatar, urls, keys, nil
+}
+
+// remove 删除已上传的头像图片，失败只记录日志
+func (u *AvatarUploader) remove(keys []string) {
+	if u == nil || u.store == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := u.store.DeleteFile(key); err != nil {
+			logger.Error("删除头像图片失败:", err)
+		}
+	}
+}
+
+// NewLoginGuard 根据配置创建登录失败锁定器
+func NewLoginGuard(db *gorm.DB, cfg config.AuthConfig) *lockout.Guard {
+	var store lockout.Store
+	if cfg.LoginAttemptStore == config.LoginAttemptStoreDatabase {
+		store = NewLockoutStore(db)
+	} else {
+		store = lockout.NewMemoryStore(cfg.LoginMaxLockoutDuration + cfg.LoginAttemptWindowDuration)
+	}
+	return lockout.NewGuard(store, lockout.Policy{
+		MaxAttempts:   cfg.LoginMaxAttempts,
+		IPMaxAttempts: cfg.LoginIPMaxAttempts,
+		Window:        cfg.LoginAttemptWindowDuration,
+		Lockout:       cfg.LoginLockoutDuration,
+		MaxLockout:    cfg.LoginMaxLockoutDuration,
+	}, nil)
 }
 
 // Create 创建 User
@@ -51,85 +295,894 @@ func (s *UserServiceImpl) Update(ctx context.Context, model *User) error {
 	return s.repo.Update(ctx, model)
 }
 
-// Delete 删除 User
-func (s *UserServiceImpl) Delete(ctx context.Context, id uint) error {
-	return s.repo.Delete(ctx, id)
-}
+// Delete 删除 User
+func (s *UserServiceImpl) Delete(ctx context.Context, id uint) error {
+	return s.repo.Delete(ctx, id)
+}
+
+// Get 获取 User
+func (s *UserServiceImpl) Get(ctx context.Context, id uint) (*User, error) {
+	return s.repo.Get(ctx, id)
+}
+
+// List 获取 User 列表
+func (s *UserServiceImpl) List(ctx context.Context, page, pageSize int) ([]*User, int64, error) {
+	return s.repo.List(ctx, page, pageSize)
+}
+
+// Register 用户注册
+func (s *UserServiceImpl) Register(req *UserRegisterRequest) (*User, error) {
+	ctx := context.Background()
+
+	// 检查邮箱是否已存在
+	exists, err := s.repo.ExistsByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("邮箱已被注册")
+	}
+
+	username := NormalizeUsername(req.Username)
+	if err := s.checkUsernameAvailable(ctx, username, 0); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateNewPassword(ctx, &User{Username: username, Email: req.Email}, req.Password); err != nil {
+		return nil, err
+	}
+
+	// 加密密码
+	hashedPassword, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+
+	user := &User{
+		Username: username,
+		Email:    req.Email,
+		Password: hashedPassword,
+		Nickname: req.Nickname,
+		Phone:    req.Phone,
+		Status:   1,
+	}
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+	s.recordPassword(ctx, user.ID, user.Password)
+
+	// 发送邮箱验证邮件，验证通过后再发送欢迎邮件
+	if err := s.sendVerificationEmail(user); err != nil {
+		logger.Error("发送邮箱验证邮件失败:", err)
+	}
+
+	return user, nil
+}
+
+// Login 用户登录
+func (s *UserServiceImpl) Login(req *UserLoginRequest, client token.ClientInfo) (*UserLoginResponse, error) {
+	ctx := context.Background()
+
+	// 用户名不能包含 @，因此含 @ 的只按邮箱查找，避免用户名和邮箱匹配到不同账户
+	var user *User
+	var err error
+	if strings.Contains(req.Username, "@") {
+		user, err = s.repo.GetByEmail(ctx, req.Username)
+	} else {
+		user, err = s.repo.GetByUsername(ctx, req.Username)
+	}
+	if err != nil {
+		user = nil
+	}
+
+	// 不存在的用户名同样计数和锁定，避免通过锁定行为探测账户是否存在
+	account := strings.ToLower(req.Username)
+	if user != nil {
+		account = accountLockoutSubject(user.ID)
+	}
+	if err := s.checkLockout(ctx, account, client.IP); err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		return nil, s.loginFailed(ctx, account, client.IP)
+	}
+
+	if user.Status == 0 {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	if !s.verifyPassword(user, req.Password) {
+		return nil, s.loginFailed(ctx, account, client.IP)
+	}
+	s.upgradePasswordHash(ctx, user, req.Password)
+
+	if s.guard != nil {
+		if err := s.guard.Succeed(ctx, account); err != nil {
+			logger.Error("清除登录失败记录失败:", err)
+		}
+	}
+
+	return s.beginSession(ctx, user, client)
+}
+
+// beginSession 在身份确认后检查邮箱验证和两步验证，再签发令牌
+func (s *UserServiceImpl) beginSession(ctx context.Context, user *User, client token.ClientInfo) (*UserLoginResponse, error) {
+	// 在密码校验通过后才检查，避免泄露账户是否存在
+	if s.auth.EmailVerificationPolicy == config.EmailVerificationLogin && !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	// 开启两步验证的账户需先换取验证码，暂不签发令牌
+	mfaEnabled, err := s.mfa.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("查询两步验证状态失败: %w", err)
+	}
+	if mfaEnabled {
+		challenge, expiresAt, err := s.mfa.NewChallenge(user.ID)
+		if err != nil {
+			return nil, fmt.Errorf("生成两步验证凭证失败: %w", err)
+		}
+		return &UserLoginResponse{
+			MFARequired:  true,
+			MFAToken:     challenge,
+			MFAExpiresAt: &expiresAt,
+		}, nil
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// BeginExternalLogin 开始通过外部身份提供方登录
+func (s *UserServiceImpl) BeginExternalLogin(ctx context.Context, provider string) (*identity.Flow, error) {
+	return s.identities.Begin(ctx, provider)
+}
+
+// CompleteExternalLogin 校验外部身份提供方的回调，关联或创建账户后登录
+func (s *UserServiceImpl) CompleteExternalLogin(ctx context.Context, provider, flowToken, state, code string, client token.ClientInfo) (*UserLoginResponse, error) {
+	external, err := s.identities.Finish(ctx, provider, flowToken, state, code)
+	if err != nil {
+		return nil, err
+	}
+
+	user, linked, err := s.resolveExternalUser(ctx, external)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == 0 {
+		return nil, errors.New("账户已被禁用")
+	}
+	s.identities.RecordLogin(ctx, linked)
+
+	return s.beginSession(ctx, user, client)
+}
+
+// resolveExternalUser 查找外部身份关联的用户；未关联时按已验证邮箱关联已有账户，或创建新账户
+func (s *UserServiceImpl) resolveExternalUser(ctx context.Context, external *identity.External) (*User, *identity.Identity, error) {
+	linked, err := s.identities.Find(ctx, external.Provider, external.Subject)
+	if err == nil {
+		user, err := s.repo.Get(ctx, linked.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("查询关联用户失败: %w", err)
+		}
+		return user, linked, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("查询外部身份失败: %w", err)
+	}
+
+	if external.Email == "" {
+		return nil, nil, ErrExternalEmailRequired
+	}
+	user, err := s.repo.GetByEmail(ctx, external.Email)
+	switch {
+	case err == nil:
+		// 只有双方都确认过该邮箱才自动关联，防止他人预先用该邮箱注册后接管账户
+		if !external.EmailVerified || !user.IsEmailVerified() {
+			return nil, nil, ErrExternalEmailConflict
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if !s.oidc.AllowSignup {
+			return nil, nil, ErrExternalSignupDisabled
+		}
+		if user, err = s.createExternalUser(ctx, external); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("查询用户失败: %w", err)
+	}
+
+	linked, err = s.identities.Link(ctx, user.ID, external)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, linked, nil
+}
+
+// createExternalUser 为外部身份创建本地账户；密码随机生成，用户可通过重置密码设置
+func (s *UserServiceImpl) createExternalUser(ctx context.Context, external *identity.External) (*User, error) {
+	random, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("生成随机密码失败: %w", err)
+	}
+	hashedPassword, err := s.hasher.Hash(random)
+	if err != nil {
+		return nil, fmt.Errorf("密码加密失败: %w", err)
+	}
+	username, err := s.externalUsername(ctx, external)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &User{
+		Username: username,
+		Email:    external.Email,
+		Password: hashedPassword,
+		Nickname: truncate(external.Name, 50),
+		Status:   1,
+	}
+	if len(external.Picture) <= 255 {
+		user.Avatar = external.Picture
+	}
+	if external.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	if !user.IsEmailVerified() {
+		if err := s.sendVerificationEmail(user); err != nil {
+			logger.Error("发送邮箱验证邮件失败:", err)
+		}
+	}
+	return user, nil
+}
+
+// externalUsername 根据外部身份生成用户名，不可用时追加随机后缀
+func (s *UserServiceImpl) externalUsername(ctx context.Context, external *identity.External) (string, error) {
+	base := external.Username
+	if base == "" {
+		base, _, _ = strings.Cut(external.Email, "@")
+	}
+	base = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		}
+		return -1
+	}, NormalizeUsername(base))
+	// 留出随机后缀的长度
+	base = strings.Trim(truncate(base, s.usernames.MaxLength-7), "._-")
+	if len(base) < s.usernames.MinLength {
+		base = "user"
+	}
+
+	username := base
+	for i := 0; i < 5; i++ {
+		err := s.checkUsernameAvailable(ctx, username, 0)
+		if err == nil {
+			return username, nil
+		}
+		if !isUsernameRejected(err) {
+			return "", err
+		}
+		suffix, err := utils.GenerateSecureToken(4)
+		if err != nil {
+			return "", err
+		}
+		username = base + "_" + strings.ToLower(suffix[:6])
+	}
+	return username, nil
+}
+
+// validateUsername 校验用户名的长度、字符和保留名，name 须已规范化
+func (s *UserServiceImpl) validateUsername(name string) error {
+	length := utf8.RuneCountInString(name)
+	if length < s.usernames.MinLength || length > s.usernames.MaxLength {
+		return fmt.Errorf("%w: 长度须为 %d-%d 个字符", ErrInvalidUsername, s.usernames.MinLength, s.usernames.MaxLength)
+	}
+	if !usernamePattern.MatchString(name) {
+		return fmt.Errorf("%w: 只能包含小写字母、数字、下划线、连字符和点，且以字母或数字开头和结尾", ErrInvalidUsername)
+	}
+	// deleted- 前缀用于已擦除的账户
+	if strings.HasPrefix(name, "deleted-") {
+		return ErrUsernameReserved
+	}
+	for _, reserved := range s.usernames.Reserved {
+		if name == reserved {
+			return ErrUsernameReserved
+		}
+	}
+	return nil
+}
+
+// checkUsernameAvailable 校验用户名并确认未被使用，也未被其他用户改名后保留
+func (s *UserServiceImpl) checkUsernameAvailable(ctx context.Context, name string, userID uint) error {
+	if err := s.validateUsername(name); err != nil {
+		return err
+	}
+	taken, err := s.repo.ExistsByUsername(ctx, name)
+	if err != nil {
+		return fmt.Errorf("查询用户名失败: %w", err)
+	}
+	if !taken {
+		taken, err = s.repo.UsernameHeld(ctx, name, userID, time.Now())
+		if err != nil {
+			return fmt.Errorf("查询用户名失败: %w", err)
+		}
+	}
+	if taken {
+		return ErrUsernameTaken
+	}
+	return nil
+}
+
+// isUsernameRejected 判断错误是否表示用户名不可用，应作为请求错误返回
+func isUsernameRejected(err error) bool {
+	return errors.Is(err, ErrInvalidUsername) || errors.Is(err, ErrUsernameReserved) || errors.Is(err, ErrUsernameTaken)
+}
+
+// ChangeUsername 修改用户名，旧用户名在保留期内仍指向该用户且不能被他人使用
+func (s *UserServiceImpl) ChangeUsername(ctx context.Context, userID uint, req *UserChangeUsernameRequest) (*User, error) {
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	username := NormalizeUsername(req.Username)
+	if username == user.Username {
+		return user, nil
+	}
+	if err := s.checkUsernameAvailable(ctx, username, user.ID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	changes, err := s.repo.CountUsernameChangesSince(ctx, user.ID, now.Add(-s.usernames.ChangeWindowDuration))
+	if err != nil {
+		return nil, fmt.Errorf("查询用户名修改记录失败: %w", err)
+	}
+	if changes >= int64(s.usernames.ChangeLimit) {
+		return nil, ErrUsernameChangeLimited
+	}
+
+	changed, err := s.repo.ChangeUsername(ctx, &UsernameHistory{
+		CreatedAt:   now,
+		UserID:      user.ID,
+		OldUsername: user.Username,
+		NewUsername: username,
+		ExpiresAt:   now.Add(s.usernames.RedirectDuration),
+	})
+	if err != nil {
+		// 并发请求抢占同一用户名时由唯一索引拦截
+		if exists, _ := s.repo.ExistsByUsername(ctx, username); exists {
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("修改用户名失败: %w", err)
+	}
+	if !changed {
+		return nil, errors.New("用户名已被修改，请刷新后重试")
+	}
+
+	logger.Info("User %d changed username from %s to %s", user.ID, user.Username, username)
+	user.Username = username
+	return user, nil
+}
+
+// ResolveUsername 按用户名查找用户，保留期内的旧用户名也能找到，此时第二个返回值为 true
+func (s *UserServiceImpl) ResolveUsername(ctx context.Context, username string) (*User, bool, error) {
+	user, err := s.repo.GetByUsername(ctx, username)
+	if err == nil {
+		return user, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	user, err = s.repo.ResolveOldUsername(ctx, username, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	return user, true, nil
+}
+
+// truncate shortens s to at most max characters
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
+
+// LoginMFA 提交两步验证码完成登录
+func (s *UserServiceImpl) LoginMFA(req *UserLoginMFARequest, client token.ClientInfo) (*UserLoginResponse, error) {
+	ctx := context.Background()
+
+	userID, err := s.mfa.ParseChallenge(req.MFAToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, mfa.ErrInvalidChallenge
+	}
+	if user.Status == 0 {
+		return nil, errors.New("账户已被禁用")
+	}
+
+	// 两步验证码与密码共用账户锁定，防止在凭证有效期内穷举验证码
+	account := accountLockoutSubject(user.ID)
+	if err := s.checkLockout(ctx, account, client.IP); err != nil {
+		return nil, err
+	}
+	if err := s.mfa.Verify(ctx, user.ID, req.Code); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			if lockErr := s.recordFailure(ctx, account, client.IP); lockErr != nil {
+				return nil, lockErr
+			}
+		}
+		return nil, err
+	}
+	if s.guard != nil {
+		if err := s.guard.Succeed(ctx, account); err != nil {
+			logger.Error("清除登录失败记录失败:", err)
+		}
+	}
+
+	return s.completeLogin(ctx, user, client)
+}
+
+// loginCodeMaxAttempts 每个免密登录验证码允许输错的次数，超过后作废
+const loginCodeMaxAttempts = 5
+
+// RequestPasswordlessLogin 发送免密登录链接或验证码
+// 无论邮箱是否存在、是否超过发送频率都返回成功，避免泄露账户信息
+func (s *UserServiceImpl) RequestPasswordlessLogin(req *UserPasswordlessRequest, client token.ClientInfo) error {
+	if !s.auth.PasswordlessEnabled {
+		return ErrPasswordlessDisabled
+	}
+	ctx := context.Background()
+	address := strings.ToLower(strings.TrimSpace(req.Email))
+	now := time.Now()
+
+	if s.auth.PasswordlessMaxRequests > 0 {
+		sent, err := s.repo.CountLoginCodesSince(ctx, address, now.Add(-s.auth.PasswordlessRequestWindowDuration))
+		if err != nil {
+			return fmt.Errorf("查询登录邮件发送次数失败: %w", err)
+		}
+		if sent >= int64(s.auth.PasswordlessMaxRequests) {
+			logger.Warn("Passwordless login requests for %s exceeded the limit", address)
+			return nil
+		}
+	}
+
+	user, err := s.repo.GetByEmail(ctx, address)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("查询免密登录用户失败:", err)
+		}
+		return nil
+	}
+	if user.Status == 0 {
+		return nil
+	}
+
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return fmt.Errorf("生成登录令牌失败: %w", err)
+	}
+	code, err := generateLoginCode()
+	if err != nil {
+		return fmt.Errorf("生成登录验证码失败: %w", err)
+	}
+	ttl := s.auth.PasswordlessExpireDuration
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	record := &LoginCode{
+		CreatedAt: now,
+		UserID:    user.ID,
+		Email:     address,
+		LinkHash:  utils.HashToken(raw),
+		CodeHash:  s.hashLoginCode(address, code),
+		ExpiresAt: now.Add(ttl),
+		IP:        client.IP,
+	}
+	if err := s.repo.CreateLoginCode(ctx, record); err != nil {
+		return fmt.Errorf("保存登录令牌失败: %w", err)
+	}
+
+	// 只发送用户请求的一种方式；失败时只记录日志，以免泄露邮箱是否存在
+	link := withToken(s.auth.PasswordlessURL, raw)
+	if req.Method == "code" {
+		link, raw = "", ""
+	} else {
+		code = ""
+	}
+	if err := email.SendLoginEmail(user.Email, link, code, ttl); err != nil {
+		logger.Error("发送免密登录邮件失败:", err)
+	}
+	return nil
+}
+
+// PasswordlessLogin 使用登录链接或验证码登录，返回与密码登录相同的结果
+func (s *UserServiceImpl) PasswordlessLogin(req *UserPasswordlessLoginRequest, client token.ClientInfo) (*UserLoginResponse, error) {
+	if !s.auth.PasswordlessEnabled {
+		return nil, ErrPasswordlessDisabled
+	}
+	ctx := context.Background()
+	now := time.Now()
+
+	var (
+		record *LoginCode
+		err    error
+	)
+	switch {
+	case req.Token != "":
+		record, err = s.repo.GetLoginCodeByLinkHash(ctx, utils.HashToken(req.Token))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvalidLoginCode
+			}
+			return nil, fmt.Errorf("查询登录令牌失败: %w", err)
+		}
+		if record.UsedAt != nil || !now.Before(record.ExpiresAt) {
+			return nil, ErrInvalidLoginCode
+		}
+	case req.Email != "" && req.Code != "":
+		if record, err = s.verifyLoginCode(ctx, req, client.IP, now); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("请提供登录链接令牌，或邮箱和验证码")
+	}
+
+	user, err := s.repo.Get(ctx, record.UserID)
+	if err != nil {
+		return nil, ErrInvalidLoginCode
+	}
+	// 登录邮件发出后邮箱被修改，则旧链接失效
+	if !strings.EqualFold(user.Email, record.Email) {
+		return nil, ErrInvalidLoginCode
+	}
+	if user.Status == 0 {
+		return nil, ErrAccountDisabled
+	}
+
+	consumed, err := s.repo.ConsumeLoginCode(ctx, record.ID, now)
+	if err != nil {
+		return nil, fmt.Errorf("使用登录令牌失败: %w", err)
+	}
+	if !consumed {
+		return nil, ErrInvalidLoginCode
+	}
+
+	// 能收到登录邮件即证明拥有该邮箱
+	if !user.IsEmailVerified() {
+		user.EmailVerifiedAt = &now
+		if err := s.repo.Update(ctx, user); err != nil {
+			logger.Error("更新邮箱验证状态失败:", err)
+		}
+	}
+
+	return s.beginSession(ctx, user, client)
+}
+
+// verifyLoginCode 校验邮箱最新的免密登录验证码
+// 验证码与密码共用账户锁定，且每个验证码只允许输错 loginCodeMaxAttempts 次
+func (s *UserServiceImpl) verifyLoginCode(ctx context.Context, req *UserPasswordlessLoginRequest, ip string, now time.Time) (*LoginCode, error) {
+	address := strings.ToLower(strings.TrimSpace(req.Email))
+	record, err := s.repo.GetActiveLoginCode(ctx, address, now)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("查询登录验证码失败: %w", err)
+	}
+
+	account := address
+	if record != nil {
+		account = accountLockoutSubject(record.UserID)
+	}
+	if err := s.checkLockout(ctx, account, ip); err != nil {
+		return nil, err
+	}
+
+	if record == nil || record.Attempts >= loginCodeMaxAttempts ||
+		!hmac.Equal([]byte(record.CodeHash), []byte(s.hashLoginCode(address, req.Code))) {
+		if record != nil {
+			if err := s.repo.IncrementLoginCodeAttempts(ctx, record.ID); err != nil {
+				logger.Error("记录登录验证码错误次数失败:", err)
+			}
+		}
+		if lockErr := s.recordFailure(ctx, account, ip); lockErr != nil {
+			return nil, lockErr
+		}
+		return nil, ErrInvalidLoginCode
+	}
+
+	if s.guard != nil {
+		if err := s.guard.Succeed(ctx, account); err != nil {
+			logger.Error("清除登录失败记录失败:", err)
+		}
+	}
+	return record, nil
+}
+
+// hashLoginCode 验证码只有 6 位，使用 HMAC 而不是普通哈希，避免数据库泄露后被穷举
+func (s *UserServiceImpl) hashLoginCode(address, code string) string {
+	mac := hmac.New(sha256.New, []byte(s.auth.SigningSecret))
+	mac.Write([]byte(address + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateLoginCode 生成 6 位数字验证码
+func generateLoginCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// UnlockAccount 管理员解除账户登录锁定
+func (s *UserServiceImpl) UnlockAccount(ctx context.Context, adminID, userID uint) error {
+	if _, err := s.repo.Get(ctx, userID); err != nil {
+		return err
+	}
+	if s.guard == nil {
+		return nil
+	}
+	if err := s.guard.Unlock(ctx, lockout.ScopeAccount, accountLockoutSubject(userID), adminID); err != nil {
+		return err
+	}
+	s.audit(ctx, AdminActor{ID: adminID}, userID, AuditActionUnlock, "")
+	return nil
+}
+
+// UnlockIP 管理员解除客户端 IP 登录锁定
+func (s *UserServiceImpl) UnlockIP(ctx context.Context, adminID uint, ip string) error {
+	if s.guard == nil {
+		return nil
+	}
+	return s.guard.Unlock(ctx, lockout.ScopeIP, ip, adminID)
+}
+
+// SearchUsers 管理员按条件分页查询用户
+func (s *UserServiceImpl) SearchUsers(ctx context.Context, query *AdminUserQuery) ([]*User, int64, error) {
+	return s.repo.Search(ctx, query)
+}
+
+// DisableUser 管理员禁用账户，并注销其所有已登录会话
+func (s *UserServiceImpl) DisableUser(ctx context.Context, actor AdminActor, userID uint) error {
+	user, err := s.adminTarget(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+	if user.Status != 0 {
+		user.Status = 0
+		if err := s.repo.Update(ctx, user); err != nil {
+			return fmt.Errorf("禁用账户失败: %w", err)
+		}
+	}
+	if err := s.tokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
+	s.audit(ctx, actor, user.ID, AuditActionDisable, "")
+	return nil
+}
+
+// EnableUser 管理员启用被禁用的账户
+func (s *UserServiceImpl) EnableUser(ctx context.Context, actor AdminActor, userID uint) error {
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.Status != 1 {
+		user.Status = 1
+		if err := s.repo.Update(ctx, user); err != nil {
+			return fmt.Errorf("启用账户失败: %w", err)
+		}
+	}
+	s.audit(ctx, actor, user.ID, AuditActionEnable, "")
+	return nil
+}
+
+// ForcePasswordReset 管理员强制重置密码：原密码立即失效，所有会话被注销，用户需通过邮件链接设置新密码
+func (s *UserServiceImpl) ForcePasswordReset(ctx context.Context, actor AdminActor, userID uint) error {
+	user, err := s.adminTarget(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
 
-// Get 获取 User
-func (s *UserServiceImpl) Get(ctx context.Context, id uint) (*User, error) {
-	return s.repo.Get(ctx, id)
-}
+	random, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return fmt.Errorf("生成随机密码失败: %w", err)
+	}
+	hashedPassword, err := s.hasher.Hash(random)
+	if err != nil {
+		return fmt.Errorf("密码加密失败: %w", err)
+	}
+	user.Password = hashedPassword
+	if err := s.repo.Update(ctx, user); err != nil {
+		return fmt.Errorf("更新密码失败: %w", err)
+	}
+	if err := s.tokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
+	s.audit(ctx, actor, user.ID, AuditActionPasswordReset, "")
 
-// List 获取 User 列表
-func (s *UserServiceImpl) List(ctx context.Context, page, pageSize int) ([]*User, int64, error) {
-	return s.repo.List(ctx, page, pageSize)
+	return s.sendPasswordReset(ctx, user, actor.IP)
 }
 
-// Register 用户注册
-func (s *UserServiceImpl) Register(req *UserRegisterRequest) (*User, error) {
-	ctx := context.Background()
-
-	// 检查邮箱是否已存在
-	exists, err := s.repo.ExistsByEmail(ctx, req.Email)
+// Impersonate 管理员以用户身份签发访问令牌，令牌带有管理员 ID，不能用于管理接口和修改凭证
+func (s *UserServiceImpl) Impersonate(ctx context.Context, actor AdminActor, userID uint, reason string) (*AdminImpersonateResponse, error) {
+	user, err := s.adminTarget(ctx, actor, userID)
 	if err != nil {
 		return nil, err
 	}
-	if exists {
-		return nil, errors.New("邮箱已被注册")
+	if user.Status == 0 {
+		return nil, ErrAccountDisabled
 	}
 
-	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	pair, err := s.tokens.Impersonate(ctx, subjectFromUser(user), actor.ID)
 	if err != nil {
-		return nil, fmt.Errorf("密码加密失败: %w", err)
+		return nil, fmt.Errorf("生成 token 失败: %w", err)
 	}
+	s.audit(ctx, actor, user.ID, AuditActionImpersonate, reason)
+	logger.Warn("Admin %d is impersonating user %d: %s", actor.ID, user.ID, reason)
 
-	user := &User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: string(hashedPassword),
-		Nickname: req.Nickname,
-		Phone:    req.Phone,
-		Status:   1,
+	return &AdminImpersonateResponse{TokenPair: pair, User: user}, nil
+}
+
+// HardDelete 管理员永久删除账户及其关联的外部身份和两步验证数据
+func (s *UserServiceImpl) HardDelete(ctx context.Context, actor AdminActor, userID uint) error {
+	user, err := s.adminTarget(ctx, actor, userID)
+	if err != nil {
+		return err
 	}
 
-	if err := s.repo.Create(ctx, user); err != nil {
-		return nil, fmt.Errorf("创建用户失败: %w", err)
+	if err := s.purgeSignIn(ctx, user.ID); err != nil {
+		return err
+	}
+	if err := s.repo.HardDelete(ctx, user.ID); err != nil {
+		return fmt.Errorf("删除账户失败: %w", err)
 	}
+	s.avatars.remove(user.AvatarKeys)
+	s.audit(ctx, actor, user.ID, AuditActionDelete, user.Email)
+	return nil
+}
 
-	// 发送欢迎邮件
-	if err := email.SendWelcomeEmail(user.Email, user.Username); err != nil {
-		logger.Error("发送欢迎邮件失败:", err)
+// EraseAccount 清除已删除账户的个人信息和登录方式，只保留匿名的用户记录
+// 可重复执行，用于删除宽限期结束后的数据擦除
+func (s *UserServiceImpl) EraseAccount(ctx context.Context, userID uint) error {
+	if err := s.purgeSignIn(ctx, userID); err != nil {
+		return err
 	}
+	avatarKeys, err := s.repo.Anonymize(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("匿名化账户失败: %w", err)
+	}
+	s.avatars.remove(avatarKeys)
+	return nil
+}
 
-	return user, nil
+// RestoreAccount 管理员在擦除前恢复已删除的账户
+func (s *UserServiceImpl) RestoreAccount(ctx context.Context, actor AdminActor, userID uint) error {
+	restored, err := s.repo.Restore(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("恢复账户失败: %w", err)
+	}
+	if !restored {
+		return gorm.ErrRecordNotFound
+	}
+	s.audit(ctx, actor, userID, AuditActionRestore, "")
+	return nil
 }
 
-// Login 用户登录
-func (s *UserServiceImpl) Login(req *UserLoginRequest) (*UserLoginResponse, error) {
-	ctx := context.Background()
+// purgeSignIn 注销会话并删除两步验证和外部身份，使账户无法再登录
+func (s *UserServiceImpl) purgeSignIn(ctx context.Context, userID uint) error {
+	if err := s.tokens.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
+	if err := s.mfa.Purge(ctx, userID); err != nil {
+		return fmt.Errorf("删除两步验证数据失败: %w", err)
+	}
+	linked, err := s.identities.List(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("查询外部身份失败: %w", err)
+	}
+	for _, i := range linked {
+		if err := s.identities.Unlink(ctx, userID, i.ID); err != nil && !errors.Is(err, identity.ErrIdentityNotFound) {
+			return fmt.Errorf("解除外部身份失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListAuditLogs 分页查询管理员操作记录
+func (s *UserServiceImpl) ListAuditLogs(ctx context.Context, query *AdminAuditQuery) ([]*AdminAuditLog, int64, error) {
+	return s.repo.ListAuditLogs(ctx, query)
+}
 
-	// Try to find user by username first
-	user, err := s.repo.GetByUsername(ctx, req.Username)
+// adminTarget 加载管理操作的目标用户；不允许操作自己或其他管理员
+func (s *UserServiceImpl) adminTarget(ctx context.Context, actor AdminActor, userID uint) (*User, error) {
+	if actor.ID == userID {
+		return nil, ErrAdminSelfAction
+	}
+	user, err := s.repo.Get(ctx, userID)
 	if err != nil {
-		// If not found by username, try email
-		user, err = s.repo.GetByEmail(ctx, req.Username)
+		return nil, err
+	}
+	if s.roles != nil {
+		isAdmin, err := s.roles.HasSystemRole(ctx, userID, authorization.RoleAdmin)
 		if err != nil {
-			return nil, errors.New("用户名或密码错误")
+			return nil, fmt.Errorf("查询用户角色失败: %w", err)
 		}
+		if isAdmin {
+			return nil, ErrAdminTarget
+		}
+	}
+	return user, nil
+}
+
+// audit 记录管理员操作；写入失败只记录日志，不影响已完成的操作
+func (s *UserServiceImpl) audit(ctx context.Context, actor AdminActor, userID uint, action, detail string) {
+	entry := &AdminAuditLog{
+		ActorID:      actor.ID,
+		TargetUserID: userID,
+		Action:       action,
+		IP:           actor.IP,
+		Detail:       truncate(detail, 500),
+	}
+	if err := s.repo.CreateAuditLog(ctx, entry); err != nil {
+		logger.Error("记录管理员操作失败:", err)
 	}
+}
 
-	if user.Status == 0 {
-		return nil, errors.New("账户已被禁用")
+// checkLockout 账户或 IP 被锁定时返回 *lockout.LockedError
+func (s *UserServiceImpl) checkLockout(ctx context.Context, account, ip string) error {
+	if s.guard == nil {
+		return nil
+	}
+	err := s.guard.Check(ctx, account, ip)
+	if err != nil && !errors.Is(err, lockout.ErrLocked) {
+		// 存储不可用时不阻断登录，只记录日志
+		logger.Error("查询登录锁定状态失败:", err)
+		return nil
 	}
+	return err
+}
+
+// recordFailure 记录一次失败尝试，本次失败触发锁定时返回 *lockout.LockedError
+func (s *UserServiceImpl) recordFailure(ctx context.Context, account, ip string) error {
+	if s.guard == nil {
+		return nil
+	}
+	err := s.guard.Fail(ctx, account, ip)
+	if err != nil && !errors.Is(err, lockout.ErrLocked) {
+		logger.Error("记录登录失败次数失败:", err)
+		return nil
+	}
+	return err
+}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("用户名或密码错误")
+// loginFailed 记录密码错误并返回应答给客户端的错误
+func (s *UserServiceImpl) loginFailed(ctx context.Context, account, ip string) error {
+	if err := s.recordFailure(ctx, account, ip); err != nil {
+		return err
 	}
+	return ErrInvalidCredentials
+}
+
+// accountLockoutSubject 已存在账户以用户 ID 作为锁定主体，用户名和邮箱登录共用计数
+func accountLockoutSubject(userID uint) string {
+	return strconv.FormatUint(uint64(userID), 10)
+}
 
-	// 生成 JWT token
-	token, err := jwt.GenerateToken(user.ID, user.Username)
+// completeLogin 签发访问令牌和刷新令牌并记录登录时间
+func (s *UserServiceImpl) completeLogin(ctx context.Context, user *User, client token.ClientInfo) (*UserLoginResponse, error) {
+	pair, err := s.tokens.Issue(ctx, subjectFromUser(user), client)
 	if err != nil {
 		return nil, fmt.Errorf("生成 token 失败: %w", err)
 	}
@@ -141,8 +1194,9 @@ func (s *UserServiceImpl) Login(req *UserLoginRequest) (*UserLoginResponse, erro
 	}
 
 	return &UserLoginResponse{
-		Token: token,
-		User:  user,
+		Token:     pair.AccessToken,
+		TokenPair: pair,
+		User:      user,
 	}, nil
 }
 
@@ -158,8 +1212,13 @@ func (s *UserServiceImpl) UpdateProfile(userID uint, req *UserUpdateRequest) (*U
 	if req.Nickname != "" {
 		user.Nickname = req.Nickname
 	}
-	if req.Avatar != "" {
+	var replacedAvatar []string
+	if req.Avatar != "" && req.Avatar != user.Avatar {
+		// 改用外部头像地址时删除已上传的头像图片
+		replacedAvatar = user.AvatarKeys
 		user.Avatar = req.Avatar
+		user.AvatarURLs = nil
+		user.AvatarKeys = nil
 	}
 	if req.Phone != "" {
 		user.Phone = req.Phone
@@ -171,10 +1230,48 @@ func (s *UserServiceImpl) UpdateProfile(userID uint, req *UserUpdateRequest) (*U
 	if err := s.repo.Update(ctx, user); err != nil {
 		return nil, fmt.Errorf("更新用户信息失败: %w", err)
 	}
+	s.avatars.remove(replacedAvatar)
 
 	return user, nil
 }
 
+// UploadAvatar 上传头像，替换并删除之前上传的头像图片
+func (s *UserServiceImpl) UploadAvatar(ctx context.Context, userID uint, file io.Reader) (*User, error) {
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	avatar, urls, keys, err := s.avatars.upload(userID, file)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetAvatar(ctx, userID, avatar, urls, keys); err != nil {
+		s.avatars.remove(keys)
+		return nil, fmt.Errorf("更新头像失败: %w", err)
+	}
+	s.avatars.remove(user.AvatarKeys)
+
+	user.Avatar, user.AvatarURLs, user.AvatarKeys = avatar, urls, keys
+	return user, nil
+}
+
+// DeleteAvatar 删除头像
+func (s *UserServiceImpl) DeleteAvatar(ctx context.Context, userID uint) (*User, error) {
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	if err := s.repo.SetAvatar(ctx, userID, "", nil, nil); err != nil {
+		return nil, fmt.Errorf("删除头像失败: %w", err)
+	}
+	s.avatars.remove(user.AvatarKeys)
+
+	user.Avatar, user.AvatarURLs, user.AvatarKeys = "", nil, nil
+	return user, nil
+}
+
 // ChangePassword 修改密码
 func (s *UserServiceImpl) ChangePassword(userID uint, req *UserChangePasswordRequest) error {
 	ctx := context.Background()
@@ -184,53 +1281,326 @@ func (s *UserServiceImpl) ChangePassword(userID uint, req *UserChangePasswordReq
 		return errors.New("用户不存在")
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+	if !s.verifyPassword(user, req.OldPassword) {
 		return errors.New("原密码错误")
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err := s.validateNewPassword(ctx, user, req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
 	if err != nil {
 		return fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	user.Password = string(hashedPassword)
+	user.Password = hashedPassword
 	if err := s.repo.Update(ctx, user); err != nil {
 		return fmt.Errorf("更新密码失败: %w", err)
 	}
+	s.recordPassword(ctx, user.ID, user.Password)
+
+	// 修改密码后注销该用户所有已签发的令牌
+	if err := s.tokens.RevokeAllForUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
 
 	return nil
 }
 
-// ResetPassword 重置密码
-func (s *UserServiceImpl) ResetPassword(req *UserPasswordResetRequest) error {
+// ResetPassword 发送重置密码链接
+// 无论邮箱是否存在都返回成功，避免泄露账户信息
+func (s *UserServiceImpl) ResetPassword(req *UserPasswordResetRequest, client token.ClientInfo) error {
 	ctx := context.Background()
 
 	user, err := s.repo.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return errors.New("邮箱不存在")
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("查询重置密码用户失败:", err)
+		}
+		return nil
+	}
+	if user.Status == 0 {
+		return nil
+	}
+
+	return s.sendPasswordReset(ctx, user, client.IP)
+}
+
+// sendPasswordReset 作废旧的重置链接，生成新的重置令牌并发送邮件
+func (s *UserServiceImpl) sendPasswordReset(ctx context.Context, user *User, ip string) error {
+	now := time.Now()
+	// 新链接生成后，之前发送的链接全部作废
+	if err := s.repo.InvalidatePasswordResets(ctx, user.ID, now); err != nil {
+		return fmt.Errorf("作废旧的重置链接失败: %w", err)
+	}
+
+	raw, err := utils.GenerateSecureToken(32)
+	if err != nil {
+		return fmt.Errorf("生成重置令牌失败: %w", err)
+	}
+	ttl := s.passwordResetTTL()
+	reset := &PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(raw),
+		ExpiresAt: now.Add(ttl),
+		IP:        ip,
+	}
+	if err := s.repo.CreatePasswordReset(ctx, reset); err != nil {
+		return fmt.Errorf("保存重置令牌失败: %w", err)
+	}
+
+	// 发送重置密码邮件；失败时只记录日志，以免通过错误信息泄露邮箱是否存在
+	if err := email.SendPasswordResetEmail(user.Email, s.passwordResetLink(raw), ttl); err != nil {
+		logger.Error("发送重置密码邮件失败:", err)
+	}
+
+	return nil
+}
+
+// ConfirmPasswordReset 使用重置令牌设置新密码
+func (s *UserServiceImpl) ConfirmPasswordReset(req *UserPasswordResetConfirmRequest) error {
+	ctx := context.Background()
+
+	reset, err := s.repo.GetPasswordResetByHash(ctx, utils.HashToken(req.Token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("查询重置令牌失败: %w", err)
+	}
+
+	now := time.Now()
+	if reset.UsedAt != nil || !now.Before(reset.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.Get(ctx, reset.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return fmt.Errorf("查询用户失败: %w", err)
+	}
+	if err := s.validateNewPassword(ctx, user, req.NewPassword); err != nil {
+		return err
 	}
 
-	// 生成随机密码
-	newPassword := utils.GenerateRandomString(12)
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
 	if err != nil {
 		return fmt.Errorf("密码加密失败: %w", err)
 	}
 
-	user.Password = string(hashedPassword)
-	if err := s.repo.Update(ctx, user); err != nil {
+	consumed, err := s.repo.ConsumePasswordReset(ctx, reset, hashedPassword, now)
+	if err != nil {
 		return fmt.Errorf("重置密码失败: %w", err)
 	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+	s.recordPassword(ctx, user.ID, hashedPassword)
 
-	// 发送重置密码邮件
-	if err := email.SendPasswordResetEmail(user.Email, newPassword); err != nil {
-		logger.Error("发送重置密码邮件失败:", err)
-		return errors.New("发送重置密码邮件失败")
+	// 重置密码后注销该用户所有已签发的令牌
+	if err := s.tokens.RevokeAllForUser(ctx, reset.UserID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
+
+	return nil
+}
+
+// verifyPassword 校验用户密码；无法识别的哈希格式视为不匹配
+func (s *UserServiceImpl) verifyPassword(user *User, plain string) bool {
+	ok, err := s.hasher.Verify(user.Password, plain)
+	if err != nil {
+		logger.Error("校验密码哈希失败:", err)
+		return false
+	}
+	return ok
+}
+
+// upgradePasswordHash 在登录成功后用当前配置的算法和参数重新计算过时的密码哈希
+func (s *UserServiceImpl) upgradePasswordHash(ctx context.Context, user *User, plain string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+	hash, err := s.hasher.Hash(plain)
+	if err != nil {
+		logger.Error("重新计算密码哈希失败:", err)
+		return
+	}
+	if err := s.repo.RehashPassword(ctx, user.ID, user.Password, hash); err != nil {
+		logger.Error("更新密码哈希失败:", err)
+		return
+	}
+	user.Password = hash
+}
+
+// validateNewPassword 校验新密码；user 已存在时同时检查当前密码和近期密码
+func (s *UserServiceImpl) validateNewPassword(ctx context.Context, user *User, plain string) error {
+	if s.passwords == nil {
+		return nil
+	}
+	if err := s.passwords.policy.Validate(plain, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	if s.passwords.breached != nil {
+		breached, err := s.passwords.breached.IsBreached(ctx, plain)
+		if err != nil {
+			// 列表不可读时不阻断修改密码，只记录日志
+			logger.Error("检查泄露密码列表失败:", err)
+		} else if breached {
+			return password.ErrBreached
+		}
+	}
+
+	if user.ID == 0 || s.passwords.historySize <= 0 {
+		return nil
+	}
+	hashes, err := s.repo.RecentPasswordHashes(ctx, user.ID, s.passwords.historySize)
+	if err != nil {
+		return fmt.Errorf("查询历史密码失败: %w", err)
+	}
+	if user.Password != "" {
+		hashes = append(hashes, user.Password)
+	}
+	for _, hash := range hashes {
+		if ok, _ := s.hasher.Verify(hash, plain); ok {
+			return password.ErrReused
+		}
+	}
+	return nil
+}
+
+// recordPassword 将新密码的哈希写入历史记录；失败只记录日志
+func (s *UserServiceImpl) recordPassword(ctx context.Context, userID uint, hash string) {
+	if s.passwords == nil || s.passwords.historySize <= 0 {
+		return
+	}
+	if err := s.repo.AddPasswordHistory(ctx, &PasswordHistory{UserID: userID, PasswordHash: hash}, s.passwords.historySize); err != nil {
+		logger.Error("记录历史密码失败:", err)
+	}
+}
+
+func (s *UserServiceImpl) passwordResetTTL() time.Duration {
+	if s.auth.PasswordResetExpireDuration <= 0 {
+		return 30 * time.Minute
+	}
+	return s.auth.PasswordResetExpireDuration
+}
+
+func (s *UserServiceImpl) passwordResetLink(raw string) string {
+	return withToken(s.auth.PasswordResetURL, raw)
+}
+
+// withToken appends the token query parameter to a frontend URL
+func withToken(base, raw string) string {
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(raw)
+}
+
+// emailVerificationClaims 邮箱验证链接中签名的内容
+type emailVerificationClaims struct {
+	UserID    uint   `json:"uid"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// VerifyEmail 校验签名链接并标记邮箱已验证
+func (s *UserServiceImpl) VerifyEmail(req *UserVerifyEmailRequest) (*User, error) {
+	ctx := context.Background()
+
+	payload, err := utils.VerifySignedToken([]byte(s.auth.SigningSecret), req.Token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	var claims emailVerificationClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	user, err := s.repo.Get(ctx, claims.UserID)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	// 链接签发后邮箱被修改，则旧链接失效
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if user.IsEmailVerified() {
+		return user, nil
+	}
+
+	now := time.Now()
+	user.EmailVerifiedAt = &now
+	if err := s.repo.Update(ctx, user); err != nil {
+		return nil, fmt.Errorf("更新邮箱验证状态失败: %w", err)
+	}
+
+	// 发送欢迎邮件
+	if err := email.SendWelcomeEmail(user.Email, user.Username); err != nil {
+		logger.Error("发送欢迎邮件失败:", err)
 	}
 
+	return user, nil
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+// 无论邮箱是否存在都返回成功，避免泄露账户信息
+func (s *UserServiceImpl) ResendVerification(req *UserResendVerificationRequest) error {
+	ctx := context.Background()
+
+	user, err := s.repo.GetByEmail(ctx, req.Email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Error("查询待验证用户失败:", err)
+		}
+		return nil
+	}
+	if user.IsEmailVerified() || user.Status == 0 {
+		return nil
+	}
+
+	if err := s.sendVerificationEmail(user); err != nil {
+		logger.Error("发送邮箱验证邮件失败:", err)
+	}
 	return nil
 }
 
+// IsEmailVerified 查询用户邮箱是否已验证
+func (s *UserServiceImpl) IsEmailVerified(ctx context.Context, userID uint) (bool, error) {
+	user, err := s.repo.Get(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsEmailVerified(), nil
+}
+
+func (s *UserServiceImpl) sendVerificationEmail(user *User) error {
+	ttl := s.auth.EmailVerificationExpireDuration
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	payload, err := json.Marshal(emailVerificationClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	signed := utils.SignToken([]byte(s.auth.SigningSecret), payload)
+
+	return email.SendVerificationEmail(user.Email, user.Username, withToken(s.auth.EmailVerificationURL, signed), ttl)
+}
+
 // GetProfile 获取用户信息
 func (s *UserServiceImpl) GetProfile(userID uint) (*User, error) {
 	ctx := context.Background()
@@ -244,6 +1614,9 @@ func (s *UserServiceImpl) GetProfile(userID uint) (*User, error) {
 // DeleteAccount 删除账户
 func (s *UserServiceImpl) DeleteAccount(userID uint) error {
 	ctx := context.Background()
+	if err := s.tokens.RevokeAllForUser(ctx, userID); err != nil {
+		return fmt.Errorf("注销已登录会话失败: %w", err)
+	}
 	if err := s.repo.Delete(ctx, userID); err != nil {
 		return fmt.Errorf("删除账户失败: %w", err)
 	}
@@ -260,3 +1633,23 @@ func (s *UserServiceImpl) GetByID(id uint) (*User, error) {
 	ctx := context.Background()
 	return s.repo.Get(ctx, id)
 }
+
+// NewSubjectLoader adapts the user repository for the token service, which
+// re-checks the account every time a refresh token is exchanged.
+func NewSubjectLoader(repo UserRepository) token.SubjectLoader {
+	return token.SubjectLoaderFunc(func(ctx context.Context, userID uint) (*token.Subject, error) {
+		user, err := repo.Get(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return subjectFromUser(user), nil
+	})
+}
+
+func subjectFromUser(user *User) *token.Subject {
+	return &token.Subject{
+		ID:       user.ID,
+		Username: user.Username,
+		Active:   user.Status != 0,
+	}
+}
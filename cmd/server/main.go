@@ -1,20 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/app/token"
 	"github.com/llamacto/llama-gin-kit/config"
 	"github.com/llamacto/llama-gin-kit/pkg/container"
 	"github.com/llamacto/llama-gin-kit/pkg/database"
 	"github.com/llamacto/llama-gin-kit/pkg/email"
 	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+	"github.com/llamacto/llama-gin-kit/pkg/storage"
 	"github.com/llamacto/llama-gin-kit/routes"
 )
 
@@ -24,6 +31,10 @@ import (
 // @host localhost:6066
 // @BasePath /v1
 
+// shutdownTimeout bounds how long in-flight requests may take to finish on
+// shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load configuration (uses cache when available)
 	cfg, err := config.Load()
@@ -33,13 +44,33 @@ func main() {
 	container.App().Set(container.ServiceConfig, cfg)
 
 	// Initialize JWT service
-	jwt.Init(cfg)
+	if err := jwt.Init(cfg); err != nil {
+		log.Fatalf("Failed to initialize JWT service: %v", err)
+	}
 	container.App().Set(container.ServiceJWT, jwt.MustServiceInstance())
 
+	// Background work runs until shutdown; workers tracks the workers that
+	// must finish writing before the process exits
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	var workers sync.WaitGroup
+
+	// Rotate asymmetric signing keys in the background until shutdown
+	jwt.MustServiceInstance().StartKeyRotation(ctx)
+
 	// Initialize email service
 	email.Init(cfg)
 	container.App().Set(container.ServiceEmail, email.MustServiceInstance())
 
+	// Initialize object storage when configured
+	if cfg.R2.Bucket != "" {
+		if err := storage.InitR2Storage(cfg); err != nil {
+			log.Fatalf("Failed to initialize R2 storage: %v", err)
+		}
+	} else {
+		log.Println("R2 storage not configured, avatar uploads disabled")
+	}
+
 	// Initialize database when enabled
 	if cfg.Database.Enabled {
 		db, err := database.InitDB(cfg.Database)
@@ -47,6 +78,11 @@ func main() {
 			log.Fatalf("Failed to initialize database: %v", err)
 		}
 		container.App().Set(container.ServiceDB, db)
+
+		// Share token revocations across instances through the database
+		revocations := jwt.NewCachedRevocationStore(token.NewRevocationStore(db), cfg.JWT.RevocationCacheTTL)
+		revocations.Start(ctx)
+		jwt.MustServiceInstance().SetRevocationStore(revocations)
 	} else {
 		log.Println("Database initialization skipped (DB_ENABLED=false)")
 	}
@@ -62,7 +98,10 @@ func main() {
 	}
 
 	// Create Gin engine
-	r := gin.Default()
+	r, err := routes.NewEngine(cfg.Server)
+	if err != nil {
+		log.Fatalf("Failed to create server: %v", err)
+	}
 
 	// Enable CORS
 	corsConfig := cors.Config{
@@ -75,14 +114,15 @@ func main() {
 	r.Use(cors.New(corsConfig))
 
 	// Register routes
-	routes.RegisterRoutes(r)
+	routes.RegisterRoutes(ctx, r, &workers)
 
 	// Start server
 	serverAddr := fmt.Sprintf(":%d", cfg.Server.Port)
 	log.Printf("Starting server on %s", serverAddr)
 
+	srv := &http.Server{Addr: serverAddr, Handler: r}
 	go func() {
-		if err := r.Run(serverAddr); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
@@ -92,4 +132,15 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+
+	// Finish in-flight requests first, then stop the background workers and
+	// wait for them to write what they have buffered
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shut down: %v", err)
+	}
+	stop()
+	workers.Wait()
+	log.Println("Server stopped")
 }
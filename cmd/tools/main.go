@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/keyformat"
 	"github.com/llamacto/llama-gin-kit/pkg/storage"
 )
 
 func main() {
 	toolName := flag.String("tool", "", "Tool to run (generate-url or check-file)")
+	apiKey := flag.String("key", "", "API key for check-apikey (read from stdin when empty)")
 	flag.Parse()
 
 	switch *toolName {
@@ -23,9 +28,11 @@ func main() {
 		CacheConfig()
 	case "config-clear":
 		ClearConfigCache()
+	case "check-apikey":
+		CheckAPIKey(*apiKey)
 	default:
 		fmt.Printf("Unknown tool: %s\n", *toolName)
-		fmt.Println("Available tools: generate-url, check-file, config-cache, config-clear")
+		fmt.Println("Available tools: generate-url, check-file, config-cache, config-clear, check-apikey")
 		os.Exit(1)
 	}
 }
@@ -84,3 +91,32 @@ func ClearConfigCache() {
 
 	fmt.Printf("Configuration cache cleared (%s)\n", config.CacheFilePath())
 }
+
+// CheckAPIKey checks the format and checksum of an API key offline, without
+// the database or server pepper. It exits with status 1 if the key is not
+// valid. The key is read from stdin when not given so that it stays out of
+// the shell history.
+func CheckAPIKey(key string) {
+	if key == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			log.Fatalf("Failed to read API key: %v", err)
+		}
+		key = line
+	}
+	key = strings.TrimSpace(key)
+
+	parsed, err := keyformat.Parse(key)
+	if err == nil && !parsed.Checksummed {
+		err = keyformat.ErrNoChecksum
+	}
+	switch {
+	case errors.Is(err, keyformat.ErrNoChecksum):
+		fmt.Printf("API key is well formed but has no checksum (issued before checksums)\nEnvironment: %s\nLookup ID: %s\n", parsed.Environment, parsed.LookupID)
+	case err != nil:
+		fmt.Printf("Invalid API key: %v\n", err)
+		os.Exit(1)
+	default:
+		fmt.Printf("API key checksum is valid\nEnvironment: %s\nLookup ID: %s\n", parsed.Environment, parsed.LookupID)
+	}
+}
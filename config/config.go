@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -22,16 +23,24 @@ var ErrConfigCacheNotFound = errors.New("config cache not found")
 const defaultCachePath = "storage/framework/cache/config.json"
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
-	JWT      JWTConfig
-	Log      LogConfig
-	OpenAI   OpenAIConfig
-	R2       R2Config
-	Email    EmailConfig
-	App      AppConfig
-	CORS     CORSConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	JWT       JWTConfig
+	Log       LogConfig
+	OpenAI    OpenAIConfig
+	R2        R2Config
+	Email     EmailConfig
+	App       AppConfig
+	Auth      AuthConfig
+	OIDC      OIDCConfig
+	Password  PasswordConfig
+	Privacy   PrivacyConfig
+	Avatar    AvatarConfig
+	Username  UsernameConfig
+	APIKey    APIKeyConfig
+	RateLimit RateLimitConfig
+	CORS      CORSConfig
 }
 
 type ServerConfig struct {
@@ -40,6 +49,10 @@ type ServerConfig struct {
 	ReadTimeout    int    `json:"read_timeout"`
 	WriteTimeout   int    `json:"write_timeout"`
 	MaxHeaderBytes int    `json:"max_header_bytes"`
+	// TrustedProxies lists the IP addresses or CIDR ranges of reverse
+	// proxies whose X-Forwarded-For header is believed. Empty trusts none,
+	// so the client IP is the address of the connection.
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 type CORSConfig struct {
@@ -75,9 +88,24 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	Secret         string        `json:"-"` // 敏感信息不序列化
-	ExpireDays     int           `json:"expire_days"`
-	ExpireDuration time.Duration `json:"-"`
+	Secret                string        `json:"-"` // 敏感信息不序列化
+	AccessExpireMinutes   int           `json:"access_expire_minutes"`
+	AccessExpireDuration  time.Duration `json:"-"`
+	RefreshExpireDays     int           `json:"refresh_expire_days"`
+	RefreshExpireDuration time.Duration `json:"-"`
+	// RevocationCacheSeconds bounds how long a revocation made on another
+	// instance can take to be noticed by this one.
+	RevocationCacheSeconds int           `json:"revocation_cache_seconds"`
+	RevocationCacheTTL     time.Duration `json:"-"`
+	// Algorithm is HS256 (shared secret) or RS256/ES256/EdDSA (key pairs
+	// published at /.well-known/jwks.json).
+	Algorithm string `json:"algorithm"`
+	// KeysDir stores the PEM private keys for asymmetric algorithms. Leave
+	// empty to keep generated keys in memory only.
+	KeysDir string `json:"keys_dir"`
+	// KeyRotationHours is how often a new signing key is generated; 0 disables rotation.
+	KeyRotationHours    int           `json:"key_rotation_hours"`
+	KeyRotationInterval time.Duration `json:"-"`
 }
 
 type LogConfig struct {
@@ -113,11 +141,241 @@ type EmailConfig struct {
 }
 
 type AppConfig struct {
-	Name      string        `json:"name"`
-	Version   string        `json:"version"`
-	Secret    string        `json:"-"` // 敏感信息不序列化
-	JWTSecret string        `json:"-"` // 敏感信息不序列化
-	JWTExpire time.Duration `json:"jwt_expire"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Secret  string `json:"-"` // 敏感信息不序列化
+	// URL is the public base URL used to build links in emails.
+	URL string `json:"url"`
+}
+
+// Email verification policies
+const (
+	// EmailVerificationNone sends verification links but never blocks unverified users
+	EmailVerificationNone = "none"
+	// EmailVerificationRoutes blocks routes guarded by RequireVerifiedEmail
+	EmailVerificationRoutes = "routes"
+	// EmailVerificationLogin additionally refuses to log unverified users in
+	EmailVerificationLogin = "login"
+)
+
+const (
+	// LoginAttemptStoreMemory keeps failed login counters in process memory
+	LoginAttemptStoreMemory = "memory"
+	// LoginAttemptStoreDatabase shares failed login counters across instances
+	LoginAttemptStoreDatabase = "database"
+)
+
+type AuthConfig struct {
+	// SigningSecret signs stateless links such as email verification links.
+	SigningSecret string `json:"-"` // 敏感信息不序列化
+
+	PasswordResetExpireMinutes  int           `json:"password_reset_expire_minutes"`
+	PasswordResetExpireDuration time.Duration `json:"-"`
+	// PasswordResetURL is the page that receives ?token=...; defaults to
+	// App.URL + "/reset-password".
+	PasswordResetURL string `json:"password_reset_url"`
+
+	EmailVerificationExpireHours    int           `json:"email_verification_expire_hours"`
+	EmailVerificationExpireDuration time.Duration `json:"-"`
+	// EmailVerificationURL is the page that receives ?token=...; defaults to
+	// App.URL + "/verify-email".
+	EmailVerificationURL    string `json:"email_verification_url"`
+	EmailVerificationPolicy string `json:"email_verification_policy"`
+
+	// MFAChallengeExpireMinutes is how long a password-verified login may
+	// wait for its two-factor code.
+	MFAChallengeExpireMinutes  int           `json:"mfa_challenge_expire_minutes"`
+	MFAChallengeExpireDuration time.Duration `json:"-"`
+
+	// LoginMaxAttempts and LoginIPMaxAttempts are the failed logins allowed
+	// per account and per client IP within LoginAttemptWindow before a
+	// temporary lockout; 0 disables the respective check. Each further
+	// lockout doubles, from LoginLockout up to LoginMaxLockout.
+	LoginMaxAttempts           int           `json:"login_max_attempts"`
+	LoginIPMaxAttempts         int           `json:"login_ip_max_attempts"`
+	LoginAttemptWindowMinutes  int           `json:"login_attempt_window_minutes"`
+	LoginAttemptWindowDuration time.Duration `json:"-"`
+	LoginLockoutMinutes        int           `json:"login_lockout_minutes"`
+	LoginLockoutDuration       time.Duration `json:"-"`
+	LoginMaxLockoutMinutes     int           `json:"login_max_lockout_minutes"`
+	LoginMaxLockoutDuration    time.Duration `json:"-"`
+	LoginAttemptStore          string        `json:"login_attempt_store"`
+
+	// PasswordlessEnabled allows signing in with a single-use link or code
+	// sent by email. At most PasswordlessMaxRequests are sent per email
+	// address within PasswordlessRequestWindow.
+	PasswordlessEnabled               bool          `json:"passwordless_enabled"`
+	PasswordlessExpireMinutes         int           `json:"passwordless_expire_minutes"`
+	PasswordlessExpireDuration        time.Duration `json:"-"`
+	PasswordlessMaxRequests           int           `json:"passwordless_max_requests"`
+	PasswordlessRequestWindowMinutes  int           `json:"passwordless_request_window_minutes"`
+	PasswordlessRequestWindowDuration time.Duration `json:"-"`
+	// PasswordlessURL is the page that receives ?token=...; defaults to
+	// App.URL + "/login/magic".
+	PasswordlessURL string `json:"passwordless_url"`
+}
+
+// OIDCProviderConfig is an OpenID Connect identity provider users can sign
+// in with, such as the company IdP.
+type OIDCProviderConfig struct {
+	// Name identifies the provider in URLs: /v1/auth/oidc/<name>
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	IssuerURL    string   `json:"issuer_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"-"` // 敏感信息不序列化
+	Scopes       []string `json:"scopes"`
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `json:"providers"`
+	// RedirectBaseURL is the public URL of the OIDC routes; the callback
+	// registered with each provider is <RedirectBaseURL>/<name>/callback.
+	RedirectBaseURL string `json:"redirect_base_url"`
+	// StateExpireMinutes is how long a user may take to sign in at the provider.
+	StateExpireMinutes  int           `json:"state_expire_minutes"`
+	StateExpireDuration time.Duration `json:"-"`
+	// AllowSignup creates an account for unknown identities; otherwise only
+	// identities matching an existing account can sign in.
+	AllowSignup bool `json:"allow_signup"`
+}
+
+// PasswordConfig is the policy new passwords must satisfy on registration,
+// password change and password reset.
+type PasswordConfig struct {
+	MinLength int `json:"min_length"`
+	// MaxLength is in bytes; with bcrypt it may not exceed 72 bytes.
+	MaxLength      int  `json:"max_length"`
+	RequireUpper   bool `json:"require_upper"`
+	RequireLower   bool `json:"require_lower"`
+	RequireDigit   bool `json:"require_digit"`
+	RequireSymbol  bool `json:"require_symbol"`
+	MinCharClasses int  `json:"min_char_classes"`
+	// RejectUserInfo rejects passwords containing the username or email.
+	RejectUserInfo bool `json:"reject_user_info"`
+	// HistorySize is how many previous passwords may not be reused; 0 disables.
+	HistorySize int `json:"history_size"`
+	// BreachedListPath is a local breached-password list: a file of SHA-1
+	// hashes or passwords, or a directory of range files. Empty disables.
+	BreachedListPath string `json:"breached_list_path"`
+	// HashAlgorithm is used for new hashes (argon2id or bcrypt). Hashes made
+	// with another algorithm or weaker parameters are upgraded on login.
+	HashAlgorithm     string `json:"hash_algorithm"`
+	BcryptCost        int    `json:"bcrypt_cost"`
+	Argon2MemoryKB    int    `json:"argon2_memory_kb"`
+	Argon2Iterations  int    `json:"argon2_iterations"`
+	Argon2Parallelism int    `json:"argon2_parallelism"`
+}
+
+// PrivacyConfig controls personal data exports and account erasure.
+type PrivacyConfig struct {
+	// ErasureGraceDays is how long a deleted account can still be restored
+	// before its personal data is erased.
+	ErasureGraceDays     int           `json:"erasure_grace_days"`
+	ErasureGraceDuration time.Duration `json:"-"`
+	// ExportExpireHours is how long a finished data export can be downloaded.
+	ExportExpireHours    int           `json:"export_expire_hours"`
+	ExportExpireDuration time.Duration `json:"-"`
+}
+
+// AvatarConfig controls avatar uploads. Uploads are stored in R2 and are
+// unavailable when R2 is not configured.
+type AvatarConfig struct {
+	MaxSizeKB int `json:"max_size_kb"`
+	// MaxDimension rejects images wider or taller than this many pixels.
+	MaxDimension int `json:"max_dimension"`
+	// Sizes are the square edge lengths in pixels each upload is resized to.
+	Sizes       []int `json:"sizes"`
+	JPEGQuality int   `json:"jpeg_quality"`
+}
+
+// UsernameConfig controls which usernames can be chosen and how often they
+// can be changed. Usernames are case-insensitive and stored in lower case.
+type UsernameConfig struct {
+	MinLength int `json:"min_length"`
+	MaxLength int `json:"max_length"`
+	// Reserved usernames cannot be registered or changed to.
+	Reserved []string `json:"reserved"`
+	// ChangeLimit is how many times a username may be changed per window.
+	ChangeLimit          int           `json:"change_limit"`
+	ChangeWindowDays     int           `json:"change_window_days"`
+	ChangeWindowDuration time.Duration `json:"-"`
+	// RedirectDays is how long an old username keeps resolving to the
+	// account; no one else can claim it meanwhile.
+	RedirectDays     int           `json:"redirect_days"`
+	RedirectDuration time.Duration `json:"-"`
+}
+
+// APIKeyConfig controls how API keys are verified, rotated and cleaned up.
+type APIKeyConfig struct {
+	// Pepper keys the HMAC-SHA256 hashes of API key secrets. Changing it
+	// invalidates every key.
+	Pepper string `json:"-"` // 敏感信息不序列化
+	// CacheSeconds is how long a verified key is trusted without looking it
+	// up again, so a key revoked on another instance keeps working for up
+	// to this long there. CacheSize bounds the number of cached keys.
+	CacheSeconds  int           `json:"cache_seconds"`
+	CacheDuration time.Duration `json:"-"`
+	CacheSize     int           `json:"cache_size"`
+	// RotationGraceHours is how long a rotated key keeps working next to
+	// its successor unless the rotation asks for another window.
+	RotationGraceHours    int           `json:"rotation_grace_hours"`
+	RotationGraceDuration time.Duration `json:"-"`
+	// RotationNoticeHours is how long before a rotated key stops working
+	// its owner is reminded by email.
+	RotationNoticeHours    int           `json:"rotation_notice_hours"`
+	RotationNoticeDuration time.Duration `json:"-"`
+	// ExpiryNoticeDays is how long before a key expires its owner is
+	// reminded by email; 0 disables the reminder.
+	ExpiryNoticeDays     int           `json:"expiry_notice_days"`
+	ExpiryNoticeDuration time.Duration `json:"-"`
+	// DormantDays is how long after creation a key that was never used is
+	// marked as dormant; 0 disables marking.
+	DormantDays     int           `json:"dormant_days"`
+	DormantDuration time.Duration `json:"-"`
+	// RetentionDays is how long expired and revoked keys are kept before
+	// they are purged; 0 keeps them forever.
+	RetentionDays     int           `json:"retention_days"`
+	RetentionDuration time.Duration `json:"-"`
+}
+
+const (
+	// RateLimitKeyAPIKey counts API key requests per key
+	RateLimitKeyAPIKey = "api_key"
+	// RateLimitKeyUser counts requests per user, including their API keys
+	RateLimitKeyUser = "user"
+	// RateLimitKeyOrganization counts organization API key requests per organization
+	RateLimitKeyOrganization = "org"
+	// RateLimitKeyIP counts requests per client IP
+	RateLimitKeyIP = "ip"
+
+	// RateLimitStoreMemory keeps rate limit counters in process memory
+	RateLimitStoreMemory = "memory"
+	// RateLimitStoreDatabase shares rate limit counters across instances
+	RateLimitStoreDatabase = "database"
+)
+
+// RateLimitConfig controls request rate limits and monthly quotas. API keys
+// may override Requests, Burst and MonthlyQuota.
+type RateLimitConfig struct {
+	Enabled bool `json:"enabled"`
+	// Algorithm is token_bucket or sliding_window.
+	Algorithm string `json:"algorithm"`
+	// Requests are allowed per WindowSeconds. Burst is the token bucket
+	// capacity, Requests when 0.
+	Requests      int           `json:"requests"`
+	Burst         int           `json:"burst"`
+	WindowSeconds int           `json:"window_seconds"`
+	Window        time.Duration `json:"-"`
+	// KeyBy is what requests are counted against: api_key, user, org or ip.
+	// Requests without that subject fall back to the user, then the client
+	// IP.
+	KeyBy string `json:"key_by"`
+	// MonthlyQuota is the number of requests a subject may make per
+	// calendar month (UTC); 0 is unlimited.
+	MonthlyQuota int64 `json:"monthly_quota"`
+	// Store is memory or database.
+	Store string `json:"store"`
 }
 
 // Load loads configuration, preferring cached values if available.
@@ -201,6 +459,38 @@ func loadInternal(forceReload bool) (*Config, error) {
 		return nil, err
 	}
 
+	if err := loadAuthConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadOIDCConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadPasswordConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadPrivacyConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadAvatarConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadUsernameConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadAPIKeyConfig(config); err != nil {
+		return nil, err
+	}
+
+	if err := loadRateLimitConfig(config); err != nil {
+		return nil, err
+	}
+
 	if err := validateConfig(config); err != nil {
 		return nil, err
 	}
@@ -235,12 +525,29 @@ func loadServerConfig(config *Config) error {
 		mode = getEnv("APP_ENV", "debug")
 	}
 
+	// Trust no proxy unless configured, otherwise any client could choose
+	// its IP address with X-Forwarded-For
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnv("SERVER_TRUSTED_PROXIES", ""), ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid SERVER_TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy)
+			}
+		}
+		trustedProxies = append(trustedProxies, proxy)
+	}
+
 	config.Server = ServerConfig{
 		Port:           port,
 		Mode:           mode,
 		ReadTimeout:    readTimeout,
 		WriteTimeout:   writeTimeout,
 		MaxHeaderBytes: maxHeaderBytes,
+		TrustedProxies: trustedProxies,
 	}
 
 	return nil
@@ -366,23 +673,32 @@ func loadCachedConfig() (*Config, error) {
 }
 
 type cachedConfig struct {
-	Server   cachedServerConfig   `json:"server"`
-	Database cachedDatabaseConfig `json:"database"`
-	Redis    cachedRedisConfig    `json:"redis"`
-	JWT      cachedJWTConfig      `json:"jwt"`
-	Log      cachedLogConfig      `json:"log"`
-	OpenAI   cachedOpenAIConfig   `json:"openai"`
-	R2       cachedR2Config       `json:"r2"`
-	Email    cachedEmailConfig    `json:"email"`
-	App      cachedAppConfig      `json:"app"`
+	Server    cachedServerConfig    `json:"server"`
+	Database  cachedDatabaseConfig  `json:"database"`
+	Redis     cachedRedisConfig     `json:"redis"`
+	JWT       cachedJWTConfig       `json:"jwt"`
+	Log       cachedLogConfig       `json:"log"`
+	OpenAI    cachedOpenAIConfig    `json:"openai"`
+	R2        cachedR2Config        `json:"r2"`
+	Email     cachedEmailConfig     `json:"email"`
+	App       cachedAppConfig       `json:"app"`
+	Auth      cachedAuthConfig      `json:"auth"`
+	OIDC      cachedOIDCConfig      `json:"oidc"`
+	Password  cachedPasswordConfig  `json:"password"`
+	Privacy   cachedPrivacyConfig   `json:"privacy"`
+	Avatar    cachedAvatarConfig    `json:"avatar"`
+	Username  cachedUsernameConfig  `json:"username"`
+	APIKey    cachedAPIKeyConfig    `json:"api_key"`
+	RateLimit cachedRateLimitConfig `json:"rate_limit"`
 }
 
 type cachedServerConfig struct {
-	Port           int    `json:"port"`
-	Mode           string `json:"mode"`
-	ReadTimeout    int    `json:"read_timeout"`
-	WriteTimeout   int    `json:"write_timeout"`
-	MaxHeaderBytes int    `json:"max_header_bytes"`
+	Port           int      `json:"port"`
+	Mode           string   `json:"mode"`
+	ReadTimeout    int      `json:"read_timeout"`
+	WriteTimeout   int      `json:"write_timeout"`
+	MaxHeaderBytes int      `json:"max_header_bytes"`
+	TrustedProxies []string `json:"trusted_proxies"`
 }
 
 type cachedDatabaseConfig struct {
@@ -410,8 +726,13 @@ type cachedRedisConfig struct {
 }
 
 type cachedJWTConfig struct {
-	Secret     string `json:"secret"`
-	ExpireDays int    `json:"expire_days"`
+	Secret                 string `json:"secret"`
+	AccessExpireMinutes    int    `json:"access_expire_minutes"`
+	RefreshExpireDays      int    `json:"refresh_expire_days"`
+	RevocationCacheSeconds int    `json:"revocation_cache_seconds"`
+	Algorithm              string `json:"algorithm"`
+	KeysDir                string `json:"keys_dir"`
+	KeyRotationHours       int    `json:"key_rotation_hours"`
 }
 
 type cachedLogConfig struct {
@@ -447,14 +768,116 @@ type cachedEmailConfig struct {
 }
 
 type cachedAppConfig struct {
-	Name          string `json:"name"`
-	Version       string `json:"version"`
-	Secret        string `json:"secret"`
-	JWTSecret     string `json:"jwt_secret"`
-	JWTExpireDays int    `json:"jwt_expire_days"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Secret  string `json:"secret"`
+	URL     string `json:"url"`
+}
+
+type cachedAuthConfig struct {
+	SigningSecret                    string `json:"signing_secret"`
+	PasswordResetExpireMinutes       int    `json:"password_reset_expire_minutes"`
+	PasswordResetURL                 string `json:"password_reset_url"`
+	EmailVerificationExpireHours     int    `json:"email_verification_expire_hours"`
+	EmailVerificationURL             string `json:"email_verification_url"`
+	EmailVerificationPolicy          string `json:"email_verification_policy"`
+	MFAChallengeExpireMinutes        int    `json:"mfa_challenge_expire_minutes"`
+	LoginMaxAttempts                 int    `json:"login_max_attempts"`
+	LoginIPMaxAttempts               int    `json:"login_ip_max_attempts"`
+	LoginAttemptWindowMinutes        int    `json:"login_attempt_window_minutes"`
+	LoginLockoutMinutes              int    `json:"login_lockout_minutes"`
+	LoginMaxLockoutMinutes           int    `json:"login_max_lockout_minutes"`
+	LoginAttemptStore                string `json:"login_attempt_store"`
+	PasswordlessEnabled              bool   `json:"passwordless_enabled"`
+	PasswordlessExpireMinutes        int    `json:"passwordless_expire_minutes"`
+	PasswordlessMaxRequests          int    `json:"passwordless_max_requests"`
+	PasswordlessRequestWindowMinutes int    `json:"passwordless_request_window_minutes"`
+	PasswordlessURL                  string `json:"passwordless_url"`
+}
+
+type cachedOIDCProviderConfig struct {
+	Name         string   `json:"name"`
+	DisplayName  string   `json:"display_name"`
+	IssuerURL    string   `json:"issuer_url"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+type cachedOIDCConfig struct {
+	Providers          []cachedOIDCProviderConfig `json:"providers"`
+	RedirectBaseURL    string                     `json:"redirect_base_url"`
+	StateExpireMinutes int                        `json:"state_expire_minutes"`
+	AllowSignup        bool                       `json:"allow_signup"`
+}
+
+type cachedPasswordConfig struct {
+	MinLength         int    `json:"min_length"`
+	MaxLength         int    `json:"max_length"`
+	RequireUpper      bool   `json:"require_upper"`
+	RequireLower      bool   `json:"require_lower"`
+	RequireDigit      bool   `json:"require_digit"`
+	RequireSymbol     bool   `json:"require_symbol"`
+	MinCharClasses    int    `json:"min_char_classes"`
+	RejectUserInfo    bool   `json:"reject_user_info"`
+	HistorySize       int    `json:"history_size"`
+	BreachedListPath  string `json:"breached_list_path"`
+	HashAlgorithm     string `json:"hash_algorithm"`
+	BcryptCost        int    `json:"bcrypt_cost"`
+	Argon2MemoryKB    int    `json:"argon2_memory_kb"`
+	Argon2Iterations  int    `json:"argon2_iterations"`
+	Argon2Parallelism int    `json:"argon2_parallelism"`
+}
+
+type cachedPrivacyConfig struct {
+	ErasureGraceDays  int `json:"erasure_grace_days"`
+	ExportExpireHours int `json:"export_expire_hours"`
+}
+
+type cachedAvatarConfig struct {
+	MaxSizeKB    int   `json:"max_size_kb"`
+	MaxDimension int   `json:"max_dimension"`
+	Sizes        []int `json:"sizes"`
+	JPEGQuality  int   `json:"jpeg_quality"`
+}
+
+type cachedAPIKeyConfig struct {
+	Pepper              string `json:"pepper"`
+	CacheSeconds        int    `json:"cache_seconds"`
+	CacheSize           int    `json:"cache_size"`
+	RotationGraceHours  int    `json:"rotation_grace_hours"`
+	RotationNoticeHours int    `json:"rotation_notice_hours"`
+	ExpiryNoticeDays    int    `json:"expiry_notice_days"`
+	DormantDays         int    `json:"dormant_days"`
+	RetentionDays       int    `json:"retention_days"`
+}
+
+type cachedRateLimitConfig struct {
+	Enabled       bool   `json:"enabled"`
+	Algorithm     string `json:"algorithm"`
+	Requests      int    `json:"requests"`
+	Burst         int    `json:"burst"`
+	WindowSeconds int    `json:"window_seconds"`
+	KeyBy         string `json:"key_by"`
+	MonthlyQuota  int64  `json:"monthly_quota"`
+	Store         string `json:"store"`
+}
+
+type cachedUsernameConfig struct {
+	MinLength        int      `json:"min_length"`
+	MaxLength        int      `json:"max_length"`
+	Reserved         []string `json:"reserved"`
+	ChangeLimit      int      `json:"change_limit"`
+	ChangeWindowDays int      `json:"change_window_days"`
+	RedirectDays     int      `json:"redirect_days"`
 }
 
 func newCachedConfig(cfg *Config) cachedConfig {
+	oidcProviders := make([]cachedOIDCProviderConfig, 0, len(cfg.OIDC.Providers))
+	for _, p := range cfg.OIDC.Providers {
+		oidcProviders = append(oidcProviders, cachedOIDCProviderConfig(p))
+	}
+
 	return cachedConfig{
 		Server: cachedServerConfig{
 			Port:           cfg.Server.Port,
@@ -462,6 +885,7 @@ func newCachedConfig(cfg *Config) cachedConfig {
 			ReadTimeout:    cfg.Server.ReadTimeout,
 			WriteTimeout:   cfg.Server.WriteTimeout,
 			MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+			TrustedProxies: cfg.Server.TrustedProxies,
 		},
 		Database: cachedDatabaseConfig{
 			Driver:          cfg.Database.Driver,
@@ -486,8 +910,13 @@ func newCachedConfig(cfg *Config) cachedConfig {
 			MinIdleConns: cfg.Redis.MinIdleConns,
 		},
 		JWT: cachedJWTConfig{
-			Secret:     cfg.JWT.Secret,
-			ExpireDays: cfg.JWT.ExpireDays,
+			Secret:                 cfg.JWT.Secret,
+			AccessExpireMinutes:    cfg.JWT.AccessExpireMinutes,
+			RefreshExpireDays:      cfg.JWT.RefreshExpireDays,
+			RevocationCacheSeconds: cfg.JWT.RevocationCacheSeconds,
+			Algorithm:              cfg.JWT.Algorithm,
+			KeysDir:                cfg.JWT.KeysDir,
+			KeyRotationHours:       cfg.JWT.KeyRotationHours,
 		},
 		Log: cachedLogConfig{
 			Level:      cfg.Log.Level,
@@ -518,11 +947,70 @@ func newCachedConfig(cfg *Config) cachedConfig {
 			ResendAPIKey: cfg.Email.ResendAPIKey,
 		},
 		App: cachedAppConfig{
-			Name:          cfg.App.Name,
-			Version:       cfg.App.Version,
-			Secret:        cfg.App.Secret,
-			JWTSecret:     cfg.App.JWTSecret,
-			JWTExpireDays: int(cfg.App.JWTExpire / (24 * time.Hour)),
+			Name:    cfg.App.Name,
+			Version: cfg.App.Version,
+			Secret:  cfg.App.Secret,
+			URL:     cfg.App.URL,
+		},
+		Auth: cachedAuthConfig{
+			SigningSecret:                    cfg.Auth.SigningSecret,
+			PasswordResetExpireMinutes:       cfg.Auth.PasswordResetExpireMinutes,
+			PasswordResetURL:                 cfg.Auth.PasswordResetURL,
+			EmailVerificationExpireHours:     cfg.Auth.EmailVerificationExpireHours,
+			EmailVerificationURL:             cfg.Auth.EmailVerificationURL,
+			EmailVerificationPolicy:          cfg.Auth.EmailVerificationPolicy,
+			MFAChallengeExpireMinutes:        cfg.Auth.MFAChallengeExpireMinutes,
+			LoginMaxAttempts:                 cfg.Auth.LoginMaxAttempts,
+			LoginIPMaxAttempts:               cfg.Auth.LoginIPMaxAttempts,
+			LoginAttemptWindowMinutes:        cfg.Auth.LoginAttemptWindowMinutes,
+			LoginLockoutMinutes:              cfg.Auth.LoginLockoutMinutes,
+			LoginMaxLockoutMinutes:           cfg.Auth.LoginMaxLockoutMinutes,
+			LoginAttemptStore:                cfg.Auth.LoginAttemptStore,
+			PasswordlessEnabled:              cfg.Auth.PasswordlessEnabled,
+			PasswordlessExpireMinutes:        cfg.Auth.PasswordlessExpireMinutes,
+			PasswordlessMaxRequests:          cfg.Auth.PasswordlessMaxRequests,
+			PasswordlessRequestWindowMinutes: cfg.Auth.PasswordlessRequestWindowMinutes,
+			PasswordlessURL:                  cfg.Auth.PasswordlessURL,
+		},
+		OIDC: cachedOIDCConfig{
+			Providers:          oidcProviders,
+			RedirectBaseURL:    cfg.OIDC.RedirectBaseURL,
+			StateExpireMinutes: cfg.OIDC.StateExpireMinutes,
+			AllowSignup:        cfg.OIDC.AllowSignup,
+		},
+		Password: cachedPasswordConfig(cfg.Password),
+		Privacy: cachedPrivacyConfig{
+			ErasureGraceDays:  cfg.Privacy.ErasureGraceDays,
+			ExportExpireHours: cfg.Privacy.ExportExpireHours,
+		},
+		Avatar: cachedAvatarConfig(cfg.Avatar),
+		Username: cachedUsernameConfig{
+			MinLength:        cfg.Username.MinLength,
+			MaxLength:        cfg.Username.MaxLength,
+			Reserved:         cfg.Username.Reserved,
+			ChangeLimit:      cfg.Username.ChangeLimit,
+			ChangeWindowDays: cfg.Username.ChangeWindowDays,
+			RedirectDays:     cfg.Username.RedirectDays,
+		},
+		APIKey: cachedAPIKeyConfig{
+			Pepper:              cfg.APIKey.Pepper,
+			CacheSeconds:        cfg.APIKey.CacheSeconds,
+			CacheSize:           cfg.APIKey.CacheSize,
+			RotationGraceHours:  cfg.APIKey.RotationGraceHours,
+			RotationNoticeHours: cfg.APIKey.RotationNoticeHours,
+			ExpiryNoticeDays:    cfg.APIKey.ExpiryNoticeDays,
+			DormantDays:         cfg.APIKey.DormantDays,
+			RetentionDays:       cfg.APIKey.RetentionDays,
+		},
+		RateLimit: cachedRateLimitConfig{
+			Enabled:       cfg.RateLimit.Enabled,
+			Algorithm:     cfg.RateLimit.Algorithm,
+			Requests:      cfg.RateLimit.Requests,
+			Burst:         cfg.RateLimit.Burst,
+			WindowSeconds: cfg.RateLimit.WindowSeconds,
+			KeyBy:         cfg.RateLimit.KeyBy,
+			MonthlyQuota:  cfg.RateLimit.MonthlyQuota,
+			Store:         cfg.RateLimit.Store,
 		},
 	}
 }
@@ -536,6 +1024,7 @@ func (c cachedConfig) toConfig() *Config {
 		ReadTimeout:    c.Server.ReadTimeout,
 		WriteTimeout:   c.Server.WriteTimeout,
 		MaxHeaderBytes: c.Server.MaxHeaderBytes,
+		TrustedProxies: c.Server.TrustedProxies,
 	}
 
 	cfg.Database = DatabaseConfig{
@@ -563,9 +1052,17 @@ func (c cachedConfig) toConfig() *Config {
 	}
 
 	cfg.JWT = JWTConfig{
-		Secret:         c.JWT.Secret,
-		ExpireDays:     c.JWT.ExpireDays,
-		ExpireDuration: time.Duration(c.JWT.ExpireDays) * 24 * time.Hour,
+		Secret:                 c.JWT.Secret,
+		AccessExpireMinutes:    c.JWT.AccessExpireMinutes,
+		AccessExpireDuration:   time.Duration(c.JWT.AccessExpireMinutes) * time.Minute,
+		RefreshExpireDays:      c.JWT.RefreshExpireDays,
+		RefreshExpireDuration:  time.Duration(c.JWT.RefreshExpireDays) * 24 * time.Hour,
+		RevocationCacheSeconds: c.JWT.RevocationCacheSeconds,
+		RevocationCacheTTL:     time.Duration(c.JWT.RevocationCacheSeconds) * time.Second,
+		Algorithm:              c.JWT.Algorithm,
+		KeysDir:                c.JWT.KeysDir,
+		KeyRotationHours:       c.JWT.KeyRotationHours,
+		KeyRotationInterval:    time.Duration(c.JWT.KeyRotationHours) * time.Hour,
 	}
 
 	cfg.Log = LogConfig{
@@ -599,11 +1096,95 @@ func (c cachedConfig) toConfig() *Config {
 	}
 
 	cfg.App = AppConfig{
-		Name:      c.App.Name,
-		Version:   c.App.Version,
-		Secret:    c.App.Secret,
-		JWTSecret: c.App.JWTSecret,
-		JWTExpire: time.Duration(c.App.JWTExpireDays) * 24 * time.Hour,
+		Name:    c.App.Name,
+		Version: c.App.Version,
+		Secret:  c.App.Secret,
+		URL:     c.App.URL,
+	}
+
+	cfg.Auth = AuthConfig{
+		SigningSecret:                     c.Auth.SigningSecret,
+		PasswordResetExpireMinutes:        c.Auth.PasswordResetExpireMinutes,
+		PasswordResetExpireDuration:       time.Duration(c.Auth.PasswordResetExpireMinutes) * time.Minute,
+		PasswordResetURL:                  c.Auth.PasswordResetURL,
+		EmailVerificationExpireHours:      c.Auth.EmailVerificationExpireHours,
+		EmailVerificationExpireDuration:   time.Duration(c.Auth.EmailVerificationExpireHours) * time.Hour,
+		EmailVerificationURL:              c.Auth.EmailVerificationURL,
+		EmailVerificationPolicy:           c.Auth.EmailVerificationPolicy,
+		MFAChallengeExpireMinutes:         c.Auth.MFAChallengeExpireMinutes,
+		MFAChallengeExpireDuration:        time.Duration(c.Auth.MFAChallengeExpireMinutes) * time.Minute,
+		LoginMaxAttempts:                  c.Auth.LoginMaxAttempts,
+		LoginIPMaxAttempts:                c.Auth.LoginIPMaxAttempts,
+		LoginAttemptWindowMinutes:         c.Auth.LoginAttemptWindowMinutes,
+		LoginAttemptWindowDuration:        time.Duration(c.Auth.LoginAttemptWindowMinutes) * time.Minute,
+		LoginLockoutMinutes:               c.Auth.LoginLockoutMinutes,
+		LoginLockoutDuration:              time.Duration(c.Auth.LoginLockoutMinutes) * time.Minute,
+		LoginMaxLockoutMinutes:            c.Auth.LoginMaxLockoutMinutes,
+		LoginMaxLockoutDuration:           time.Duration(c.Auth.LoginMaxLockoutMinutes) * time.Minute,
+		LoginAttemptStore:                 c.Auth.LoginAttemptStore,
+		PasswordlessEnabled:               c.Auth.PasswordlessEnabled,
+		PasswordlessExpireMinutes:         c.Auth.PasswordlessExpireMinutes,
+		PasswordlessExpireDuration:        time.Duration(c.Auth.PasswordlessExpireMinutes) * time.Minute,
+		PasswordlessMaxRequests:           c.Auth.PasswordlessMaxRequests,
+		PasswordlessRequestWindowMinutes:  c.Auth.PasswordlessRequestWindowMinutes,
+		PasswordlessRequestWindowDuration: time.Duration(c.Auth.PasswordlessRequestWindowMinutes) * time.Minute,
+		PasswordlessURL:                   c.Auth.PasswordlessURL,
+	}
+
+	cfg.OIDC = OIDCConfig{
+		RedirectBaseURL:     c.OIDC.RedirectBaseURL,
+		StateExpireMinutes:  c.OIDC.StateExpireMinutes,
+		StateExpireDuration: time.Duration(c.OIDC.StateExpireMinutes) * time.Minute,
+		AllowSignup:         c.OIDC.AllowSignup,
+	}
+	for _, p := range c.OIDC.Providers {
+		cfg.OIDC.Providers = append(cfg.OIDC.Providers, OIDCProviderConfig(p))
+	}
+
+	cfg.Password = PasswordConfig(c.Password)
+	cfg.Privacy = PrivacyConfig{
+		ErasureGraceDays:     c.Privacy.ErasureGraceDays,
+		ErasureGraceDuration: time.Duration(c.Privacy.ErasureGraceDays) * 24 * time.Hour,
+		ExportExpireHours:    c.Privacy.ExportExpireHours,
+		ExportExpireDuration: time.Duration(c.Privacy.ExportExpireHours) * time.Hour,
+	}
+	cfg.Avatar = AvatarConfig(c.Avatar)
+	cfg.Username = UsernameConfig{
+		MinLength:            c.Username.MinLength,
+		MaxLength:            c.Username.MaxLength,
+		Reserved:             c.Username.Reserved,
+		ChangeLimit:          c.Username.ChangeLimit,
+		ChangeWindowDays:     c.Username.ChangeWindowDays,
+		ChangeWindowDuration: time.Duration(c.Username.ChangeWindowDays) * 24 * time.Hour,
+		RedirectDays:         c.Username.RedirectDays,
+		RedirectDuration:     time.Duration(c.Username.RedirectDays) * 24 * time.Hour,
+	}
+	cfg.APIKey = APIKeyConfig{
+		Pepper:                 c.APIKey.Pepper,
+		CacheSeconds:           c.APIKey.CacheSeconds,
+		CacheDuration:          time.Duration(c.APIKey.CacheSeconds) * time.Second,
+		CacheSize:              c.APIKey.CacheSize,
+		RotationGraceHours:     c.APIKey.RotationGraceHours,
+		RotationGraceDuration:  time.Duration(c.APIKey.RotationGraceHours) * time.Hour,
+		RotationNoticeHours:    c.APIKey.RotationNoticeHours,
+		RotationNoticeDuration: time.Duration(c.APIKey.RotationNoticeHours) * time.Hour,
+		ExpiryNoticeDays:       c.APIKey.ExpiryNoticeDays,
+		ExpiryNoticeDuration:   time.Duration(c.APIKey.ExpiryNoticeDays) * 24 * time.Hour,
+		DormantDays:            c.APIKey.DormantDays,
+		DormantDuration:        time.Duration(c.APIKey.DormantDays) * 24 * time.Hour,
+		RetentionDays:          c.APIKey.RetentionDays,
+		RetentionDuration:      time.Duration(c.APIKey.RetentionDays) * 24 * time.Hour,
+	}
+	cfg.RateLimit = RateLimitConfig{
+		Enabled:       c.RateLimit.Enabled,
+		Algorithm:     c.RateLimit.Algorithm,
+		Requests:      c.RateLimit.Requests,
+		Burst:         c.RateLimit.Burst,
+		WindowSeconds: c.RateLimit.WindowSeconds,
+		Window:        time.Duration(c.RateLimit.WindowSeconds) * time.Second,
+		KeyBy:         c.RateLimit.KeyBy,
+		MonthlyQuota:  c.RateLimit.MonthlyQuota,
+		Store:         c.RateLimit.Store,
 	}
 
 	return cfg
@@ -687,20 +1268,55 @@ func loadRedisConfig(config *Config) error {
 }
 
 func loadJWTConfig(config *Config) error {
-	expireDays, err := strconv.Atoi(getEnv("JWT_EXPIRE_DAYS", "7"))
+	accessMinutes, err := strconv.Atoi(getEnv("JWT_ACCESS_EXPIRE_MINUTES", "15"))
+	if err != nil {
+		return fmt.Errorf("invalid JWT_ACCESS_EXPIRE_MINUTES: %v", err)
+	}
+
+	// JWT_EXPIRE_DAYS used to control the single long-lived token; it now
+	// serves as the fallback lifetime of the refresh token.
+	refreshDays, err := strconv.Atoi(getEnv("JWT_REFRESH_EXPIRE_DAYS", getEnv("JWT_EXPIRE_DAYS", "30")))
+	if err != nil {
+		return fmt.Errorf("invalid JWT_REFRESH_EXPIRE_DAYS: %v", err)
+	}
+
+	revocationCacheSeconds, err := strconv.Atoi(getEnv("JWT_REVOCATION_CACHE_SECONDS", "30"))
 	if err != nil {
-		return fmt.Errorf("invalid JWT_EXPIRE_DAYS: %v", err)
+		return fmt.Errorf("invalid JWT_REVOCATION_CACHE_SECONDS: %v", err)
+	}
+
+	rotationHours, err := strconv.Atoi(getEnv("JWT_KEY_ROTATION_HOURS", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid JWT_KEY_ROTATION_HOURS: %v", err)
 	}
 
 	config.JWT = JWTConfig{
-		Secret:         getEnv("JWT_SECRET", ""),
-		ExpireDays:     expireDays,
-		ExpireDuration: time.Duration(expireDays) * 24 * time.Hour,
+		Secret:                 getEnv("JWT_SECRET", ""),
+		AccessExpireMinutes:    accessMinutes,
+		AccessExpireDuration:   time.Duration(accessMinutes) * time.Minute,
+		RefreshExpireDays:      refreshDays,
+		RefreshExpireDuration:  time.Duration(refreshDays) * 24 * time.Hour,
+		RevocationCacheSeconds: revocationCacheSeconds,
+		RevocationCacheTTL:     time.Duration(revocationCacheSeconds) * time.Second,
+		Algorithm:              normalizeJWTAlgorithm(getEnv("JWT_ALGORITHM", "HS256")),
+		KeysDir:                getEnv("JWT_KEYS_DIR", ""),
+		KeyRotationHours:       rotationHours,
+		KeyRotationInterval:    time.Duration(rotationHours) * time.Hour,
 	}
 
 	return nil
 }
 
+// normalizeJWTAlgorithm accepts algorithm names in any case and returns the
+// JWS spelling ("EdDSA" is the only mixed-case one).
+func normalizeJWTAlgorithm(alg string) string {
+	alg = strings.ToUpper(strings.TrimSpace(alg))
+	if alg == "EDDSA" {
+		return "EdDSA"
+	}
+	return alg
+}
+
 func loadLogConfig(config *Config) error {
 	maxSize, err := strconv.Atoi(getEnv("LOG_MAX_SIZE", "100"))
 	if err != nil {
@@ -772,21 +1388,456 @@ func loadEmailConfig(config *Config) error {
 }
 
 func loadAppConfig(config *Config) error {
-	expireDays, err := strconv.Atoi(getEnv("APP_JWT_EXPIRE_DAYS", "7"))
+	config.App = AppConfig{
+		Name:    getEnv("APP_NAME", "Llamabase"),
+		Version: getEnv("APP_VERSION", "1.0.0"),
+		Secret:  getEnv("APP_SECRET", ""),
+		URL:     strings.TrimRight(getEnv("APP_URL", "http://localhost:6066"), "/"),
+	}
+	return nil
+}
+
+func loadAuthConfig(config *Config) error {
+	resetMinutes, err := strconv.Atoi(getEnv("AUTH_PASSWORD_RESET_EXPIRE_MINUTES", "30"))
 	if err != nil {
-		return fmt.Errorf("invalid APP_JWT_EXPIRE_DAYS: %v", err)
+		return fmt.Errorf("invalid AUTH_PASSWORD_RESET_EXPIRE_MINUTES: %v", err)
 	}
 
-	config.App = AppConfig{
-		Name:      getEnv("APP_NAME", "Llamabase"),
-		Version:   getEnv("APP_VERSION", "1.0.0"),
-		Secret:    getEnv("APP_SECRET", ""),
-		JWTSecret: getEnv("APP_JWT_SECRET", ""),
-		JWTExpire: time.Duration(expireDays) * 24 * time.Hour,
+	verificationHours, err := strconv.Atoi(getEnv("AUTH_EMAIL_VERIFICATION_EXPIRE_HOURS", "24"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_EMAIL_VERIFICATION_EXPIRE_HOURS: %v", err)
+	}
+
+	mfaChallengeMinutes, err := strconv.Atoi(getEnv("AUTH_MFA_CHALLENGE_EXPIRE_MINUTES", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_MFA_CHALLENGE_EXPIRE_MINUTES: %v", err)
+	}
+
+	loginMaxAttempts, err := strconv.Atoi(getEnv("AUTH_LOGIN_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOGIN_MAX_ATTEMPTS: %v", err)
+	}
+
+	loginIPMaxAttempts, err := strconv.Atoi(getEnv("AUTH_LOGIN_IP_MAX_ATTEMPTS", "20"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOGIN_IP_MAX_ATTEMPTS: %v", err)
+	}
+
+	loginWindowMinutes, err := strconv.Atoi(getEnv("AUTH_LOGIN_ATTEMPT_WINDOW_MINUTES", "15"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOGIN_ATTEMPT_WINDOW_MINUTES: %v", err)
+	}
+
+	loginLockoutMinutes, err := strconv.Atoi(getEnv("AUTH_LOGIN_LOCKOUT_MINUTES", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOGIN_LOCKOUT_MINUTES: %v", err)
+	}
+
+	loginMaxLockoutMinutes, err := strconv.Atoi(getEnv("AUTH_LOGIN_MAX_LOCKOUT_MINUTES", "1440"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_LOGIN_MAX_LOCKOUT_MINUTES: %v", err)
+	}
+
+	passwordlessEnabled, err := strconv.ParseBool(getEnv("AUTH_PASSWORDLESS_ENABLED", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_PASSWORDLESS_ENABLED: %v", err)
+	}
+
+	passwordlessMinutes, err := strconv.Atoi(getEnv("AUTH_PASSWORDLESS_EXPIRE_MINUTES", "15"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_PASSWORDLESS_EXPIRE_MINUTES: %v", err)
+	}
+
+	passwordlessMaxRequests, err := strconv.Atoi(getEnv("AUTH_PASSWORDLESS_MAX_REQUESTS", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_PASSWORDLESS_MAX_REQUESTS: %v", err)
+	}
+
+	passwordlessWindowMinutes, err := strconv.Atoi(getEnv("AUTH_PASSWORDLESS_REQUEST_WINDOW_MINUTES", "60"))
+	if err != nil {
+		return fmt.Errorf("invalid AUTH_PASSWORDLESS_REQUEST_WINDOW_MINUTES: %v", err)
+	}
+
+	// Fall back to the application secret, then the JWT secret, so existing
+	// deployments do not need a new variable
+	signingSecret := getEnv("AUTH_SIGNING_SECRET", "")
+	if signingSecret == "" {
+		signingSecret = config.App.Secret
+	}
+	if signingSecret == "" {
+		signingSecret = config.JWT.Secret
+	}
+
+	config.Auth = AuthConfig{
+		SigningSecret:                     signingSecret,
+		PasswordResetExpireMinutes:        resetMinutes,
+		PasswordResetExpireDuration:       time.Duration(resetMinutes) * time.Minute,
+		PasswordResetURL:                  getEnv("AUTH_PASSWORD_RESET_URL", config.App.URL+"/reset-password"),
+		EmailVerificationExpireHours:      verificationHours,
+		EmailVerificationExpireDuration:   time.Duration(verificationHours) * time.Hour,
+		EmailVerificationURL:              getEnv("AUTH_EMAIL_VERIFICATION_URL", config.App.URL+"/verify-email"),
+		EmailVerificationPolicy:           strings.ToLower(getEnv("AUTH_EMAIL_VERIFICATION_POLICY", EmailVerificationNone)),
+		MFAChallengeExpireMinutes:         mfaChallengeMinutes,
+		MFAChallengeExpireDuration:        time.Duration(mfaChallengeMinutes) * time.Minute,
+		LoginMaxAttempts:                  loginMaxAttempts,
+		LoginIPMaxAttempts:                loginIPMaxAttempts,
+		LoginAttemptWindowMinutes:         loginWindowMinutes,
+		LoginAttemptWindowDuration:        time.Duration(loginWindowMinutes) * time.Minute,
+		LoginLockoutMinutes:               loginLockoutMinutes,
+		LoginLockoutDuration:              time.Duration(loginLockoutMinutes) * time.Minute,
+		LoginMaxLockoutMinutes:            loginMaxLockoutMinutes,
+		LoginMaxLockoutDuration:           time.Duration(loginMaxLockoutMinutes) * time.Minute,
+		LoginAttemptStore:                 strings.ToLower(getEnv("AUTH_LOGIN_ATTEMPT_STORE", LoginAttemptStoreMemory)),
+		PasswordlessEnabled:               passwordlessEnabled,
+		PasswordlessExpireMinutes:         passwordlessMinutes,
+		PasswordlessExpireDuration:        time.Duration(passwordlessMinutes) * time.Minute,
+		PasswordlessMaxRequests:           passwordlessMaxRequests,
+		PasswordlessRequestWindowMinutes:  passwordlessWindowMinutes,
+		PasswordlessRequestWindowDuration: time.Duration(passwordlessWindowMinutes) * time.Minute,
+		PasswordlessURL:                   getEnv("AUTH_PASSWORDLESS_URL", config.App.URL+"/login/magic"),
 	}
 	return nil
 }
 
+func loadOIDCConfig(config *Config) error {
+	stateMinutes, err := strconv.Atoi(getEnv("OIDC_STATE_EXPIRE_MINUTES", "10"))
+	if err != nil {
+		return fmt.Errorf("invalid OIDC_STATE_EXPIRE_MINUTES: %v", err)
+	}
+
+	allowSignup, err := strconv.ParseBool(getEnv("OIDC_ALLOW_SIGNUP", "true"))
+	if err != nil {
+		return fmt.Errorf("invalid OIDC_ALLOW_SIGNUP: %v", err)
+	}
+
+	// Providers are listed by name; each one reads OIDC_<NAME>_* variables
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			IssuerURL:    strings.TrimRight(getEnv(prefix+"ISSUER_URL", ""), "/"),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		})
+	}
+
+	config.OIDC = OIDCConfig{
+		Providers:           providers,
+		RedirectBaseURL:     strings.TrimRight(getEnv("OIDC_REDIRECT_BASE_URL", config.App.URL+"/v1/auth/oidc"), "/"),
+		StateExpireMinutes:  stateMinutes,
+		StateExpireDuration: time.Duration(stateMinutes) * time.Minute,
+		AllowSignup:         allowSignup,
+	}
+	return nil
+}
+
+func loadPasswordConfig(config *Config) error {
+	minLength, err := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_MIN_LENGTH: %v", err)
+	}
+
+	maxLength, err := strconv.Atoi(getEnv("PASSWORD_MAX_LENGTH", "72"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_MAX_LENGTH: %v", err)
+	}
+
+	minCharClasses, err := strconv.Atoi(getEnv("PASSWORD_MIN_CHAR_CLASSES", "0"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_MIN_CHAR_CLASSES: %v", err)
+	}
+
+	historySize, err := strconv.Atoi(getEnv("PASSWORD_HISTORY_SIZE", "5"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_HISTORY_SIZE: %v", err)
+	}
+
+	requireUpper, err := strconv.ParseBool(getEnv("PASSWORD_REQUIRE_UPPER", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_REQUIRE_UPPER: %v", err)
+	}
+
+	requireLower, err := strconv.ParseBool(getEnv("PASSWORD_REQUIRE_LOWER", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_REQUIRE_LOWER: %v", err)
+	}
+
+	requireDigit, err := strconv.ParseBool(getEnv("PASSWORD_REQUIRE_DIGIT", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_REQUIRE_DIGIT: %v", err)
+	}
+
+	requireSymbol, err := strconv.ParseBool(getEnv("PASSWORD_REQUIRE_SYMBOL", "false"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_REQUIRE_SYMBOL: %v", err)
+	}
+
+	rejectUserInfo, err := strconv.ParseBool(getEnv("PASSWORD_REJECT_USER_INFO", "true"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_REJECT_USER_INFO: %v", err)
+	}
+
+	bcryptCost, err := strconv.Atoi(getEnv("PASSWORD_BCRYPT_COST", "10"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_BCRYPT_COST: %v", err)
+	}
+
+	argon2Memory, err := strconv.Atoi(getEnv("PASSWORD_ARGON2_MEMORY_KB", "65536"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_ARGON2_MEMORY_KB: %v", err)
+	}
+
+	argon2Iterations, err := strconv.Atoi(getEnv("PASSWORD_ARGON2_ITERATIONS", "3"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_ARGON2_ITERATIONS: %v", err)
+	}
+
+	argon2Parallelism, err := strconv.Atoi(getEnv("PASSWORD_ARGON2_PARALLELISM", "2"))
+	if err != nil {
+		return fmt.Errorf("invalid PASSWORD_ARGON2_PARALLELISM: %v", err)
+	}
+
+	config.Password = PasswordConfig{
+		MinLength:         minLength,
+		MaxLength:         maxLength,
+		RequireUpper:      requireUpper,
+		RequireLower:      requireLower,
+		RequireDigit:      requireDigit,
+		RequireSymbol:     requireSymbol,
+		MinCharClasses:    minCharClasses,
+		RejectUserInfo:    rejectUserInfo,
+		HistorySize:       historySize,
+		BreachedListPath:  getEnv("PASSWORD_BREACHED_LIST_PATH", ""),
+		HashAlgorithm:     strings.ToLower(getEnv("PASSWORD_HASH_ALGORITHM", "argon2id")),
+		BcryptCost:        bcryptCost,
+		Argon2MemoryKB:    argon2Memory,
+		Argon2Iterations:  argon2Iterations,
+		Argon2Parallelism: argon2Parallelism,
+	}
+	return nil
+}
+
+func loadPrivacyConfig(config *Config) error {
+	graceDays, err := strconv.Atoi(getEnv("PRIVACY_ERASURE_GRACE_DAYS", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid PRIVACY_ERASURE_GRACE_DAYS: %v", err)
+	}
+
+	exportHours, err := strconv.Atoi(getEnv("PRIVACY_EXPORT_EXPIRE_HOURS", "72"))
+	if err != nil {
+		return fmt.Errorf("invalid PRIVACY_EXPORT_EXPIRE_HOURS: %v", err)
+	}
+
+	config.Privacy = PrivacyConfig{
+		ErasureGraceDays:     graceDays,
+		ErasureGraceDuration: time.Duration(graceDays) * 24 * time.Hour,
+		ExportExpireHours:    exportHours,
+		ExportExpireDuration: time.Duration(exportHours) * time.Hour,
+	}
+	return nil
+}
+
+func loadAvatarConfig(config *Config) error {
+	maxSizeKB, err := strconv.Atoi(getEnv("AVATAR_MAX_SIZE_KB", "5120"))
+	if err != nil {
+		return fmt.Errorf("invalid AVATAR_MAX_SIZE_KB: %v", err)
+	}
+
+	maxDimension, err := strconv.Atoi(getEnv("AVATAR_MAX_DIMENSION", "4096"))
+	if err != nil {
+		return fmt.Errorf("invalid AVATAR_MAX_DIMENSION: %v", err)
+	}
+
+	var sizes []int
+	for _, v := range strings.Split(getEnv("AVATAR_SIZES", "512,256,64"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid AVATAR_SIZES: %v", err)
+		}
+		sizes = append(sizes, size)
+	}
+
+	quality, err := strconv.Atoi(getEnv("AVATAR_JPEG_QUALITY", "85"))
+	if err != nil {
+		return fmt.Errorf("invalid AVATAR_JPEG_QUALITY: %v", err)
+	}
+
+	config.Avatar = AvatarConfig{
+		MaxSizeKB:    maxSizeKB,
+		MaxDimension: maxDimension,
+		Sizes:        sizes,
+		JPEGQuality:  quality,
+	}
+	return nil
+}
+
+func loadUsernameConfig(config *Config) error {
+	minLength, err := strconv.Atoi(getEnv("USERNAME_MIN_LENGTH", "3"))
+	if err != nil {
+		return fmt.Errorf("invalid USERNAME_MIN_LENGTH: %v", err)
+	}
+
+	maxLength, err := strconv.Atoi(getEnv("USERNAME_MAX_LENGTH", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid USERNAME_MAX_LENGTH: %v", err)
+	}
+
+	reservedList := getEnv("USERNAME_RESERVED", "")
+	if strings.TrimSpace(reservedList) == "" {
+		reservedList = defaultReservedUsernames
+	}
+	var reserved []string
+	for _, name := range strings.Split(reservedList, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			reserved = append(reserved, name)
+		}
+	}
+
+	changeLimit, err := strconv.Atoi(getEnv("USERNAME_CHANGE_LIMIT", "2"))
+	if err != nil {
+		return fmt.Errorf("invalid USERNAME_CHANGE_LIMIT: %v", err)
+	}
+
+	changeWindow, err := strconv.Atoi(getEnv("USERNAME_CHANGE_WINDOW_DAYS", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid USERNAME_CHANGE_WINDOW_DAYS: %v", err)
+	}
+
+	redirectDays, err := strconv.Atoi(getEnv("USERNAME_REDIRECT_DAYS", "90"))
+	if err != nil {
+		return fmt.Errorf("invalid USERNAME_REDIRECT_DAYS: %v", err)
+	}
+
+	config.Username = UsernameConfig{
+		MinLength:            minLength,
+		MaxLength:            maxLength,
+		Reserved:             reserved,
+		ChangeLimit:          changeLimit,
+		ChangeWindowDays:     changeWindow,
+		ChangeWindowDuration: time.Duration(changeWindow) * 24 * time.Hour,
+		RedirectDays:         redirectDays,
+		RedirectDuration:     time.Duration(redirectDays) * 24 * time.Hour,
+	}
+	return nil
+}
+
+func loadAPIKeyConfig(config *Config) error {
+	cacheSeconds, err := strconv.Atoi(getEnv("API_KEY_CACHE_SECONDS", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_CACHE_SECONDS: %v", err)
+	}
+
+	cacheSize, err := strconv.Atoi(getEnv("API_KEY_CACHE_SIZE", "10000"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_CACHE_SIZE: %v", err)
+	}
+
+	graceHours, err := strconv.Atoi(getEnv("API_KEY_ROTATION_GRACE_HOURS", "24"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_ROTATION_GRACE_HOURS: %v", err)
+	}
+
+	noticeHours, err := strconv.Atoi(getEnv("API_KEY_ROTATION_NOTICE_HOURS", "2"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_ROTATION_NOTICE_HOURS: %v", err)
+	}
+
+	expiryNoticeDays, err := strconv.Atoi(getEnv("API_KEY_EXPIRY_NOTICE_DAYS", "7"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_EXPIRY_NOTICE_DAYS: %v", err)
+	}
+
+	dormantDays, err := strconv.Atoi(getEnv("API_KEY_DORMANT_DAYS", "90"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_DORMANT_DAYS: %v", err)
+	}
+
+	retentionDays, err := strconv.Atoi(getEnv("API_KEY_RETENTION_DAYS", "30"))
+	if err != nil {
+		return fmt.Errorf("invalid API_KEY_RETENTION_DAYS: %v", err)
+	}
+
+	// Fall back to the signing secret so existing deployments do not need a
+	// new variable
+	pepper := getEnv("API_KEY_PEPPER", "")
+	if pepper == "" {
+		pepper = config.Auth.SigningSecret
+	}
+
+	config.APIKey = APIKeyConfig{
+		Pepper:                 pepper,
+		CacheSeconds:           cacheSeconds,
+		CacheDuration:          time.Duration(cacheSeconds) * time.Second,
+		CacheSize:              cacheSize,
+		RotationGraceHours:     graceHours,
+		RotationGraceDuration:  time.Duration(graceHours) * time.Hour,
+		RotationNoticeHours:    noticeHours,
+		RotationNoticeDuration: time.Duration(noticeHours) * time.Hour,
+		ExpiryNoticeDays:       expiryNoticeDays,
+		ExpiryNoticeDuration:   time.Duration(expiryNoticeDays) * 24 * time.Hour,
+		DormantDays:            dormantDays,
+		DormantDuration:        time.Duration(dormantDays) * 24 * time.Hour,
+		RetentionDays:          retentionDays,
+		RetentionDuration:      time.Duration(retentionDays) * 24 * time.Hour,
+	}
+	return nil
+}
+
+func loadRateLimitConfig(config *Config) error {
+	enabled, err := strconv.ParseBool(getEnv("RATE_LIMIT_ENABLED", "true"))
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_ENABLED: %v", err)
+	}
+
+	requests, err := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "100"))
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_REQUESTS: %v", err)
+	}
+
+	burst, err := strconv.Atoi(getEnv("RATE_LIMIT_BURST", "50"))
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_BURST: %v", err)
+	}
+
+	windowSeconds, err := strconv.Atoi(getEnv("RATE_LIMIT_WINDOW_SECONDS", "1"))
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_WINDOW_SECONDS: %v", err)
+	}
+
+	monthlyQuota, err := strconv.ParseInt(getEnv("RATE_LIMIT_MONTHLY_QUOTA", "0"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid RATE_LIMIT_MONTHLY_QUOTA: %v", err)
+	}
+
+	config.RateLimit = RateLimitConfig{
+		Enabled:       enabled,
+		Algorithm:     strings.ToLower(getEnv("RATE_LIMIT_ALGORITHM", "token_bucket")),
+		Requests:      requests,
+		Burst:         burst,
+		WindowSeconds: windowSeconds,
+		Window:        time.Duration(windowSeconds) * time.Second,
+		KeyBy:         strings.ToLower(getEnv("RATE_LIMIT_KEY_BY", RateLimitKeyAPIKey)),
+		MonthlyQuota:  monthlyQuota,
+		Store:         strings.ToLower(getEnv("RATE_LIMIT_STORE", RateLimitStoreMemory)),
+	}
+	return nil
+}
+
+// defaultReservedUsernames are names that could be mistaken for the service
+// itself or collide with routes
+const defaultReservedUsernames = "admin,administrator,root,system,support,help,security,abuse,postmaster,webmaster," +
+	"api,www,mail,billing,settings,account,login,logout,register,signup,me,null,undefined"
+
 func loadCORSConfig(config *Config) error {
 	// Parse allowed origins from environment variable (comma-separated)
 	originsStr := getEnv("CORS_ALLOW_ORIGINS", "http://localhost:3000,http://localhost:3001")
@@ -820,7 +1871,7 @@ func loadCORSConfig(config *Config) error {
 	}
 
 	// Parse exposed headers from environment variable (comma-separated)
-	exposeHeadersStr := getEnv("CORS_EXPOSE_HEADERS", "Content-Length")
+	exposeHeadersStr := getEnv("CORS_EXPOSE_HEADERS", "Content-Length,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After")
 	var exposeHeaders []string
 	if exposeHeadersStr != "" {
 		exposeHeaders = strings.Split(exposeHeadersStr, ",")
@@ -852,8 +1903,122 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("DB_PASSWORD is required")
 	}
 
-	if config.JWT.Secret == "" {
-		return fmt.Errorf("JWT_SECRET is required")
+	switch config.Auth.EmailVerificationPolicy {
+	case EmailVerificationNone, EmailVerificationRoutes, EmailVerificationLogin:
+	default:
+		return fmt.Errorf("unsupported AUTH_EMAIL_VERIFICATION_POLICY: %s", config.Auth.EmailVerificationPolicy)
+	}
+
+	switch config.Auth.LoginAttemptStore {
+	case LoginAttemptStoreMemory, LoginAttemptStoreDatabase:
+	default:
+		return fmt.Errorf("unsupported AUTH_LOGIN_ATTEMPT_STORE: %s", config.Auth.LoginAttemptStore)
+	}
+
+	if config.Auth.SigningSecret == "" {
+		return fmt.Errorf("AUTH_SIGNING_SECRET or APP_SECRET is required")
+	}
+
+	if config.Auth.PasswordlessEnabled && (config.Auth.PasswordlessExpireMinutes < 1 || config.Auth.PasswordlessRequestWindowMinutes < 1) {
+		return fmt.Errorf("AUTH_PASSWORDLESS_EXPIRE_MINUTES and AUTH_PASSWORDLESS_REQUEST_WINDOW_MINUTES must be positive")
+	}
+
+	// bcrypt ignores everything past 72 bytes; the request binding caps passwords at 128
+	maxPasswordLength := 128
+	switch config.Password.HashAlgorithm {
+	case "argon2id":
+		if config.Password.Argon2MemoryKB < 8*config.Password.Argon2Parallelism || config.Password.Argon2Iterations < 1 ||
+			config.Password.Argon2Parallelism < 1 || config.Password.Argon2Parallelism > 255 {
+			return fmt.Errorf("invalid PASSWORD_ARGON2_* parameters")
+		}
+	case "bcrypt":
+		if config.Password.BcryptCost < 4 || config.Password.BcryptCost > 31 {
+			return fmt.Errorf("PASSWORD_BCRYPT_COST must be between 4 and 31")
+		}
+		maxPasswordLength = 72
+	default:
+		return fmt.Errorf("unsupported PASSWORD_HASH_ALGORITHM: %s", config.Password.HashAlgorithm)
+	}
+	if config.Password.MinLength < 1 || config.Password.MaxLength > maxPasswordLength || config.Password.MaxLength < config.Password.MinLength {
+		return fmt.Errorf("PASSWORD_MIN_LENGTH must be at least 1 and PASSWORD_MAX_LENGTH between it and %d", maxPasswordLength)
+	}
+	if config.Password.MinCharClasses < 0 || config.Password.MinCharClasses > 4 {
+		return fmt.Errorf("PASSWORD_MIN_CHAR_CLASSES must be between 0 and 4")
+	}
+
+	if config.Privacy.ErasureGraceDays < 0 || config.Privacy.ExportExpireHours < 1 {
+		return fmt.Errorf("PRIVACY_ERASURE_GRACE_DAYS must not be negative and PRIVACY_EXPORT_EXPIRE_HOURS must be positive")
+	}
+
+	if config.Avatar.MaxSizeKB < 1 || config.Avatar.MaxDimension < 1 {
+		return fmt.Errorf("AVATAR_MAX_SIZE_KB and AVATAR_MAX_DIMENSION must be positive")
+	}
+	if len(config.Avatar.Sizes) == 0 {
+		return fmt.Errorf("AVATAR_SIZES must list at least one size")
+	}
+	for _, size := range config.Avatar.Sizes {
+		if size < 16 || size > 2048 {
+			return fmt.Errorf("AVATAR_SIZES must be between 16 and 2048 pixels")
+		}
+	}
+	if config.Avatar.JPEGQuality < 1 || config.Avatar.JPEGQuality > 100 {
+		return fmt.Errorf("AVATAR_JPEG_QUALITY must be between 1 and 100")
+	}
+
+	// Usernames are stored in a 50 character column
+	if config.Username.MinLength < 1 || config.Username.MaxLength > 50 || config.Username.MaxLength < config.Username.MinLength {
+		return fmt.Errorf("USERNAME_MIN_LENGTH must be at least 1 and USERNAME_MAX_LENGTH between it and 50")
+	}
+	if config.Username.ChangeLimit < 0 || config.Username.ChangeWindowDays < 0 || config.Username.RedirectDays < 0 {
+		return fmt.Errorf("USERNAME_CHANGE_LIMIT, USERNAME_CHANGE_WINDOW_DAYS and USERNAME_REDIRECT_DAYS must not be negative")
+	}
+
+	if config.APIKey.Pepper == "" {
+		return fmt.Errorf("API_KEY_PEPPER or AUTH_SIGNING_SECRET is required")
+	}
+	if config.APIKey.CacheSeconds < 0 || config.APIKey.CacheSize < 0 {
+		return fmt.Errorf("API_KEY_CACHE_SECONDS and API_KEY_CACHE_SIZE must not be negative")
+	}
+	if config.APIKey.RotationGraceHours < 0 || config.APIKey.RotationNoticeHours < 0 {
+		return fmt.Errorf("API_KEY_ROTATION_GRACE_HOURS and API_KEY_ROTATION_NOTICE_HOURS must not be negative")
+	}
+	if config.APIKey.ExpiryNoticeDays < 0 || config.APIKey.DormantDays < 0 || config.APIKey.RetentionDays < 0 {
+		return fmt.Errorf("API_KEY_EXPIRY_NOTICE_DAYS, API_KEY_DORMANT_DAYS and API_KEY_RETENTION_DAYS must not be negative")
+	}
+
+	switch config.RateLimit.Algorithm {
+	case "token_bucket", "sliding_window":
+	default:
+		return fmt.Errorf("unsupported RATE_LIMIT_ALGORITHM: %s", config.RateLimit.Algorithm)
+	}
+	switch config.RateLimit.KeyBy {
+	case RateLimitKeyAPIKey, RateLimitKeyUser, RateLimitKeyOrganization, RateLimitKeyIP:
+	default:
+		return fmt.Errorf("unsupported RATE_LIMIT_KEY_BY: %s", config.RateLimit.KeyBy)
+	}
+	switch config.RateLimit.Store {
+	case RateLimitStoreMemory, RateLimitStoreDatabase:
+	default:
+		return fmt.Errorf("unsupported RATE_LIMIT_STORE: %s", config.RateLimit.Store)
+	}
+	if config.RateLimit.Requests < 0 || config.RateLimit.Burst < 0 || config.RateLimit.MonthlyQuota < 0 || config.RateLimit.WindowSeconds < 1 {
+		return fmt.Errorf("RATE_LIMIT_REQUESTS, RATE_LIMIT_BURST and RATE_LIMIT_MONTHLY_QUOTA must not be negative and RATE_LIMIT_WINDOW_SECONDS must be positive")
+	}
+
+	for _, p := range config.OIDC.Providers {
+		if p.IssuerURL == "" || p.ClientID == "" {
+			return fmt.Errorf("OIDC provider %s requires an issuer URL and client ID", p.Name)
+		}
+	}
+
+	switch config.JWT.Algorithm {
+	case "HS256":
+		if config.JWT.Secret == "" {
+			return fmt.Errorf("JWT_SECRET is required")
+		}
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return fmt.Errorf("unsupported JWT_ALGORITHM: %s", config.JWT.Algorithm)
 	}
 
 	return nil
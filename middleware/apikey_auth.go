@@ -1,105 +1,151 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
 )
 
-// APIKeyAuth is a middleware for API key authentication
-func APIKeyAuth(apiKeyService apikey.Service) gin.HandlerFunc {
-	return func(c *gin.Context) {
+// ErrInvalidAPIKey is returned when an API key is present but not valid
+var ErrInvalidAPIKey = errors.New("invalid API key")
+
+// APIKeyAuthenticator authenticates requests carrying an API key in the
+// X-API-Key header or the api_key query parameter. Keys restricted to other
+// client IPs or origins, or to the header, fail with an error wrapping
+// auth.ErrRestricted.
+func APIKeyAuthenticator(apiKeyService apikey.Service) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(c *gin.Context) (*auth.Principal, error) {
 		// Check for API key in header
 		apiKeyHeader := c.GetHeader("X-API-Key")
-		
+
 		// If no API key in header, check for it in query parameters
+		fromQuery := false
 		if apiKeyHeader == "" {
 			apiKeyHeader = c.Query("api_key")
+			fromQuery = true
 		}
-		
-		// If still no API key, return error
 		if apiKeyHeader == "" {
+			return nil, auth.ErrNoCredentials
+		}
+
+		apiKeyObj, err := apiKeyService.ValidateAPIKey(apiKeyHeader)
+		if err != nil {
+			return nil, ErrInvalidAPIKey
+		}
+		err = apiKeyObj.Permits(apikey.RequestInfo{
+			ClientIP:  c.ClientIP(),
+			Origin:    c.GetHeader("Origin"),
+			Referer:   c.GetHeader("Referer"),
+			FromQuery: fromQuery,
+		})
+		if err != nil {
+			return nil, auth.Restricted(err)
+		}
+		// Personal keys stop working with their owner's account, organization
+		// keys with their organization or service account, and the latter
+		// are bounded by the current role permissions
+		permissions, err := apiKeyService.ResolvePermissions(c.Request.Context(), apiKeyObj)
+		if err != nil {
+			return nil, ErrInvalidAPIKey
+		}
+
+		principal := &auth.Principal{
+			UserID:      apiKeyObj.UserID,
+			Method:      auth.MethodAPIKey,
+			APIKeyID:    apiKeyObj.ID,
+			Permissions: permissions,
+			Environment: apiKeyObj.Environment,
+			RateLimit:   apiKeyObj.RateLimitOverride(),
+		}
+		if apiKeyObj.OrganizationID != nil {
+			principal.OrganizationID = *apiKeyObj.OrganizationID
+		}
+		if apiKeyObj.ServiceAccountID != nil {
+			principal.ServiceAccountID = *apiKeyObj.ServiceAccountID
+		}
+		return principal, nil
+	})
+}
+
+// APIKeyAuth is a middleware for API key authentication
+func APIKeyAuth(apiKeyService apikey.Service) gin.HandlerFunc {
+	authenticator := APIKeyAuthenticator(apiKeyService)
+	return func(c *gin.Context) {
+		principal, err := auth.Authenticate(c, authenticator)
+		if errors.Is(err, auth.ErrRestricted) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  err.Error(),
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			msg := "Invalid API key"
+			if errors.Is(err, auth.ErrNoCredentials) {
+				msg = "API key is required"
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 401,
-				"msg":  "API key is required",
+				"msg":  msg,
 			})
 			c.Abort()
 			return
 		}
-		
-		// Validate API key
-		apiKeyObj, err := apiKeyService.ValidateAPIKey(apiKeyHeader)
-		if err != nil {
+		auth.SetPrincipal(c, principal)
+
+		c.Next()
+	}
+}
+
+// RequireScopes requires API key principals to hold a scope covering each
+// of the given templates, e.g. "organizations:read" or
+// "org:{id}/organizations:{id}:write" where {id} is filled from the path
+// parameter. It must be mounted after the authentication middleware;
+// principals that did not authenticate with an API key are not limited.
+// Templates are parsed when the route is declared and must name a resource
+// and action registered in scope.Default.
+func RequireScopes(templates ...string) gin.HandlerFunc {
+	required := make([]scope.Template, len(templates))
+	for i, raw := range templates {
+		t := scope.MustParseTemplate(raw)
+		if !scope.Default.Known(t.Resource(), t.Action()) {
+			panic(fmt.Sprintf("scope %q is not registered", raw))
+		}
+		required[i] = t
+	}
+
+	return func(c *gin.Context) {
+		principal, ok := auth.FromContext(c)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 401,
-				"msg":  "Invalid API key",
+				"msg":  "Authentication required",
 			})
 			c.Abort()
 			return
 		}
-		
-		// Store user ID and API key ID in context
-		c.Set("userID", apiKeyObj.UserID)
-		c.Set("apiKeyID", apiKeyObj.ID)
-		
-		// If specific permissions are required, check them
-		if requiredPerms, exists := c.Get("requiredPermissions"); exists {
-			if !hasPermissions(apiKeyObj.Permissions, requiredPerms.([]string)) {
+		if !principal.IsAPIKey() {
+			c.Next()
+			return
+		}
+
+		for _, t := range required {
+			if !scope.Allows(principal.Permissions, t.Resolve(c.Param)) {
 				c.JSON(http.StatusForbidden, gin.H{
 					"code": 403,
-					"msg":  "API key does not have required permissions",
+					"msg":  "API key does not have required scope " + t.String(),
 				})
 				c.Abort()
 				return
 			}
 		}
-		
-		c.Next()
-	}
-}
 
-// RequirePermissions is a middleware for requiring specific permissions
-func RequirePermissions(permissions ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Set("requiredPermissions", permissions)
 		c.Next()
 	}
 }
-
-// hasPermissions checks if the API key has the required permissions
-func hasPermissions(apiKeyPerms string, requiredPerms []string) bool {
-	// If no permissions are required, allow access
-	if len(requiredPerms) == 0 {
-		return true
-	}
-	
-	// If API key has no permissions, deny access
-	if apiKeyPerms == "" {
-		return false
-	}
-	
-	// Split API key permissions
-	perms := strings.Split(apiKeyPerms, ",")
-	
-	// Check if API key has all required permissions
-	permMap := make(map[string]bool)
-	for _, p := range perms {
-		permMap[strings.TrimSpace(p)] = true
-	}
-	
-	// Check for wildcard permission
-	if permMap["*"] {
-		return true
-	}
-	
-	// Check for each required permission
-	for _, required := range requiredPerms {
-		if !permMap[required] {
-			return false
-		}
-	}
-	
-	return true
-}
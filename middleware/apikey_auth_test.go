@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/hasher"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
+	"gorm.io/gorm"
+)
+
+// userKeys stores personal keys in memory and tracks which owners are
+// disabled
+type userKeys struct {
+	apikey.Repository
+
+	mu       sync.Mutex
+	keys     []*apikey.APIKey
+	disabled map[uint]bool
+}
+
+func (r *userKeys) Create(apiKey *apikey.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	apiKey.ID = uint(len(r.keys) + 1)
+	copied := *apiKey
+	r.keys = append(r.keys, &copied)
+	return nil
+}
+
+func (r *userKeys) FindByLookupID(lookupID string) (*apikey.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range r.keys {
+		if k.LookupID != nil && *k.LookupID == lookupID {
+			copied := *k
+			return &copied, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *userKeys) UpdateLastUsed(uint, time.Time) error { return nil }
+
+func (r *userKeys) UserActive(userID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.disabled[userID], nil
+}
+
+func TestAPIKeyAuth_RejectsKeysOfDisabledUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &userKeys{disabled: make(map[uint]bool)}
+	cfg := config.APIKeyConfig{Pepper: "test-pepper", CacheDuration: time.Minute, CacheSize: 10}
+	svc := apikey.NewAPIKeyService(repo, hasher.NewBcrypt(4), scope.Default, nil, cfg)
+	secret, _, err := svc.GenerateAPIKey(5, "cli", nil, nil, "")
+	if err != nil {
+		t.Fatalf("GenerateAPIKey failed: %v", err)
+	}
+
+	r := gin.New()
+	r.GET("/", APIKeyAuth(svc), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", secret)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request(); code != http.StatusOK {
+		t.Fatalf("Expected 200 for an active owner, got %d", code)
+	}
+	// The key is cached by now and must still be rejected
+	repo.mu.Lock()
+	repo.disabled[5] = true
+	repo.mu.Unlock()
+	if code := request(); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 once the owner is disabled, got %d", code)
+	}
+}
@@ -1,88 +0,0 @@
-package middleware
-
-import (
-	"net/http"
-	"strings"
-	"time"
-
-	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"github.com/llamacto/llama-gin-kit/config"
-	"github.com/llamacto/llama-gin-kit/pkg/logger"
-)
-
-type Claims struct {
-	UserID uint `json:"user_id"`
-	jwt.RegisteredClaims
-}
-
-// JWT authentication middleware
-func JWT() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "Authorization header is required",
-			})
-			c.Abort()
-			return
-		}
-
-		// Check Bearer token
-		parts := strings.SplitN(authHeader, " ", 2)
-		if !(len(parts) == 2 && parts[0] == "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "Invalid authorization format",
-			})
-			c.Abort()
-			return
-		}
-
-		// Parse token
-		token, err := jwt.ParseWithClaims(parts[1], &Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(config.GlobalConfig.App.JWTSecret), nil
-		})
-
-		if err != nil {
-			logger.Error("Failed to parse token", err)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "Invalid token",
-			})
-			c.Abort()
-			return
-		}
-
-		// Validate token
-		claims, ok := token.Claims.(*Claims)
-		if !ok || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "Invalid token",
-			})
-			c.Abort()
-			return
-		}
-
-		// Store user information in context
-		c.Set("userID", claims.UserID)
-		c.Next()
-	}
-}
-
-// GenerateToken 生成 JWT token
-func GenerateToken(userID uint) (string, error) {
-	claims := Claims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(config.GlobalConfig.App.JWTExpire) * time.Second)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.GlobalConfig.App.JWTSecret))
-}
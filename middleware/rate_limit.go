@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
+)
+
+// RateLimit enforces the configured rate limit and monthly quota and sets
+// the RateLimit-* response headers. Mount it after the authentication
+// middleware so requests count against the principal; requests without one
+// count against the client IP. The limits stored on an API key replace the
+// defaults when requests are counted per key. If the counters cannot be
+// reached the request is let through.
+func RateLimit(limiter *ratelimit.Limiter, cfg config.RateLimitConfig) gin.HandlerFunc {
+	defaults := ratelimit.Policy{
+		Requests:     cfg.Requests,
+		Window:       cfg.Window,
+		Burst:        cfg.Burst,
+		MonthlyQuota: cfg.MonthlyQuota,
+	}
+	return func(c *gin.Context) {
+		if !cfg.Enabled {
+			c.Next()
+			return
+		}
+
+		subject := rateLimitSubject(c, cfg.KeyBy)
+		policy := defaults
+		if principal, ok := auth.FromContext(c); ok && principal.IsAPIKey() && subject == apiKeySubject(principal) {
+			policy = policy.With(principal.RateLimit)
+		}
+
+		ctx := c.Request.Context()
+		var results []ratelimit.Result
+		if policy.Requests > 0 {
+			result, err := limiter.Allow(ctx, subject, policy)
+			if err != nil {
+				logger.Error("Failed to check rate limit", err)
+				c.Next()
+				return
+			}
+			results = append(results, result)
+		}
+		// Requests over the rate limit do not use up the quota
+		if policy.MonthlyQuota > 0 && (len(results) == 0 || results[0].Allowed) {
+			result, err := limiter.AllowQuota(ctx, "quota:"+subject, policy.MonthlyQuota)
+			if err != nil {
+				logger.Error("Failed to check monthly quota", err)
+				c.Next()
+				return
+			}
+			results = append(results, result)
+		}
+		ratelimit.WriteHeaders(c.Writer.Header(), results...)
+
+		for i, result := range results {
+			if result.Allowed {
+				continue
+			}
+			msg := "Rate limit exceeded"
+			if i > 0 || policy.Requests <= 0 {
+				msg = "Monthly request quota exceeded"
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": 429,
+				"msg":  msg,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitSubject returns the counter key of the request for the keyBy
+// setting. Principals without the requested subject fall back to their
+// user, organization or API key, and anonymous requests to the client IP,
+// which is the connection address unless a trusted proxy forwarded the
+// request (see routes.NewEngine).
+func rateLimitSubject(c *gin.Context, keyBy string) string {
+	principal, ok := auth.FromContext(c)
+	if !ok || keyBy == config.RateLimitKeyIP {
+		return "ip:" + c.ClientIP()
+	}
+
+	var order []string
+	switch keyBy {
+	case config.RateLimitKeyUser:
+		order = []string{config.RateLimitKeyUser, config.RateLimitKeyOrganization, config.RateLimitKeyAPIKey}
+	case config.RateLimitKeyOrganization:
+		order = []string{config.RateLimitKeyOrganization, config.RateLimitKeyUser, config.RateLimitKeyAPIKey}
+	default:
+		order = []string{config.RateLimitKeyAPIKey, config.RateLimitKeyUser}
+	}
+	for _, kind := range order {
+		switch {
+		case kind == config.RateLimitKeyAPIKey && principal.IsAPIKey():
+			return apiKeySubject(principal)
+		case kind == config.RateLimitKeyUser && principal.UserID != 0:
+			return "user:" + strconv.FormatUint(uint64(principal.UserID), 10)
+		case kind == config.RateLimitKeyOrganization && principal.OrganizationID != 0:
+			return "org:" + strconv.FormatUint(uint64(principal.OrganizationID), 10)
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func apiKeySubject(principal *auth.Principal) string {
+	return "key:" + strconv.FormatUint(uint64(principal.APIKeyID), 10)
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+)
+
+// SystemRoleChecker reports whether a user holds one of the given system roles
+type SystemRoleChecker interface {
+	HasSystemRole(ctx context.Context, userID uint, roles ...string) (bool, error)
+}
+
+// RequireSystemRole only lets users holding one of the roles through. It
+// must run after an authentication middleware; API keys and impersonation
+// tokens are always refused so that administration stays tied to an
+// administrator's own interactive login.
+func RequireSystemRole(checker SystemRoleChecker, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.FromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": auth.ErrNoCredentials.Error()})
+			c.Abort()
+			return
+		}
+		if principal.IsAPIKey() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API keys cannot access this resource"})
+			c.Abort()
+			return
+		}
+		if principal.IsImpersonated() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Impersonation tokens cannot access this resource"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := checker.HasSystemRole(c.Request.Context(), principal.UserID, roles...)
+		if err != nil {
+			logger.Error("Failed to check system role", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
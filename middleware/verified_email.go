@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+)
+
+// EmailVerificationChecker reports whether a user's email address is verified
+type EmailVerificationChecker interface {
+	IsEmailVerified(ctx context.Context, userID uint) (bool, error)
+}
+
+// RequireVerifiedEmail rejects users whose email address is not verified.
+// It must run after an authentication middleware and does nothing when the
+// policy is config.EmailVerificationNone. API keys are not checked; their
+// owners already had to pass this guard to create them.
+func RequireVerifiedEmail(checker EmailVerificationChecker, policy string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if policy == "" || policy == config.EmailVerificationNone {
+			c.Next()
+			return
+		}
+
+		principal, ok := auth.FromContext(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": auth.ErrNoCredentials.Error()})
+			c.Abort()
+			return
+		}
+		if principal.IsAPIKey() {
+			c.Next()
+			return
+		}
+
+		verified, err := checker.IsEmailVerified(c.Request.Context(), principal.UserID)
+		if err != nil {
+			logger.Error("Failed to check email verification", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check email verification"})
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
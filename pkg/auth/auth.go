@@ -0,0 +1,173 @@
+// Package auth is the single authentication pipeline for the API. Each
+// credential type (JWT bearer token, session cookie, API key) is an
+// Authenticator; Middleware runs them in order and stores the resulting
+// Principal in the gin context for handlers to read with FromContext.
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
+)
+
+// Method identifies how a request was authenticated.
+type Method string
+
+const (
+	MethodJWT     Method = "jwt"
+	MethodSession Method = "session"
+	MethodAPIKey  Method = "api_key"
+)
+
+// principalKey is the gin context key holding the *Principal.
+const principalKey = "auth.principal"
+
+// ErrNoCredentials is returned by an Authenticator when the request does not
+// carry its kind of credential, so the next authenticator should be tried.
+var ErrNoCredentials = errors.New("authorization information not provided")
+
+// ErrRestricted is wrapped by Authenticator errors for valid credentials
+// that may not be used for the request, e.g. an API key sent from an IP
+// address outside its allowlist. Middleware answers them with 403.
+var ErrRestricted = errors.New("credentials not allowed for this request")
+
+// Restricted wraps err so that it also matches ErrRestricted, keeping the
+// message of err.
+func Restricted(err error) error {
+	return restrictedError{err: err}
+}
+
+type restrictedError struct {
+	err error
+}
+
+func (e restrictedError) Error() string {
+	return e.err.Error()
+}
+
+func (e restrictedError) Unwrap() []error {
+	return []error{ErrRestricted, e.err}
+}
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID   uint
+	Username string
+	Method   Method
+
+	// TokenID is the jti of the access token for JWT and session requests,
+	// and SessionID the login session the token was issued for, if any.
+	TokenID   string
+	SessionID string
+
+	// ImpersonatorID is the administrator acting as the user, if any.
+	ImpersonatorID uint
+
+	// APIKeyID and Permissions are set for API key requests. Permissions is
+	// nil for user credentials, which are not restricted by key scopes; see
+	// pkg/scope for the scope syntax.
+	APIKeyID    uint
+	Permissions []string
+
+	// Environment is "live" or "test" for API key requests, so handlers can
+	// keep test traffic away from live data.
+	Environment string
+
+	// OrganizationID is set for organization-owned API keys, and
+	// ServiceAccountID when the key belongs to a service account. Such keys
+	// act for the organization: UserID is 0.
+	OrganizationID   uint
+	ServiceAccountID uint
+
+	// RateLimit holds the limits of an API key that differ from the
+	// configured defaults, if any.
+	RateLimit *ratelimit.Override
+}
+
+// IsImpersonated reports whether an administrator is acting as the user.
+func (p *Principal) IsImpersonated() bool {
+	return p.ImpersonatorID != 0
+}
+
+// IsAPIKey reports whether the request was authenticated with an API key.
+func (p *Principal) IsAPIKey() bool {
+	return p.Method == MethodAPIKey
+}
+
+// Authenticator extracts and verifies one kind of credential. It returns
+// ErrNoCredentials when the request does not carry that credential; any
+// other error rejects the request.
+type Authenticator interface {
+	Authenticate(c *gin.Context) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(c *gin.Context) (*Principal, error)
+
+// Authenticate calls f(c).
+func (f AuthenticatorFunc) Authenticate(c *gin.Context) (*Principal, error) {
+	return f(c)
+}
+
+// Middleware authenticates the request with the first authenticator that
+// finds credentials and aborts with 401 if none does, or 403 if the
+// credentials are restricted.
+func Middleware(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := Authenticate(c, authenticators...)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if errors.Is(err, ErrRestricted) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		SetPrincipal(c, principal)
+		c.Next()
+	}
+}
+
+// Authenticate runs the authenticators in order without touching the response.
+func Authenticate(c *gin.Context, authenticators ...Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		principal, err := a.Authenticate(c)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return principal, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// SetPrincipal stores the principal in the gin context.
+func SetPrincipal(c *gin.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// FromContext returns the principal of an authenticated request.
+func FromContext(c *gin.Context) (*Principal, bool) {
+	v, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	principal, ok := v.(*Principal)
+	return principal, ok && principal != nil
+}
+
+// UserID returns the authenticated user's ID. It reports false for
+// organization API keys, which do not act as a user.
+func UserID(c *gin.Context) (uint, bool) {
+	principal, ok := FromContext(c)
+	if !ok || principal.UserID == 0 {
+		return 0, false
+	}
+	return principal.UserID, true
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func runMiddleware(authenticators ...Authenticator) (*httptest.ResponseRecorder, *Principal) {
+	gin.SetMode(gin.TestMode)
+	var got *Principal
+	r := gin.New()
+	r.GET("/", Middleware(authenticators...), func(c *gin.Context) {
+		got, _ = FromContext(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return w, got
+}
+
+func TestMiddleware_FirstAuthenticatorWithCredentialsWins(t *testing.T) {
+	skip := AuthenticatorFunc(func(c *gin.Context) (*Principal, error) { return nil, ErrNoCredentials })
+	user := AuthenticatorFunc(func(c *gin.Context) (*Principal, error) {
+		return &Principal{UserID: 42, Method: MethodJWT}, nil
+	})
+
+	w, principal := runMiddleware(skip, user)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+	if principal == nil || principal.UserID != 42 || principal.Method != MethodJWT {
+		t.Errorf("Unexpected principal: %+v", principal)
+	}
+}
+
+func TestMiddleware_InvalidCredentialsDoNotFallThrough(t *testing.T) {
+	invalid := AuthenticatorFunc(func(c *gin.Context) (*Principal, error) { return nil, errors.New("invalid API key") })
+	user := AuthenticatorFunc(func(c *gin.Context) (*Principal, error) { return &Principal{UserID: 1}, nil })
+
+	w, principal := runMiddleware(invalid, user)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", w.Code)
+	}
+	if principal != nil {
+		t.Errorf("Handler should not run, got principal %+v", principal)
+	}
+}
+
+func TestMiddleware_NoCredentials(t *testing.T) {
+	w, _ := runMiddleware(JWT())
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", w.Code)
+	}
+}
+
+func TestMiddleware_RestrictedCredentials(t *testing.T) {
+	cause := errors.New("client IP 10.0.0.1 is not allowed")
+	restricted := AuthenticatorFunc(func(c *gin.Context) (*Principal, error) { return nil, Restricted(cause) })
+
+	w, principal := runMiddleware(restricted)
+	if w.Code != http.StatusForbidden || principal != nil {
+		t.Fatalf("Expected 403 without running the handler, got %d", w.Code)
+	}
+	if err := Restricted(cause); !errors.Is(err, ErrRestricted) || !errors.Is(err, cause) || err.Error() != cause.Error() {
+		t.Errorf("Unexpected restricted error %v", err)
+	}
+}
+
+// sessionRequest sends a request with a session cookie that is not a valid
+// token, so requests passing the CSRF check fail with 401 and those
+// failing it with 403
+func sessionRequest(method, csrfCookie, csrfHeader, fetchSite string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, "/", Middleware(JWT(), Session("")), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(method, "/", nil)
+	req.AddCookie(&http.Cookie{Name: DefaultSessionCookie, Value: "not-a-token"})
+	if csrfCookie != "" {
+		req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: csrfCookie})
+	}
+	if csrfHeader != "" {
+		req.Header.Set(CSRFHeader, csrfHeader)
+	}
+	if fetchSite != "" {
+		req.Header.Set("Sec-Fetch-Site", fetchSite)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestSession_RequiresCSRFTokenForStateChanges(t *testing.T) {
+	cases := []struct {
+		name                 string
+		method               string
+		cookie, header, site string
+		want                 int
+	}{
+		{"safe method", http.MethodGet, "", "", "cross-site", http.StatusUnauthorized},
+		{"no token", http.MethodPost, "", "", "", http.StatusForbidden},
+		{"cookie only", http.MethodDelete, "abc", "", "same-origin", http.StatusForbidden},
+		{"mismatch", http.MethodPut, "abc", "abd", "same-origin", http.StatusForbidden},
+		{"cross-site", http.MethodPost, "abc", "abc", "cross-site", http.StatusForbidden},
+		{"matching token", http.MethodPost, "abc", "abc", "same-origin", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if code := sessionRequest(tc.method, tc.cookie, tc.header, tc.site); code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, code)
+		}
+	}
+}
+
+func TestSetSessionCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/login", nil)
+
+	if err := SetSessionCookie(c, "", "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("SetSessionCookie failed: %v", err)
+	}
+	cookies := map[string]*http.Cookie{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	session, csrf := cookies[DefaultSessionCookie], cookies[CSRFCookie]
+	if session == nil || session.Value != "token" || !session.HttpOnly || !session.Secure || session.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Unexpected session cookie %+v", session)
+	}
+	if csrf == nil || len(csrf.Value) != 64 || csrf.HttpOnly || !csrf.Secure || csrf.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Unexpected CSRF cookie %+v", csrf)
+	}
+	if header := strings.Join(w.Header().Values("Set-Cookie"), "; "); !strings.Contains(header, "SameSite=Lax") {
+		t.Errorf("Expected SameSite=Lax, got %s", header)
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
+)
+
+const (
+	// DefaultSessionCookie is the cookie read by Session() when no name is given.
+	DefaultSessionCookie = "session"
+	// CSRFCookie holds the token that browser clients send back in the
+	// CSRFHeader on state-changing requests made with the session cookie.
+	CSRFCookie = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+var (
+	// ErrInvalidAuthorization is returned for a malformed Authorization header.
+	ErrInvalidAuthorization = errors.New("invalid authorization format")
+	// ErrInvalidToken is returned when the access token fails verification.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrCSRF is returned for cross-site requests and requests without a
+	// valid CSRF token made with the session cookie.
+	ErrCSRF = errors.New("missing or invalid CSRF token")
+)
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(c *gin.Context) (string, error) {
+	header := c.GetHeader("Authorization")
+	if header == "" {
+		return "", ErrNoCredentials
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || parts[0] != "Bearer" || parts[1] == "" {
+		return "", ErrInvalidAuthorization
+	}
+	return parts[1], nil
+}
+
+// JWT authenticates bearer access tokens issued by the global jwt service.
+func JWT() Authenticator {
+	return AuthenticatorFunc(func(c *gin.Context) (*Principal, error) {
+		tokenString, err := BearerToken(c)
+		if err != nil {
+			return nil, err
+		}
+		return principalFromToken(c, tokenString, MethodJWT)
+	})
+}
+
+// Session authenticates browser requests that carry the access token in a
+// cookie instead of the Authorization header. Browsers send the cookie with
+// requests made by other sites too, so requests other than GET, HEAD and
+// OPTIONS must not be cross-site and must echo the CSRF cookie in the
+// X-CSRF-Token header; see SetSessionCookie.
+func Session(cookieName string) Authenticator {
+	if cookieName == "" {
+		cookieName = DefaultSessionCookie
+	}
+	return AuthenticatorFunc(func(c *gin.Context) (*Principal, error) {
+		tokenString, err := c.Cookie(cookieName)
+		if err != nil || tokenString == "" {
+			return nil, ErrNoCredentials
+		}
+		if err := checkCSRF(c); err != nil {
+			return nil, Restricted(err)
+		}
+		return principalFromToken(c, tokenString, MethodSession)
+	})
+}
+
+// checkCSRF verifies state-changing requests: the browser must not report
+// them as cross-site, and the X-CSRF-Token header must match the CSRF cookie,
+// which other sites can neither read nor set.
+func checkCSRF(c *gin.Context) error {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+	if c.GetHeader("Sec-Fetch-Site") == "cross-site" {
+		return ErrCSRF
+	}
+	cookie, err := c.Cookie(CSRFCookie)
+	header := c.GetHeader(CSRFHeader)
+	if err != nil || cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) != 1 {
+		return ErrCSRF
+	}
+	return nil
+}
+
+// SetSessionCookie stores the access token in the session cookie for
+// browser clients, with a new CSRF token in CSRFCookie for scripts to send
+// back in the X-CSRF-Token header. The session cookie is HttpOnly; both
+// are Secure and SameSite=Lax and expire with the token.
+func SetSessionCookie(c *gin.Context, cookieName, accessToken string, expiresAt time.Time) error {
+	if cookieName == "" {
+		cookieName = DefaultSessionCookie
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	maxAge := int(time.Until(expiresAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieName, accessToken, maxAge, "/", "", true, true)
+	c.SetCookie(CSRFCookie, hex.EncodeToString(b), maxAge, "/", "", true, false)
+	return nil
+}
+
+// ClearSessionCookie removes the session and CSRF cookies.
+func ClearSessionCookie(c *gin.Context, cookieName string) {
+	if cookieName == "" {
+		cookieName = DefaultSessionCookie
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cookieName, "", -1, "/", "", true, true)
+	c.SetCookie(CSRFCookie, "", -1, "/", "", true, false)
+}
+
+func principalFromToken(c *gin.Context, tokenString string, method Method) (*Principal, error) {
+	claims, err := jwt.ParseTokenWithContext(c.Request.Context(), tokenString)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &Principal{
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		Method:    method,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+
+		ImpersonatorID: claims.ImpersonatorID,
+	}, nil
+}
@@ -5,13 +5,19 @@ import (
 
 	"log"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-gormigrate/gormigrate/v2"
 	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/app/authorization"
+	"github.com/llamacto/llama-gin-kit/app/identity"
 	"github.com/llamacto/llama-gin-kit/app/member"
+	"github.com/llamacto/llama-gin-kit/app/mfa"
 	"github.com/llamacto/llama-gin-kit/app/organization"
+	"github.com/llamacto/llama-gin-kit/app/privacy"
 	"github.com/llamacto/llama-gin-kit/app/team"
+	"github.com/llamacto/llama-gin-kit/app/token"
 	"github.com/llamacto/llama-gin-kit/app/user"
 	"github.com/llamacto/llama-gin-kit/config"
 	"gorm.io/driver/postgres"
@@ -45,6 +51,351 @@ func getMigrations() []*gormigrate.Migration {
 				)
 			},
 		},
+		{
+			ID: "20251016_create_refresh_tokens",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&token.RefreshToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&token.RefreshToken{})
+			},
+		},
+		{
+			ID: "20251016_create_token_revocations",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&token.RevokedToken{}, &token.UserTokenRevocation{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&token.UserTokenRevocation{}, &token.RevokedToken{})
+			},
+		},
+		{
+			ID: "20251016_create_password_reset_tokens",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&user.PasswordResetToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&user.PasswordResetToken{})
+			},
+		},
+		{
+			ID: "20251016_add_users_email_verified_at",
+			Migrate: func(tx *gorm.DB) error {
+				if !tx.Migrator().HasColumn(&user.User{}, "EmailVerifiedAt") {
+					if err := tx.Migrator().AddColumn(&user.User{}, "EmailVerifiedAt"); err != nil {
+						return err
+					}
+				}
+				// Accounts created before verification existed are treated as verified
+				return tx.Model(&user.User{}).Where("email_verified_at IS NULL").
+					UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&user.User{}, "EmailVerifiedAt")
+			},
+		},
+		{
+			ID: "20251016_create_system_roles",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(
+					&authorization.Role{},
+					&authorization.Permission{},
+					&authorization.RolePermission{},
+					&authorization.UserRole{},
+				); err != nil {
+					return err
+				}
+				admin := authorization.Role{
+					Name:        authorization.RoleAdmin,
+					DisplayName: "Administrator",
+					Description: "Full access to the administration endpoints",
+					Level:       100,
+					IsSystem:    true,
+					Status:      1,
+				}
+				return tx.Where(authorization.Role{Name: admin.Name}).FirstOrCreate(&admin).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(
+					&authorization.UserRole{},
+					&authorization.RolePermission{},
+					&authorization.Permission{},
+					&authorization.Role{},
+				)
+			},
+		},
+		{
+			ID: "20251016_create_mfa",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&mfa.TOTPFactor{}, &mfa.RecoveryCode{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&mfa.RecoveryCode{}, &mfa.TOTPFactor{})
+			},
+		},
+		{
+			ID: "20251016_create_login_attempts",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&user.LoginAttempt{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&user.LoginAttempt{})
+			},
+		},
+		{
+			ID: "20251016_create_user_identities",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&identity.Identity{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&identity.Identity{})
+			},
+		},
+		{
+			ID: "20251016_create_sessions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&token.Session{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&token.Session{})
+			},
+		},
+		{
+			ID: "20251016_create_admin_audit_logs",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&user.AdminAuditLog{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&user.AdminAuditLog{})
+			},
+		},
+		{
+			ID: "20251016_create_password_histories",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&user.PasswordHistory{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&user.PasswordHistory{})
+			},
+		},
+		{
+			ID: "20251016_widen_secret_hash_columns",
+			Migrate: func(tx *gorm.DB) error {
+				// Argon2id PHC hashes do not fit the columns sized for bcrypt
+				if err := tx.Migrator().AlterColumn(&user.User{}, "Password"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().AlterColumn(&user.PasswordHistory{}, "PasswordHash"); err != nil {
+					return err
+				}
+				return tx.Migrator().AlterColumn(&apikey.APIKey{}, "Key")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				// Stored hashes may no longer fit the old sizes, so the columns stay wide
+				return nil
+			},
+		},
+		{
+			ID: "20251016_create_login_codes",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&user.LoginCode{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&user.LoginCode{})
+			},
+		},
+		{
+			ID: "20251016_create_privacy_tables",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&privacy.DataExport{}, &privacy.ErasureRequest{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable(&privacy.ErasureRequest{}, &privacy.DataExport{})
+			},
+		},
+		{
+			ID: "20251016_add_users_avatar_images",
+			Migrate: func(tx *gorm.DB) error {
+				// Fresh databases already have the columns from the initial schema
+				for _, field := range []string{"AvatarURLs", "AvatarKeys"} {
+					if tx.Migrator().HasColumn(&user.User{}, field) {
+						continue
+					}
+					if err := tx.Migrator().AddColumn(&user.User{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropColumn(&user.User{}, "AvatarKeys"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&user.User{}, "AvatarURLs")
+			},
+		},
+		{
+			ID: "20251016_normalize_usernames_and_emails",
+			Migrate: func(tx *gorm.DB) error {
+				// Emails differing only in case cannot be merged automatically
+				var conflicts []string
+				if err := tx.Model(&user.User{}).Unscoped().
+					Select("LOWER(TRIM(email))").
+					Group("LOWER(TRIM(email))").
+					Having("COUNT(*) > 1").
+					Pluck("LOWER(TRIM(email))", &conflicts).Error; err != nil {
+					return err
+				}
+				if len(conflicts) > 0 {
+					return fmt.Errorf("users share email addresses differing only in case, resolve before migrating: %v", conflicts)
+				}
+				if err := tx.Exec("UPDATE users SET email = LOWER(TRIM(email)) WHERE email <> LOWER(TRIM(email))").Error; err != nil {
+					return err
+				}
+
+				// Later accounts sharing a username (ignoring case) get their ID appended
+				if err := tx.Exec(`UPDATE users u SET username = LEFT(LOWER(TRIM(u.username)), 40) || '_' || u.id
+					WHERE EXISTS (SELECT 1 FROM users o WHERE LOWER(TRIM(o.username)) = LOWER(TRIM(u.username)) AND o.id < u.id)`).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec("UPDATE users SET username = LOWER(TRIM(username)) WHERE username <> LOWER(TRIM(username))").Error; err != nil {
+					return err
+				}
+
+				if !tx.Migrator().HasIndex(&user.User{}, "Username") {
+					if err := tx.Migrator().CreateIndex(&user.User{}, "Username"); err != nil {
+						return err
+					}
+				}
+				return tx.AutoMigrate(&user.UsernameHistory{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable(&user.UsernameHistory{}); err != nil {
+					return err
+				}
+				return tx.Migrator().DropIndex(&user.User{}, "Username")
+			},
+		},
+		{
+			ID: "20251016_api_key_scopes_json",
+			Migrate: func(tx *gorm.DB) error {
+				// Fresh databases already have the jsonb column from the initial schema
+				columns, err := tx.Migrator().ColumnTypes(&apikey.APIKey{})
+				if err != nil {
+					return err
+				}
+				for _, column := range columns {
+					if column.Name() == "permissions" && strings.EqualFold(column.DatabaseTypeName(), "jsonb") {
+						return nil
+					}
+				}
+				// Keys without permissions were never restricted, so they keep
+				// full access; comma-separated values become a JSON array
+				return tx.Exec(`ALTER TABLE api_keys ALTER COLUMN permissions TYPE jsonb USING
+					CASE WHEN permissions IS NULL OR btrim(permissions) = '' THEN '["*"]'::jsonb
+					ELSE array_to_json(string_to_array(regexp_replace(permissions, '\s', '', 'g'), ','))::jsonb END`).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				// Scopes never contain brackets, quotes or spaces
+				return tx.Exec(`ALTER TABLE api_keys ALTER COLUMN permissions TYPE text USING
+					translate(permissions::text, '[]" ', '')`).Error
+			},
+		},
+		{
+			ID: "20251016_add_organization_api_keys",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&apikey.APIKey{}, &apikey.ServiceAccount{}); err != nil {
+					return err
+				}
+				// Existing keys were created by the users owning them
+				return tx.Unscoped().Model(&apikey.APIKey{}).
+					Where("created_by = 0 OR created_by IS NULL").
+					Update("created_by", gorm.Expr("user_id")).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				// Organization keys have no user to fall back to
+				if err := tx.Unscoped().Where("organization_id IS NOT NULL").Delete(&apikey.APIKey{}).Error; err != nil {
+					return err
+				}
+				for _, field := range []string{"CreatedBy", "RoleID", "ServiceAccountID", "OrganizationID"} {
+					if err := tx.Migrator().DropColumn(&apikey.APIKey{}, field); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropTable(&apikey.ServiceAccount{})
+			},
+		},
+		{
+			ID: "20251016_api_key_lookup",
+			Migrate: func(tx *gorm.DB) error {
+				// Existing keys keep their bcrypt hash and no lookup ID; they
+				// are found by prefix and rehashed with HMAC on first use
+				return tx.AutoMigrate(&apikey.APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				// HMAC digests cannot be verified without the lookup path
+				if err := tx.Unscoped().Where("key LIKE ?", "$hmac-sha256$%").Delete(&apikey.APIKey{}).Error; err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&apikey.APIKey{}, "LookupID")
+			},
+		},
+		{
+			ID: "20251016_add_api_key_rate_limits",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&apikey.APIKey{}, &apikey.RateLimitCounter{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, field := range []string{"MonthlyQuota", "RateLimitBurst", "RateLimit"} {
+					if err := tx.Migrator().DropColumn(&apikey.APIKey{}, field); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropTable(&apikey.RateLimitCounter{})
+			},
+		},
+		{
+			ID: "20251016_add_api_key_rotation",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&apikey.APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, field := range []string{"ExpiryNotifiedAt", "RotatedAt", "SuccessorID", "PredecessorID"} {
+					if err := tx.Migrator().DropColumn(&apikey.APIKey{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Existing keys are live keys without restrictions
+			ID: "20251016_add_api_key_restrictions",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&apikey.APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, field := range []string{"DisableQueryParam", "AllowedReferrers", "AllowedIPs", "Environment"} {
+					if err := tx.Migrator().DropColumn(&apikey.APIKey{}, field); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Expiry reminders and purges look keys up by expiry
+			ID: "20251016_add_api_key_dormancy",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&apikey.APIKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&apikey.APIKey{}, "ExpiresAt"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&apikey.APIKey{}, "DormantAt")
+			},
+		},
 	}
 }
 
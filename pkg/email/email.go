@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/llamacto/llama-gin-kit/config"
 	"github.com/llamacto/llama-gin-kit/pkg/logger"
@@ -171,16 +173,51 @@ func SendEmail(to []string, subject, htmlContent string) error {
 	return svc.SendEmail(to, subject, htmlContent)
 }
 
-// SendPasswordResetEmail sends a password reset notification email
-func SendPasswordResetEmail(to string, newPassword string) error {
-	subject := "Password Reset Notification"
+// SendPasswordResetEmail sends a link for choosing a new password
+func SendPasswordResetEmail(to string, resetLink string, expiresIn time.Duration) error {
+	subject := "Reset your password"
 	htmlContent := fmt.Sprintf(`
-		<h2>Password Reset Notification</h2>
-		<p>Your password has been reset. The new temporary password is:</p>
-		<p style="font-size: 18px; font-weight: bold; color: #333;">%s</p>
-		<p>Please use this temporary password to log in and change it to your own password immediately.</p>
-		<p>If this was not your action, please contact the administrator immediately.</p>
-	`, newPassword)
+		<h2>Reset your password</h2>
+		<p>We received a request to reset the password for your account.</p>
+		<p><a href="%s" style="font-size: 16px; font-weight: bold;">Choose a new password</a></p>
+		<p>This link can be used once and expires in %d minutes.</p>
+		<p>If you did not request a password reset, you can ignore this email; your password will not change.</p>
+	`, html.EscapeString(resetLink), int(expiresIn.Minutes()))
+
+	return SendEmail([]string{to}, subject, htmlContent)
+}
+
+// SendLoginEmail sends a passwordless sign-in link or one-time code. Either
+// loginLink or code may be empty.
+func SendLoginEmail(to string, loginLink string, code string, expiresIn time.Duration) error {
+	subject := "Your sign-in link"
+	body := fmt.Sprintf(`<p><a href="%s" style="font-size: 16px; font-weight: bold;">Sign in</a></p>`, html.EscapeString(loginLink))
+	if code != "" {
+		subject = "Your sign-in code"
+		body = fmt.Sprintf(`<p>Enter this code to sign in:</p>
+		<p style="font-size: 24px; font-weight: bold; letter-spacing: 4px;">%s</p>`, html.EscapeString(code))
+	}
+	htmlContent := fmt.Sprintf(`
+		<h2>Sign in to your account</h2>
+		%s
+		<p>This can be used once and expires in %d minutes.</p>
+		<p>If you did not try to sign in, you can ignore this email.</p>
+	`, body, int(expiresIn.Minutes()))
+
+	return SendEmail([]string{to}, subject, htmlContent)
+}
+
+// SendVerificationEmail sends a link for confirming the account's email address
+func SendVerificationEmail(to string, username string, verifyLink string, expiresIn time.Duration) error {
+	subject := "Verify your email address"
+	htmlContent := fmt.Sprintf(`
+		<h2>Verify your email address</h2>
+		<p>Dear %s,</p>
+		<p>Please confirm that this is your email address:</p>
+		<p><a href="%s" style="font-size: 16px; font-weight: bold;">Verify email address</a></p>
+		<p>This link expires in %d hours.</p>
+		<p>If you did not create an account, you can ignore this email.</p>
+	`, html.EscapeString(username), html.EscapeString(verifyLink), int(expiresIn.Hours()))
 
 	return SendEmail([]string{to}, subject, htmlContent)
 }
@@ -197,3 +234,51 @@ func SendWelcomeEmail(to string, username string) error {
 
 	return SendEmail([]string{to}, subject, htmlContent)
 }
+
+// SendAPIKeyRotationEmail reminds the owner of a rotated API key that it is
+// about to stop working and that clients must switch to its successor
+func SendAPIKeyRotationEmail(to string, keyName string, prefix string, expiresAt time.Time) error {
+	subject := "Your rotated API key expires soon"
+	htmlContent := fmt.Sprintf(`
+		<h2>Your rotated API key expires soon</h2>
+		<p>The API key <strong>%s</strong> (%s…) was rotated and stops working at %s.</p>
+		<p>Make sure every client uses its replacement before then; requests made with the old key will be rejected.</p>
+		<p>If you did not rotate this key, revoke it and its replacement and create a new key.</p>
+	`, html.EscapeString(keyName), html.EscapeString(prefix), expiresAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	return SendEmail([]string{to}, subject, htmlContent)
+}
+
+// SendAPIKeyExpiryEmail reminds the owner of an API key that it is about to
+// expire
+func SendAPIKeyExpiryEmail(to string, keyName string, prefix string, expiresAt time.Time) error {
+	subject := "Your API key expires soon"
+	htmlContent := fmt.Sprintf(`
+		<h2>Your API key expires soon</h2>
+		<p>The API key <strong>%s</strong> (%s…) expires at %s.</p>
+		<p>Rotate the key or create a new one and update your clients before then; requests made with an expired key will be rejected.</p>
+	`, html.EscapeString(keyName), html.EscapeString(prefix), expiresAt.UTC().Format("2006-01-02 15:04 MST"))
+
+	return SendEmail([]string{to}, subject, htmlContent)
+}
+
+// SendAPIKeyLeakedEmail tells the owner of an API key that it was revoked
+// because it was found in public
+func SendAPIKeyLeakedEmail(to string, keyName string, prefix string, source string, location string) error {
+	subject := "Your API key was leaked and has been revoked"
+	found := "in public"
+	if location != "" {
+		// Not linked, the location comes from the reporter
+		found = fmt.Sprintf("at <code>%s</code>", html.EscapeString(location))
+	}
+	if source != "" {
+		found += fmt.Sprintf(" (reported by %s)", html.EscapeString(source))
+	}
+	htmlContent := fmt.Sprintf(`
+		<h2>Your API key has been revoked</h2>
+		<p>The API key <strong>%s</strong> (%s…) was found %s and has been revoked to protect your account.</p>
+		<p>Create a new key, update your clients and remove the leaked key from wherever it was published. Review your recent activity for requests you do not recognize.</p>
+	`, html.EscapeString(keyName), html.EscapeString(prefix), found)
+
+	return SendEmail([]string{to}, subject, htmlContent)
+}
@@ -0,0 +1,85 @@
+// Package hasher hashes secrets such as passwords and API keys. Hashes are
+// self-describing PHC strings, so the algorithm and its parameters can be
+// changed without invalidating hashes created with the previous settings.
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/llamacto/llama-gin-kit/config"
+)
+
+// Supported algorithm names, as used in configuration and hash identifiers.
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// ErrUnknownFormat is returned when a hash was not produced by a supported algorithm.
+var ErrUnknownFormat = errors.New("unknown hash format")
+
+// Hasher hashes secrets and verifies them against stored hashes.
+type Hasher interface {
+	// Hash returns the encoded hash of plain.
+	Hash(plain string) (string, error)
+	// Verify reports whether plain matches the encoded hash.
+	Verify(encoded, plain string) (bool, error)
+	// NeedsRehash reports whether encoded was produced with a different
+	// algorithm or weaker parameters than the ones currently configured.
+	NeedsRehash(encoded string) bool
+}
+
+// scheme is a single algorithm with fixed parameters.
+type scheme interface {
+	Hasher
+	// Matches reports whether encoded was produced by this algorithm.
+	Matches(encoded string) bool
+}
+
+// multi hashes with the preferred scheme and verifies hashes of any scheme.
+type multi struct {
+	preferred scheme
+	schemes   []scheme
+}
+
+// New returns a Hasher that hashes with the configured algorithm and still
+// verifies hashes created by the other supported algorithms.
+func New(cfg config.PasswordConfig) (Hasher, error) {
+	argon := newArgon2id(Argon2Params{
+		Memory:      uint32(cfg.Argon2MemoryKB),
+		Iterations:  uint32(cfg.Argon2Iterations),
+		Parallelism: uint8(cfg.Argon2Parallelism),
+	})
+	bc := newBcrypt(cfg.BcryptCost)
+
+	switch strings.ToLower(cfg.HashAlgorithm) {
+	case "", Argon2id:
+		return &multi{preferred: argon, schemes: []scheme{argon, bc}}, nil
+	case Bcrypt:
+		return &multi{preferred: bc, schemes: []scheme{bc, argon}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported hash algorithm: %s", cfg.HashAlgorithm)
+	}
+}
+
+func (m *multi) Hash(plain string) (string, error) {
+	return m.preferred.Hash(plain)
+}
+
+func (m *multi) Verify(encoded, plain string) (bool, error) {
+	for _, s := range m.schemes {
+		if s.Matches(encoded) {
+			return s.Verify(encoded, plain)
+		}
+	}
+	return false, ErrUnknownFormat
+}
+
+func (m *multi) NeedsRehash(encoded string) bool {
+	if !m.preferred.Matches(encoded) {
+		return true
+	}
+	return m.preferred.NeedsRehash(encoded)
+}
@@ -0,0 +1,85 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llamacto/llama-gin-kit/config"
+)
+
+// cheap keeps the Argon2id parameters small so the tests stay fast.
+func cheap(algorithm string) config.PasswordConfig {
+	return config.PasswordConfig{
+		HashAlgorithm:     algorithm,
+		BcryptCost:        4,
+		Argon2MemoryKB:    1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	}
+}
+
+func TestArgon2id_HashAndVerify(t *testing.T) {
+	h, err := New(cheap(Argon2id))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	encoded, err := h.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected PHC string %q", encoded)
+	}
+
+	if ok, err := h.Verify(encoded, "correct horse"); err != nil || !ok {
+		t.Fatalf("expected password to verify, got %v, %v", ok, err)
+	}
+	if ok, err := h.Verify(encoded, "wrong horse"); err != nil || ok {
+		t.Fatalf("expected wrong password to fail, got %v, %v", ok, err)
+	}
+	if h.NeedsRehash(encoded) {
+		t.Fatal("fresh hash should not need rehashing")
+	}
+}
+
+func TestNeedsRehash_AlgorithmAndParameters(t *testing.T) {
+	bc, _ := New(cheap(Bcrypt))
+	legacy, err := bc.Hash("correct horse")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	argon, _ := New(cheap(Argon2id))
+	if ok, err := argon.Verify(legacy, "correct horse"); err != nil || !ok {
+		t.Fatalf("argon2id hasher should still verify bcrypt hashes, got %v, %v", ok, err)
+	}
+	if !argon.NeedsRehash(legacy) {
+		t.Fatal("bcrypt hash should be upgraded when argon2id is configured")
+	}
+
+	weak, _ := argon.Hash("correct horse")
+	stronger := cheap(Argon2id)
+	stronger.Argon2Iterations = 2
+	upgraded, _ := New(stronger)
+	if !upgraded.NeedsRehash(weak) {
+		t.Fatal("hash with fewer iterations should be upgraded")
+	}
+
+	costlier := cheap(Bcrypt)
+	costlier.BcryptCost = 5
+	bc5, _ := New(costlier)
+	if !bc5.NeedsRehash(legacy) {
+		t.Fatal("bcrypt hash with lower cost should be upgraded")
+	}
+}
+
+func TestVerify_UnknownFormat(t *testing.T) {
+	h, _ := New(cheap(Argon2id))
+	if _, err := h.Verify("plaintext", "plaintext"); err != ErrUnknownFormat {
+		t.Fatalf("expected ErrUnknownFormat, got %v", err)
+	}
+	if _, err := New(cheap("md5")); err == nil {
+		t.Fatal("expected unsupported algorithm to be rejected")
+	}
+}
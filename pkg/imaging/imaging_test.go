@@ -0,0 +1,128 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("png.Encode failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withOrientation inserts an EXIF APP1 segment carrying the orientation tag
+// right after the SOI marker of a JPEG file
+func withOrientation(jpg []byte, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+12+4)
+	copy(tiff, "MM")
+	binary.BigEndian.PutUint16(tiff[2:], 42)
+	binary.BigEndian.PutUint32(tiff[4:], 8)
+	binary.BigEndian.PutUint16(tiff[8:], 1)
+	binary.BigEndian.PutUint16(tiff[10:], 0x0112)
+	binary.BigEndian.PutUint16(tiff[12:], 3) // SHORT
+	binary.BigEndian.PutUint32(tiff[14:], 1)
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func TestDecode_RejectsNonImages(t *testing.T) {
+	if _, _, err := Decode([]byte("<svg xmlns='http://www.w3.org/2000/svg'></svg>"), 0); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat, got %v", err)
+	}
+	// A PNG signature followed by garbage
+	if _, _, err := Decode(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...), 0); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("Expected ErrInvalidImage, got %v", err)
+	}
+}
+
+func TestDecode_RejectsLargeDimensions(t *testing.T) {
+	data := encodePNG(t, image.NewRGBA(image.Rect(0, 0, 300, 20)))
+	if _, _, err := Decode(data, 256); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+	if _, format, err := Decode(data, 300); err != nil || format != FormatPNG {
+		t.Fatalf("Expected PNG within limits to decode, got %q %v", format, err)
+	}
+}
+
+func TestDecode_AppliesEXIFOrientation(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, src, nil); err != nil {
+		t.Fatalf("jpeg.Encode failed: %v", err)
+	}
+
+	img, format, err := Decode(withOrientation(buf.Bytes(), 6), 0)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if format != FormatJPEG {
+		t.Errorf("Expected jpeg, got %s", format)
+	}
+	if b := img.Bounds(); b.Dx() != 20 || b.Dy() != 40 {
+		t.Errorf("Expected rotated 20x40 image, got %dx%d", b.Dx(), b.Dy())
+	}
+}
+
+func TestApplyOrientation_Rotate90Clockwise(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.RGBA{R: 255, A: 255})
+	src.Set(1, 0, color.RGBA{B: 255, A: 255})
+
+	out := applyOrientation(src, 6)
+	if r, _, _, _ := out.At(0, 0).RGBA(); r == 0 {
+		t.Error("Expected the left pixel to move to the top")
+	}
+	if _, _, b, _ := out.At(0, 1).RGBA(); b == 0 {
+		t.Error("Expected the right pixel to move to the bottom")
+	}
+}
+
+func TestSquare_CropsCenterAndScales(t *testing.T) {
+	// Red left third, green middle third, blue right third
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			c := color.RGBA{G: 255, A: 255}
+			if x < 100 {
+				c = color.RGBA{R: 255, A: 255}
+			} else if x >= 200 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	out := Square(src, 32)
+	if b := out.Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+		t.Fatalf("Expected 32x32, got %dx%d", b.Dx(), b.Dy())
+	}
+	for _, p := range []image.Point{{0, 0}, {31, 31}, {16, 16}} {
+		if c := out.RGBAAt(p.X, p.Y); c.G != 255 || c.R != 0 || c.B != 0 {
+			t.Errorf("Expected only the green center at %v, got %v", p, c)
+		}
+	}
+
+	up := Square(image.NewRGBA(image.Rect(0, 0, 10, 10)), 64)
+	if up.Bounds().Dx() != 64 {
+		t.Errorf("Expected upscaling to 64, got %d", up.Bounds().Dx())
+	}
+}
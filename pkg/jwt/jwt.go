@@ -1,11 +1,14 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
 )
 
 var (
@@ -14,17 +17,99 @@ var (
 
 // Service provides JWT helpers bound to a configuration instance.
 type Service struct {
-	cfg *config.Config
+	cfg         *config.Config
+	revocations RevocationStore
+	keys        *KeySet // nil when signing with the shared HS256 secret
 }
 
 // NewService constructs a JWT service using the provided configuration.
+// Revocations are kept in memory until SetRevocationStore installs a shared store.
+// For asymmetric algorithms LoadKeys must be called before issuing tokens.
 func NewService(cfg *config.Config) *Service {
-	return &Service{cfg: cfg}
+	s := &Service{cfg: cfg, revocations: NewMemoryRevocationStore()}
+	if cfg != nil && IsAsymmetric(cfg.JWT.Algorithm) {
+		// Retired keys must outlive every access token they signed
+		s.keys = NewKeySet(cfg.JWT.Algorithm, cfg.JWT.KeysDir, s.AccessTokenTTL()+time.Minute)
+	}
+	return s
+}
+
+// LoadKeys loads the signing keys from disk, generating the first one if
+// needed. It is a no-op for HS256.
+func (s *Service) LoadKeys() error {
+	if s.keys == nil {
+		return nil
+	}
+	return s.keys.Load()
+}
+
+// Keys returns the asymmetric key set, or nil for HS256.
+func (s *Service) Keys() *KeySet {
+	return s.keys
+}
+
+// JWKS returns the public keys that verify tokens issued by this service.
+// The set is empty for HS256, whose secret must never be published.
+func (s *Service) JWKS() JWKSet {
+	if s == nil || s.keys == nil {
+		return JWKSet{Keys: []JWK{}}
+	}
+	return s.keys.JWKS()
+}
+
+// StartKeyRotation rotates the signing key every JWT.KeyRotationInterval
+// until ctx is cancelled. It does nothing for HS256 or when rotation is disabled.
+func (s *Service) StartKeyRotation(ctx context.Context) {
+	if s.keys == nil || s.cfg.JWT.KeyRotationInterval <= 0 {
+		return
+	}
+	interval := s.cfg.JWT.KeyRotationInterval
+
+	// Check more often than the interval so a restart does not postpone rotation
+	check := interval / 10
+	if check > time.Hour {
+		check = time.Hour
+	}
+	if check < time.Minute {
+		check = time.Minute
+	}
+
+	go func() {
+		ticker := time.NewTicker(check)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				rotated, err := s.keys.RotateIfDue(interval)
+				if err != nil {
+					logger.Error("Failed to rotate JWT signing key", err)
+					continue
+				}
+				if rotated {
+					if key, err := s.keys.Active(); err == nil {
+						logger.Info("Rotated JWT signing key, new kid %s", key.ID)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// SetRevocationStore replaces the store consulted when validating tokens.
+func (s *Service) SetRevocationStore(store RevocationStore) {
+	s.revocations = store
 }
 
 // Init 初始化 JWT 服务
-func Init(c *config.Config) {
-	defaultService = NewService(c)
+func Init(c *config.Config) error {
+	svc := NewService(c)
+	if err := svc.LoadKeys(); err != nil {
+		return fmt.Errorf("failed to load jwt signing keys: %w", err)
+	}
+	defaultService = svc
+	return nil
 }
 
 // SetDefaultService overrides the global JWT service used by package-level helpers.
@@ -53,42 +138,157 @@ func MustServiceInstance() *Service {
 type Claims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	// SessionID ties the token to the login session it was issued for, so
+	// signing out of that session also invalidates the token.
+	SessionID string `json:"sid,omitempty"`
+	// ImpersonatorID is the administrator acting as the user, if any.
+	ImpersonatorID uint `json:"imp,omitempty"`
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成 JWT token
 func (s *Service) GenerateToken(userID uint, username string) (string, error) {
+	return s.GenerateSessionToken(userID, username, "")
+}
+
+// GenerateSessionToken 生成属于某个登录会话的 JWT token
+func (s *Service) GenerateSessionToken(userID uint, username, sessionID string) (string, error) {
+	return s.sign(Claims{UserID: userID, Username: username, SessionID: sessionID})
+}
+
+// GenerateImpersonationToken 生成管理员以用户身份操作的 JWT token
+func (s *Service) GenerateImpersonationToken(userID uint, username string, impersonatorID uint) (string, error) {
+	return s.sign(Claims{UserID: userID, Username: username, ImpersonatorID: impersonatorID})
+}
+
+// sign fills in the registered claims and signs the token with the active key.
+func (s *Service) sign(claims Claims) (string, error) {
 	if s == nil || s.cfg == nil {
 		return "", fmt.Errorf("jwt service not initialized")
 	}
 
 	now := time.Now()
-	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.JWT.ExpireDuration)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-		},
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        uuid.NewString(),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTokenTTL())),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+
+	if s.keys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(s.cfg.JWT.Secret))
+	}
+
+	key, err := s.keys.Active()
+	if err != nil {
+		return "", err
 	}
+	token := jwt.NewWithClaims(key.Method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.JWT.Secret))
+// AccessTokenTTL returns how long newly issued access tokens stay valid.
+func (s *Service) AccessTokenTTL() time.Duration {
+	if s == nil || s.cfg == nil || s.cfg.JWT.AccessExpireDuration <= 0 {
+		return 15 * time.Minute
+	}
+	return s.cfg.JWT.AccessExpireDuration
+}
+
+// RefreshTokenTTL returns how long newly issued refresh tokens stay valid.
+func (s *Service) RefreshTokenTTL() time.Duration {
+	if s == nil || s.cfg == nil || s.cfg.JWT.RefreshExpireDuration <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return s.cfg.JWT.RefreshExpireDuration
 }
 
 // ParseToken 解析 JWT token
 func (s *Service) ParseToken(tokenString string) (*Claims, error) {
+	return s.ParseTokenWithContext(context.Background(), tokenString)
+}
+
+// ParseTokenWithContext parses a token and rejects it if it has been revoked.
+func (s *Service) ParseTokenWithContext(ctx context.Context, tokenString string) (*Claims, error) {
+	claims, err := s.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRevocation(ctx, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// RevokeToken revokes a single token until it would have expired anyway.
+func (s *Service) RevokeToken(ctx context.Context, claims *Claims) error {
+	if claims == nil || claims.ID == "" {
+		return fmt.Errorf("token has no jti claim")
+	}
+	expiresAt := time.Now().Add(s.AccessTokenTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return s.revocations.RevokeToken(ctx, claims.ID, claims.UserID, expiresAt)
+}
+
+// RevokeSession revokes every token issued for a login session. No new
+// tokens are issued for a revoked session, so the entry is only needed
+// until the last one expires.
+func (s *Service) RevokeSession(ctx context.Context, sessionID string) error {
+	return s.revocations.RevokeSession(ctx, sessionID, time.Now().Add(s.AccessTokenTTL()))
+}
+
+// RevokeUserTokens revokes every token issued to the user so far.
+func (s *Service) RevokeUserTokens(ctx context.Context, userID uint) error {
+	return s.revocations.RevokeUser(ctx, userID, time.Now())
+}
+
+func (s *Service) checkRevocation(ctx context.Context, claims *Claims) error {
+	if s.revocations == nil {
+		return nil
+	}
+
+	if claims.ID != "" {
+		revoked, err := s.revocations.IsTokenRevoked(ctx, claims.ID)
+		if err != nil {
+			return fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	if claims.SessionID != "" {
+		revoked, err := s.revocations.IsSessionRevoked(ctx, claims.SessionID)
+		if err != nil {
+			return fmt.Errorf("failed to check session revocation: %w", err)
+		}
+		if revoked {
+			return ErrTokenRevoked
+		}
+	}
+
+	cutoff, found, err := s.revocations.UserRevokedAt(ctx, claims.UserID)
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	// iat only has second precision, so anything issued within the
+	// revocation second is treated as revoked as well.
+	if found && (claims.IssuedAt == nil || !claims.IssuedAt.Time.After(cutoff.Truncate(time.Second))) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+func (s *Service) parse(tokenString string) (*Claims, error) {
 	if s == nil || s.cfg == nil {
 		return nil, fmt.Errorf("jwt service not initialized")
 	}
 
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keyFunc)
 
 	if err != nil {
 		return nil, err
@@ -101,6 +301,31 @@ func (s *Service) ParseToken(tokenString string) (*Claims, error) {
 	return nil, fmt.Errorf("invalid token")
 }
 
+// keyFunc selects the verification key. Asymmetric tokens are matched by
+// their kid header and must use the key's own algorithm, so a public key
+// can never be replayed as an HMAC secret.
+func (s *Service) keyFunc(token *jwt.Token) (interface{}, error) {
+	if s.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(s.cfg.JWT.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid header")
+	}
+	key, err := s.keys.Lookup(kid)
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.Public(), nil
+}
+
 // GenerateToken 生成 JWT token using the global service.
 func GenerateToken(userID uint, username string) (string, error) {
 	svc, err := ServiceInstance()
@@ -118,3 +343,12 @@ func ParseToken(tokenString string) (*Claims, error) {
 	}
 	return svc.ParseToken(tokenString)
 }
+
+// ParseTokenWithContext 解析 JWT token 并检查吊销状态 using the global service.
+func ParseTokenWithContext(ctx context.Context, tokenString string) (*Claims, error) {
+	svc, err := ServiceInstance()
+	if err != nil {
+		return nil, err
+	}
+	return svc.ParseTokenWithContext(ctx, tokenString)
+}
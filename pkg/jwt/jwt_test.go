@@ -0,0 +1,156 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/config"
+)
+
+func newTestService() *Service {
+	return NewService(&config.Config{JWT: config.JWTConfig{
+		Secret:               "test-secret",
+		AccessExpireDuration: time.Minute,
+	}})
+}
+
+func TestGenerateToken_SetsJTI(t *testing.T) {
+	svc := newTestService()
+
+	tokenString, err := svc.GenerateToken(1, "alice")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	claims, err := svc.ParseToken(tokenString)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if claims.ID == "" {
+		t.Fatal("Expected jti claim to be set")
+	}
+	if claims.UserID != 1 || claims.Username != "alice" {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+}
+
+func TestParseToken_RevokedToken(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	first, _ := svc.GenerateToken(1, "alice")
+	second, _ := svc.GenerateToken(1, "alice")
+
+	claims, err := svc.ParseTokenWithContext(ctx, first)
+	if err != nil {
+		t.Fatalf("ParseToken failed: %v", err)
+	}
+	if err := svc.RevokeToken(ctx, claims); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+
+	if _, err := svc.ParseTokenWithContext(ctx, first); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.ParseTokenWithContext(ctx, second); err != nil {
+		t.Fatalf("Expected unrelated token to stay valid, got %v", err)
+	}
+}
+
+func TestParseToken_RevokedUser(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	tokenString, _ := svc.GenerateToken(1, "alice")
+	other, _ := svc.GenerateToken(2, "bob")
+
+	if err := svc.RevokeUserTokens(ctx, 1); err != nil {
+		t.Fatalf("RevokeUserTokens failed: %v", err)
+	}
+
+	if _, err := svc.ParseTokenWithContext(ctx, tokenString); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.ParseTokenWithContext(ctx, other); err != nil {
+		t.Fatalf("Expected other user's token to stay valid, got %v", err)
+	}
+}
+
+func TestCachedRevocationStore_WritesThrough(t *testing.T) {
+	backend := NewMemoryRevocationStore()
+	cached := NewCachedRevocationStore(backend, time.Minute)
+	ctx := context.Background()
+
+	if revoked, _ := cached.IsTokenRevoked(ctx, "jti-1"); revoked {
+		t.Fatal("Expected token not to be revoked")
+	}
+	if err := cached.RevokeToken(ctx, "jti-1", 1, time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("RevokeToken failed: %v", err)
+	}
+	if revoked, _ := cached.IsTokenRevoked(ctx, "jti-1"); !revoked {
+		t.Fatal("Expected cached lookup to see the revocation")
+	}
+	if revoked, _ := backend.IsTokenRevoked(ctx, "jti-1"); !revoked {
+		t.Fatal("Expected revocation to reach the backend")
+	}
+}
+
+func TestCachedRevocationStore_PrunesExpiredEntries(t *testing.T) {
+	cached := NewCachedRevocationStore(NewMemoryRevocationStore(), time.Minute)
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cached.RevokeToken(ctx, "revoked", 1, now.Add(time.Hour))
+	cached.RevokeSession(ctx, "session", now.Add(time.Hour))
+	cached.RevokeUser(ctx, 1, now)
+	cached.IsTokenRevoked(ctx, "valid")
+	cached.UserRevokedAt(ctx, 2)
+
+	// Lookups are cached for the ttl, revocations until the token expires
+	now = now.Add(2 * time.Minute)
+	cached.prune()
+	if len(cached.tokens) != 1 || len(cached.sessions) != 1 || len(cached.users) != 0 {
+		t.Fatalf("Expected only the revocations to survive the ttl, got %d tokens, %d sessions, %d users",
+			len(cached.tokens), len(cached.sessions), len(cached.users))
+	}
+
+	now = now.Add(time.Hour)
+	cached.prune()
+	if len(cached.tokens) != 0 || len(cached.sessions) != 0 {
+		t.Fatalf("Expected expired revocations to be dropped, got %d tokens, %d sessions",
+			len(cached.tokens), len(cached.sessions))
+	}
+	// The backend still has the revocation for anyone who asks again
+	if revoked, _ := cached.IsTokenRevoked(ctx, "revoked"); !revoked {
+		t.Error("Expected the backend to still report the revocation")
+	}
+}
+
+func TestParseToken_RevokedSession(t *testing.T) {
+	svc := newTestService()
+	ctx := context.Background()
+
+	tokenString, _ := svc.GenerateSessionToken(1, "alice", "session-1")
+	other, _ := svc.GenerateSessionToken(1, "alice", "session-2")
+
+	claims, err := svc.ParseTokenWithContext(ctx, tokenString)
+	if err != nil {
+		t.Fatalf("ParseTokenWithContext failed: %v", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Fatalf("Expected sid claim session-1, got %q", claims.SessionID)
+	}
+
+	if err := svc.RevokeSession(ctx, "session-1"); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if _, err := svc.ParseTokenWithContext(ctx, tokenString); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("Expected ErrTokenRevoked, got %v", err)
+	}
+	if _, err := svc.ParseTokenWithContext(ctx, other); err != nil {
+		t.Fatalf("Expected other session's token to stay valid, got %v", err)
+	}
+}
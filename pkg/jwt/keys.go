@@ -0,0 +1,407 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmES256 = "ES256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// reloadInterval limits how often an unknown kid triggers a re-read of the key directory.
+const reloadInterval = 10 * time.Second
+
+// ErrUnknownKey is returned when a token references a kid that is not in the key set.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// IsAsymmetric reports whether the algorithm signs with a private/public key pair.
+func IsAsymmetric(alg string) bool {
+	switch alg {
+	case AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA:
+		return true
+	}
+	return false
+}
+
+// SigningKey is one private key of a KeySet, identified by its kid.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// Method returns the jwt signing method matching the key's algorithm.
+func (k *SigningKey) Method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+// Public returns the verification key.
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// GenerateSigningKey creates a new random key for the algorithm.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var (
+		signer crypto.Signer
+		err    error
+	)
+	switch alg {
+	case AlgorithmRS256:
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmES256:
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmEdDSA:
+		_, signer, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", alg, err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &SigningKey{
+		ID:        now.Format("20060102150405") + "-" + hex.EncodeToString(suffix),
+		Algorithm: alg,
+		Private:   signer,
+		CreatedAt: now,
+	}, nil
+}
+
+// algorithmForKey infers the JWS algorithm from a parsed private key.
+func algorithmForKey(key interface{}) (crypto.Signer, string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, AlgorithmRS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, "", fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+		return k, AlgorithmES256, nil
+	case ed25519.PrivateKey:
+		return k, AlgorithmEdDSA, nil
+	}
+	return nil, "", fmt.Errorf("unsupported private key type %T", key)
+}
+
+// KeySet holds the active signing key and the retired keys that can still
+// verify tokens issued before a rotation. When dir is set, keys are stored
+// there as PKCS#8 PEM files named <kid>.pem so that every instance sharing
+// the directory signs and verifies with the same keys.
+type KeySet struct {
+	alg       string
+	dir       string
+	retention time.Duration
+
+	mu         sync.RWMutex
+	active     *SigningKey
+	keys       map[string]*SigningKey
+	lastReload time.Time
+}
+
+// NewKeySet creates an empty key set. retention is how long a retired key
+// keeps verifying tokens; it should be at least the access token lifetime.
+func NewKeySet(alg, dir string, retention time.Duration) *KeySet {
+	return &KeySet{
+		alg:       alg,
+		dir:       dir,
+		retention: retention,
+		keys:      make(map[string]*SigningKey),
+	}
+}
+
+// Load reads existing keys from the key directory and generates the first
+// key if none are present.
+func (ks *KeySet) Load() error {
+	if err := ks.reload(); err != nil {
+		return err
+	}
+	ks.mu.RLock()
+	empty := ks.active == nil
+	ks.mu.RUnlock()
+	if empty {
+		return ks.Rotate()
+	}
+	return nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.active == nil {
+		return nil, errors.New("no active signing key")
+	}
+	return ks.active, nil
+}
+
+// Lookup returns the key with the given kid, re-reading the key directory
+// at most once per reloadInterval when the kid is unknown.
+func (ks *KeySet) Lookup(kid string) (*SigningKey, error) {
+	ks.mu.RLock()
+	key, ok := ks.keys[kid]
+	stale := ks.dir != "" && time.Since(ks.lastReload) > reloadInterval
+	ks.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if stale {
+		if err := ks.reload(); err != nil {
+			return nil, err
+		}
+		ks.mu.RLock()
+		key, ok = ks.keys[kid]
+		ks.mu.RUnlock()
+		if ok {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Rotate generates a new active key and drops keys past their retention.
+func (ks *KeySet) Rotate() error {
+	key, err := GenerateSigningKey(ks.alg)
+	if err != nil {
+		return err
+	}
+	if ks.dir != "" {
+		if err := writeKeyFile(ks.dir, key); err != nil {
+			return err
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[key.ID] = key
+	ks.active = key
+	ks.pruneLocked(time.Now())
+	return nil
+}
+
+// RotateIfDue rotates when the active key is older than interval. Keys
+// written by other instances are picked up first so that a shared key
+// directory is only rotated once.
+func (ks *KeySet) RotateIfDue(interval time.Duration) (bool, error) {
+	if ks.dir != "" {
+		if err := ks.reload(); err != nil {
+			return false, err
+		}
+	}
+	ks.mu.RLock()
+	due := ks.active == nil || time.Since(ks.active.CreatedAt) >= interval
+	ks.mu.RUnlock()
+	if !due {
+		return false, nil
+	}
+	return true, ks.Rotate()
+}
+
+// Keys returns all keys that can currently verify tokens, newest first.
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys
+}
+
+// pruneLocked removes retired keys that can no longer have valid tokens.
+// A key retires when its successor is created. Callers must hold ks.mu.
+func (ks *KeySet) pruneLocked(now time.Time) {
+	keys := make([]*SigningKey, 0, len(ks.keys))
+	for _, k := range ks.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+
+	for i := 0; i < len(keys)-1; i++ {
+		retiredAt := keys[i+1].CreatedAt
+		if now.Sub(retiredAt) < ks.retention {
+			continue
+		}
+		delete(ks.keys, keys[i].ID)
+		if ks.dir != "" {
+			_ = os.Remove(filepath.Join(ks.dir, keys[i].ID+".pem"))
+		}
+	}
+}
+
+// reload re-reads the key directory, if any.
+func (ks *KeySet) reload() error {
+	if ks.dir == "" {
+		return nil
+	}
+	loaded, err := readKeyDir(ks.dir, ks.alg)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.lastReload = time.Now()
+	for _, k := range loaded {
+		if _, ok := ks.keys[k.ID]; !ok {
+			ks.keys[k.ID] = k
+		}
+		if ks.active == nil || k.CreatedAt.After(ks.active.CreatedAt) {
+			ks.active = ks.keys[k.ID]
+		}
+	}
+	ks.pruneLocked(time.Now())
+	return nil
+}
+
+func writeKeyFile(dir string, key *SigningKey) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	path := filepath.Join(dir, key.ID+".pem")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return os.Chtimes(path, key.CreatedAt, key.CreatedAt)
+}
+
+func readKeyDir(dir, alg string) ([]*SigningKey, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read key directory: %w", err)
+	}
+
+	var keys []*SigningKey
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pem") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%s is not a PEM file", path)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		signer, keyAlg, err := algorithmForKey(parsed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if keyAlg != alg {
+			// Keys for another algorithm are ignored so the directory can be migrated gradually
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, &SigningKey{
+			ID:        strings.TrimSuffix(entry.Name(), ".pem"),
+			Algorithm: keyAlg,
+			Private:   signer,
+			CreatedAt: info.ModTime(),
+		})
+	}
+	return keys, nil
+}
+
+// JWK is a single public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	Curve     string `json:"crv,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every key that can verify tokens.
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range ks.Keys() {
+		if jwk, ok := publicJWK(k); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func publicJWK(k *SigningKey) (JWK, bool) {
+	jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+	switch pub := k.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package jwt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/config"
+)
+
+func newAsymmetricTestService(t *testing.T, alg, dir string) *Service {
+	t.Helper()
+	svc := NewService(&config.Config{JWT: config.JWTConfig{
+		Algorithm:            alg,
+		KeysDir:              dir,
+		AccessExpireDuration: time.Minute,
+	}})
+	if err := svc.LoadKeys(); err != nil {
+		t.Fatalf("LoadKeys failed: %v", err)
+	}
+	return svc
+}
+
+func TestAsymmetricAlgorithms_SignAndVerify(t *testing.T) {
+	for _, alg := range []string{AlgorithmRS256, AlgorithmES256, AlgorithmEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			svc := newAsymmetricTestService(t, alg, "")
+
+			tokenString, err := svc.GenerateToken(7, "bob")
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+			claims, err := svc.ParseToken(tokenString)
+			if err != nil {
+				t.Fatalf("ParseToken failed: %v", err)
+			}
+			if claims.UserID != 7 {
+				t.Errorf("Expected user 7, got %d", claims.UserID)
+			}
+
+			jwks := svc.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Algorithm != alg {
+				t.Errorf("Unexpected JWKS: %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestKeyRotation_OldTokensStillVerify(t *testing.T) {
+	svc := newAsymmetricTestService(t, AlgorithmES256, "")
+
+	oldToken, err := svc.GenerateToken(1, "alice")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if err := svc.Keys().Rotate(); err != nil {
+		t.Fatalf("Rotate failed: %v", err)
+	}
+	newToken, err := svc.GenerateToken(1, "alice")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	for _, tok := range []string{oldToken, newToken} {
+		if _, err := svc.ParseToken(tok); err != nil {
+			t.Errorf("ParseToken failed after rotation: %v", err)
+		}
+	}
+	if n := len(svc.JWKS().Keys); n != 2 {
+		t.Errorf("Expected 2 published keys during retention, got %d", n)
+	}
+}
+
+func TestKeySet_SharedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	issuer := newAsymmetricTestService(t, AlgorithmEdDSA, dir)
+	verifier := newAsymmetricTestService(t, AlgorithmEdDSA, dir)
+
+	tokenString, err := issuer.GenerateToken(3, "carol")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err := verifier.ParseToken(tokenString); err != nil {
+		t.Fatalf("Verifier sharing the key directory rejected token: %v", err)
+	}
+}
+
+func TestParseToken_RejectsHMACWithPublishedKey(t *testing.T) {
+	asymmetric := newAsymmetricTestService(t, AlgorithmRS256, "")
+	hmacToken, err := newTestService().GenerateToken(1, "alice")
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	if _, err := asymmetric.ParseToken(hmacToken); err == nil {
+		t.Fatal("Expected HS256 token to be rejected by an RS256 service")
+	}
+}
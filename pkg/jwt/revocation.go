@@ -0,0 +1,280 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned when a token has been revoked server side.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore records revoked tokens. Individual tokens are revoked by
+// their jti claim and login sessions by their sid claim; RevokeUser
+// invalidates every token issued to a user up to the given time.
+type RevocationStore interface {
+	RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+	RevokeUser(ctx context.Context, userID uint, at time.Time) error
+	UserRevokedAt(ctx context.Context, userID uint) (time.Time, bool, error)
+}
+
+// MemoryRevocationStore keeps revocations in process memory. It is suitable
+// for single-instance deployments and as the cache layer of a shared store.
+type MemoryRevocationStore struct {
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> token expiry
+	sessions map[string]time.Time // sid -> expiry of the session's last token
+	users    map[uint]time.Time   // user ID -> revocation cutoff
+}
+
+// NewMemoryRevocationStore creates an empty in-memory revocation store.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[uint]time.Time),
+	}
+}
+
+// RevokeToken marks a single token as revoked until it expires.
+func (m *MemoryRevocationStore) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, exp := range m.tokens {
+		if exp.Before(now) {
+			delete(m.tokens, id)
+		}
+	}
+	m.tokens[jti] = expiresAt
+	return nil
+}
+
+// IsTokenRevoked reports whether the token has been revoked.
+func (m *MemoryRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.tokens[jti]
+	return ok, nil
+}
+
+// RevokeSession marks a session as revoked until its tokens expire.
+func (m *MemoryRevocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for id, exp := range m.sessions {
+		if exp.Before(now) {
+			delete(m.sessions, id)
+		}
+	}
+	m.sessions[sessionID] = expiresAt
+	return nil
+}
+
+// IsSessionRevoked reports whether the session has been revoked.
+func (m *MemoryRevocationStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.sessions[sessionID]
+	return ok, nil
+}
+
+// RevokeUser invalidates every token issued to the user before at.
+func (m *MemoryRevocationStore) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.users[userID]; !ok || at.After(current) {
+		m.users[userID] = at
+	}
+	return nil
+}
+
+// UserRevokedAt returns the revocation cutoff for the user, if any.
+func (m *MemoryRevocationStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	at, ok := m.users[userID]
+	return at, ok, nil
+}
+
+// revocationPruneInterval is how often CachedRevocationStore drops expired
+// entries
+const revocationPruneInterval = time.Minute
+
+// CachedRevocationStore fronts a shared store (usually the database) with a
+// short-lived in-memory cache. Writes go to both layers; lookups are served
+// from the cache for up to ttl, which bounds how long a revocation made by
+// another instance can go unnoticed. Start must run to drop expired entries.
+type CachedRevocationStore struct {
+	backend RevocationStore
+	ttl     time.Duration
+	now     func() time.Time
+
+	mu       sync.Mutex
+	tokens   map[string]cachedTokenLookup
+	sessions map[string]cachedTokenLookup
+	users    map[uint]cachedUserLookup
+}
+
+type cachedTokenLookup struct {
+	revoked bool
+	until   time.Time // when the entry stops being served
+}
+
+type cachedUserLookup struct {
+	at    time.Time
+	found bool
+	until time.Time
+}
+
+// NewCachedRevocationStore wraps backend with a lookup cache of the given ttl.
+func NewCachedRevocationStore(backend RevocationStore, ttl time.Duration) *CachedRevocationStore {
+	return &CachedRevocationStore{
+		backend:  backend,
+		ttl:      ttl,
+		now:      time.Now,
+		tokens:   make(map[string]cachedTokenLookup),
+		sessions: make(map[string]cachedTokenLookup),
+		users:    make(map[uint]cachedUserLookup),
+	}
+}
+
+// Start drops expired entries every revocationPruneInterval until ctx is
+// done.
+func (c *CachedRevocationStore) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(revocationPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.prune()
+			}
+		}
+	}()
+}
+
+// RevokeToken revokes a token in the backend and the local cache, where it
+// is kept until the token expires.
+func (c *CachedRevocationStore) RevokeToken(ctx context.Context, jti string, userID uint, expiresAt time.Time) error {
+	if err := c.backend.RevokeToken(ctx, jti, userID, expiresAt); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.tokens[jti] = cachedTokenLookup{revoked: true, until: expiresAt}
+	c.mu.Unlock()
+	return nil
+}
+
+// IsTokenRevoked checks the cache before falling back to the backend.
+func (c *CachedRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.tokens[jti]
+	c.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.backend.IsTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.tokens[jti] = cachedTokenLookup{revoked: revoked, until: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return revoked, nil
+}
+
+// RevokeSession revokes a session in the backend and the local cache, where
+// it is kept until the session's tokens expire.
+func (c *CachedRevocationStore) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	if err := c.backend.RevokeSession(ctx, sessionID, expiresAt); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.sessions[sessionID] = cachedTokenLookup{revoked: true, until: expiresAt}
+	c.mu.Unlock()
+	return nil
+}
+
+// IsSessionRevoked checks the cache before falling back to the backend.
+func (c *CachedRevocationStore) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.sessions[sessionID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.backend.IsSessionRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.sessions[sessionID] = cachedTokenLookup{revoked: revoked, until: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return revoked, nil
+}
+
+// RevokeUser revokes all of a user's tokens in the backend and the local cache.
+func (c *CachedRevocationStore) RevokeUser(ctx context.Context, userID uint, at time.Time) error {
+	if err := c.backend.RevokeUser(ctx, userID, at); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.users[userID] = cachedUserLookup{at: at, found: true, until: c.now().Add(c.ttl)}
+	c.mu.Unlock()
+	return nil
+}
+
+// UserRevokedAt checks the cache before falling back to the backend.
+func (c *CachedRevocationStore) UserRevokedAt(ctx context.Context, userID uint) (time.Time, bool, error) {
+	now := c.now()
+	c.mu.Lock()
+	entry, ok := c.users[userID]
+	c.mu.Unlock()
+	if ok && now.Before(entry.until) {
+		return entry.at, entry.found, nil
+	}
+
+	at, found, err := c.backend.UserRevokedAt(ctx, userID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	c.mu.Lock()
+	c.users[userID] = cachedUserLookup{at: at, found: found, until: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return at, found, nil
+}
+
+// prune drops the entries that are no longer served, so the cache does not
+// grow with every token, session and user ever seen.
+func (c *CachedRevocationStore) prune() {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, entry := range c.tokens {
+		if !now.Before(entry.until) {
+			delete(c.tokens, id)
+		}
+	}
+	for id, entry := range c.sessions {
+		if !now.Before(entry.until) {
+			delete(c.sessions, id)
+		}
+	}
+	for id, entry := range c.users {
+		if !now.Before(entry.until) {
+			delete(c.users, id)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package keyformat defines the format of API keys so that they can be
+// recognized and checked without the database:
+//
+//	llk_<environment>_<lookup ID>_<secret><checksum>
+//
+// The environment is live or test, the lookup ID is 16 and the secret 64
+// lower-case hex characters, and the checksum is the CRC32 (IEEE) of
+// everything before it in 6 base62 characters. Secret scanners match
+// Pattern and reject false positives with Verify.
+package keyformat
+
+import (
+	"errors"
+	"hash/crc32"
+	"strings"
+)
+
+const (
+	// Prefix starts every API key
+	Prefix = "llk_"
+
+	EnvironmentLive = "live"
+	EnvironmentTest = "test"
+
+	// LookupIDLength and SecretLength are the lengths of the hex parts
+	LookupIDLength = 16
+	SecretLength   = 64
+	// ChecksumLength is the length of the base62 checksum
+	ChecksumLength = 6
+
+	// Pattern is a regular expression matching checksummed keys, for
+	// secret scanners
+	Pattern = `\bllk_(?:live|test)_[0-9a-f]{16}_[0-9a-f]{64}[0-9A-Za-z]{6}\b`
+)
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	// ErrMalformed is returned for strings that are not API keys
+	ErrMalformed = errors.New("malformed API key")
+	// ErrChecksum is returned when the checksum does not match the key
+	ErrChecksum = errors.New("API key checksum mismatch")
+	// ErrNoChecksum is returned by Verify for keys issued before checksums
+	ErrNoChecksum = errors.New("API key has no checksum")
+)
+
+// Key is a parsed API key
+type Key struct {
+	Environment string
+	LookupID    string
+	// Checksummed is false for keys issued before checksums were added,
+	// which also may not name their environment (those are live keys)
+	Checksummed bool
+}
+
+// New returns the API key for the parts
+func New(environment, lookupID, secret string) string {
+	body := Prefix + environment + "_" + lookupID + "_" + secret
+	return body + Checksum(body)
+}
+
+// Checksum returns the checksum of a key body
+func Checksum(body string) string {
+	n := crc32.ChecksumIEEE([]byte(body))
+	out := make([]byte, ChecksumLength)
+	for i := ChecksumLength - 1; i >= 0; i-- {
+		out[i] = base62[n%62]
+		n /= 62
+	}
+	return string(out)
+}
+
+// Parse splits an API key into its parts and verifies its checksum if it
+// has one. Keys without a checksum are accepted so that they keep working;
+// use Verify to require one.
+func Parse(key string) (Key, error) {
+	rest, ok := strings.CutPrefix(key, Prefix)
+	if !ok {
+		return Key{}, ErrMalformed
+	}
+	k := Key{Environment: EnvironmentLive}
+	marked := false
+	if marker, after, ok := strings.Cut(rest, "_"); ok && (marker == EnvironmentLive || marker == EnvironmentTest) {
+		k.Environment, rest, marked = marker, after, true
+	}
+	lookupID, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(lookupID) != LookupIDLength || !isHex(lookupID) {
+		return Key{}, ErrMalformed
+	}
+	k.LookupID = lookupID
+
+	switch {
+	case len(secret) == SecretLength && isHex(secret):
+		return k, nil
+	case marked && len(secret) == SecretLength+ChecksumLength && isHex(secret[:SecretLength]):
+		body, checksum := key[:len(key)-ChecksumLength], key[len(key)-ChecksumLength:]
+		if checksum != Checksum(body) {
+			return Key{}, ErrChecksum
+		}
+		k.Checksummed = true
+		return k, nil
+	default:
+		return Key{}, ErrMalformed
+	}
+}
+
+// Verify checks that key is a well-formed API key with a valid checksum
+func Verify(key string) error {
+	k, err := Parse(key)
+	if err != nil {
+		return err
+	}
+	if !k.Checksummed {
+		return ErrNoChecksum
+	}
+	return nil
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package keyformat
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	lookupID = "0123456789abcdef"
+	secret   = strings.Repeat("ab", 32)
+)
+
+func TestNew_ParsesAndVerifies(t *testing.T) {
+	key := New(EnvironmentTest, lookupID, secret)
+	if !strings.HasPrefix(key, "llk_test_"+lookupID+"_"+secret) || len(key) != len("llk_test_")+LookupIDLength+1+SecretLength+ChecksumLength {
+		t.Fatalf("Unexpected key %q", key)
+	}
+	k, err := Parse(key)
+	if err != nil || k.Environment != EnvironmentTest || k.LookupID != lookupID || !k.Checksummed {
+		t.Fatalf("Unexpected parse result %+v %v", k, err)
+	}
+	if err := Verify(key); err != nil {
+		t.Errorf("Expected the key to verify, got %v", err)
+	}
+	if !regexp.MustCompile(Pattern).MatchString("token=" + key + "\n") {
+		t.Error("Expected Pattern to match the key")
+	}
+}
+
+func TestParse_DetectsTypos(t *testing.T) {
+	key := New(EnvironmentLive, lookupID, secret)
+	for _, typo := range []string{
+		strings.Replace(key, "llk_live_", "llk_test_", 1),
+		key[:20] + "f" + key[21:],
+		key[:len(key)-1] + "x",
+	} {
+		if typo == key {
+			continue
+		}
+		if err := Verify(typo); !errors.Is(err, ErrChecksum) {
+			t.Errorf("Expected ErrChecksum for %q, got %v", typo, err)
+		}
+	}
+}
+
+func TestParse_OlderFormats(t *testing.T) {
+	for key, environment := range map[string]string{
+		"llk_" + lookupID + "_" + secret:      EnvironmentLive,
+		"llk_test_" + lookupID + "_" + secret: EnvironmentTest,
+	} {
+		k, err := Parse(key)
+		if err != nil || k.Checksummed || k.Environment != environment {
+			t.Errorf("Unexpected parse result for %q: %+v %v", key, k, err)
+		}
+		if err := Verify(key); !errors.Is(err, ErrNoChecksum) {
+			t.Errorf("Expected ErrNoChecksum, got %v", err)
+		}
+	}
+}
+
+func TestParse_Malformed(t *testing.T) {
+	for _, key := range []string{
+		"",
+		secret,
+		"llk_live_" + lookupID,
+		"llk_live_" + lookupID[:8] + "_" + secret,
+		"llk_live_" + lookupID + "_" + strings.ToUpper(secret),
+		"llk_" + lookupID + "_" + secret + "AAAAAA",
+		"llk_prod_" + lookupID + "_" + secret,
+	} {
+		if _, err := Parse(key); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Expected ErrMalformed for %q, got %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Package lockout throttles password guessing. A Guard counts failed
+// attempts per key (an account or a client IP) within a sliding window and
+// locks the key once the limit is reached. Each consecutive lockout of the
+// same key doubles in length, up to a maximum.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/llamacto/llama-gin-kit/pkg/logger"
+)
+
+// Scope identifies what a key throttles.
+type Scope string
+
+const (
+	ScopeAccount Scope = "account"
+	ScopeIP      Scope = "ip"
+)
+
+// Key returns the store key for a subject in the given scope.
+func Key(scope Scope, subject string) string {
+	return string(scope) + ":" + subject
+}
+
+// Record is the failure state of a single key.
+type Record struct {
+	Key         string
+	Failures    int       // Failures since the window started
+	WindowStart time.Time // First failure counted in the current window
+	Lockouts    int       // Consecutive lockouts, drives the backoff
+	LockedUntil time.Time
+}
+
+// Locked reports whether the key is locked at now.
+func (r *Record) Locked(now time.Time) bool {
+	return r != nil && now.Before(r.LockedUntil)
+}
+
+// Store persists failure records. Implementations must run Update
+// atomically per key so that concurrent attempts across instances are all
+// counted.
+type Store interface {
+	// Get returns the record for key, or nil if there is none.
+	Get(ctx context.Context, key string) (*Record, error)
+	// Update loads the record for key (a zero record with Key set if there is
+	// none), applies fn to it and saves the result.
+	Update(ctx context.Context, key string, fn func(record *Record)) (*Record, error)
+	// Reset removes the record for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// EventType is the kind of an audit Event.
+type EventType string
+
+const (
+	EventLocked   EventType = "locked"
+	EventUnlocked EventType = "unlocked"
+)
+
+// Event is emitted when a key is locked or unlocked.
+type Event struct {
+	Type        EventType
+	Scope       Scope
+	Subject     string
+	IP          string
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	ActorID     uint // Administrator that unlocked the key, if any
+}
+
+// EventHandler receives audit events.
+type EventHandler func(ctx context.Context, event Event)
+
+// LogEvents writes audit events to the application log.
+func LogEvents(ctx context.Context, event Event) {
+	switch event.Type {
+	case EventLocked:
+		logger.Warn("Login lockout: %s %q locked until %s after %d failed attempts (ip=%s, lockout #%d)",
+			event.Scope, event.Subject, event.LockedUntil.Format(time.RFC3339), event.Failures, event.IP, event.Lockouts)
+	case EventUnlocked:
+		logger.Warn("Login lockout: %s %q unlocked by administrator %d", event.Scope, event.Subject, event.ActorID)
+	}
+}
+
+// LockedError is returned while a key is locked.
+type LockedError struct {
+	Scope      Scope
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// ErrLocked matches any *LockedError with errors.Is.
+var ErrLocked = errors.New("login temporarily locked")
+
+// Is makes errors.Is(err, ErrLocked) true for every LockedError.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// Policy configures a Guard.
+type Policy struct {
+	MaxAttempts   int           // Per account; 0 disables account lockout
+	IPMaxAttempts int           // Per client IP; 0 disables IP lockout
+	Window        time.Duration // How long failures are remembered
+	Lockout       time.Duration // First lockout
+	MaxLockout    time.Duration // Upper bound of the doubling lockout
+}
+
+// Guard applies a Policy on top of a Store.
+type Guard struct {
+	store  Store
+	policy Policy
+	events EventHandler
+	now    func() time.Time
+}
+
+// NewGuard creates a guard. A nil events handler logs events.
+func NewGuard(store Store, policy Policy, events EventHandler) *Guard {
+	if policy.Window <= 0 {
+		policy.Window = 15 * time.Minute
+	}
+	if policy.Lockout <= 0 {
+		policy.Lockout = 5 * time.Minute
+	}
+	if policy.MaxLockout < policy.Lockout {
+		policy.MaxLockout = policy.Lockout
+	}
+	if events == nil {
+		events = LogEvents
+	}
+	return &Guard{store: store, policy: policy, events: events, now: time.Now}
+}
+
+// Check returns a *LockedError if the account or the IP is locked.
+func (g *Guard) Check(ctx context.Context, account, ip string) error {
+	now := g.now()
+	for _, k := range g.keys(account, ip) {
+		record, err := g.store.Get(ctx, Key(k.scope, k.subject))
+		if err != nil {
+			return fmt.Errorf("failed to load login attempts: %w", err)
+		}
+		if record.Locked(now) {
+			return &LockedError{Scope: k.scope, RetryAfter: record.LockedUntil.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// Fail records a failed attempt for the account and the IP. It returns a
+// *LockedError if this attempt locked either of them.
+func (g *Guard) Fail(ctx context.Context, account, ip string) error {
+	now := g.now()
+	var locked error
+	for _, k := range g.keys(account, ip) {
+		var lockedNow bool
+		record, err := g.store.Update(ctx, Key(k.scope, k.subject), func(r *Record) {
+			lockedNow = g.countFailure(r, now, k.limit)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to record login attempt: %w", err)
+		}
+		if lockedNow {
+			g.events(ctx, Event{
+				Type:        EventLocked,
+				Scope:       k.scope,
+				Subject:     k.subject,
+				IP:          ip,
+				Failures:    k.limit,
+				Lockouts:    record.Lockouts,
+				LockedUntil: record.LockedUntil,
+			})
+			if locked == nil {
+				locked = &LockedError{Scope: k.scope, RetryAfter: record.LockedUntil.Sub(now)}
+			}
+		}
+	}
+	return locked
+}
+
+// Succeed clears the account's failures after a successful login. The IP
+// counter is left alone so one valid account cannot reset it.
+func (g *Guard) Succeed(ctx context.Context, account string) error {
+	if g.policy.MaxAttempts <= 0 || account == "" {
+		return nil
+	}
+	return g.store.Reset(ctx, Key(ScopeAccount, account))
+}
+
+// Unlock clears a key on behalf of an administrator.
+func (g *Guard) Unlock(ctx context.Context, scope Scope, subject string, actorID uint) error {
+	if err := g.store.Reset(ctx, Key(scope, subject)); err != nil {
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	g.events(ctx, Event{Type: EventUnlocked, Scope: scope, Subject: subject, ActorID: actorID})
+	return nil
+}
+
+// countFailure adds a failure to r and locks it once limit is reached. It
+// reports whether this failure started a lockout.
+func (g *Guard) countFailure(r *Record, now time.Time, limit int) bool {
+	// The backoff is forgotten once a key has behaved for MaxLockout after
+	// its last lockout ended
+	if r.Lockouts > 0 && now.Sub(r.LockedUntil) >= g.policy.MaxLockout {
+		r.Lockouts = 0
+	}
+	if r.Failures == 0 || now.Sub(r.WindowStart) >= g.policy.Window {
+		r.Failures = 0
+		r.WindowStart = now
+	}
+
+	r.Failures++
+	if r.Failures < limit {
+		return false
+	}
+	r.LockedUntil = now.Add(g.lockoutDuration(r.Lockouts))
+	r.Lockouts++
+	r.Failures = 0
+	return true
+}
+
+// lockoutDuration doubles the base lockout for every previous lockout.
+func (g *Guard) lockoutDuration(previous int) time.Duration {
+	d := g.policy.Lockout
+	for i := 0; i < previous && d < g.policy.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > g.policy.MaxLockout {
+		d = g.policy.MaxLockout
+	}
+	return d
+}
+
+type guardKey struct {
+	scope   Scope
+	subject string
+	limit   int
+}
+
+func (g *Guard) keys(account, ip string) []guardKey {
+	keys := make([]guardKey, 0, 2)
+	if g.policy.MaxAttempts > 0 && account != "" {
+		keys = append(keys, guardKey{ScopeAccount, account, g.policy.MaxAttempts})
+	}
+	if g.policy.IPMaxAttempts > 0 && ip != "" {
+		keys = append(keys, guardKey{ScopeIP, ip, g.policy.IPMaxAttempts})
+	}
+	return keys
+}
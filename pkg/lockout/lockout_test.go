@@ -0,0 +1,111 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestGuard(policy Policy) (*Guard, *time.Time, *[]Event) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	var events []Event
+	g := NewGuard(NewMemoryStore(time.Hour), policy, func(ctx context.Context, event Event) {
+		events = append(events, event)
+	})
+	g.now = func() time.Time { return now }
+	return g, &now, &events
+}
+
+func TestGuard_LocksAccountAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	g, _, events := newTestGuard(Policy{MaxAttempts: 3, Window: time.Minute, Lockout: time.Minute, MaxLockout: time.Hour})
+
+	for i := 0; i < 2; i++ {
+		if err := g.Fail(ctx, "42", "10.0.0.1"); err != nil {
+			t.Fatalf("attempt %d: unexpected error %v", i+1, err)
+		}
+	}
+	if err := g.Check(ctx, "42", "10.0.0.1"); err != nil {
+		t.Fatalf("expected account to be open before the limit, got %v", err)
+	}
+
+	err := g.Fail(ctx, "42", "10.0.0.1")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Scope != ScopeAccount || locked.RetryAfter != time.Minute {
+		t.Fatalf("expected a one minute account lockout, got %v", err)
+	}
+	if !errors.Is(g.Check(ctx, "42", "10.0.0.2"), ErrLocked) {
+		t.Error("expected the account to be locked from any address")
+	}
+	if len(*events) != 1 || (*events)[0].Type != EventLocked {
+		t.Errorf("expected one locked event, got %+v", *events)
+	}
+}
+
+func TestGuard_BackoffDoublesUpToMax(t *testing.T) {
+	ctx := context.Background()
+	g, now, _ := newTestGuard(Policy{MaxAttempts: 1, Window: time.Minute, Lockout: time.Minute, MaxLockout: 3 * time.Minute})
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		var locked *LockedError
+		if err := g.Fail(ctx, "42", ""); !errors.As(err, &locked) || locked.RetryAfter != want {
+			t.Fatalf("expected lockout of %s, got %v", want, err)
+		}
+		*now = now.Add(want)
+	}
+
+	// Behaving for MaxLockout forgets the backoff
+	*now = now.Add(3 * time.Minute)
+	var locked *LockedError
+	if err := g.Fail(ctx, "42", ""); !errors.As(err, &locked) || locked.RetryAfter != time.Minute {
+		t.Fatalf("expected the backoff to reset, got %v", err)
+	}
+}
+
+func TestGuard_WindowExpiresFailures(t *testing.T) {
+	ctx := context.Background()
+	g, now, _ := newTestGuard(Policy{MaxAttempts: 2, Window: time.Minute, Lockout: time.Minute})
+
+	_ = g.Fail(ctx, "42", "")
+	*now = now.Add(time.Minute)
+	if err := g.Fail(ctx, "42", ""); err != nil {
+		t.Fatalf("expected failures outside the window to be forgotten, got %v", err)
+	}
+}
+
+func TestGuard_SucceedKeepsIPCounter(t *testing.T) {
+	ctx := context.Background()
+	g, _, _ := newTestGuard(Policy{MaxAttempts: 2, IPMaxAttempts: 2, Window: time.Minute, Lockout: time.Minute})
+
+	_ = g.Fail(ctx, "42", "10.0.0.1")
+	if err := g.Succeed(ctx, "42"); err != nil {
+		t.Fatalf("Succeed failed: %v", err)
+	}
+
+	err := g.Fail(ctx, "43", "10.0.0.1")
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Scope != ScopeIP {
+		t.Fatalf("expected the address to be locked, got %v", err)
+	}
+	if err := g.Check(ctx, "42", ""); err != nil {
+		t.Errorf("expected the successful account to be open, got %v", err)
+	}
+}
+
+func TestGuard_Unlock(t *testing.T) {
+	ctx := context.Background()
+	g, _, events := newTestGuard(Policy{MaxAttempts: 1, Window: time.Minute, Lockout: time.Hour})
+
+	_ = g.Fail(ctx, "42", "")
+	if err := g.Unlock(ctx, ScopeAccount, "42", 1); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := g.Check(ctx, "42", ""); err != nil {
+		t.Errorf("expected the account to be unlocked, got %v", err)
+	}
+	last := (*events)[len(*events)-1]
+	if last.Type != EventUnlocked || last.ActorID != 1 {
+		t.Errorf("expected an unlocked event by administrator 1, got %+v", last)
+	}
+}
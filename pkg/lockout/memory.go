@@ -0,0 +1,73 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failure records in process memory. It is the default
+// for single-instance deployments; counters are lost on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	// retention is how long an idle record is kept before pruning
+	retention time.Duration
+}
+
+// NewMemoryStore creates an empty in-memory store. Records untouched for
+// retention are pruned; retention should be at least the guard's MaxLockout.
+func NewMemoryStore(retention time.Duration) *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record), retention: retention}
+}
+
+// Get returns the record for key, or nil if there is none.
+func (m *MemoryStore) Get(ctx context.Context, key string) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.records[key]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+// Update applies fn to the record for key under the store lock.
+func (m *MemoryStore) Update(ctx context.Context, key string, fn func(record *Record)) (*Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(time.Now())
+
+	record, ok := m.records[key]
+	if !ok {
+		record = Record{Key: key}
+	}
+	fn(&record)
+	m.records[key] = record
+	return &record, nil
+}
+
+// Reset removes the record for key.
+func (m *MemoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+// pruneLocked drops idle records so the store does not grow with every
+// address ever seen. Callers must hold m.mu.
+func (m *MemoryStore) pruneLocked(now time.Time) {
+	if len(m.records) < 10000 {
+		return
+	}
+	for key, record := range m.records {
+		lastActive := record.WindowStart
+		if record.LockedUntil.After(lastActive) {
+			lastActive = record.LockedUntil
+		}
+		if now.Sub(lastActive) >= m.retention {
+			delete(m.records, key)
+		}
+	}
+}
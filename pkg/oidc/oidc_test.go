@@ -0,0 +1,246 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testProvider is a stand-in OpenID provider. It remembers the PKCE
+// challenge and nonce of each authorization request and issues an RS256 ID
+// token when the matching code and verifier are redeemed.
+type testProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu       sync.Mutex
+	requests map[string]url.Values // code -> authorization request
+	subject  string
+	audience string
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p := &testProvider{t: t, key: key, kid: "key-1", requests: make(map[string]url.Values), subject: "user-123", audience: "client-1"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                           p.server.URL,
+			"authorization_endpoint":           p.server.URL + "/authorize",
+			"token_endpoint":                   p.server.URL + "/token",
+			"jwks_uri":                         p.server.URL + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": p.kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if id, secret, ok := r.BasicAuth(); !ok || id != "client-1" || secret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"error": "invalid_client"})
+			return
+		}
+
+		p.mu.Lock()
+		authz, ok := p.requests[r.PostForm.Get("code")]
+		delete(p.requests, r.PostForm.Get("code"))
+		p.mu.Unlock()
+		if !ok || CodeChallenge(r.PostForm.Get("code_verifier")) != authz.Get("code_challenge") ||
+			r.PostForm.Get("redirect_uri") != authz.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]string{"access_token": "at", "token_type": "Bearer", "id_token": p.idToken(authz.Get("nonce"))})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize simulates the user signing in and returns the issued code.
+func (p *testProvider) authorize(authURL string) string {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("invalid authorization URL: %v", err)
+	}
+	code := "code-" + u.Query().Get("state")
+	p.mu.Lock()
+	p.requests[code] = u.Query()
+	p.mu.Unlock()
+	return code
+}
+
+func (p *testProvider) idToken(nonce string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            p.subject,
+		"aud":            p.audience,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          "jane@example.com",
+		"email_verified": "true",
+		"name":           "Jane",
+	})
+	token.Header["kid"] = p.kid
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		p.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestClient(p *testProvider) *Provider {
+	return NewProvider(Config{
+		IssuerURL:    p.server.URL,
+		ClientID:     "client-1",
+		ClientSecret: "secret",
+		RedirectURL:  "http://app.test/callback",
+	})
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestProvider(t)
+	client := newTestClient(idp)
+
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-0123456789012345678901234567890123")
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+	if !strings.HasPrefix(authURL, idp.server.URL+"/authorize?") {
+		t.Fatalf("unexpected authorization URL %s", authURL)
+	}
+	query, _ := url.Parse(authURL)
+	if m := query.Query().Get("code_challenge_method"); m != "S256" {
+		t.Errorf("expected S256 PKCE, got %q", m)
+	}
+
+	code := idp.authorize(authURL)
+	claims, err := client.Exchange(ctx, code, "verifier-0123456789012345678901234567890123", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange failed: %v", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "jane@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+}
+
+func TestProvider_ExchangeRejectsWrongVerifier(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestProvider(t)
+	client := newTestClient(idp)
+
+	authURL, _ := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-a")
+	if _, err := client.Exchange(ctx, idp.authorize(authURL), "verifier-b", "nonce-1"); err == nil {
+		t.Fatal("expected exchange with the wrong PKCE verifier to fail")
+	}
+}
+
+func TestProvider_ExchangeRejectsNonceMismatch(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestProvider(t)
+	client := newTestClient(idp)
+
+	authURL, _ := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier")
+	_, err := client.Exchange(ctx, idp.authorize(authURL), "verifier", "nonce-2")
+	if !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("expected ErrNonceMismatch, got %v", err)
+	}
+}
+
+func TestProvider_VerifyIDTokenRejectsOtherAudience(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestProvider(t)
+	client := newTestClient(idp)
+	idp.audience = "someone-else"
+
+	_, err := client.VerifyIDToken(ctx, idp.idToken("n"), "n")
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("expected ErrInvalidIDToken, got %v", err)
+	}
+}
+
+func TestProvider_VerifyIDTokenFollowsKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	idp := newTestProvider(t)
+	client := newTestClient(idp)
+
+	if _, err := client.VerifyIDToken(ctx, idp.idToken("n"), "n"); err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	idp.mu.Lock()
+	idp.key, idp.kid = key, "key-2"
+	idp.mu.Unlock()
+	// Pretend the last fetch is old enough for an unknown kid to refresh
+	client.keys.fetchedAt = time.Time{}
+
+	if _, err := client.VerifyIDToken(ctx, idp.idToken("n"), "n"); err != nil {
+		t.Fatalf("expected the rotated key to be fetched, got %v", err)
+	}
+}
+
+func TestProvider_DiscoverRejectsIssuerMismatch(t *testing.T) {
+	// A server whose metadata claims to be a different issuer
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{
+			"issuer":                 "https://idp.example.com",
+			"authorization_endpoint": "https://idp.example.com/authorize",
+			"token_endpoint":         "https://idp.example.com/token",
+			"jwks_uri":               "https://idp.example.com/jwks",
+		})
+	}))
+	defer other.Close()
+	client := NewProvider(Config{IssuerURL: other.URL, ClientID: "client-1"})
+
+	if _, err := client.Discover(context.Background()); err == nil {
+		t.Fatal("expected discovery to fail when the issuer does not match")
+	}
+}
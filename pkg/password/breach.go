@@ -0,0 +1,163 @@
+package password
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// prefixLength is the number of hex characters of the SHA-1 used to
+	// select a range, as in the Pwned Passwords range API.
+	prefixLength = 5
+	hashLength   = sha1.Size * 2
+)
+
+// RangeSource returns the SHA-1 suffixes (upper-case hex, without the
+// prefix) of the breached passwords whose hash starts with prefix.
+type RangeSource interface {
+	Range(ctx context.Context, prefix string) ([]string, error)
+}
+
+// BreachChecker reports whether a password is known to be breached. Only
+// the first characters of the password's SHA-1 are passed to the source,
+// so a remote source would never learn the password.
+type BreachChecker struct {
+	source RangeSource
+}
+
+// NewBreachChecker creates a checker backed by source.
+func NewBreachChecker(source RangeSource) *BreachChecker {
+	return &BreachChecker{source: source}
+}
+
+// IsBreached reports whether password appears in the breached list.
+func (b *BreachChecker) IsBreached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	suffixes, err := b.source.Range(ctx, hash[:prefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[prefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// OpenBreachedList opens a local breached-password list. path is either a
+// file, loaded into memory, or a directory of range files named after the
+// 5-character hash prefix (e.g. "21BD1" or "21BD1.txt") as produced by the
+// Pwned Passwords downloader.
+//
+// A file holds one entry per line: a SHA-1 hash, optionally followed by
+// ":count", or a plain-text password, which is hashed on load. Range files
+// hold "SUFFIX:count" lines.
+func OpenBreachedList(path string) (*BreachChecker, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	if info.IsDir() {
+		return NewBreachChecker(rangeDir(path)), nil
+	}
+	source, err := loadRangeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewBreachChecker(source), nil
+}
+
+// memoryRanges is a breached list held in memory, indexed by prefix.
+type memoryRanges map[string][]string
+
+// Range implements RangeSource.
+func (m memoryRanges) Range(ctx context.Context, prefix string) ([]string, error) {
+	return m[prefix], nil
+}
+
+func loadRangeFile(path string) (memoryRanges, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password list: %w", err)
+	}
+	defer f.Close()
+
+	ranges := make(memoryRanges)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		if !isHex(hash, hashLength) {
+			sum := sha1.Sum([]byte(line))
+			hash = hex.EncodeToString(sum[:])
+		}
+		hash = strings.ToUpper(hash)
+		ranges[hash[:prefixLength]] = append(ranges[hash[:prefixLength]], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+	return ranges, nil
+}
+
+// rangeDir reads range files from a directory on every lookup.
+type rangeDir string
+
+// Range implements RangeSource.
+func (d rangeDir) Range(ctx context.Context, prefix string) ([]string, error) {
+	var (
+		f   *os.File
+		err error
+	)
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		f, err = os.Open(filepath.Join(string(d), name))
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open breached password range: %w", err)
+	}
+	defer f.Close()
+
+	var suffixes []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		suffix, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if isHex(suffix, hashLength-prefixLength) {
+			suffixes = append(suffixes, strings.ToUpper(suffix))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password range: %w", err)
+	}
+	return suffixes, nil
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+package password
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := Policy{MinLength: 8, MaxLength: 72, RequireDigit: true, MinCharClasses: 3, RejectUserInfo: true}
+
+	tests := []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"valid", "Correct7horse", true},
+		{"too short", "Ab1!", false},
+		{"too long", strings.Repeat("Ab1", 25), false},
+		{"missing digit", "CorrectHorse!", false},
+		{"too few classes", "correct7horse", false},
+		{"contains username", "Alice2024!x", false},
+		{"contains email local part", "XxJane.Doe1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, "alice", "jane.doe@example.com")
+			if tt.valid && err != nil {
+				t.Fatalf("expected %q to be valid, got %v", tt.password, err)
+			}
+			if !tt.valid && !errors.Is(err, ErrPolicy) {
+				t.Fatalf("expected %q to violate the policy, got %v", tt.password, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_ReportsEveryViolation(t *testing.T) {
+	err := Policy{MinLength: 10, RequireUpper: true, RequireSymbol: true}.Validate("short")
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || len(policyErr.Violations) != 3 {
+		t.Fatalf("expected three violations, got %v", err)
+	}
+}
+
+func TestBreachChecker_File(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2"))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# common passwords\npassword123\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":1337\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := OpenBreachedList(path)
+	if err != nil {
+		t.Fatalf("OpenBreachedList failed: %v", err)
+	}
+	for password, want := range map[string]bool{"hunter2": true, "password123": true, "Correct7horse": false} {
+		got, err := checker.IsBreached(context.Background(), password)
+		if err != nil || got != want {
+			t.Errorf("IsBreached(%q) = %v, %v; want %v", password, got, err, want)
+		}
+	}
+}
+
+func TestBreachChecker_RangeDirectory(t *testing.T) {
+	sum := sha1.Sum([]byte("hunter2"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte(hash[5:]+":12\r\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	checker, err := OpenBreachedList(dir)
+	if err != nil {
+		t.Fatalf("OpenBreachedList failed: %v", err)
+	}
+	if breached, err := checker.IsBreached(context.Background(), "hunter2"); err != nil || !breached {
+		t.Errorf("expected hunter2 to be breached, got %v %v", breached, err)
+	}
+	// A prefix without a range file is simply not breached
+	if breached, err := checker.IsBreached(context.Background(), "Correct7horse"); err != nil || breached {
+		t.Errorf("expected a clean password, got %v %v", breached, err)
+	}
+}
@@ -0,0 +1,134 @@
+// Package password checks new passwords against a configurable policy and
+// a local list of breached passwords.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrPolicy is matched by every *PolicyError.
+	ErrPolicy = errors.New("password does not meet the password policy")
+	// ErrBreached is returned for passwords found in the breached list.
+	ErrBreached = errors.New("password has appeared in a data breach, choose a different one")
+	// ErrReused is returned for one of the user's recent passwords.
+	ErrReused = errors.New("password was used recently, choose a different one")
+)
+
+// minRelatedLength is the shortest username or email part checked for in a
+// password; shorter ones match too many unrelated passwords.
+const minRelatedLength = 3
+
+// Policy describes what a new password must look like. MaxLength is counted
+// in bytes because bcrypt ignores everything past 72 bytes; the other
+// lengths are counted in characters.
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	MinCharClasses int  // Of upper, lower, digit and symbol
+	RejectUserInfo bool // Reject passwords containing the username or email
+}
+
+// PolicyError lists every rule a password broke.
+type PolicyError struct {
+	Violations []string
+}
+
+// Error implements error.
+func (e *PolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, ", ")
+}
+
+// Is makes errors.Is(err, ErrPolicy) match.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicy
+}
+
+// Validate checks password against the policy. related holds the username,
+// email and similar values the password must not contain. It returns a
+// *PolicyError or nil.
+func (p Policy) Validate(password string, related ...string) error {
+	var violations []string
+
+	if p.MinLength > 0 && utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.MaxLength))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		violations = append(violations, "must contain a symbol")
+	}
+	if classes := countTrue(upper, lower, digit, symbol); classes < p.MinCharClasses {
+		violations = append(violations, fmt.Sprintf("must mix at least %d of uppercase letters, lowercase letters, digits and symbols", p.MinCharClasses))
+	}
+
+	if p.RejectUserInfo && containsRelated(password, related) {
+		violations = append(violations, "must not contain your username or email address")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsRelated reports whether the password contains, or is contained
+// in, one of the related values. Email addresses are checked by local part.
+func containsRelated(password string, related []string) bool {
+	lowered := strings.ToLower(password)
+	for _, value := range related {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if local, _, ok := strings.Cut(value, "@"); ok {
+			value = local
+		}
+		if utf8.RuneCountInString(value) < minRelatedLength {
+			continue
+		}
+		if strings.Contains(lowered, value) || strings.Contains(value, lowered) {
+			return true
+		}
+	}
+	return false
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMemoryStoreSize is how many counters a MemoryStore keeps at most
+	DefaultMemoryStoreSize = 100000
+	// memoryPruneInterval is how often expired counters are dropped
+	memoryPruneInterval = time.Minute
+)
+
+// MemoryStore keeps counters in process memory. It is the default for
+// single-instance deployments; counters are lost on restart and each
+// instance of a cluster counts separately.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryEntry
+	size      int
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type memoryEntry struct {
+	state     State
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store holding up to
+// DefaultMemoryStoreSize counters.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		size:    DefaultMemoryStoreSize,
+		now:     time.Now,
+	}
+}
+
+// Update applies fn to the state of key under the store lock.
+func (m *MemoryStore) Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if now.Sub(m.lastPrune) >= memoryPruneInterval {
+		m.pruneLocked(now)
+		m.lastPrune = now
+	}
+
+	entry, ok := m.entries[key]
+	if !ok || now.After(entry.expiresAt) {
+		entry = memoryEntry{}
+	}
+	if !ok && len(m.entries) >= m.size {
+		// Full of live counters: evict an arbitrary one rather than scan
+		for k := range m.entries {
+			delete(m.entries, k)
+			break
+		}
+	}
+	fn(&entry.state)
+	entry.expiresAt = now.Add(ttl)
+	m.entries[key] = entry
+	state := entry.state
+	return &state, nil
+}
+
+// pruneLocked drops expired counters so the store does not grow with every
+// address ever seen. Callers must hold m.mu.
+func (m *MemoryStore) pruneLocked(now time.Time) {
+	for key, entry := range m.entries {
+		if now.After(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// Package ratelimit limits how often a subject (an API key, a user, an
+// organization or a client IP) may make requests. Short-term rates are
+// enforced with a token bucket or a sliding window; monthly quotas count the
+// requests of each calendar month (UTC).
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm selects how short-term rates are enforced.
+type Algorithm string
+
+const (
+	// TokenBucket refills Requests tokens per Window up to Burst, so idle
+	// subjects may send a burst at once.
+	TokenBucket Algorithm = "token_bucket"
+	// SlidingWindow allows Requests per Window, weighting the previous
+	// window's count by how much of it still overlaps the sliding window.
+	SlidingWindow Algorithm = "sliding_window"
+)
+
+// ErrUnknownAlgorithm is returned for unsupported algorithms.
+var ErrUnknownAlgorithm = errors.New("unknown rate limit algorithm")
+
+// Policy is the limit applied to a subject.
+type Policy struct {
+	Requests     int           // Requests per Window; 0 disables the rate limit
+	Window       time.Duration // Defaults to one second
+	Burst        int           // Token bucket capacity; Requests when 0
+	MonthlyQuota int64         // Requests per calendar month; 0 is unlimited
+}
+
+// Override replaces parts of a policy for one subject, e.g. the limits
+// stored on an API key. Nil fields keep the default.
+type Override struct {
+	Requests     *int
+	Burst        *int
+	MonthlyQuota *int64
+}
+
+// With returns p with the fields set in o replaced.
+func (p Policy) With(o *Override) Policy {
+	if o == nil {
+		return p
+	}
+	if o.Requests != nil {
+		p.Requests = *o.Requests
+	}
+	if o.Burst != nil {
+		p.Burst = *o.Burst
+	}
+	if o.MonthlyQuota != nil {
+		p.MonthlyQuota = *o.MonthlyQuota
+	}
+	return p
+}
+
+// State is the stored counter of one key. Token buckets use Tokens; sliding
+// windows and quotas count requests in the window starting at WindowStart.
+type State struct {
+	Tokens      float64
+	Count       int64
+	Previous    int64 // Count of the window before WindowStart
+	WindowStart time.Time
+	UpdatedAt   time.Time
+}
+
+// Store persists counters. Implementations must run Update atomically per
+// key so that concurrent requests across instances are all counted; a
+// shared store maps a State onto a database row or a Redis hash updated in
+// a transaction or script. Counters idle for ttl may be dropped.
+type Store interface {
+	// Update loads the state of key (a zero State if there is none or it
+	// expired), applies fn to it and saves the result.
+	Update(ctx context.Context, key string, ttl time.Duration, fn func(state *State)) (*State, error)
+}
+
+// Result is the outcome of counting one request.
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration // Until the full limit is available again
+	RetryAfter time.Duration // Until the next request is allowed, when denied
+	Policy     string        // RateLimit-Policy item, e.g. "100;w=1;burst=50"
+}
+
+// Limiter applies policies on top of a Store.
+type Limiter struct {
+	store     Store
+	algorithm Algorithm
+	now       func() time.Time
+}
+
+// NewLimiter creates a limiter enforcing rates with the given algorithm.
+func NewLimiter(store Store, algorithm Algorithm) (*Limiter, error) {
+	switch algorithm {
+	case TokenBucket, SlidingWindow:
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+	return &Limiter{store: store, algorithm: algorithm, now: time.Now}, nil
+}
+
+// Allow counts a request of key against the rate of p.
+func (l *Limiter) Allow(ctx context.Context, key string, p Policy) (Result, error) {
+	if p.Window <= 0 {
+		p.Window = time.Second
+	}
+	now := l.now()
+	if l.algorithm == SlidingWindow {
+		return l.slidingWindow(ctx, key, p, now)
+	}
+	return l.tokenBucket(ctx, key, p, now)
+}
+
+func (l *Limiter) tokenBucket(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	capacity := float64(p.Burst)
+	if p.Burst <= 0 {
+		capacity = float64(p.Requests)
+	}
+	perSecond := float64(p.Requests) / p.Window.Seconds()
+	// Time to refill an empty bucket
+	refill := time.Duration(capacity / perSecond * float64(time.Second))
+
+	var allowed bool
+	state, err := l.store.Update(ctx, key, refill+p.Window, func(s *State) {
+		if s.UpdatedAt.IsZero() {
+			s.Tokens = capacity
+		} else if elapsed := now.Sub(s.UpdatedAt).Seconds(); elapsed > 0 {
+			s.Tokens = math.Min(capacity, s.Tokens+elapsed*perSecond)
+		}
+		s.UpdatedAt = now
+		if s.Tokens >= 1 {
+			s.Tokens--
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int64(capacity),
+		Remaining: int64(math.Floor(state.Tokens)),
+		Reset:     seconds((capacity - state.Tokens) / perSecond),
+		Policy:    fmt.Sprintf("%d;w=%d;burst=%d", p.Requests, windowSeconds(p.Window), int64(capacity)),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - state.Tokens) / perSecond)
+	}
+	return result, nil
+}
+
+func (l *Limiter) slidingWindow(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	current := now.Truncate(p.Window)
+	elapsed := now.Sub(current)
+	weight := 1 - float64(elapsed)/float64(p.Window)
+	limit := float64(p.Requests)
+
+	var allowed bool
+	var estimate float64
+	state, err := l.store.Update(ctx, key, 2*p.Window, func(s *State) {
+		if !s.WindowStart.Equal(current) {
+			if s.WindowStart.Equal(current.Add(-p.Window)) {
+				s.Previous = s.Count
+			} else {
+				s.Previous = 0
+			}
+			s.Count = 0
+			s.WindowStart = current
+		}
+		s.UpdatedAt = now
+		estimate = float64(s.Previous)*weight + float64(s.Count)
+		if estimate+1 <= limit {
+			s.Count++
+			estimate++
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int64(p.Requests),
+		Remaining: max(0, int64(p.Requests)-int64(math.Ceil(estimate))),
+		Reset:     p.Window - elapsed,
+		Policy:    fmt.Sprintf("%d;w=%d", p.Requests, windowSeconds(p.Window)),
+	}
+	if !allowed {
+		// The previous window's share shrinks as the window slides; wait
+		// until it leaves room for one request or the window ends
+		result.RetryAfter = result.Reset
+		if state.Previous > 0 {
+			room := (limit - 1 - float64(state.Count)) / float64(state.Previous)
+			if wait := time.Duration((1-room)*float64(p.Window)) - elapsed; room > 0 && wait < result.RetryAfter {
+				result.RetryAfter = wait
+			}
+		}
+	}
+	return result, nil
+}
+
+// AllowQuota counts a request of key against a monthly quota.
+func (l *Limiter) AllowQuota(ctx context.Context, key string, quota int64) (Result, error) {
+	now := l.now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	next := month.AddDate(0, 1, 0)
+
+	var allowed bool
+	state, err := l.store.Update(ctx, key, next.Sub(now)+24*time.Hour, func(s *State) {
+		if !s.WindowStart.Equal(month) {
+			s.Count = 0
+			s.WindowStart = month
+		}
+		s.UpdatedAt = now
+		if s.Count < quota {
+			s.Count++
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     quota,
+		Remaining: max(0, quota-state.Count),
+		Reset:     next.Sub(now),
+		Policy:    fmt.Sprintf("%d;w=%d", quota, windowSeconds(next.Sub(month))),
+	}
+	if !allowed {
+		result.RetryAfter = result.Reset
+	}
+	return result, nil
+}
+
+// WriteHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers from the most restrictive result, the
+// RateLimit-Policy header from all of them and Retry-After if a request was
+// denied.
+func WriteHeaders(h http.Header, results ...Result) {
+	if len(results) == 0 {
+		return
+	}
+	binding := results[0]
+	policies := make([]string, len(results))
+	for i, r := range results {
+		policies[i] = r.Policy
+		if !binding.Allowed {
+			continue
+		}
+		if !r.Allowed || r.Remaining < binding.Remaining {
+			binding = r
+		}
+	}
+
+	h.Set("RateLimit-Limit", strconv.FormatInt(binding.Limit, 10))
+	h.Set("RateLimit-Remaining", strconv.FormatInt(binding.Remaining, 10))
+	h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(binding.Reset), 10))
+	h.Set("RateLimit-Policy", strings.Join(policies, ", "))
+	if !binding.Allowed {
+		h.Set("Retry-After", strconv.FormatInt(max(1, ceilSeconds(binding.RetryAfter)), 10))
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(0, s) * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+func windowSeconds(d time.Duration) int64 {
+	return max(1, ceilSeconds(d))
+}
@@ -0,0 +1,186 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, algorithm Algorithm, now *time.Time) *Limiter {
+	t.Helper()
+	l, err := NewLimiter(NewMemoryStore(), algorithm)
+	if err != nil {
+		t.Fatalf("NewLimiter failed: %v", err)
+	}
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func allow(t *testing.T, l *Limiter, p Policy) Result {
+	t.Helper()
+	r, err := l.Allow(context.Background(), "key:1", p)
+	if err != nil {
+		t.Fatalf("Allow failed: %v", err)
+	}
+	return r
+}
+
+func TestNewLimiter_RejectsUnknownAlgorithm(t *testing.T) {
+	if _, err := NewLimiter(NewMemoryStore(), "leaky"); err == nil {
+		t.Error("Expected an error for an unknown algorithm")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(t, TokenBucket, &now)
+	p := Policy{Requests: 10, Window: time.Second, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		if r := allow(t, l, p); !r.Allowed || r.Remaining != int64(2-i) {
+			t.Fatalf("Request %d: expected to be allowed with %d remaining, got %+v", i, 2-i, r)
+		}
+	}
+	r := allow(t, l, p)
+	if r.Allowed || r.RetryAfter != 100*time.Millisecond || r.Limit != 3 || r.Policy != "10;w=1;burst=3" {
+		t.Fatalf("Expected the empty bucket to deny, got %+v", r)
+	}
+
+	// One token every 100ms
+	now = now.Add(100 * time.Millisecond)
+	if r := allow(t, l, p); !r.Allowed || r.Remaining != 0 {
+		t.Errorf("Expected a refilled token, got %+v", r)
+	}
+	now = now.Add(time.Hour)
+	if r := allow(t, l, p); !r.Allowed || r.Remaining != 2 {
+		t.Errorf("Expected the bucket to refill only up to its burst, got %+v", r)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	l := newTestLimiter(t, SlidingWindow, &now)
+	p := Policy{Requests: 4, Window: time.Minute}
+
+	for i := 0; i < 4; i++ {
+		if r := allow(t, l, p); !r.Allowed {
+			t.Fatalf("Request %d: expected to be allowed, got %+v", i, r)
+		}
+	}
+	if r := allow(t, l, p); r.Allowed || r.Remaining != 0 || r.Reset != time.Minute {
+		t.Fatalf("Expected the full window to deny, got %+v", r)
+	}
+
+	// A quarter into the next window 3 of the previous 4 requests still
+	// count, leaving room for one
+	now = now.Add(75 * time.Second)
+	if r := allow(t, l, p); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("Expected one request to be allowed, got %+v", r)
+	}
+	r := allow(t, l, p)
+	if r.Allowed {
+		t.Fatalf("Expected the sliding window to deny, got %+v", r)
+	}
+	// 4*(1-x)+1 <= 3 once half of the window has passed
+	if r.RetryAfter != 15*time.Second {
+		t.Errorf("Expected to retry after 15s, got %s", r.RetryAfter)
+	}
+
+	now = now.Add(3 * time.Minute)
+	if r := allow(t, l, p); !r.Allowed || r.Remaining != 3 {
+		t.Errorf("Expected old windows to be forgotten, got %+v", r)
+	}
+}
+
+func TestAllowQuota(t *testing.T) {
+	now := time.Date(2025, 10, 31, 23, 0, 0, 0, time.UTC)
+	l := newTestLimiter(t, TokenBucket, &now)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if r, err := l.AllowQuota(ctx, "quota:key:1", 2); err != nil || !r.Allowed {
+			t.Fatalf("Request %d: expected to be allowed, got %+v %v", i, r, err)
+		}
+	}
+	r, err := l.AllowQuota(ctx, "quota:key:1", 2)
+	if err != nil || r.Allowed || r.RetryAfter != time.Hour {
+		t.Fatalf("Expected the exhausted quota to deny until the month ends, got %+v %v", r, err)
+	}
+
+	now = now.Add(time.Hour)
+	if r, err := l.AllowQuota(ctx, "quota:key:1", 2); err != nil || !r.Allowed || r.Remaining != 1 {
+		t.Errorf("Expected the quota to reset with the month, got %+v %v", r, err)
+	}
+}
+
+func TestPolicyWith(t *testing.T) {
+	requests, quota := 5, int64(0)
+	p := Policy{Requests: 100, Burst: 50, MonthlyQuota: 1000}.With(&Override{Requests: &requests, MonthlyQuota: &quota})
+	if p.Requests != 5 || p.Burst != 50 || p.MonthlyQuota != 0 {
+		t.Errorf("Unexpected policy %+v", p)
+	}
+}
+
+func TestWriteHeaders(t *testing.T) {
+	h := http.Header{}
+	WriteHeaders(h,
+		Result{Allowed: true, Limit: 50, Remaining: 40, Reset: 1500 * time.Millisecond, Policy: "100;w=1;burst=50"},
+		Result{Allowed: true, Limit: 1000, Remaining: 3, Reset: time.Hour, Policy: "1000;w=2592000"},
+	)
+	if h.Get("RateLimit-Limit") != "1000" || h.Get("RateLimit-Remaining") != "3" || h.Get("RateLimit-Reset") != "3600" {
+		t.Errorf("Expected the quota to bind, got %v", h)
+	}
+	if h.Get("RateLimit-Policy") != "100;w=1;burst=50, 1000;w=2592000" || h.Get("Retry-After") != "" {
+		t.Errorf("Unexpected headers %v", h)
+	}
+
+	h = http.Header{}
+	WriteHeaders(h, Result{Allowed: false, Limit: 50, Reset: 2 * time.Second, RetryAfter: 10 * time.Millisecond, Policy: "100;w=1;burst=50"})
+	if h.Get("RateLimit-Remaining") != "0" || h.Get("Retry-After") != "1" {
+		t.Errorf("Unexpected headers for a denied request %v", h)
+	}
+}
+
+func TestMemoryStore_PrunesPeriodicallyAndStaysBounded(t *testing.T) {
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	m.size = 3
+	ctx := context.Background()
+	touch := func(key string, ttl time.Duration) {
+		t.Helper()
+		if _, err := m.Update(ctx, key, ttl, func(s *State) { s.Tokens++ }); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+	}
+
+	touch("ip:a", time.Second)
+	touch("ip:b", time.Hour)
+	touch("ip:c", time.Hour)
+	touch("ip:d", time.Hour)
+	if len(m.entries) != 3 {
+		t.Fatalf("Expected the store to stay at its size, got %d entries", len(m.entries))
+	}
+	if _, ok := m.entries["ip:d"]; !ok {
+		t.Error("Expected the new counter to be kept")
+	}
+
+	// Expired counters are dropped once per prune interval, not on every update
+	m.size = 100
+	now = now.Add(2 * time.Second)
+	touch("ip:e", time.Second)
+	now = now.Add(2 * time.Second)
+	touch("ip:f", time.Hour)
+	if _, ok := m.entries["ip:e"]; !ok {
+		t.Error("Expected no prune before the interval")
+	}
+	now = now.Add(memoryPruneInterval)
+	touch("ip:f", time.Hour)
+	if _, ok := m.entries["ip:e"]; ok {
+		t.Error("Expected the expired counter to be pruned")
+	}
+	if _, ok := m.entries["ip:f"]; !ok {
+		t.Error("Expected the live counter to be kept")
+	}
+}
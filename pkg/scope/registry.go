@@ -0,0 +1,93 @@
+package scope
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry lists the resources and actions scopes may refer to. Requested
+// scopes are validated against it when an API key is created, so a typo is
+// rejected instead of silently granting nothing.
+type Registry struct {
+	mu        sync.RWMutex
+	resources map[string]map[string]bool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{resources: make(map[string]map[string]bool)}
+}
+
+// Default is the registry routes declare their scopes in.
+var Default = NewRegistry()
+
+// Register adds a resource and its actions.
+func (r *Registry) Register(resource string, actions ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	set := r.resources[resource]
+	if set == nil {
+		set = make(map[string]bool)
+		r.resources[resource] = set
+	}
+	for _, action := range actions {
+		set[action] = true
+	}
+}
+
+// Register adds a resource and its actions to the default registry.
+func Register(resource string, actions ...string) {
+	Default.Register(resource, actions...)
+}
+
+// Known reports whether the resource and action are registered.
+// Wildcards are always known.
+func (r *Registry) Known(resource, action string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if resource == Wildcard {
+		return true
+	}
+	actions, ok := r.resources[resource]
+	if !ok {
+		return false
+	}
+	return action == Wildcard || actions[action]
+}
+
+// Validate parses the scopes and checks them against the registry. It
+// returns the scopes in canonical form, without duplicates.
+func (r *Registry) Validate(scopes []string) ([]string, error) {
+	out := make([]string, 0, len(scopes))
+	seen := make(map[string]bool, len(scopes))
+	for _, s := range scopes {
+		sc, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if !r.Known(sc.Resource, sc.Action) {
+			return nil, fmt.Errorf("%w %q: unknown resource or action", ErrInvalid, s)
+		}
+		canonical := sc.String()
+		if !seen[canonical] {
+			seen[canonical] = true
+			out = append(out, canonical)
+		}
+	}
+	return out, nil
+}
+
+// Scopes lists every registered resource:action pair, sorted.
+func (r *Registry) Scopes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []string
+	for resource, actions := range r.resources {
+		for action := range actions {
+			out = append(out, resource+":"+action)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,282 @@
+// Package scope implements the permission scopes granted to API keys.
+//
+// A scope names an action on a resource, optionally narrowed to one resource
+// ID and to one organization:
+//
+//	organizations:read       read any organization
+//	teams:7:write            write team 7
+//	teams:*:write            write any team
+//	teams:*                  every action on teams
+//	org:42/teams:read        read teams within organization 42
+//	org:42/*                 everything within organization 42
+//	*                        everything
+//
+// Routes declare the scope they require with the same syntax, using {param}
+// placeholders for path parameters, e.g. "org:{id}/organizations:{id}:read".
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Wildcard matches any resource, ID, action or organization.
+const Wildcard = "*"
+
+// ErrInvalid is returned for scopes that do not follow the scope syntax.
+var ErrInvalid = errors.New("invalid scope")
+
+// segmentPattern limits scope segments to safe identifier characters
+var segmentPattern = regexp.MustCompile(`^(\*|[a-z0-9][a-z0-9_.-]*)$`)
+
+// Scope is a parsed scope. Empty Org and ID mean the scope is not narrowed
+// to an organization or resource ID.
+type Scope struct {
+	Org      string
+	Resource string
+	ID       string
+	Action   string
+}
+
+// Parse parses a scope string.
+func Parse(s string) (Scope, error) {
+	var sc Scope
+	rest := strings.TrimSpace(s)
+	if rest == Wildcard {
+		return Scope{Resource: Wildcard, Action: Wildcard}, nil
+	}
+
+	if org, inner, found := strings.Cut(rest, "/"); found {
+		kind, id, ok := strings.Cut(org, ":")
+		if !ok || kind != "org" || !validSegment(id) {
+			return Scope{}, fmt.Errorf("%w %q: expected org:<id>/ prefix", ErrInvalid, s)
+		}
+		sc.Org = id
+		rest = inner
+		if rest == Wildcard {
+			sc.Resource, sc.Action = Wildcard, Wildcard
+			return sc, nil
+		}
+	}
+
+	parts := strings.Split(rest, ":")
+	switch len(parts) {
+	case 2:
+		sc.Resource, sc.Action = parts[0], parts[1]
+	case 3:
+		sc.Resource, sc.ID, sc.Action = parts[0], parts[1], parts[2]
+	default:
+		return Scope{}, fmt.Errorf("%w %q: expected resource:action or resource:id:action", ErrInvalid, s)
+	}
+	for _, segment := range parts {
+		if !validSegment(segment) {
+			return Scope{}, fmt.Errorf("%w %q", ErrInvalid, s)
+		}
+	}
+	if sc.Resource == Wildcard && sc.ID != "" {
+		return Scope{}, fmt.Errorf("%w %q: an ID requires a resource", ErrInvalid, s)
+	}
+	return sc, nil
+}
+
+func validSegment(segment string) bool {
+	return segmentPattern.MatchString(segment)
+}
+
+// String formats the scope in its canonical form.
+func (s Scope) String() string {
+	var b strings.Builder
+	if s.Org != "" {
+		b.WriteString("org:" + s.Org + "/")
+		if s.Resource == Wildcard && s.Action == Wildcard {
+			b.WriteString(Wildcard)
+			return b.String()
+		}
+	} else if s.Resource == Wildcard && s.Action == Wildcard {
+		return Wildcard
+	}
+	b.WriteString(s.Resource)
+	if s.ID != "" {
+		b.WriteString(":" + s.ID)
+	}
+	b.WriteString(":" + s.Action)
+	return b.String()
+}
+
+// Grants reports whether a key holding scope s may perform the required
+// scope. Each part of s is either a wildcard, left out (any ID, any
+// organization) or must equal the required part. A grant narrowed to an
+// ID or organization never covers a request that has none.
+func (s Scope) Grants(required Scope) bool {
+	return matchPart(s.Org, required.Org) &&
+		matchPart(s.Resource, required.Resource) &&
+		matchPart(s.ID, required.ID) &&
+		matchPart(s.Action, required.Action)
+}
+
+func matchPart(granted, required string) bool {
+	return granted == "" || granted == Wildcard || granted == required
+}
+
+// Intersect returns the scope covering exactly what both a and b grant, or
+// false when they have nothing in common. It is used to bound a key's
+// scopes by the permissions of a role.
+func Intersect(a, b Scope) (Scope, bool) {
+	var out Scope
+	var ok bool
+	if out.Org, ok = meetPart(a.Org, b.Org); !ok {
+		return Scope{}, false
+	}
+	if out.Resource, ok = meetPart(a.Resource, b.Resource); !ok {
+		return Scope{}, false
+	}
+	if out.ID, ok = meetPart(a.ID, b.ID); !ok {
+		return Scope{}, false
+	}
+	if out.Action, ok = meetPart(a.Action, b.Action); !ok {
+		return Scope{}, false
+	}
+	return out, true
+}
+
+// meetPart returns the narrower of two scope parts
+func meetPart(a, b string) (string, bool) {
+	switch {
+	case a == "" || a == Wildcard:
+		if b == "" {
+			return a, true
+		}
+		return b, true
+	case b == "" || b == Wildcard || a == b:
+		return a, true
+	default:
+		return "", false
+	}
+}
+
+// Allows reports whether any of the granted scopes covers the required
+// scope. Grants that do not parse are ignored.
+func Allows(granted []string, required Scope) bool {
+	for _, g := range granted {
+		sc, err := Parse(g)
+		if err != nil {
+			continue
+		}
+		if sc.Grants(required) {
+			return true
+		}
+	}
+	return false
+}
+
+// Template is a route's required scope with {param} placeholders for the
+// organization and resource ID.
+type Template struct {
+	raw    string
+	scope  Scope
+	params [2]string // path parameters filling Org and ID, if any
+}
+
+// placeholderPattern matches a {param} placeholder
+var placeholderPattern = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// ParseTemplate parses a required scope. The organization and ID may be
+// {param} placeholders; the resource and action must be concrete.
+func ParseTemplate(s string) (Template, error) {
+	t := Template{raw: s}
+	sc, err := parseWithPlaceholders(s, &t.params)
+	if err != nil {
+		return Template{}, err
+	}
+	if sc.Resource == Wildcard || sc.Action == Wildcard || sc.Org == Wildcard || sc.ID == Wildcard {
+		return Template{}, fmt.Errorf("%w %q: required scopes cannot contain wildcards", ErrInvalid, s)
+	}
+	t.scope = sc
+	return t, nil
+}
+
+// parseWithPlaceholders parses s with stand-ins for the {param} placeholders
+// in the organization and ID positions, which are not valid segments, and
+// records their parameter names
+func parseWithPlaceholders(s string, params *[2]string) (Scope, error) {
+	org, rest, hasOrg := strings.Cut(s, "/")
+	if !hasOrg {
+		rest, org = s, ""
+	}
+
+	var orgParam string
+	if hasOrg {
+		kind, id, ok := strings.Cut(org, ":")
+		if ok && kind == "org" {
+			if m := placeholderPattern.FindStringSubmatch(id); m != nil {
+				orgParam = m[1]
+				org = "org:0"
+			}
+		}
+	}
+
+	var idParam string
+	parts := strings.Split(rest, ":")
+	if len(parts) == 3 {
+		if m := placeholderPattern.FindStringSubmatch(parts[1]); m != nil {
+			idParam = m[1]
+			parts[1] = "0"
+		}
+	}
+	rest = strings.Join(parts, ":")
+	if hasOrg {
+		rest = org + "/" + rest
+	}
+
+	sc, err := Parse(rest)
+	if err != nil {
+		return Scope{}, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+	if orgParam != "" {
+		sc.Org = ""
+	}
+	if idParam != "" {
+		sc.ID = ""
+	}
+	params[0], params[1] = orgParam, idParam
+	return sc, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics on error. It is meant
+// for route declarations.
+func MustParseTemplate(s string) Template {
+	t, err := ParseTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Resource returns the resource the template requires an action on.
+func (t Template) Resource() string {
+	return t.scope.Resource
+}
+
+// Action returns the required action.
+func (t Template) Action() string {
+	return t.scope.Action
+}
+
+// String returns the template as declared.
+func (t Template) String() string {
+	return t.raw
+}
+
+// Resolve fills the placeholders using param, typically gin's c.Param.
+func (t Template) Resolve(param func(string) string) Scope {
+	sc := t.scope
+	if t.params[0] != "" {
+		sc.Org = param(t.params[0])
+	}
+	if t.params[1] != "" {
+		sc.ID = param(t.params[1])
+	}
+	return sc
+}
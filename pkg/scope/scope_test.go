@@ -0,0 +1,127 @@
+package scope
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse_Canonical(t *testing.T) {
+	cases := map[string]string{
+		"*":                   "*",
+		"organizations:read":  "organizations:read",
+		" teams:*:write ":     "teams:*:write",
+		"org:42/*":            "org:42/*",
+		"org:42/teams:7:read": "org:42/teams:7:read",
+	}
+	for in, want := range cases {
+		sc, err := Parse(in)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", in, err)
+			continue
+		}
+		if got := sc.String(); got != want {
+			t.Errorf("Parse(%q).String() = %q, want %q", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "read", "a:b:c:d", "team:7/teams:read", "org:/teams:read", "Teams:read", "*:7:read", "teams::read"} {
+		if _, err := Parse(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected Parse(%q) to fail, got %v", in, err)
+		}
+	}
+}
+
+func TestGrants_HierarchicalWildcards(t *testing.T) {
+	required := MustParseTemplate("org:{org}/teams:{id}:write").Resolve(func(name string) string {
+		return map[string]string{"org": "42", "id": "7"}[name]
+	})
+
+	allowed := []string{"*", "teams:write", "teams:*", "teams:*:write", "teams:7:write", "org:42/*", "org:42/teams:*:write"}
+	for _, g := range allowed {
+		if !Allows([]string{g}, required) {
+			t.Errorf("Expected %q to grant %s", g, required)
+		}
+	}
+
+	denied := []string{"teams:read", "teams:8:write", "org:43/*", "organizations:*", "org:42/teams:read", "not a scope"}
+	for _, g := range denied {
+		if Allows([]string{g}, required) {
+			t.Errorf("Expected %q not to grant %s", g, required)
+		}
+	}
+}
+
+func TestGrants_NarrowedGrantNeedsContext(t *testing.T) {
+	// Listing organizations has neither an organization nor an ID
+	list := MustParseTemplate("organizations:read").Resolve(func(string) string { return "" })
+	if Allows([]string{"org:42/*"}, list) {
+		t.Error("Expected an organization-scoped key not to list all organizations")
+	}
+	if Allows([]string{"organizations:42:read"}, list) {
+		t.Error("Expected an ID-scoped key not to list all organizations")
+	}
+	if !Allows([]string{"organizations:read"}, list) {
+		t.Error("Expected organizations:read to list organizations")
+	}
+}
+
+func TestParseTemplate_RejectsWildcards(t *testing.T) {
+	for _, in := range []string{"*", "teams:*", "org:*/teams:read", "teams:*:read"} {
+		if _, err := ParseTemplate(in); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected ParseTemplate(%q) to fail, got %v", in, err)
+		}
+	}
+}
+
+func TestRegistry_Validate(t *testing.T) {
+	r := NewRegistry()
+	r.Register("organizations", "read", "write")
+
+	got, err := r.Validate([]string{"organizations:read", " organizations:read", "org:1/organizations:*", "*"})
+	if err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if len(got) != 3 {
+		t.Errorf("Expected duplicates to be removed, got %v", got)
+	}
+
+	for _, s := range []string{"organizations:delete", "teams:read", "organisations:read"} {
+		if _, err := r.Validate([]string{s}); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Expected %q to be rejected, got %v", s, err)
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	role := func(s string) Scope {
+		sc, err := Parse(s)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", s, err)
+		}
+		return sc
+	}
+	cases := []struct {
+		a, b, want string
+	}{
+		{"*", "teams:read", "teams:read"},
+		{"teams:*", "teams:read", "teams:read"},
+		{"teams:7:*", "teams:write", "teams:7:write"},
+		{"teams:read", "org:42/*", "org:42/teams:read"},
+		{"org:42/teams:*", "org:42/teams:7:read", "org:42/teams:7:read"},
+		{"teams:read", "teams:write", ""},
+		{"org:42/*", "org:43/*", ""},
+		{"teams:7:read", "teams:8:read", ""},
+	}
+	for _, tc := range cases {
+		got, ok := Intersect(role(tc.a), role(tc.b))
+		if tc.want == "" {
+			if ok {
+				t.Errorf("Intersect(%q, %q) = %s, want none", tc.a, tc.b, got)
+			}
+			continue
+		}
+		if !ok || got.String() != tc.want {
+			t.Errorf("Intersect(%q, %q) = %s, %v, want %s", tc.a, tc.b, got, ok, tc.want)
+		}
+	}
+}
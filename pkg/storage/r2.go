@@ -95,11 +95,20 @@ func (s *R2Storage) UploadFile(data []byte, fileName string, contentType string)
 
 	// Return the file URL
 	if s.publicURL != "" {
-		return fmt.Sprintf("%s/%s", strings.TrimRight(s.publicURL, "/"), url.PathEscape(fileName)), nil
+		return fmt.Sprintf("%s/%s", strings.TrimRight(s.publicURL, "/"), escapeKey(fileName)), nil
 	}
 
 	// Use the S3-compatible URL format if no public URL is configured
-	return fmt.Sprintf("https://%s.%s/%s", s.bucket, strings.TrimPrefix(strings.TrimPrefix(s.client.Endpoint, "https://"), "http://"), url.PathEscape(fileName)), nil
+	return fmt.Sprintf("https://%s.%s/%s", s.bucket, strings.TrimPrefix(strings.TrimPrefix(s.client.Endpoint, "https://"), "http://"), escapeKey(fileName)), nil
+}
+
+// escapeKey escapes each segment of an object key, keeping the slashes
+func escapeKey(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
 }
 
 // GetFileURL returns the public URL for a file
@@ -0,0 +1,10 @@
+package storage
+
+// Store is the object storage that uploaded files are written to
+type Store interface {
+	// UploadFile stores data under fileName and returns its public URL
+	UploadFile(data []byte, fileName string, contentType string) (string, error)
+	DeleteFile(fileName string) error
+}
+
+var _ Store = (*R2Storage)(nil)
@@ -15,6 +15,7 @@ const (
 var src = rand.NewSource(time.Now().UnixNano())
 
 // GenerateRandomString generates a random string of the specified length
+// It uses math/rand and must not be used for secrets; see GenerateSecureToken
 func GenerateRandomString(n int) string {
 	b := make([]byte, n)
 	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
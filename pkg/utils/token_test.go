@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignToken_RoundTrip(t *testing.T) {
+	secret := []byte("secret")
+	signed := SignToken(secret, []byte(`{"uid":1}`))
+
+	payload, err := VerifySignedToken(secret, signed)
+	if err != nil {
+		t.Fatalf("VerifySignedToken failed: %v", err)
+	}
+	if string(payload) != `{"uid":1}` {
+		t.Errorf("Unexpected payload: %s", payload)
+	}
+}
+
+func TestVerifySignedToken_RejectsTampering(t *testing.T) {
+	secret := []byte("secret")
+	signed := SignToken(secret, []byte(`{"uid":1}`))
+	forged := SignToken([]byte("other"), []byte(`{"uid":2}`))
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	_, mac, _ := strings.Cut(signed, ".")
+
+	cases := map[string]string{
+		"wrong secret":    forged,
+		"swapped payload": forgedPayload + "." + mac,
+		"no separator":    "abc",
+	}
+	for name, tok := range cases {
+		if _, err := VerifySignedToken(secret, tok); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature, got %v", name, err)
+		}
+	}
+}
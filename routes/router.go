@@ -1,9 +1,14 @@
 package routes
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
 	v1 "github.com/llamacto/llama-gin-kit/routes/v1"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -36,8 +41,20 @@ type Links struct {
 	Swagger       string `json:"swagger"`
 }
 
-// RegisterRoutes registers all routes
-func RegisterRoutes(r *gin.Engine) {
+// NewEngine creates the Gin engine. Only the configured proxies may set the
+// client IP with X-Forwarded-For, which API key IP allowlists, login
+// lockouts and rate limits rely on.
+func NewEngine(server config.ServerConfig) (*gin.Engine, error) {
+	r := gin.Default()
+	if err := r.SetTrustedProxies(server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return r, nil
+}
+
+// RegisterRoutes registers all routes. Background workers run until ctx is
+// cancelled and are added to workers, which is done once they have stopped.
+func RegisterRoutes(ctx context.Context, r *gin.Engine, workers *sync.WaitGroup) {
 	// Global middleware
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
@@ -60,17 +77,56 @@ func RegisterRoutes(r *gin.Engine) {
 				Endpoints: []string{
 					"POST /v1/register - User registration",
 					"POST /v1/login - User login",
+					"POST /v1/login/mfa - Complete login with two-factor code",
+					"POST /v1/login/passwordless - Email a sign-in link or code",
+					"POST /v1/login/passwordless/verify - Sign in with emailed link or code",
+					"POST /v1/token/refresh - Rotate refresh token",
+					"POST /v1/logout - Revoke refresh token",
+					"POST /v1/password/reset - Request password reset link",
+					"POST /v1/password/reset/confirm - Set new password with reset token",
+					"POST /v1/email/verify - Verify email address",
+					"POST /v1/email/verify/resend - Resend verification email",
+					"GET /v1/auth/oidc/providers - List OpenID Connect providers",
+					"GET /v1/auth/oidc/:provider - Sign in with an OpenID Connect provider",
+					"GET /.well-known/jwks.json - Token verification keys",
 					"GET /v1/users/profile - Get user profile",
+					"POST /v1/users/avatar - Upload avatar image",
+					"PUT /v1/users/username - Change username (old name redirects)",
+					"POST /v1/users/mfa/totp - Enroll authenticator app",
+					"GET /v1/users/sessions - List login sessions",
+					"DELETE /v1/users/sessions - Sign out everywhere",
+					"POST /v1/users/data-exports - Export personal data",
+					"DELETE /v1/users/account - Delete account (erased after grace period)",
+					"GET /v1/admin/users - Search users (admin)",
+					"POST /v1/admin/users/:id/impersonate - Impersonate user (admin, audited)",
 					"POST /v1/organizations - Create organization",
 					"GET /v1/organizations - List organizations",
 					"POST /v1/teams - Create team",
 					"GET /v1/teams/:id - Get team details",
 					"POST /v1/apikeys - Create API key",
 					"GET /v1/apikeys - List API keys",
+					"POST /v1/apikeys/:id/rotate - Rotate API key with a grace window",
+					"PUT /v1/apikeys/:id/restrictions - Restrict API key to IP ranges and origins",
+					"POST /v1/apikeys/leaked - Report and revoke leaked API keys",
+					"POST /v1/organizations/:id/apikeys - Create organization API key",
+					"POST /v1/organizations/:id/service-accounts - Create service account",
+					"PUT /v1/admin/apikeys/:id/rate-limits - Override API key rate limits (admin)",
 				},
 				Features: []string{
 					"JWT Authentication",
+					"Two-Factor Authentication (TOTP)",
+					"OpenID Connect Sign-In",
+					"Passwordless Email Sign-In",
+					"Session Management",
+					"Personal Data Export and Erasure",
 					"API Key Authentication",
+					"Scoped API Key Permissions",
+					"Organization API Keys and Service Accounts",
+					"API Key Rotation",
+					"API Key IP and Origin Restrictions",
+					"API Key Expiry Reminders and Cleanup",
+					"Leaked API Key Detection and Revocation",
+					"Rate Limits and Monthly Quotas",
 					"User Management",
 					"Organization Management",
 					"Team Management",
@@ -95,6 +151,17 @@ func RegisterRoutes(r *gin.Engine) {
 		})
 	})
 
+	// Public keys for verifying access tokens (empty for HS256)
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		svc, err := jwt.ServiceInstance()
+		if err != nil {
+			c.JSON(503, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, svc.JWKS())
+	})
+
 	// Legacy ping endpoint
 	r.GET("/ping", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -104,7 +171,7 @@ func RegisterRoutes(r *gin.Engine) {
 
 	// API v1 routes
 	v1Group := r.Group("/v1")
-	v1.RegisterRoutes(r, v1Group)
+	v1.RegisterRoutes(ctx, r, v1Group, workers)
 
 	// API v2 routes will be added when needed
 	// v2Group := r.Group("/v2")
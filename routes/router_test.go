@@ -0,0 +1,95 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/app/token"
+	"github.com/llamacto/llama-gin-kit/config"
+	"github.com/llamacto/llama-gin-kit/middleware"
+)
+
+// restrictedKeys validates every key as one restricted to allowedIP
+type restrictedKeys struct {
+	apikey.Service
+}
+
+const allowedIP = "203.0.113.7"
+
+func (restrictedKeys) ValidateAPIKey(string) (*apikey.APIKey, error) {
+	return &apikey.APIKey{UserID: 1, AllowedIPs: []string{allowedIP + "/32"}}, nil
+}
+
+func (restrictedKeys) ResolvePermissions(context.Context, *apikey.APIKey) ([]string, error) {
+	return []string{"*"}, nil
+}
+
+func requestWithForwardedFor(t *testing.T, server config.ServerConfig, remoteAddr string) int {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r, err := NewEngine(server)
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	r.GET("/", middleware.APIKeyAuth(restrictedKeys{}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("X-API-Key", "llk_test")
+	req.Header.Set("X-Forwarded-For", allowedIP)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestNewEngine_IgnoresSpoofedForwardedFor(t *testing.T) {
+	if code := requestWithForwardedFor(t, config.ServerConfig{}, "198.51.100.9:4321"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for a spoofed X-Forwarded-For, got %d", code)
+	}
+}
+
+func TestNewEngine_TrustsConfiguredProxies(t *testing.T) {
+	server := config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}}
+	if code := requestWithForwardedFor(t, server, "10.1.2.3:4321"); code != http.StatusOK {
+		t.Errorf("Expected 200 through a trusted proxy, got %d", code)
+	}
+	if code := requestWithForwardedFor(t, server, "198.51.100.9:4321"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 from an untrusted address, got %d", code)
+	}
+}
+
+func TestNewEngine_RejectsInvalidProxies(t *testing.T) {
+	if _, err := NewEngine(config.ServerConfig{TrustedProxies: []string{"not-an-ip"}}); err == nil {
+		t.Error("Expected an error for an invalid proxy")
+	}
+}
+
+func TestNewEngine_LockoutIPIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, err := NewEngine(config.ServerConfig{})
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	var client token.ClientInfo
+	r.POST("/login", func(c *gin.Context) {
+		client = token.ClientFromContext(c)
+	})
+
+	// A new X-Forwarded-For on every attempt must not give a new lockout subject
+	for _, spoofed := range []string{"192.0.2.1", "192.0.2.2"} {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = "198.51.100.9:4321"
+		req.Header.Set("X-Forwarded-For", spoofed)
+		req.Header.Set("X-Real-IP", spoofed)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if client.IP != "198.51.100.9" {
+			t.Errorf("Expected the connection address, got %q", client.IP)
+		}
+	}
+}
@@ -7,18 +7,44 @@ import (
 )
 
 // RegisterAPIKeyRoutes registers routes related to API key management
-func RegisterAPIKeyRoutes(v1 *gin.RouterGroup, apiKeyService apikey.Service) {
+func RegisterAPIKeyRoutes(v1 *gin.RouterGroup, apiKeyService apikey.Service, requireVerifiedEmail, requireAdmin, rateLimit gin.HandlerFunc) {
 	// Create API key handler
 	handler := apikey.NewAPIKeyHandler(apiKeyService)
 
 	// API key management routes (needs JWT authentication)
 	apikeyGroup := v1.Group("/apikeys")
-	apikeyGroup.Use(middleware.JWTAuth())
+	apikeyGroup.Use(middleware.JWTAuth(), rateLimit, requireVerifiedEmail)
 	{
 		apikeyGroup.POST("", handler.Create)
 		apikeyGroup.GET("", handler.List)
 		apikeyGroup.GET("/:id", handler.Get)
 		apikeyGroup.PUT("/:id", handler.Update)
 		apikeyGroup.DELETE("/:id", handler.Delete)
+		apikeyGroup.POST("/:id/rotate", handler.Rotate)
+		apikeyGroup.PUT("/:id/restrictions", handler.SetRestrictions)
+	}
+
+	// Leaked key reports from secret scanners. Public: anyone holding a
+	// key may revoke it.
+	v1.POST("/apikeys/leaked", rateLimit, handler.ReportLeaked)
+
+	// Organization-owned keys and service accounts, managed by the
+	// organization's owners and admins
+	orgGroup := v1.Group("/organizations/:id")
+	orgGroup.Use(middleware.JWTAuth(), rateLimit, requireVerifiedEmail)
+	{
+		orgGroup.POST("/apikeys", handler.CreateOrganizationKey)
+		orgGroup.GET("/apikeys", handler.ListOrganizationKeys)
+		orgGroup.DELETE("/apikeys/:key_id", handler.DeleteOrganizationKey)
+		orgGroup.POST("/service-accounts", handler.CreateServiceAccount)
+		orgGroup.GET("/service-accounts", handler.ListServiceAccounts)
+		orgGroup.DELETE("/service-accounts/:account_id", handler.DeleteServiceAccount)
+	}
+
+	// Per-key rate limit overrides, managed by system administrators
+	adminGroup := v1.Group("/admin/apikeys")
+	adminGroup.Use(middleware.JWTAuth(), rateLimit, requireAdmin)
+	{
+		adminGroup.PUT("/:id/rate-limits", handler.SetRateLimits)
 	}
 }
@@ -5,20 +5,26 @@ import (
 	"github.com/llamacto/llama-gin-kit/app/apikey"
 	"github.com/llamacto/llama-gin-kit/app/organization"
 	apikeyMiddleware "github.com/llamacto/llama-gin-kit/middleware"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
 )
 
 // RegisterOrganizationRoutes registers organization routes
-func RegisterOrganizationRoutes(router *gin.RouterGroup, handler *organization.Handler, apiKeyService apikey.Service) {
+func RegisterOrganizationRoutes(router *gin.RouterGroup, handler *organization.Handler, apiKeyService apikey.Service, requireVerifiedEmail, rateLimit gin.HandlerFunc) {
+	// Scopes API keys may be granted for these routes
+	scope.Register("organizations", "read", "write", "delete")
+
 	// Routes that require authentication
 	authRouter := router.Group("")
-	authRouter.Use(apikeyMiddleware.CombinedAuth(apiKeyService))
+	authRouter.Use(apikeyMiddleware.CombinedAuth(apiKeyService), rateLimit)
 
 	// Organization endpoints - only core organization functionality
 	orgRouter := authRouter.Group("/organizations")
-	orgRouter.POST("", handler.CreateOrganization)
-	orgRouter.GET("", handler.ListOrganizations)
-	orgRouter.GET("/me", handler.GetMyOrganizations)
-	orgRouter.GET("/:id", handler.GetOrganization)
-	orgRouter.PUT("/:id", handler.UpdateOrganization)
-	orgRouter.DELETE("/:id", handler.DeleteOrganization)
+	// API keys are limited to the scopes they were granted; {id} scopes the
+	// request to the organization in the path
+	orgRouter.POST("", requireVerifiedEmail, apikeyMiddleware.RequireScopes("organizations:write"), handler.CreateOrganization)
+	orgRouter.GET("", apikeyMiddleware.RequireScopes("organizations:read"), handler.ListOrganizations)
+	orgRouter.GET("/me", apikeyMiddleware.RequireScopes("organizations:read"), handler.GetMyOrganizations)
+	orgRouter.GET("/:id", apikeyMiddleware.RequireScopes("org:{id}/organizations:{id}:read"), handler.GetOrganization)
+	orgRouter.PUT("/:id", apikeyMiddleware.RequireScopes("org:{id}/organizations:{id}:write"), handler.UpdateOrganization)
+	orgRouter.DELETE("/:id", apikeyMiddleware.RequireScopes("org:{id}/organizations:{id}:delete"), handler.DeleteOrganization)
 }
@@ -1,21 +1,35 @@
 package v1
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/llamacto/llama-gin-kit/app/apikey"
+	"github.com/llamacto/llama-gin-kit/app/authorization"
+	"github.com/llamacto/llama-gin-kit/app/identity"
+	"github.com/llamacto/llama-gin-kit/app/mfa"
 	"github.com/llamacto/llama-gin-kit/app/organization"
+	"github.com/llamacto/llama-gin-kit/app/privacy"
+	"github.com/llamacto/llama-gin-kit/app/token"
 	"github.com/llamacto/llama-gin-kit/app/user"
 	"github.com/llamacto/llama-gin-kit/config"
 	"github.com/llamacto/llama-gin-kit/middleware"
+	"github.com/llamacto/llama-gin-kit/pkg/auth"
 	"github.com/llamacto/llama-gin-kit/pkg/database"
+	"github.com/llamacto/llama-gin-kit/pkg/hasher"
+	"github.com/llamacto/llama-gin-kit/pkg/jwt"
 	pkgmiddleware "github.com/llamacto/llama-gin-kit/pkg/middleware"
+	"github.com/llamacto/llama-gin-kit/pkg/ratelimit"
+	"github.com/llamacto/llama-gin-kit/pkg/scope"
+	"github.com/llamacto/llama-gin-kit/pkg/storage"
 )
 
-// RegisterRoutes registers all v1 version routes
-func RegisterRoutes(engine *gin.Engine, v1 *gin.RouterGroup) {
+// RegisterRoutes registers all v1 version routes. Background workers run
+// until ctx is cancelled and are added to workers.
+func RegisterRoutes(ctx context.Context, engine *gin.Engine, v1 *gin.RouterGroup, workers *sync.WaitGroup) {
 	// Register health check routes
 	RegisterHealthRoutes(v1)
 
@@ -31,36 +45,145 @@ func RegisterRoutes(engine *gin.Engine, v1 *gin.RouterGroup) {
 
 	// Initialize user module
 	userRepo := user.NewUserRepository(db)
-	userService := user.NewUserService(userRepo)
+	tokenRepo := token.NewRepository(db)
+	tokenService := token.NewService(tokenRepo, jwt.MustServiceInstance(), user.NewSubjectLoader(userRepo))
+	tokenHandler := token.NewHandler(tokenService)
+	mfaService := mfa.NewService(mfa.NewRepository(db), mfa.Options{
+		Issuer:        config.GlobalConfig.App.Name,
+		SigningSecret: config.GlobalConfig.Auth.SigningSecret,
+		ChallengeTTL:  config.GlobalConfig.Auth.MFAChallengeExpireDuration,
+	})
+	mfaHandler := mfa.NewHandler(mfaService)
+	loginGuard := user.NewLoginGuard(db, config.GlobalConfig.Auth)
+	identityService := identity.NewService(identity.NewRepository(db), identity.ProvidersFromConfig(config.GlobalConfig.OIDC), identity.Options{
+		SigningSecret: config.GlobalConfig.Auth.SigningSecret,
+		StateTTL:      config.GlobalConfig.OIDC.StateExpireDuration,
+		BaseURL:       config.GlobalConfig.OIDC.RedirectBaseURL,
+	})
+	identityHandler := identity.NewHandler(identityService)
+
+	// Rate limits and monthly quotas, counted against the authenticated
+	// principal, so the middleware runs after authentication
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.GlobalConfig.RateLimit.Store == config.RateLimitStoreDatabase {
+		rateLimitStore = apikey.NewRateLimitStore(db)
+	}
+	limiter, err := ratelimit.NewLimiter(rateLimitStore, ratelimit.Algorithm(config.GlobalConfig.RateLimit.Algorithm))
+	if err != nil {
+		log.Fatalf("Failed to configure rate limiting: %v", err)
+	}
+	rateLimit := middleware.RateLimit(limiter, config.GlobalConfig.RateLimit)
+
+	secretHasher, err := hasher.New(config.GlobalConfig.Password)
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
+	passwordValidator, err := user.NewPasswordValidator(config.GlobalConfig.Password)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+	// Avatar uploads need object storage; without it only avatar URLs can be set
+	var avatarStore storage.Store
+	if r2 := storage.GetR2Storage(); r2 != nil {
+		avatarStore = r2
+	}
+	avatarUploader := user.NewAvatarUploader(avatarStore, config.GlobalConfig.Avatar)
+	roleRepo := authorization.NewRepository(db)
+	userService := user.NewUserService(userRepo, tokenService, mfaService, identityService, loginGuard, secretHasher, passwordValidator, avatarUploader, roleRepo, config.GlobalConfig.Auth, config.GlobalConfig.Username, config.GlobalConfig.OIDC)
 	userHandler := user.NewUserHandler(userService)
+	privacyService := privacy.NewService(privacy.NewRepository(db), userService, privacy.Options{
+		ErasureGracePeriod: config.GlobalConfig.Privacy.ErasureGraceDuration,
+		ExportTTL:          config.GlobalConfig.Privacy.ExportExpireDuration,
+	})
+	track(workers, privacyService.Start(ctx))
+	privacyHandler := privacy.NewHandler(privacyService)
+	requireAdmin := middleware.RequireSystemRole(roleRepo, authorization.RoleAdmin)
+	denyImpersonation := middleware.DenyImpersonation()
+	requireVerifiedEmail := middleware.RequireVerifiedEmail(userService, config.GlobalConfig.Auth.EmailVerificationPolicy)
 
 	// Register user routes
-	// Public auth routes
-	v1.POST("/register", userHandler.Register)
-	v1.POST("/login", userHandler.Login)
-	v1.POST("/password/reset", userHandler.ResetPassword)
+	// Public auth routes, rate limited per client IP
+	public := v1.Group("")
+	public.Use(rateLimit)
+	public.POST("/register", userHandler.Register)
+	public.POST("/login", userHandler.Login)
+	public.POST("/login/mfa", userHandler.LoginMFA)
+	public.POST("/login/passwordless", userHandler.RequestPasswordlessLogin)
+	public.POST("/login/passwordless/verify", userHandler.PasswordlessLogin)
+	public.POST("/token/refresh", tokenHandler.Refresh)
+	public.POST("/logout", tokenHandler.Logout)
+	public.POST("/password/reset", userHandler.ResetPassword)
+	public.POST("/password/reset/confirm", userHandler.ConfirmPasswordReset)
+	public.POST("/email/verify", userHandler.VerifyEmail)
+	public.POST("/email/verify/resend", userHandler.ResendVerification)
+
+	// External identity providers (OpenID Connect)
+	public.GET("/auth/oidc/providers", identityHandler.Providers)
+	public.GET("/auth/oidc/:provider", userHandler.OIDCLogin)
+	public.GET("/auth/oidc/:provider/callback", userHandler.OIDCCallback)
 
 	// Protected user routes
 	userGroup := v1.Group("/users")
-	userGroup.Use(pkgmiddleware.JWTAuth())
+	userGroup.Use(pkgmiddleware.JWTAuth(), rateLimit)
 	{
 		userGroup.GET("/profile", userHandler.GetProfile)
 		userGroup.PUT("/profile", userHandler.UpdateProfile)
-		userGroup.PUT("/password", userHandler.ChangePassword)
-		userGroup.DELETE("/account", userHandler.DeleteAccount)
+		userGroup.PUT("/username", denyImpersonation, userHandler.ChangeUsername)
+		userGroup.GET("/by-username/:username", userHandler.GetByUsername)
+		userGroup.POST("/avatar", userHandler.UploadAvatar)
+		userGroup.DELETE("/avatar", userHandler.DeleteAvatar)
+		userGroup.PUT("/password", denyImpersonation, userHandler.ChangePassword)
+		userGroup.DELETE("/account", denyImpersonation, privacyHandler.DeleteAccount)
+
+		// Personal data export
+		userGroup.POST("/data-exports", denyImpersonation, privacyHandler.RequestExport)
+		userGroup.GET("/data-exports", privacyHandler.ListExports)
+		userGroup.GET("/data-exports/:id", privacyHandler.GetExport)
+		userGroup.GET("/data-exports/:id/download", denyImpersonation, privacyHandler.DownloadExport)
+
+		// Two-factor authentication
+		userGroup.GET("/mfa", mfaHandler.Status)
+		userGroup.POST("/mfa/totp", denyImpersonation, mfaHandler.Enroll)
+		userGroup.POST("/mfa/totp/confirm", denyImpersonation, mfaHandler.Confirm)
+		userGroup.POST("/mfa/totp/disable", denyImpersonation, mfaHandler.Disable)
+		userGroup.POST("/mfa/recovery-codes", denyImpersonation, mfaHandler.RegenerateRecoveryCodes)
 
-		// Admin routes
-		userGroup.GET("", userHandler.List)
-		userGroup.GET("/:id", userHandler.Get)
-		userGroup.GET("/:id/info", userHandler.GetUserInfo)
+		// Linked external accounts
+		userGroup.GET("/identities", identityHandler.List)
+		userGroup.DELETE("/identities/:id", denyImpersonation, identityHandler.Unlink)
+
+		// Login sessions
+		userGroup.GET("/sessions", tokenHandler.ListSessions)
+		userGroup.DELETE("/sessions", tokenHandler.RevokeAllSessions)
+		userGroup.DELETE("/sessions/:id", tokenHandler.RevokeSession)
+	}
+
+	// System administrator routes
+	adminGroup := v1.Group("/admin")
+	adminGroup.Use(pkgmiddleware.JWTAuth(), rateLimit, requireAdmin)
+	{
+		adminGroup.GET("/users", userHandler.List)
+		adminGroup.GET("/users/:id", userHandler.Get)
+		adminGroup.DELETE("/users/:id", userHandler.HardDelete)
+		adminGroup.POST("/users/:id/disable", userHandler.Disable)
+		adminGroup.POST("/users/:id/enable", userHandler.Enable)
+		adminGroup.POST("/users/:id/password-reset", userHandler.ForcePasswordReset)
+		adminGroup.POST("/users/:id/impersonate", userHandler.Impersonate)
+		adminGroup.GET("/audit-logs", userHandler.ListAuditLogs)
+		adminGroup.DELETE("/users/:id/mfa", mfaHandler.ForceDisable)
+		adminGroup.DELETE("/users/:id/lockout", userHandler.UnlockAccount)
+		adminGroup.DELETE("/lockouts/ips/:ip", userHandler.UnlockIP)
+		adminGroup.GET("/erasures", privacyHandler.ListErasures)
+		adminGroup.POST("/users/:id/restore", privacyHandler.RestoreAccount)
 	}
 
 	// Initialize API key module
 	apiKeyRepo := apikey.NewAPIKeyRepository(db)
-	apiKeyService := apikey.NewAPIKeyService(apiKeyRepo)
+	apiKeyService := apikey.NewAPIKeyService(apiKeyRepo, secretHasher, scope.Default, roleRepo, config.GlobalConfig.APIKey)
+	track(workers, apiKeyService.Start(ctx))
 
 	// Register API key routes
-	RegisterAPIKeyRoutes(v1, apiKeyService)
+	RegisterAPIKeyRoutes(v1, apiKeyService, requireVerifiedEmail, requireAdmin, rateLimit)
 
 	// Initialize organization module
 	orgRepo := organization.NewRepository(db)
@@ -68,23 +191,33 @@ func RegisterRoutes(engine *gin.Engine, v1 *gin.RouterGroup) {
 	orgHandler := organization.NewHandler(orgService)
 
 	// Register organization routes
-	RegisterOrganizationRoutes(v1, orgHandler, apiKeyService)
+	RegisterOrganizationRoutes(v1, orgHandler, apiKeyService, requireVerifiedEmail, rateLimit)
 
 	// Register team routes
-	TeamRoutes(v1)
+	TeamRoutes(v1, rateLimit)
 
 	// Example of a route that accepts either JWT or API key authentication
 	// 使用CombinedAuth中间件，支持JWT和API key双重认证
 	combinedAuthMiddleware := middleware.CombinedAuth(apiKeyService)
-	v1.GET("/protected", combinedAuthMiddleware, func(c *gin.Context) {
-		// 获取认证类型
-		authType := c.GetString("authType")
-		userID := c.GetUint("userID")
+	v1.GET("/protected", combinedAuthMiddleware, rateLimit, func(c *gin.Context) {
+		// 获取认证主体
+		principal, _ := auth.FromContext(c)
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "认证成功",
-			"auth_type": authType,
-			"user_id":   userID,
+			"auth_type": principal.Method,
+			"user_id":   principal.UserID,
+			// 组织API key的所属组织
+			"organization_id": principal.OrganizationID,
 		})
 	})
 }
+
+// track adds a background worker to workers until it closes done
+func track(workers *sync.WaitGroup, done <-chan struct{}) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		<-done
+	}()
+}
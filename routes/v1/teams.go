@@ -8,7 +8,7 @@ import (
 )
 
 // TeamRoutes sets up team-related routes
-func TeamRoutes(router *gin.RouterGroup) {
+func TeamRoutes(router *gin.RouterGroup, rateLimit gin.HandlerFunc) {
 	// Initialize team dependencies
 	teamRepo := team.NewRepository(database.DB)
 	teamService := team.NewService(teamRepo)
@@ -16,7 +16,7 @@ func TeamRoutes(router *gin.RouterGroup) {
 
 	// Team routes group
 	teams := router.Group("/teams")
-	teams.Use(pkgmiddleware.JWTAuth()) // Require authentication for all team operations
+	teams.Use(pkgmiddleware.JWTAuth(), rateLimit) // Require authentication for all team operations
 	{
 		teams.POST("", teamHandler.CreateTeam)                    // Create team
 		teams.GET("/:id", teamHandler.GetTeam)                    // Get team by ID
@@ -27,7 +27,7 @@ func TeamRoutes(router *gin.RouterGroup) {
 
 	// Organization-specific team routes - moved to avoid route conflicts
 	orgTeams := router.Group("/org-teams")
-	orgTeams.Use(pkgmiddleware.JWTAuth())
+	orgTeams.Use(pkgmiddleware.JWTAuth(), rateLimit)
 	{
 		orgTeams.GET("/:organization_id", teamHandler.GetTeamsByOrganization) // Get organization teams
 	}